@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log"
+
+	"sso-web-app/configs"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/services"
+)
+
+// rotate-signing-key replaces the active RS256 signing key. Running servers start
+// signing with the new key within a minute and keep accepting tokens signed with
+// the old one for JWT_KEY_GRACE_PERIOD.
+func main() {
+	// Load configuration
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		log.Fatal("Configuration error: ", err)
+	}
+	if cfg.JWTSigningMethod != "RS256" {
+		log.Fatal("JWT_SIGNING_METHOD must be RS256 to rotate signing keys")
+	}
+
+	// Initialize repository
+	if err := repository.InitDB(cfg); err != nil {
+		log.Fatal(err)
+	}
+	if err := repository.Migrate(); err != nil {
+		log.Fatal(err)
+	}
+
+	signingKeys, err := services.NewSigningKeyService(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	key, err := signingKeys.Rotate()
+	if err != nil {
+		log.Fatal("Failed to rotate signing key: ", err)
+	}
+	log.Printf("Active signing key is now %s", key.KID)
+}
@@ -4,12 +4,25 @@ import (
 	"log"
 
 	"golang.org/x/crypto/bcrypt"
+	"sso-web-app/configs"
 	"sso-web-app/internal/models"
 	"sso-web-app/internal/repository"
 )
 
 func main() {
+	// Load configuration
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		log.Fatal("Configuration error: ", err)
+	}
+
 	// Initialize repository
+	if err := repository.InitDB(cfg); err != nil {
+		log.Fatal(err)
+	}
+	if err := repository.Migrate(); err != nil {
+		log.Fatal(err)
+	}
 	userRepo := repository.NewUserRepository()
 	
 	// Check if admin user already exists
@@ -39,8 +52,8 @@ func main() {
 		IsVerified:   true,
 		IsAdmin:      true,
 		Role:         "admin",
-		Bio:          "System administrator account for managing the SSO application",
-		Location:     "System",
+		Bio:          stringPtr("System administrator account for managing the SSO application"),
+		Location:     stringPtr("System"),
 	}
 	
 	// Save to database
@@ -64,8 +77,8 @@ func main() {
 			IsActive:   true,
 			IsVerified: true,
 			Role:       "user",
-			Bio:        "Regular user account for testing",
-			Location:   "New York, USA",
+			Bio:        stringPtr("Regular user account for testing"),
+			Location:   stringPtr("New York, USA"),
 		},
 		{
 			FirstName:  "Jane",
@@ -75,8 +88,8 @@ func main() {
 			IsActive:   true,
 			IsVerified: false,
 			Role:       "moderator",
-			Bio:        "Moderator account for testing",
-			Location:   "Los Angeles, USA",
+			Bio:        stringPtr("Moderator account for testing"),
+			Location:   stringPtr("Los Angeles, USA"),
 		},
 		{
 			FirstName:  "Bob",
@@ -86,8 +99,8 @@ func main() {
 			IsActive:   false,
 			IsVerified: true,
 			Role:       "user",
-			Bio:        "Inactive user account for testing",
-			Location:   "Chicago, USA",
+			Bio:        stringPtr("Inactive user account for testing"),
+			Location:   stringPtr("Chicago, USA"),
 		},
 	}
 	
@@ -109,3 +122,8 @@ func main() {
 	
 	log.Println("Database seeding completed!")
 }
+
+// stringPtr returns a pointer to the given string
+func stringPtr(s string) *string {
+	return &s
+}
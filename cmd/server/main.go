@@ -1,33 +1,88 @@
 package main
 
 import (
+	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"sso-web-app/configs"
 	"sso-web-app/internal/handlers"
 	"sso-web-app/internal/middleware"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
 	"sso-web-app/internal/services"
+	"sso-web-app/internal/timeutil"
 )
 
 func main() {
 	// Load configuration
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		os.Exit(1)
 	}
 
-	// Initialize services
-	authService := services.NewAuthService()
-	oauthService := services.NewOAuthService()
+	models.ConfigureGravatar(cfg.UseGravatar, cfg.GravatarDefault)
+	models.ConfigureLogRedaction(cfg.LogMaskEmails)
+	if err := models.ConfigureDirectory(strings.Split(cfg.DirectoryVisibleFields, ",")); err != nil {
+		log.Fatalf("Invalid DIRECTORY_VISIBLE_FIELDS: %v", err)
+	}
+	if err := timeutil.SetLocation(cfg.AppTimezone); err != nil {
+		log.Fatalf("Invalid timezone: %v", err)
+	}
+
+	// Initialize database
+	if err := repository.InitDB(cfg); err != nil {
+		log.Fatal(err)
+	}
+	if err := repository.Migrate(); err != nil {
+		log.Fatal(err)
+	}
 
-	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authService, oauthService)
-	adminHandler := handlers.NewAdminHandler()
+	// Initialize services
+	mailer := services.NewMailer(cfg)
+	alertService := services.NewAlertService(cfg, mailer)
+	auditService := services.NewAuditService(cfg, alertService)
+	signingKeyService, err := services.NewSigningKeyService(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	authService := services.NewAuthService(cfg, mailer, services.NewSMSSender(cfg), signingKeyService, auditService)
+	oauthService := services.NewOAuthService(cfg, authService)
+	adminService := services.NewAdminService(cfg, authService, oauthService)
+	roleService := services.NewRoleService()
+	apiKeyService := services.NewAPIKeyService()
+	directoryService := services.NewDirectoryService(cfg)
+	deviceService := services.NewDeviceService(cfg, mailer)
+	sessionService := services.NewSessionService(cfg, authService)
+	services.NewRetentionService(cfg)
 
 	// Setup Gin router
 	router := gin.Default()
+	if proxies := cfg.TrustedProxyList(); proxies != nil {
+		if err := router.SetTrustedProxies(proxies); err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+	}
+	if cfg.ForceHTTPS {
+		forceHTTPS, err := middleware.ForceHTTPS(cfg.HSTSMaxAge, cfg.TrustedProxyList(), "/healthz")
+		if err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+		router.Use(forceHTTPS)
+	}
+	if cfg.MaxConcurrentRequests > 0 {
+		router.Use(middleware.ConcurrencyLimit(cfg.MaxConcurrentRequests, cfg.RequestQueueDepth, cfg.RequestQueueTimeout, "/healthz"))
+	}
+
+	// Template helpers must be registered before the templates are parsed
+	router.SetFuncMap(template.FuncMap{
+		"localTime": timeutil.FormatLocal,
+	})
 
 	// Load HTML templates from templates directory
 	router.LoadHTMLGlob("templates/*.html")
@@ -35,61 +90,22 @@ func main() {
 	// Serve static files
 	router.Static("/static", "./static")
 
-	// Public routes
-	public := router.Group("/")
-	{
-		public.GET("/", authHandler.Home)
-		public.GET("/login", authHandler.LoginPage)
-		public.POST("/login", authHandler.Login)
-		public.GET("/register", authHandler.RegisterPage)
-		public.POST("/register", authHandler.Register)
-		public.GET("/logout", authHandler.Logout)
-		
-		// OAuth routes
-		public.GET("/auth/google", authHandler.GoogleLogin)
-		public.GET("/auth/google/callback", authHandler.GoogleCallback)
-		public.GET("/auth/github", authHandler.GitHubLogin)
-		public.GET("/auth/github/callback", authHandler.GitHubCallback)
-	}
-
-	// Protected routes
-	protected := router.Group("/")
-	protected.Use(middleware.AuthMiddleware())
-	{
-		protected.GET("/dashboard", authHandler.Dashboard)
-		protected.GET("/profile", authHandler.Profile)
-		protected.POST("/profile", authHandler.UpdateProfile)
-	}
-
-	// API routes
-	api := router.Group("/api/v1")
-	api.Use(middleware.AuthMiddleware())
-	{
-		api.GET("/user", authHandler.GetUser)
-		api.PUT("/user", authHandler.UpdateUser)
-	}
-
-	// Admin routes
-	admin := router.Group("/admin")
-	admin.Use(middleware.AuthMiddleware(), middleware.AdminRequired())
-	{
-		admin.GET("/dashboard", adminHandler.Dashboard)
-		admin.GET("/users", adminHandler.UsersList)
-		admin.GET("/users/:id", adminHandler.UserDetail)
-	}
-
-	// Admin API routes
-	adminAPI := router.Group("/admin/api")
-	adminAPI.Use(middleware.AuthMiddleware(), middleware.AdminAPIRequired())
-	{
-		adminAPI.PUT("/users/:id", adminHandler.UpdateUser)
-		adminAPI.POST("/users/:id/activate", adminHandler.ActivateUser)
-		adminAPI.POST("/users/:id/deactivate", adminHandler.DeactivateUser)
-		adminAPI.DELETE("/users/:id", adminHandler.DeleteUser)
-		adminAPI.POST("/users/:id/promote", adminHandler.PromoteToAdmin)
-		adminAPI.POST("/users/:id/demote", adminHandler.DemoteFromAdmin)
+	// Application routes, each with the authentication and authorization it needs
+	if err := handlers.RegisterRoutes(router, cfg, handlers.Services{
+		Auth:        authService,
+		OAuth:       oauthService,
+		Admin:       adminService,
+		Roles:       roleService,
+		APIKeys:     apiKeyService,
+		Directory:   directoryService,
+		Devices:     deviceService,
+		Sessions:    sessionService,
+		Audit:       auditService,
+		SigningKeys: signingKeyService,
+	}); err != nil {
+		log.Fatal(err)
 	}
 
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	log.Printf("Server starting on port %s", cfg.Port)
+	log.Fatal(http.ListenAndServe(":"+cfg.Port, router))
 }
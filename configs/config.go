@@ -1,42 +1,296 @@
-package main
+package configs
 
 import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+// cookieDomainPattern matches a domain with at least two labels and an optional leading dot
+var cookieDomainPattern = regexp.MustCompile(`^\.?([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,63}$`)
+
+// phoneCountryCodePattern matches an international calling code without the +
+var phoneCountryCodePattern = regexp.MustCompile(`^[1-9][0-9]{0,2}$`)
+
 // Config holds all configuration for the application
 type Config struct {
-	Port         string
-	DatabaseURL  string
-	JWTSecret    string
-	
+	AppEnv        string `yaml:"app_env"`
+	Port          string `yaml:"port"`
+	DatabaseURL   string `yaml:"database_url"`
+	DBLogLevel    string `yaml:"db_log_level"`
+	DBSlowQueryMS int    `yaml:"db_slow_query_ms"`
+	JWTSecret     string `yaml:"jwt_secret"`
+	AppTimezone   string `yaml:"app_timezone"`
+	AppBaseURL    string `yaml:"app_base_url"` // Public URL used for links in emails
+
+	// Times a statement or transaction that fails because the database is busy is
+	// tried again, waiting DBRetryBackoff before the first retry and twice as long
+	// before each one after. 0 disables retries.
+	DBRetryAttempts int           `yaml:"db_retry_attempts"`
+	DBRetryBackoff  time.Duration `yaml:"db_retry_backoff"`
+
+	// Session token signing. HS256 uses JWTSecret; RS256 uses RSA keys stored in the
+	// database and published at /.well-known/jwks.json. A key replaced by rotation
+	// stays published for JWTKeyGracePeriod so the tokens it signed still verify.
+	JWTSigningMethod  string        `yaml:"jwt_signing_method"`
+	JWTKeyGracePeriod time.Duration `yaml:"jwt_key_grace_period"`
+
+	// Session Configuration
+	ReauthWindow         time.Duration `yaml:"reauth_window"`
+	CookieDomain         string        `yaml:"cookie_domain"`          // Empty keeps the session cookie host-only
+	CookieSecure         bool          `yaml:"cookie_secure"`          // Send cookies over HTTPS only; required in production
+	ReturnTokenInBody    bool          `yaml:"return_token_in_body"`   // Also return session tokens in JSON, for API clients
+	MaxSessionsPerUser   int           `yaml:"max_sessions_per_user"`  // 0 means unlimited
+	SessionLimitStrategy string        `yaml:"session_limit_strategy"` // evict_oldest or reject_new
+
+	// Sliding sessions: a cookie session within the refresh window of expiry gets a
+	// fresh token, up to the max lifetime after sign-in. A zero window disables it.
+	SessionRefreshWindow time.Duration `yaml:"session_refresh_window"`
+	SessionMaxLifetime   time.Duration `yaml:"session_max_lifetime"`
+
+	// Session token lifetime by role, so admins can be signed out sooner than regular
+	// users. 0 uses the default of 7 days.
+	SessionTTLUser      time.Duration `yaml:"session_ttl_user"`
+	SessionTTLModerator time.Duration `yaml:"session_ttl_moderator"`
+	SessionTTLAdmin     time.Duration `yaml:"session_ttl_admin"`
+
+	// Refresh tokens are bound to the device they were issued to. strict matches the
+	// user agent and device ID; lenient matches the device ID only, for mobile
+	// clients whose user agent changes between app updates.
+	RefreshTokenBinding string `yaml:"refresh_token_binding"`
+
+	// Sign-in throttling: failed attempts allowed per window, per account and per
+	// client IP. 0 disables either limit.
+	LoginThrottleAccountAttempts int           `yaml:"login_throttle_account_attempts"`
+	LoginThrottleIPAttempts      int           `yaml:"login_throttle_ip_attempts"`
+	LoginThrottleWindow          time.Duration `yaml:"login_throttle_window"`
+
+	// Wrong passwords a signed-in user may submit to re-auth or password checks per
+	// login throttle window. 0 disables the limit.
+	PasswordCheckAttempts int `yaml:"password_check_attempts"`
+
+	// Password strength checks a client IP may make per minute. 0 disables the limit.
+	PasswordStrengthRateLimit int `yaml:"password_strength_rate_limit"`
+
+	// Password Policy
+	PasswordMaxAgeDays int    `yaml:"password_max_age_days"` // 0 disables password expiration
+	PasswordPepper     string `yaml:"password_pepper"`       // Secret mixed into passwords before hashing
+
+	// Reject new passwords found in the Have I Been Pwned breach corpus. Only a
+	// 5-character hash prefix leaves the server; the check allows the password
+	// if the service is unreachable.
+	CheckBreachedPasswords bool `yaml:"check_breached_passwords"`
+
+	// Reject sign-ups and new addresses at disposable email providers, including
+	// their subdomains. The built-in domain list is replaced by the file at
+	// DisposableEmailDomainsFile (one domain per line) when set.
+	BlockDisposableEmails      bool   `yaml:"block_disposable_emails"`
+	DisposableEmailDomainsFile string `yaml:"disposable_email_domains_file"`
+
 	// OAuth Configuration
-	GoogleClientID     string
-	GoogleClientSecret string
-	GoogleRedirectURL  string
-	
-	GitHubClientID     string
-	GitHubClientSecret string
-	GitHubRedirectURL  string
-}
-
-// LoadConfig loads configuration from environment variables
-func LoadConfig() *Config {
-	config := &Config{
-		Port:        getEnv("PORT", "8080"),
-		DatabaseURL: getEnv("DATABASE_URL", "sso_app.db"),
-		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key-change-this-in-production"),
-		
-		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
-		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
-		GoogleRedirectURL:  getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/auth/google/callback"),
-		
-		GitHubClientID:     getEnv("GITHUB_CLIENT_ID", ""),
-		GitHubClientSecret: getEnv("GITHUB_CLIENT_SECRET", ""),
-		GitHubRedirectURL:  getEnv("GITHUB_REDIRECT_URL", "http://localhost:8080/auth/github/callback"),
-	}
-	
+	GoogleClientID       string `yaml:"google_client_id"`
+	GoogleClientSecret   string `yaml:"google_client_secret"`
+	GoogleRedirectURL    string `yaml:"google_redirect_url"`
+	GoogleAllowedDomains string `yaml:"google_allowed_domains"`
+
+	GitHubClientID       string `yaml:"github_client_id"`
+	GitHubClientSecret   string `yaml:"github_client_secret"`
+	GitHubRedirectURL    string `yaml:"github_redirect_url"`
+	GitHubAllowedDomains string `yaml:"github_allowed_domains"`
+
+	// Comma-separated hosts the OAuth redirect URLs may point at; empty allows any host
+	OAuthAllowedRedirectHosts string `yaml:"oauth_allowed_redirect_hosts"`
+
+	// What to do when a new OAuth user's provider shares no email: reject or prompt
+	OAuthMissingEmail string `yaml:"oauth_missing_email"`
+
+	// Link a provider to the existing account with the same email only when that
+	// email is verified or the account is signed in; otherwise mail a confirmation link
+	RequireLinkConfirmation bool `yaml:"require_link_confirmation"`
+
+	// Time limit for each HTTP call to an OAuth provider during the callback
+	OAuthHTTPTimeout time.Duration `yaml:"oauth_http_timeout"`
+
+	// How often sign-ins abandoned at the provider are cleared from memory
+	OAuthStateSweepInterval time.Duration `yaml:"oauth_state_sweep_interval"`
+
+	// After this many sign-ins in a row fail because a provider is down (0 disables),
+	// new sign-ins with it are refused for OAuthBreakerCooldown
+	OAuthBreakerThreshold int           `yaml:"oauth_breaker_threshold"`
+	OAuthBreakerCooldown  time.Duration `yaml:"oauth_breaker_cooldown"`
+
+	// Comma-separated scopes requested on top of the sign-in ones, for calling
+	// provider APIs on the user's behalf
+	GoogleExtraScopes string `yaml:"google_extra_scopes"`
+	GitHubExtraScopes string `yaml:"github_extra_scopes"`
+	// Keep each user's provider access and refresh tokens, encrypted with
+	// OAuthTokenEncryptionKey: 32 random bytes, base64-encoded
+	OAuthStoreTokens        bool   `yaml:"oauth_store_tokens"`
+	OAuthTokenEncryptionKey string `yaml:"oauth_token_encryption_key"`
+
+	// Email Configuration
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+	SMTPFrom     string `yaml:"smtp_from"`
+
+	// Notification Configuration
+	NewDeviceAlerts bool `yaml:"new_device_alerts"`
+
+	// Welcome email for new accounts. The template is a text/template file; empty
+	// uses the built-in message.
+	SendWelcomeEmail     bool   `yaml:"send_welcome_email"`
+	WelcomeEmailSubject  string `yaml:"welcome_email_subject"`
+	WelcomeEmailTemplate string `yaml:"welcome_email_template"`
+
+	// Security alerts to admins; a zero threshold disables that alert
+	AlertEmails               string        `yaml:"alert_emails"` // Comma-separated; defaults to all super admins
+	AlertWebhookURL           string        `yaml:"alert_webhook_url"`
+	AlertFailedLoginThreshold int           `yaml:"alert_failed_login_threshold"`
+	AlertBulkDeleteThreshold  int           `yaml:"alert_bulk_delete_threshold"`
+	AlertWindow               time.Duration `yaml:"alert_window"`
+	AlertCooldown             time.Duration `yaml:"alert_cooldown"`
+
+	// Every failed password sign-in is also posted here as JSON, when set
+	FailedLoginWebhookURL string `yaml:"failed_login_webhook_url"`
+
+	// Optional phone numbers, verified with a code sent by SMS. Codes are posted as
+	// JSON to SMSWebhookURL, or only logged when it is empty.
+	PhoneVerification bool   `yaml:"phone_verification"`
+	PhoneCountryCode  string `yaml:"phone_country_code"` // Assumed for numbers entered without +, e.g. 44
+	SMSWebhookURL     string `yaml:"sms_webhook_url"`
+
+	// Data retention: audit entries, and ended sessions with their refresh tokens and
+	// login history, older than these many days are deleted; 0 keeps them forever. Deleted rows are first
+	// written to RetentionArchiveDir as JSON lines, when set.
+	AuditRetentionDays     int           `yaml:"audit_retention_days"`
+	ActivityRetentionDays  int           `yaml:"activity_retention_days"`
+	RetentionArchiveDir    string        `yaml:"retention_archive_dir"`
+	RetentionCheckInterval time.Duration `yaml:"retention_check_interval"`
+
+	// Registration Configuration
+	DisableRegistration bool          `yaml:"disable_registration"`
+	InviteOnly          bool          `yaml:"invite_only"`
+	InviteTTL           time.Duration `yaml:"invite_ttl"`
+	// Answer sign-ups for taken emails like new ones and notify the owner by email instead
+	EnumerationSafeSignup bool `yaml:"enumeration_safe_signup"`
+	// Let an email held by a deleted account be registered again, scrubbing the deleted
+	// account's row to free it; otherwise such emails stay blocked
+	AllowDeletedEmailReuse bool `yaml:"allow_deleted_email_reuse"`
+	// Block password sign-in until the email address is verified. Accounts created before
+	// this was enabled are exempt unless RequireVerifiedLoginExisting is also set.
+	RequireVerifiedLogin         bool `yaml:"require_verified_login"`
+	RequireVerifiedLoginExisting bool `yaml:"require_verified_login_existing"`
+	// Comma-separated email domains whose sign-ups are created already verified. Only
+	// exact domains match, so list subdomains separately; empty verifies none.
+	AutoVerifyDomains string `yaml:"auto_verify_domains"`
+	// Hidden form field that only bots fill in; sign-ups that fill it are silently dropped
+	EnableHoneypot bool   `yaml:"enable_honeypot"`
+	HoneypotField  string `yaml:"honeypot_field"`
+	// Sign-ups must accept the terms of service at TermsURL. Changing TermsVersion asks
+	// every signed-in user to accept the new terms before using the app further.
+	RequireTermsAcceptance bool   `yaml:"require_terms_acceptance"`
+	TermsVersion           string `yaml:"terms_version"`
+	TermsURL               string `yaml:"terms_url"`
+
+	// Admin list pagination
+	DefaultPageSize int `yaml:"default_page_size"`
+	MaxPageSize     int `yaml:"max_page_size"`
+
+	// How long admin dashboard statistics are cached; 0 disables the cache
+	AdminStatsCacheTTL time.Duration `yaml:"admin_stats_cache_ttl"`
+
+	// Admin email notifications: the most recipients one send may reach, and how
+	// many sends each admin may make per hour
+	NotifyMaxRecipients int `yaml:"notify_max_recipients"`
+	NotifySendsPerHour  int `yaml:"notify_sends_per_hour"`
+
+	// How often scheduled deactivations that have come due are applied
+	DeactivationCheckInterval time.Duration `yaml:"deactivation_check_interval"`
+
+	// Avatar Configuration
+	UseGravatar     bool   `yaml:"use_gravatar"`
+	GravatarDefault string `yaml:"gravatar_default"`
+
+	// Mask email addresses when users and requests are logged
+	LogMaskEmails bool `yaml:"log_mask_emails"`
+
+	// Comma-separated profile fields the user directory shows: name, avatar, role,
+	// location, bio
+	DirectoryVisibleFields string `yaml:"directory_visible_fields"`
+
+	// Answer 400 when ?fields= on /api/v1/user names an unknown field, instead of
+	// ignoring it
+	RejectUnknownFields bool `yaml:"reject_unknown_fields"`
+
+	// Redirect plain-HTTP requests to HTTPS and send Strict-Transport-Security with
+	// this max age. /healthz is served over either scheme.
+	ForceHTTPS bool          `yaml:"force_https"`
+	HSTSMaxAge time.Duration `yaml:"hsts_max_age"`
+
+	// Comma-separated IPs and CIDRs of the reverse proxies whose X-Forwarded-For and
+	// X-Forwarded-Proto headers are believed. Empty trusts every peer, gin's default.
+	TrustedProxies string `yaml:"trusted_proxies"`
+
+	// Comma-separated IPs and CIDRs allowed to reach /admin and /admin/api; others get
+	// 403 before any role check. Empty allows every address.
+	AdminIPAllowlist string `yaml:"admin_ip_allowlist"`
+
+	// At most MaxConcurrentRequests requests are handled at once (0 = unlimited).
+	// Up to RequestQueueDepth more wait for a slot, for at most RequestQueueTimeout;
+	// the rest get 503 with Retry-After. /healthz is never limited.
+	MaxConcurrentRequests int           `yaml:"max_concurrent_requests"`
+	RequestQueueDepth     int           `yaml:"request_queue_depth"`
+	RequestQueueTimeout   time.Duration `yaml:"request_queue_timeout"`
+}
+
+// MinRetentionDays is the shortest retention period that may be configured, so a
+// typo cannot wipe out recent audit history
+const MinRetentionDays = 30
+
+// LoadConfig loads configuration from, in order of precedence, environment
+// variables, an optional .env file, an optional YAML file named by CONFIG_FILE,
+// and built-in defaults. It returns an error if the merged configuration is invalid.
+func LoadConfig() (*Config, error) {
+	if err := loadDotEnv(".env"); err != nil {
+		return nil, err
+	}
+
+	config := defaultConfig()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadYAML(path, config); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(config); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Outside production, insecure settings are allowed but called out
+	if !config.IsProduction() {
+		for _, problem := range config.InsecureSettings() {
+			log.Printf("Warning: %s. This is refused when APP_ENV=production.", problem)
+		}
+	}
+
 	// Validate required OAuth settings
 	if config.GoogleClientID == "" {
 		log.Println("Warning: GOOGLE_CLIENT_ID not set. Google OAuth will not work.")
@@ -44,8 +298,643 @@ func LoadConfig() *Config {
 	if config.GitHubClientID == "" {
 		log.Println("Warning: GITHUB_CLIENT_ID not set. GitHub OAuth will not work.")
 	}
-	
-	return config
+
+	return config, nil
+}
+
+// defaultJWTSecret is the built-in JWT secret, only fit for development
+const defaultJWTSecret = "your-secret-key-change-this-in-production"
+
+// placeholderJWTSecrets are the sample secrets shipped in the defaults and the
+// example configuration files, which anyone can read
+var placeholderJWTSecrets = map[string]bool{
+	defaultJWTSecret: true,
+	"your-very-secure-secret-key-change-this-in-production": true,
+}
+
+// defaultConfig returns the configuration used when nothing else is set
+func defaultConfig() *Config {
+	return &Config{
+		AppEnv:        "development",
+		Port:          "8080",
+		DatabaseURL:   "sso_app.db",
+		DBSlowQueryMS: 200,
+		JWTSecret:     defaultJWTSecret,
+		AppTimezone:   "UTC",
+		AppBaseURL:    "http://localhost:8080",
+
+		DBRetryAttempts: 3,
+		DBRetryBackoff:  50 * time.Millisecond,
+
+		JWTSigningMethod:  "HS256",
+		JWTKeyGracePeriod: 8 * 24 * time.Hour,
+
+		ReauthWindow:         5 * time.Minute,
+		SessionLimitStrategy: "evict_oldest",
+		SessionMaxLifetime:   30 * 24 * time.Hour,
+		RefreshTokenBinding:  "strict",
+
+		LoginThrottleAccountAttempts: 10,
+		LoginThrottleIPAttempts:      50,
+		LoginThrottleWindow:          15 * time.Minute,
+		PasswordCheckAttempts:        5,
+		PasswordStrengthRateLimit:    60,
+
+		GoogleRedirectURL:       "http://localhost:8080/auth/google/callback",
+		GitHubRedirectURL:       "http://localhost:8080/auth/github/callback",
+		OAuthMissingEmail:       "reject",
+		OAuthHTTPTimeout:        10 * time.Second,
+		OAuthStateSweepInterval: time.Minute,
+		OAuthBreakerThreshold:   5,
+		OAuthBreakerCooldown:    time.Minute,
+
+		SMTPPort: 587,
+		SMTPFrom: "no-reply@localhost",
+
+		WelcomeEmailSubject: "Welcome to SSO Web App",
+
+		AlertFailedLoginThreshold: 20,
+		AlertBulkDeleteThreshold:  5,
+		AlertWindow:               10 * time.Minute,
+		AlertCooldown:             time.Hour,
+
+		InviteTTL: 7 * 24 * time.Hour,
+
+		RetentionCheckInterval: 6 * time.Hour,
+
+		HoneypotField: "website",
+		TermsVersion:  "1",
+
+		DefaultPageSize: 20,
+		MaxPageSize:     100,
+
+		AdminStatsCacheTTL: 30 * time.Second,
+
+		NotifyMaxRecipients: 500,
+		NotifySendsPerHour:  5,
+
+		DeactivationCheckInterval: time.Minute,
+
+		GravatarDefault: "identicon",
+
+		LogMaskEmails: true,
+
+		DirectoryVisibleFields: "name",
+
+		HSTSMaxAge: 365 * 24 * time.Hour,
+
+		RequestQueueDepth:   100,
+		RequestQueueTimeout: 5 * time.Second,
+	}
+}
+
+// applyEnv overrides config values with any environment variables that are set.
+// Every variable that is set but does not parse is reported in the error.
+func applyEnv(config *Config) error {
+	env := &envReader{}
+	config.AppEnv = getEnv("APP_ENV", config.AppEnv)
+	config.Port = getEnv("PORT", config.Port)
+	config.DatabaseURL = getEnv("DATABASE_URL", config.DatabaseURL)
+	config.DBLogLevel = getEnv("DB_LOG_LEVEL", config.DBLogLevel)
+	config.DBSlowQueryMS = env.getInt("DB_SLOW_QUERY_MS", config.DBSlowQueryMS)
+	config.DBRetryAttempts = env.getInt("DB_RETRY_ATTEMPTS", config.DBRetryAttempts)
+	config.DBRetryBackoff = env.getDuration("DB_RETRY_BACKOFF", config.DBRetryBackoff)
+	config.JWTSecret = getEnv("JWT_SECRET", config.JWTSecret)
+	config.AppTimezone = getEnv("APP_TIMEZONE", config.AppTimezone)
+	config.AppBaseURL = getEnv("APP_BASE_URL", config.AppBaseURL)
+	config.JWTSigningMethod = getEnv("JWT_SIGNING_METHOD", config.JWTSigningMethod)
+	config.JWTKeyGracePeriod = env.getDuration("JWT_KEY_GRACE_PERIOD", config.JWTKeyGracePeriod)
+
+	config.ReauthWindow = env.getDuration("REAUTH_WINDOW", config.ReauthWindow)
+	config.CookieDomain = getEnv("COOKIE_DOMAIN", config.CookieDomain)
+	config.CookieSecure = env.getBool("COOKIE_SECURE", config.CookieSecure)
+	config.ReturnTokenInBody = env.getBool("RETURN_TOKEN_IN_BODY", config.ReturnTokenInBody)
+	config.MaxSessionsPerUser = env.getInt("MAX_SESSIONS_PER_USER", config.MaxSessionsPerUser)
+	config.SessionLimitStrategy = getEnv("SESSION_LIMIT_STRATEGY", config.SessionLimitStrategy)
+	config.SessionRefreshWindow = env.getDuration("SESSION_REFRESH_WINDOW", config.SessionRefreshWindow)
+	config.SessionMaxLifetime = env.getDuration("SESSION_MAX_LIFETIME", config.SessionMaxLifetime)
+	config.SessionTTLUser = env.getDuration("SESSION_TTL_USER", config.SessionTTLUser)
+	config.SessionTTLModerator = env.getDuration("SESSION_TTL_MODERATOR", config.SessionTTLModerator)
+	config.SessionTTLAdmin = env.getDuration("SESSION_TTL_ADMIN", config.SessionTTLAdmin)
+	config.RefreshTokenBinding = getEnv("REFRESH_TOKEN_BINDING", config.RefreshTokenBinding)
+
+	config.LoginThrottleAccountAttempts = env.getInt("LOGIN_THROTTLE_ACCOUNT_ATTEMPTS", config.LoginThrottleAccountAttempts)
+	config.LoginThrottleIPAttempts = env.getInt("LOGIN_THROTTLE_IP_ATTEMPTS", config.LoginThrottleIPAttempts)
+	config.LoginThrottleWindow = env.getDuration("LOGIN_THROTTLE_WINDOW", config.LoginThrottleWindow)
+	config.PasswordCheckAttempts = env.getInt("PASSWORD_CHECK_ATTEMPTS", config.PasswordCheckAttempts)
+	config.PasswordStrengthRateLimit = env.getInt("PASSWORD_STRENGTH_RATE_LIMIT", config.PasswordStrengthRateLimit)
+
+	config.PasswordMaxAgeDays = env.getInt("PASSWORD_MAX_AGE_DAYS", config.PasswordMaxAgeDays)
+	config.PasswordPepper = getEnv("PASSWORD_PEPPER", config.PasswordPepper)
+	config.CheckBreachedPasswords = env.getBool("CHECK_BREACHED_PASSWORDS", config.CheckBreachedPasswords)
+	config.BlockDisposableEmails = env.getBool("BLOCK_DISPOSABLE_EMAILS", config.BlockDisposableEmails)
+	config.DisposableEmailDomainsFile = getEnv("DISPOSABLE_EMAIL_DOMAINS_FILE", config.DisposableEmailDomainsFile)
+
+	config.GoogleClientID = getEnv("GOOGLE_CLIENT_ID", config.GoogleClientID)
+	config.GoogleClientSecret = getEnv("GOOGLE_CLIENT_SECRET", config.GoogleClientSecret)
+	config.GoogleRedirectURL = getEnv("GOOGLE_REDIRECT_URL", config.GoogleRedirectURL)
+	config.GoogleAllowedDomains = getEnv("GOOGLE_ALLOWED_DOMAINS", config.GoogleAllowedDomains)
+
+	config.GitHubClientID = getEnv("GITHUB_CLIENT_ID", config.GitHubClientID)
+	config.GitHubClientSecret = getEnv("GITHUB_CLIENT_SECRET", config.GitHubClientSecret)
+	config.GitHubRedirectURL = getEnv("GITHUB_REDIRECT_URL", config.GitHubRedirectURL)
+	config.GitHubAllowedDomains = getEnv("GITHUB_ALLOWED_DOMAINS", config.GitHubAllowedDomains)
+
+	config.OAuthAllowedRedirectHosts = getEnv("OAUTH_ALLOWED_REDIRECT_HOSTS", config.OAuthAllowedRedirectHosts)
+	config.OAuthMissingEmail = getEnv("OAUTH_MISSING_EMAIL", config.OAuthMissingEmail)
+	config.RequireLinkConfirmation = env.getBool("REQUIRE_LINK_CONFIRMATION", config.RequireLinkConfirmation)
+	config.OAuthHTTPTimeout = env.getDuration("OAUTH_HTTP_TIMEOUT", config.OAuthHTTPTimeout)
+	config.OAuthStateSweepInterval = env.getDuration("OAUTH_STATE_SWEEP_INTERVAL", config.OAuthStateSweepInterval)
+	config.OAuthBreakerThreshold = env.getInt("OAUTH_BREAKER_THRESHOLD", config.OAuthBreakerThreshold)
+	config.OAuthBreakerCooldown = env.getDuration("OAUTH_BREAKER_COOLDOWN", config.OAuthBreakerCooldown)
+	config.GoogleExtraScopes = getEnv("GOOGLE_EXTRA_SCOPES", config.GoogleExtraScopes)
+	config.GitHubExtraScopes = getEnv("GITHUB_EXTRA_SCOPES", config.GitHubExtraScopes)
+	config.OAuthStoreTokens = env.getBool("OAUTH_STORE_TOKENS", config.OAuthStoreTokens)
+	config.OAuthTokenEncryptionKey = getEnv("OAUTH_TOKEN_ENCRYPTION_KEY", config.OAuthTokenEncryptionKey)
+
+	config.SMTPHost = getEnv("SMTP_HOST", config.SMTPHost)
+	config.SMTPPort = env.getInt("SMTP_PORT", config.SMTPPort)
+	config.SMTPUsername = getEnv("SMTP_USERNAME", config.SMTPUsername)
+	config.SMTPPassword = getEnv("SMTP_PASSWORD", config.SMTPPassword)
+	config.SMTPFrom = getEnv("SMTP_FROM", config.SMTPFrom)
+
+	config.NewDeviceAlerts = env.getBool("NEW_DEVICE_ALERTS", config.NewDeviceAlerts)
+	config.SendWelcomeEmail = env.getBool("SEND_WELCOME_EMAIL", config.SendWelcomeEmail)
+	config.WelcomeEmailSubject = getEnv("WELCOME_EMAIL_SUBJECT", config.WelcomeEmailSubject)
+	config.WelcomeEmailTemplate = getEnv("WELCOME_EMAIL_TEMPLATE", config.WelcomeEmailTemplate)
+
+	config.AlertEmails = getEnv("ALERT_EMAILS", config.AlertEmails)
+	config.AlertWebhookURL = getEnv("ALERT_WEBHOOK_URL", config.AlertWebhookURL)
+	config.AlertFailedLoginThreshold = env.getInt("ALERT_FAILED_LOGIN_THRESHOLD", config.AlertFailedLoginThreshold)
+	config.AlertBulkDeleteThreshold = env.getInt("ALERT_BULK_DELETE_THRESHOLD", config.AlertBulkDeleteThreshold)
+	config.AlertWindow = env.getDuration("ALERT_WINDOW", config.AlertWindow)
+	config.AlertCooldown = env.getDuration("ALERT_COOLDOWN", config.AlertCooldown)
+	config.FailedLoginWebhookURL = getEnv("FAILED_LOGIN_WEBHOOK_URL", config.FailedLoginWebhookURL)
+
+	config.PhoneVerification = env.getBool("PHONE_VERIFICATION", config.PhoneVerification)
+	config.PhoneCountryCode = getEnv("PHONE_COUNTRY_CODE", config.PhoneCountryCode)
+	config.SMSWebhookURL = getEnv("SMS_WEBHOOK_URL", config.SMSWebhookURL)
+
+	config.DisableRegistration = env.getBool("DISABLE_REGISTRATION", config.DisableRegistration)
+	config.RequireVerifiedLogin = env.getBool("REQUIRE_VERIFIED_LOGIN", config.RequireVerifiedLogin)
+	config.RequireVerifiedLoginExisting = env.getBool("REQUIRE_VERIFIED_LOGIN_EXISTING", config.RequireVerifiedLoginExisting)
+	config.AutoVerifyDomains = getEnv("AUTO_VERIFY_DOMAINS", config.AutoVerifyDomains)
+	config.InviteOnly = env.getBool("INVITE_ONLY", config.InviteOnly)
+	config.InviteTTL = env.getDuration("INVITE_TTL", config.InviteTTL)
+	config.EnumerationSafeSignup = env.getBool("ENUMERATION_SAFE_SIGNUP", config.EnumerationSafeSignup)
+	config.AllowDeletedEmailReuse = env.getBool("ALLOW_DELETED_EMAIL_REUSE", config.AllowDeletedEmailReuse)
+	config.EnableHoneypot = env.getBool("ENABLE_HONEYPOT", config.EnableHoneypot)
+	config.RequireTermsAcceptance = env.getBool("REQUIRE_TERMS_ACCEPTANCE", config.RequireTermsAcceptance)
+	config.TermsVersion = getEnv("TERMS_VERSION", config.TermsVersion)
+	config.TermsURL = getEnv("TERMS_URL", config.TermsURL)
+
+	config.AuditRetentionDays = env.getInt("AUDIT_RETENTION_DAYS", config.AuditRetentionDays)
+	config.ActivityRetentionDays = env.getInt("ACTIVITY_RETENTION_DAYS", config.ActivityRetentionDays)
+	config.RetentionArchiveDir = getEnv("RETENTION_ARCHIVE_DIR", config.RetentionArchiveDir)
+	config.RetentionCheckInterval = env.getDuration("RETENTION_CHECK_INTERVAL", config.RetentionCheckInterval)
+	config.HoneypotField = getEnv("HONEYPOT_FIELD", config.HoneypotField)
+
+	config.DefaultPageSize = env.getInt("DEFAULT_PAGE_SIZE", config.DefaultPageSize)
+	config.MaxPageSize = env.getInt("MAX_PAGE_SIZE", config.MaxPageSize)
+	config.AdminStatsCacheTTL = env.getDuration("ADMIN_STATS_CACHE_TTL", config.AdminStatsCacheTTL)
+	config.NotifyMaxRecipients = env.getInt("NOTIFY_MAX_RECIPIENTS", config.NotifyMaxRecipients)
+	config.NotifySendsPerHour = env.getInt("NOTIFY_SENDS_PER_HOUR", config.NotifySendsPerHour)
+	config.DeactivationCheckInterval = env.getDuration("DEACTIVATION_CHECK_INTERVAL", config.DeactivationCheckInterval)
+
+	config.UseGravatar = env.getBool("USE_GRAVATAR", config.UseGravatar)
+	config.GravatarDefault = getEnv("GRAVATAR_DEFAULT", config.GravatarDefault)
+
+	config.LogMaskEmails = env.getBool("LOG_MASK_EMAILS", config.LogMaskEmails)
+
+	config.DirectoryVisibleFields = getEnv("DIRECTORY_VISIBLE_FIELDS", config.DirectoryVisibleFields)
+	config.RejectUnknownFields = env.getBool("REJECT_UNKNOWN_FIELDS", config.RejectUnknownFields)
+
+	config.ForceHTTPS = env.getBool("FORCE_HTTPS", config.ForceHTTPS)
+	config.HSTSMaxAge = env.getDuration("HSTS_MAX_AGE", config.HSTSMaxAge)
+	config.TrustedProxies = getEnv("TRUSTED_PROXIES", config.TrustedProxies)
+	config.AdminIPAllowlist = getEnv("ADMIN_IP_ALLOWLIST", config.AdminIPAllowlist)
+
+	config.MaxConcurrentRequests = env.getInt("MAX_CONCURRENT_REQUESTS", config.MaxConcurrentRequests)
+	config.RequestQueueDepth = env.getInt("REQUEST_QUEUE_DEPTH", config.RequestQueueDepth)
+	config.RequestQueueTimeout = env.getDuration("REQUEST_QUEUE_TIMEOUT", config.RequestQueueTimeout)
+
+	return env.err()
+}
+
+// Validate checks the merged configuration for values the application cannot run with
+func (c *Config) Validate() error {
+	port, err := strconv.Atoi(c.Port)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %q: must be a number between 1 and 65535", c.Port)
+	}
+
+	if c.DBRetryAttempts < 0 || c.DBRetryAttempts > 10 {
+		return fmt.Errorf("DB retry attempts must be between 0 and 10")
+	}
+	if c.DBRetryBackoff < 0 || c.DBRetryBackoff > time.Second {
+		return fmt.Errorf("DB retry backoff must be between 0 and 1s")
+	}
+
+	if len(c.JWTSecret) < 32 {
+		return fmt.Errorf("JWT secret must be at least 32 characters long")
+	}
+
+	if c.IsProduction() {
+		if problems := c.InsecureSettings(); len(problems) > 0 {
+			return fmt.Errorf("refusing to start with APP_ENV=production: %s", strings.Join(problems, "; "))
+		}
+	}
+
+	if _, err := time.LoadLocation(c.AppTimezone); err != nil {
+		return fmt.Errorf("invalid app timezone %q: %v", c.AppTimezone, err)
+	}
+
+	if base, err := url.Parse(c.AppBaseURL); err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return fmt.Errorf("invalid app base URL %q: must be an absolute http or https URL", c.AppBaseURL)
+	}
+
+	if c.ReauthWindow <= 0 {
+		return fmt.Errorf("reauth window must be positive")
+	}
+
+	if c.CookieDomain != "" && !cookieDomainPattern.MatchString(c.CookieDomain) {
+		return fmt.Errorf("invalid cookie domain %q: must be a domain name such as example.com or .example.com", c.CookieDomain)
+	}
+
+	if c.MaxSessionsPerUser < 0 {
+		return fmt.Errorf("max sessions per user must not be negative")
+	}
+
+	switch c.SessionLimitStrategy {
+	case "evict_oldest", "reject_new":
+	default:
+		return fmt.Errorf("invalid session limit strategy %q: must be evict_oldest or reject_new", c.SessionLimitStrategy)
+	}
+
+	if c.SessionRefreshWindow < 0 || c.SessionRefreshWindow >= 7*24*time.Hour {
+		return fmt.Errorf("session refresh window must be between 0 and the 7-day token lifetime")
+	}
+
+	if c.SessionMaxLifetime <= 0 {
+		return fmt.Errorf("session max lifetime must be positive")
+	}
+
+	for _, ttl := range []time.Duration{c.SessionTTLUser, c.SessionTTLModerator, c.SessionTTLAdmin} {
+		if ttl < 0 {
+			return fmt.Errorf("session TTLs must not be negative")
+		}
+		if ttl > 0 && c.SessionRefreshWindow >= ttl {
+			return fmt.Errorf("session refresh window must be shorter than every per-role session TTL")
+		}
+	}
+
+	switch c.JWTSigningMethod {
+	case "HS256":
+	case "RS256":
+		// A retired key must outlive every token it signed
+		longest := 7 * 24 * time.Hour
+		for _, ttl := range []time.Duration{c.SessionTTLUser, c.SessionTTLModerator, c.SessionTTLAdmin} {
+			if ttl > longest {
+				longest = ttl
+			}
+		}
+		if c.JWTKeyGracePeriod < longest {
+			return fmt.Errorf("JWT key grace period must be at least the longest session token lifetime (%s)", longest)
+		}
+	default:
+		return fmt.Errorf("invalid JWT signing method %q: must be HS256 or RS256", c.JWTSigningMethod)
+	}
+
+	switch c.RefreshTokenBinding {
+	case "strict", "lenient":
+	default:
+		return fmt.Errorf("invalid refresh token binding %q: must be strict or lenient", c.RefreshTokenBinding)
+	}
+
+	switch c.OAuthMissingEmail {
+	case "reject", "prompt":
+	default:
+		return fmt.Errorf("invalid OAuth missing email behavior %q: must be reject or prompt", c.OAuthMissingEmail)
+	}
+
+	if c.OAuthHTTPTimeout <= 0 {
+		return fmt.Errorf("OAuth HTTP timeout must be positive")
+	}
+
+	if c.OAuthStateSweepInterval <= 0 {
+		return fmt.Errorf("OAuth state sweep interval must be positive")
+	}
+
+	if c.OAuthBreakerThreshold < 0 {
+		return fmt.Errorf("OAuth breaker threshold cannot be negative")
+	}
+	if c.OAuthBreakerThreshold > 0 && c.OAuthBreakerCooldown <= 0 {
+		return fmt.Errorf("OAuth breaker cooldown must be positive")
+	}
+
+	if c.OAuthStoreTokens {
+		if _, err := c.OAuthTokenKey(); err != nil {
+			return err
+		}
+	}
+
+	if c.LoginThrottleAccountAttempts < 0 || c.LoginThrottleIPAttempts < 0 || c.PasswordCheckAttempts < 0 {
+		return fmt.Errorf("login throttle attempts must not be negative")
+	}
+
+	if c.PasswordStrengthRateLimit < 0 {
+		return fmt.Errorf("password strength rate limit must not be negative")
+	}
+
+	if c.LoginThrottleWindow <= 0 {
+		return fmt.Errorf("login throttle window must be positive")
+	}
+
+	if c.PasswordMaxAgeDays < 0 {
+		return fmt.Errorf("password max age must not be negative")
+	}
+
+	if c.AlertFailedLoginThreshold < 0 || c.AlertBulkDeleteThreshold < 0 {
+		return fmt.Errorf("alert thresholds must not be negative")
+	}
+
+	if c.AlertWindow <= 0 || c.AlertCooldown < 0 {
+		return fmt.Errorf("alert window must be positive and alert cooldown must not be negative")
+	}
+
+	if c.AlertWebhookURL != "" {
+		if hook, err := url.Parse(c.AlertWebhookURL); err != nil || (hook.Scheme != "http" && hook.Scheme != "https") || hook.Host == "" {
+			return fmt.Errorf("invalid alert webhook URL %q: must be an absolute http or https URL", c.AlertWebhookURL)
+		}
+	}
+
+	if c.SMSWebhookURL != "" {
+		if hook, err := url.Parse(c.SMSWebhookURL); err != nil || (hook.Scheme != "http" && hook.Scheme != "https") || hook.Host == "" {
+			return fmt.Errorf("invalid SMS webhook URL %q: must be an absolute http or https URL", c.SMSWebhookURL)
+		}
+	}
+
+	if c.PhoneCountryCode != "" && !phoneCountryCodePattern.MatchString(c.PhoneCountryCode) {
+		return fmt.Errorf("invalid phone country code %q: must be 1 to 3 digits without +, e.g. 44", c.PhoneCountryCode)
+	}
+
+	if c.FailedLoginWebhookURL != "" {
+		if hook, err := url.Parse(c.FailedLoginWebhookURL); err != nil || (hook.Scheme != "http" && hook.Scheme != "https") || hook.Host == "" {
+			return fmt.Errorf("invalid failed-login webhook URL %q: must be an absolute http or https URL", c.FailedLoginWebhookURL)
+		}
+	}
+
+	if c.WelcomeEmailTemplate != "" {
+		if _, err := template.ParseFiles(c.WelcomeEmailTemplate); err != nil {
+			return fmt.Errorf("invalid welcome email template: %v", err)
+		}
+	}
+
+	if c.BlockDisposableEmails && c.DisposableEmailDomainsFile != "" {
+		file, err := os.Open(c.DisposableEmailDomainsFile)
+		if err != nil {
+			return fmt.Errorf("invalid disposable email domains file: %v", err)
+		}
+		file.Close()
+	}
+
+	if c.InviteTTL <= 0 {
+		return fmt.Errorf("invite TTL must be positive")
+	}
+
+	for _, days := range []int{c.AuditRetentionDays, c.ActivityRetentionDays} {
+		if days != 0 && days < MinRetentionDays {
+			return fmt.Errorf("retention periods must be 0 (keep forever) or at least %d days", MinRetentionDays)
+		}
+	}
+
+	if c.RetentionCheckInterval <= 0 {
+		return fmt.Errorf("retention check interval must be positive")
+	}
+
+	if c.EnableHoneypot {
+		switch c.HoneypotField {
+		case "", "email", "password", "first_name", "last_name", "invite_token", "accept_terms":
+			return fmt.Errorf("honeypot field must be set and must not be a real registration field")
+		}
+	}
+
+	if c.RequireTermsAcceptance && strings.TrimSpace(c.TermsVersion) == "" {
+		return fmt.Errorf("terms version must be set when terms acceptance is required")
+	}
+
+	if c.DefaultPageSize < 1 || c.DefaultPageSize > c.MaxPageSize {
+		return fmt.Errorf("default page size must be between 1 and the max page size (%d)", c.MaxPageSize)
+	}
+
+	if c.AdminStatsCacheTTL < 0 {
+		return fmt.Errorf("admin stats cache TTL must not be negative")
+	}
+
+	if c.NotifyMaxRecipients < 1 || c.NotifySendsPerHour < 1 {
+		return fmt.Errorf("notify max recipients and sends per hour must be positive")
+	}
+
+	if c.DeactivationCheckInterval <= 0 {
+		return fmt.Errorf("deactivation check interval must be positive")
+	}
+
+	if c.DBSlowQueryMS <= 0 {
+		return fmt.Errorf("slow query threshold must be positive")
+	}
+
+	switch strings.ToLower(c.DBLogLevel) {
+	case "", "silent", "error", "warn", "info":
+	default:
+		return fmt.Errorf("invalid DB log level %q: must be silent, error, warn or info", c.DBLogLevel)
+	}
+
+	if c.HSTSMaxAge < 0 {
+		return fmt.Errorf("HSTS max age must not be negative")
+	}
+
+	if c.MaxConcurrentRequests < 0 || c.RequestQueueDepth < 0 {
+		return fmt.Errorf("max concurrent requests and request queue depth must not be negative")
+	}
+
+	if c.MaxConcurrentRequests > 0 && c.RequestQueueTimeout <= 0 {
+		return fmt.Errorf("request queue timeout must be positive")
+	}
+
+	for _, proxy := range c.TrustedProxyList() {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("invalid trusted proxy %q: must be an IP address or CIDR", proxy)
+			}
+		}
+	}
+
+	return nil
+}
+
+// TrustedProxyList returns the entries of TrustedProxies, or nil when none are set
+func (c *Config) TrustedProxyList() []string {
+	return splitList(c.TrustedProxies)
+}
+
+// AdminIPAllowlistEntries returns the entries of AdminIPAllowlist, or nil when none are set
+func (c *Config) AdminIPAllowlistEntries() []string {
+	return splitList(c.AdminIPAllowlist)
+}
+
+// splitList returns the non-empty, trimmed entries of a comma-separated list
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// OAuthTokenKey decodes the key provider tokens are encrypted with
+func (c *Config) OAuthTokenKey() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(c.OAuthTokenEncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("OAuth token encryption key must be 32 bytes, base64-encoded (e.g. openssl rand -base64 32)")
+	}
+	return key, nil
+}
+
+// IsDevelopment reports whether the application runs in development mode
+func (c *Config) IsDevelopment() bool {
+	return c.AppEnv == "development"
+}
+
+// IsProduction reports whether the application runs in production mode
+func (c *Config) IsProduction() bool {
+	return c.AppEnv == "production"
+}
+
+// InsecureSettings describes the settings that are unsafe to run in production
+// with. Validate refuses them in production; LoadConfig warns about them otherwise.
+func (c *Config) InsecureSettings() []string {
+	var problems []string
+	if placeholderJWTSecrets[c.JWTSecret] {
+		problems = append(problems, "JWT_SECRET is still the sample value; set a random secret of at least 32 characters")
+	}
+	if !c.CookieSecure {
+		problems = append(problems, "COOKIE_SECURE is false, so session cookies would be sent over plain HTTP")
+	}
+	return problems
+}
+
+// loadYAML merges values from a YAML config file into config
+func loadYAML(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// loadDotEnv exports KEY=VALUE pairs from a .env file into the process environment.
+// Variables that are already set are left untouched, and a missing file is not an error.
+func loadDotEnv(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !found {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNumber)
+		}
+
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		if _, exists := os.LookupEnv(key); !exists {
+			os.Setenv(key, value)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// envReader reads typed environment variables. A value that does not parse keeps
+// the fallback and is remembered, so a typo in a setting is reported rather than
+// silently replaced by the default.
+type envReader struct {
+	problems []string
+}
+
+// getInt gets an integer environment variable with a fallback value
+func (r *envReader) getInt(key string, fallback int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		r.invalid(key, raw, "a whole number")
+		return fallback
+	}
+	return value
+}
+
+// getBool gets a boolean environment variable with a fallback value
+func (r *envReader) getBool(key string, fallback bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		r.invalid(key, raw, "true or false")
+		return fallback
+	}
+	return value
+}
+
+// getDuration gets a duration environment variable (e.g. "5m") with a fallback value
+func (r *envReader) getDuration(key string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil {
+		r.invalid(key, raw, `a duration such as "30s" or "5m"`)
+		return fallback
+	}
+	return value
+}
+
+func (r *envReader) invalid(key, raw, want string) {
+	r.problems = append(r.problems, fmt.Sprintf("%s=%q is not %s", key, raw, want))
+}
+
+// err returns the values that did not parse, or nil if all of them did
+func (r *envReader) err() error {
+	if len(r.problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid environment settings: %s", strings.Join(r.problems, "; "))
 }
 
 // getEnv gets an environment variable with a fallback value
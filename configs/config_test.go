@@ -0,0 +1,159 @@
+package configs
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// clearEnv empties the environment for one test and restores it afterwards, so
+// only the variables the test sets are seen
+func clearEnv(t *testing.T) {
+	t.Helper()
+	saved := os.Environ()
+	os.Clearenv()
+	t.Cleanup(func() {
+		os.Clearenv()
+		for _, entry := range saved {
+			key, value, _ := strings.Cut(entry, "=")
+			os.Setenv(key, value)
+		}
+	})
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	clearEnv(t)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig with nothing set: %v", err)
+	}
+	if !cfg.IsDevelopment() {
+		t.Errorf("AppEnv = %q, want development", cfg.AppEnv)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestLoadConfigProductionRefusesInsecureSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"defaults", map[string]string{}, "JWT_SECRET"},
+		{"plain http cookies", map[string]string{"JWT_SECRET": strings.Repeat("s", 40)}, "COOKIE_SECURE"},
+		{"secure", map[string]string{"JWT_SECRET": strings.Repeat("s", 40), "COOKIE_SECURE": "true"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			os.Setenv("APP_ENV", "production")
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+
+			_, err := LoadConfig()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("LoadConfig: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig error = %v, want one mentioning %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateInsecureSettings(t *testing.T) {
+	strong := strings.Repeat("s", 40)
+
+	tests := []struct {
+		name    string
+		env     string
+		secret  string
+		secure  bool
+		wantErr string
+	}{
+		{"production, default secret", "production", defaultJWTSecret, true, "JWT_SECRET is still the sample value"},
+		{"production, example secret", "production", "your-very-secure-secret-key-change-this-in-production", true, "JWT_SECRET is still the sample value"},
+		{"production, short secret", "production", "too-short", true, "at least 32 characters"},
+		{"production, insecure cookies", "production", strong, false, "COOKIE_SECURE"},
+		{"production, both problems", "production", defaultJWTSecret, false, "at least 32 characters; COOKIE_SECURE is false"},
+		{"production, secure", "production", strong, true, ""},
+		{"development, default secret and insecure cookies", "development", defaultJWTSecret, false, ""},
+		{"development, short secret", "development", "too-short", false, "at least 32 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.AppEnv = tt.env
+			cfg.JWTSecret = tt.secret
+			cfg.CookieSecure = tt.secure
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRejectsMalformedValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{"int", "MAX_SESSIONS_PER_USER", "abc"},
+		{"int with unit", "DB_RETRY_ATTEMPTS", "3x"},
+		{"bool", "COOKIE_SECURE", "yes please"},
+		{"duration without unit", "REAUTH_WINDOW", "5"},
+		{"duration with bad unit", "SESSION_TTL_USER", "5x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			os.Setenv(tt.key, tt.value)
+
+			_, err := LoadConfig()
+			if err == nil || !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("LoadConfig error = %v, want one naming %s", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestLoadConfigReportsEveryMalformedValue(t *testing.T) {
+	clearEnv(t)
+	os.Setenv("MAX_SESSIONS_PER_USER", "abc")
+	os.Setenv("REAUTH_WINDOW", "soon")
+
+	_, err := LoadConfig()
+	if err == nil || !strings.Contains(err.Error(), "MAX_SESSIONS_PER_USER") || !strings.Contains(err.Error(), "REAUTH_WINDOW") {
+		t.Errorf("LoadConfig error = %v, want both settings named", err)
+	}
+}
+
+func TestLoadConfigAcceptsExampleEnv(t *testing.T) {
+	clearEnv(t)
+	if err := loadDotEnv("../.env.example"); err != nil {
+		t.Fatalf("loadDotEnv: %v", err)
+	}
+
+	if _, err := LoadConfig(); err != nil {
+		t.Errorf("LoadConfig with .env.example: %v", err)
+	}
+}
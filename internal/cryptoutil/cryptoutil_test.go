@@ -0,0 +1,30 @@
+package cryptoutil
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"identical", "3f9a1c0e", "3f9a1c0e", true},
+		{"both empty", "", "", true},
+		{"last byte differs", "3f9a1c0e", "3f9a1c0f", false},
+		{"first byte differs", "3f9a1c0e", "4f9a1c0e", false},
+		{"prefix", "3f9a1c0e", "3f9a", false},
+		{"one empty", "3f9a1c0e", "", false},
+		{"case differs", "abcdef", "ABCDEF", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Equal(tt.a, tt.b); got != tt.want {
+				t.Errorf("Equal(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := Equal(tt.b, tt.a); got != tt.want {
+				t.Errorf("Equal(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
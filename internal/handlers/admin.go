@@ -1,39 +1,48 @@
 package handlers
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"sso-web-app/internal/middleware"
 	"sso-web-app/internal/models"
 	"sso-web-app/internal/services"
+	"sso-web-app/internal/timeutil"
 )
 
 type AdminHandler struct {
 	adminService *services.AdminService
+	auditService *services.AuditService
 }
 
-func NewAdminHandler() *AdminHandler {
+func NewAdminHandler(adminService *services.AdminService, auditService *services.AuditService) *AdminHandler {
 	return &AdminHandler{
-		adminService: services.NewAdminService(),
+		adminService: adminService,
+		auditService: auditService,
 	}
 }
 
 // Dashboard displays admin dashboard with statistics
 func (h *AdminHandler) Dashboard(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.HTML(http.StatusUnauthorized, "error.html", gin.H{
-			"title": "Unauthorized",
-			"error": "Authentication required",
-		})
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
 		return
 	}
-
-	adminUser := user.(*models.User)
 	
-	stats, err := h.adminService.GetUserStats(adminUser)
+	var stats *models.UserStatsResponse
+	var err error
+	if c.Query("refresh") != "" {
+		stats, err = h.adminService.RefreshUserStats(adminUser)
+	} else {
+		stats, err = h.adminService.GetUserStats(adminUser)
+	}
 	if err != nil {
 		if err == services.ErrNotAuthorized {
 			c.HTML(http.StatusForbidden, "error.html", gin.H{
@@ -58,42 +67,81 @@ func (h *AdminHandler) Dashboard(c *gin.Context) {
 	})
 }
 
-// UsersList displays paginated list of all users
-func (h *AdminHandler) UsersList(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.HTML(http.StatusUnauthorized, "error.html", gin.H{
-			"title": "Unauthorized",
-			"error": "Authentication required",
+// SystemHealth displays system health as HTML or JSON depending on the Accept header
+func (h *AdminHandler) SystemHealth(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+	format := c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON)
+
+	health, err := h.adminService.GetSystemHealth(adminUser)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to load system health"
+		if err == services.ErrNotAuthorized {
+			status = http.StatusForbidden
+			message = "Admin privileges required"
+		}
+		if format == gin.MIMEJSON {
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+		c.HTML(status, "error.html", gin.H{
+			"title": "Error",
+			"error": message,
 		})
 		return
 	}
 
-	adminUser := user.(*models.User)
+	if format == gin.MIMEJSON {
+		c.JSON(http.StatusOK, health)
+		return
+	}
+
+	c.HTML(http.StatusOK, "admin-system.html", gin.H{
+		"title":      "System Health",
+		"user":       adminUser,
+		"health":     health,
+		"isAdmin":    true,
+		"activePage": "system",
+	})
+}
+
+// UsersList displays paginated list of all users
+func (h *AdminHandler) UsersList(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
 
 	// Parse pagination parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if page < 1 {
-		page = 1
-	}
-	limit := 20
-	offset := (page - 1) * limit
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	params := h.adminService.PageParams(page, pageSize)
 
 	// Parse filter parameters
-	role := c.Query("role")
-	search := c.Query("search")
-
-	var users []*models.User
+	filter := models.UserListFilter{
+		Role:   c.Query("role"),
+		Search: c.Query("search"),
+	}
 	var err error
-
-	if search != "" {
-		users, err = h.adminService.SearchUsers(adminUser, search, limit, offset)
-	} else if role != "" {
-		users, err = h.adminService.GetUsersByRole(adminUser, role, limit, offset)
-	} else {
-		users, err = h.adminService.GetAllUsers(adminUser, limit, offset)
+	if filter.CreatedAfter, err = parseOptionalTime(c.Query("created_after")); err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title": "Error",
+			"error": "Invalid created_after date",
+		})
+		return
+	}
+	if filter.CreatedBefore, err = parseOptionalTime(c.Query("created_before")); err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title": "Error",
+			"error": "Invalid created_before date",
+		})
+		return
 	}
 
+	users, err := h.adminService.ListUsers(adminUser, filter, params)
 	if err != nil {
 		if err == services.ErrNotAuthorized {
 			c.HTML(http.StatusForbidden, "error.html", gin.H{
@@ -102,6 +150,20 @@ func (h *AdminHandler) UsersList(c *gin.Context) {
 			})
 			return
 		}
+		if err == services.ErrInvalidRole {
+			c.HTML(http.StatusBadRequest, "error.html", gin.H{
+				"title": "Error",
+				"error": "Invalid role specified",
+			})
+			return
+		}
+		if err == services.ErrInvalidDateRange {
+			c.HTML(http.StatusBadRequest, "error.html", gin.H{
+				"title": "Error",
+				"error": "created_after must not be later than created_before",
+			})
+			return
+		}
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
 			"title": "Error",
 			"error": "Failed to load users",
@@ -112,27 +174,25 @@ func (h *AdminHandler) UsersList(c *gin.Context) {
 	c.HTML(http.StatusOK, "admin-users.html", gin.H{
 		"title":      "User Management",
 		"user":       adminUser,
-		"users":      users,
+		"users":      users.Items,
 		"isAdmin":    true,
 		"activePage": "users",
-		"currentPage": page,
-		"searchQuery": search,
-		"roleFilter":  role,
+		"pagination":  users,
+		"currentPage": users.Page,
+		"pageSize":    users.PageSize,
+		"searchQuery": filter.Search,
+		"roleFilter":  filter.Role,
+		"createdAfter":  c.Query("created_after"),
+		"createdBefore": c.Query("created_before"),
 	})
 }
 
 // UserDetail displays detailed view of a specific user
 func (h *AdminHandler) UserDetail(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.HTML(http.StatusUnauthorized, "error.html", gin.H{
-			"title": "Unauthorized",
-			"error": "Authentication required",
-		})
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
 		return
 	}
-
-	adminUser := user.(*models.User)
 	
 	userIDStr := c.Param("id")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
@@ -167,10 +227,20 @@ func (h *AdminHandler) UserDetail(c *gin.Context) {
 		return
 	}
 
+	lockout, err := h.adminService.GetLockout(adminUser, targetUser.ID)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+			"title": "Error",
+			"error": "Failed to load user data",
+		})
+		return
+	}
+
 	c.HTML(http.StatusOK, "admin-user-detail.html", gin.H{
 		"title":      "User Details",
 		"user":       adminUser,
 		"targetUser": targetUser,
+		"lockout":    lockout,
 		"isAdmin":    true,
 		"activePage": "users",
 	})
@@ -178,13 +248,10 @@ func (h *AdminHandler) UserDetail(c *gin.Context) {
 
 // UpdateUser handles user updates from admin
 func (h *AdminHandler) UpdateUser(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
 		return
 	}
-
-	adminUser := user.(*models.User)
 	
 	userIDStr := c.Param("id")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
@@ -213,10 +280,24 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role specified"})
 			return
 		}
+		if err == services.ErrInvalidActiveFrom || err == services.ErrInvalidWebsite || err == services.ErrWebsiteTooLong {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == services.ErrStaleUpdate {
+			c.JSON(http.StatusConflict, gin.H{"error": "User was modified by someone else. Please reload and try again."})
+			return
+		}
+		if err == services.ErrLastAdmin {
+			c.JSON(http.StatusConflict, gin.H{"error": "At least one active admin must remain"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 
+	recordAudit(h.auditService, c, models.AuditActionUserUpdate, uint(userID), "")
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User updated successfully",
 		"user":    updatedUser.ToResponse(),
@@ -225,13 +306,10 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 
 // DeactivateUser deactivates a user account
 func (h *AdminHandler) DeactivateUser(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
 		return
 	}
-
-	adminUser := user.(*models.User)
 	
 	userIDStr := c.Param("id")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
@@ -240,7 +318,25 @@ func (h *AdminHandler) DeactivateUser(c *gin.Context) {
 		return
 	}
 
-	updatedUser, err := h.adminService.DeactivateUser(adminUser, uint(userID))
+	// The body is optional; without effective_at the user is deactivated now
+	var req models.DeactivateUserRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+			return
+		}
+	}
+
+	var effectiveAt time.Time
+	if req.EffectiveAt != "" {
+		effectiveAt, err = time.Parse(time.RFC3339, req.EffectiveAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidEffectiveAt.Error()})
+			return
+		}
+	}
+
+	updatedUser, err := h.adminService.DeactivateUser(adminUser, uint(userID), effectiveAt)
 	if err != nil {
 		if err == services.ErrNotAuthorized {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
@@ -250,26 +346,40 @@ func (h *AdminHandler) DeactivateUser(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
+		if err == services.ErrLastAdmin {
+			c.JSON(http.StatusConflict, gin.H{"error": "At least one active admin must remain"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if updatedUser.DeactivateAt != nil {
+		recordAudit(h.auditService, c, models.AuditActionUserDeactivate, uint(userID),
+			"scheduled for "+updatedUser.DeactivateAt.Format(time.RFC3339))
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "User deactivation scheduled",
+			"user":    updatedUser.ToResponse(),
+		})
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionUserDeactivate, uint(userID), "")
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User deactivated successfully",
 		"user":    updatedUser.ToResponse(),
 	})
 }
 
-// ActivateUser activates a user account
-func (h *AdminHandler) ActivateUser(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+// CancelDeactivation cancels a user's scheduled deactivation
+func (h *AdminHandler) CancelDeactivation(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
 		return
 	}
 
-	adminUser := user.(*models.User)
-	
 	userIDStr := c.Param("id")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
@@ -277,7 +387,7 @@ func (h *AdminHandler) ActivateUser(c *gin.Context) {
 		return
 	}
 
-	updatedUser, err := h.adminService.ActivateUser(adminUser, uint(userID))
+	updatedUser, err := h.adminService.CancelDeactivation(adminUser, uint(userID))
 	if err != nil {
 		if err == services.ErrNotAuthorized {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
@@ -287,26 +397,33 @@ func (h *AdminHandler) ActivateUser(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate user"})
+		if err == services.ErrNoScheduledDeactivation {
+			c.JSON(http.StatusConflict, gin.H{"error": "User has no scheduled deactivation"})
+			return
+		}
+		if err == services.ErrStaleUpdate {
+			c.JSON(http.StatusConflict, gin.H{"error": "User was modified by someone else. Please reload and try again."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel deactivation"})
 		return
 	}
 
+	recordAudit(h.auditService, c, models.AuditActionDeactivationCancel, uint(userID), "")
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User activated successfully",
+		"message": "Scheduled deactivation cancelled",
 		"user":    updatedUser.ToResponse(),
 	})
 }
 
-// DeleteUser permanently deletes a user account
-func (h *AdminHandler) DeleteUser(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+// ForceLogout signs a user out of every session
+func (h *AdminHandler) ForceLogout(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
 		return
 	}
 
-	adminUser := user.(*models.User)
-	
 	userIDStr := c.Param("id")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
@@ -314,7 +431,7 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	err = h.adminService.DeleteUser(adminUser, uint(userID))
+	updatedUser, err := h.adminService.ForceLogout(adminUser, uint(userID))
 	if err != nil {
 		if err == services.ErrNotAuthorized {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
@@ -324,24 +441,73 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == services.ErrStaleUpdate {
+			c.JSON(http.StatusConflict, gin.H{"error": "User was modified by someone else. Please reload and try again."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out user"})
 		return
 	}
 
+	recordAudit(h.auditService, c, models.AuditActionForceLogout, uint(userID), "")
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User deleted successfully",
+		"message": "User signed out of all sessions",
+		"user":    updatedUser.ToResponse(),
 	})
 }
 
-// PromoteToAdmin promotes a user to admin role
-func (h *AdminHandler) PromoteToAdmin(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+// DeactivateAndLogout deactivates a user account and signs it out of every session
+func (h *AdminHandler) DeactivateAndLogout(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	updatedUser, err := h.adminService.DeactivateAndLogout(adminUser, uint(userID))
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == services.ErrLastAdmin {
+			c.JSON(http.StatusConflict, gin.H{"error": "At least one active admin must remain"})
+			return
+		}
+		if err == services.ErrStaleUpdate {
+			c.JSON(http.StatusConflict, gin.H{"error": "User was modified by someone else. Please reload and try again."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	adminUser := user.(*models.User)
+	recordAudit(h.auditService, c, models.AuditActionUserDeactivate, uint(userID), "")
+	recordAudit(h.auditService, c, models.AuditActionForceLogout, uint(userID), "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User deactivated and signed out of all sessions",
+		"user":    updatedUser.ToResponse(),
+	})
+}
+
+// ActivateUser activates a user account
+func (h *AdminHandler) ActivateUser(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
 	
 	userIDStr := c.Param("id")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
@@ -350,7 +516,7 @@ func (h *AdminHandler) PromoteToAdmin(c *gin.Context) {
 		return
 	}
 
-	updatedUser, err := h.adminService.PromoteToAdmin(adminUser, uint(userID))
+	updatedUser, err := h.adminService.ActivateUser(adminUser, uint(userID))
 	if err != nil {
 		if err == services.ErrNotAuthorized {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
@@ -360,25 +526,151 @@ func (h *AdminHandler) PromoteToAdmin(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote user"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate user"})
 		return
 	}
 
+	recordAudit(h.auditService, c, models.AuditActionUserActivate, uint(userID), "")
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User promoted to admin successfully",
+		"message": "User activated successfully",
 		"user":    updatedUser.ToResponse(),
 	})
 }
 
-// DemoteFromAdmin removes admin privileges from a user
-func (h *AdminHandler) DemoteFromAdmin(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+// ResendVerification mails a user a new email verification link on their behalf
+func (h *AdminHandler) ResendVerification(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	targetUser, err := h.adminService.ResendVerification(adminUser, uint(userID))
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == services.ErrAlreadyVerified {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Email address is already verified; no email was sent",
+				"user":    targetUser.ToResponse(),
+			})
+			return
+		}
+		if err == services.ErrNoEmailAddress {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "User has no email address"})
+			return
+		}
+		if err == services.ErrVerificationCooldown {
+			retryAfter := services.VerificationRetryAfter(targetUser)
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "A verification email was sent recently. Please wait and try again."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionVerificationSend, uint(userID), "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Verification email sent",
+		"user":    targetUser.ToResponse(),
+	})
+}
+
+// UserLockout returns a user's sign-in lockout state
+func (h *AdminHandler) UserLockout(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	lockout, err := h.adminService.GetLockout(adminUser, uint(userID))
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load lockout state"})
+		return
+	}
+
+	c.JSON(http.StatusOK, lockout)
+}
+
+// UnlockUser clears the failed sign-ins counted against a user's account
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	cleared, err := h.adminService.ClearLockout(adminUser, uint(userID))
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	if cleared.FailedAttempts == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "User has no failed sign-in attempts; nothing to clear",
+			"cleared": cleared,
+		})
 		return
 	}
 
-	adminUser := user.(*models.User)
+	recordAudit(h.auditService, c, models.AuditActionLockoutClear, uint(userID),
+		fmt.Sprintf("cleared %d failed sign-ins (locked: %t)", cleared.FailedAttempts, cleared.Locked))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Sign-in lockout cleared",
+		"cleared": cleared,
+	})
+}
+
+// DeleteUser permanently deletes a user account
+func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
 	
 	userIDStr := c.Param("id")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
@@ -387,7 +679,7 @@ func (h *AdminHandler) DemoteFromAdmin(c *gin.Context) {
 		return
 	}
 
-	updatedUser, err := h.adminService.DemoteFromAdmin(adminUser, uint(userID))
+	err = h.adminService.DeleteUser(adminUser, uint(userID))
 	if err != nil {
 		if err == services.ErrNotAuthorized {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
@@ -397,12 +689,659 @@ func (h *AdminHandler) DemoteFromAdmin(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
+		if err == services.ErrLastAdmin {
+			c.JSON(http.StatusConflict, gin.H{"error": "At least one active admin must remain"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	recordAudit(h.auditService, c, models.AuditActionUserDelete, uint(userID), "")
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Admin privileges removed successfully",
-		"user":    updatedUser.ToResponse(),
+		"message": "User deleted successfully",
 	})
 }
+
+// PromoteToAdmin promotes a user to admin role
+func (h *AdminHandler) PromoteToAdmin(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+	
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	updatedUser, err := h.adminService.PromoteToAdmin(adminUser, uint(userID))
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to promote user"})
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionUserPromote, uint(userID), "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User promoted to admin successfully",
+		"user":    updatedUser.ToResponse(),
+	})
+}
+
+// DemoteFromAdmin removes admin privileges from a user
+func (h *AdminHandler) DemoteFromAdmin(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+	
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	updatedUser, err := h.adminService.DemoteFromAdmin(adminUser, uint(userID))
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == services.ErrLastAdmin {
+			c.JSON(http.StatusConflict, gin.H{"error": "At least one active admin must remain"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionUserDemote, uint(userID), "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Admin privileges removed successfully",
+		"user":    updatedUser.ToResponse(),
+	})
+}
+
+// MergeUser merges a duplicate account into the user identified by the URL
+func (h *AdminHandler) MergeUser(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+	
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.MergeUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	mergedUser, err := h.adminService.MergeUsers(adminUser, uint(userID), req.DuplicateID)
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == services.ErrInvalidMerge {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge the same account, an admin account, or your own account"})
+			return
+		}
+		if err == services.ErrMergeConflict || err == services.ErrStaleUpdate {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge users"})
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionUserMerge, uint(userID), fmt.Sprintf("merged duplicate user %d", req.DuplicateID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Users merged successfully",
+		"user":    mergedUser.ToResponse(),
+	})
+}
+
+// Invitations lists invitations as HTML or JSON depending on the Accept header
+func (h *AdminHandler) Invitations(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+	format := c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit := 50
+	offset := (page - 1) * limit
+
+	invitations, err := h.adminService.ListInvitations(adminUser, limit, offset)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to load invitations"
+		if err == services.ErrNotAuthorized {
+			status = http.StatusForbidden
+			message = "Admin privileges required"
+		}
+		if format == gin.MIMEJSON {
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+		c.HTML(status, "error.html", gin.H{
+			"title": "Error",
+			"error": message,
+		})
+		return
+	}
+
+	now := timeutil.Now()
+
+	if format == gin.MIMEJSON {
+		responses := make([]models.InvitationResponse, 0, len(invitations))
+		for _, invitation := range invitations {
+			responses = append(responses, invitation.ToResponse(now))
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"invitations": responses,
+			"invite_only": h.adminService.InviteOnly(),
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "admin-invitations.html", gin.H{
+		"title":       "Invitations",
+		"user":        adminUser,
+		"invitations": invitations,
+		"now":         now,
+		"inviteOnly":  h.adminService.InviteOnly(),
+		"isAdmin":     true,
+		"activePage":  "invitations",
+		"currentPage": page,
+	})
+}
+
+// InactiveUsers lists users who have not signed in for ?days= days (180 by default),
+// including those who never signed in, e.g. before a cleanup or re-engagement campaign
+func (h *AdminHandler) InactiveUsers(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+	format := c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON)
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "180"))
+	if err != nil {
+		days = 0
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit := 50
+	offset := (page - 1) * limit
+
+	users, cutoff, err := h.adminService.ListInactiveUsers(adminUser, days, limit, offset)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Failed to load inactive users"
+		if err == services.ErrNotAuthorized {
+			status = http.StatusForbidden
+			message = "Admin privileges required"
+		}
+		if err == services.ErrInvalidInactiveDays {
+			status = http.StatusBadRequest
+			message = err.Error()
+		}
+		if format == gin.MIMEJSON {
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+		c.HTML(status, "error.html", gin.H{
+			"title": "Error",
+			"error": message,
+		})
+		return
+	}
+
+	if format == gin.MIMEJSON {
+		responses := make([]models.UserResponse, 0, len(users))
+		for _, user := range users {
+			responses = append(responses, user.ToResponse())
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"users":  responses,
+			"days":   days,
+			"cutoff": models.NewTimestamp(cutoff),
+			"page":   page,
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "admin-inactive-users.html", gin.H{
+		"title":       "Inactive Users",
+		"user":        adminUser,
+		"users":       users,
+		"days":        days,
+		"cutoff":      cutoff,
+		"isAdmin":     true,
+		"activePage":  "users",
+		"currentPage": page,
+		"prevPage":    page - 1,
+		"nextPage":    page + 1,
+		"hasNext":     len(users) == limit,
+	})
+}
+
+// CreateInvitation issues a single-use registration invitation
+func (h *AdminHandler) CreateInvitation(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	token, invitation, err := h.adminService.CreateInvitation(adminUser, req.Email, req.Role)
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient privileges to invite with this role"})
+			return
+		}
+		if err == services.ErrInvalidRole {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role specified"})
+			return
+		}
+		if err == services.ErrUserExists {
+			c.JSON(http.StatusConflict, gin.H{"error": "A user with this email already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionInvitationCreate, 0, fmt.Sprintf("invited %s as %s", invitation.Email, invitation.Role))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Invitation created successfully",
+		"invitation": invitation.ToResponse(timeutil.Now()),
+		"token":      token,
+		"invite_url": "/register?invite=" + url.QueryEscape(token),
+	})
+}
+
+// ExportUserData downloads everything stored about a user (GDPR export)
+func (h *AdminHandler) ExportUserData(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+	
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	export, err := h.adminService.ExportUserData(adminUser, uint(userID))
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export user data"})
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionUserExport, uint(userID), "")
+
+	writeExport(c, uint(userID), export)
+}
+
+// LinkedProviders lists the sign-in methods linked to a user
+func (h *AdminHandler) LinkedProviders(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	targetUser, err := h.adminService.GetLinkedProviders(adminUser, uint(userID))
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load linked providers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, targetUser.LinkedProvidersResponse())
+}
+
+// UnlinkProvider removes an OAuth provider from a user's account
+func (h *AdminHandler) UnlinkProvider(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	provider := c.Param("provider")
+	updatedUser, err := h.adminService.UnlinkProvider(adminUser, uint(userID), provider)
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == services.ErrProviderNotLinked {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Provider is not linked to this user"})
+			return
+		}
+		if err == services.ErrLastLoginMethod {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot unlink the user's only sign-in method"})
+			return
+		}
+		if err == services.ErrStaleUpdate {
+			c.JSON(http.StatusConflict, gin.H{"error": "User was modified by someone else. Please reload and try again."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink provider"})
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionProviderUnlink, uint(userID), "provider: "+provider)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Provider unlinked successfully",
+		"providers": updatedUser.LinkedProvidersResponse(),
+	})
+}
+
+// UserAPIKeys lists a user's API keys without their secrets
+func (h *AdminHandler) UserAPIKeys(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	keys, err := h.adminService.ListUserAPIKeys(adminUser, uint(userID))
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API keys"})
+		return
+	}
+
+	responses := make([]models.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		responses = append(responses, key.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": responses})
+}
+
+// RevokeUserAPIKey revokes one of a user's API keys
+func (h *AdminHandler) RevokeUserAPIKey(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	keyID, err := strconv.ParseUint(c.Param("keyID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	key, err := h.adminService.RevokeUserAPIKey(adminUser, uint(userID), uint(keyID))
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrUserNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err == services.ErrAPIKeyNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionAPIKeyRevoke, uint(userID),
+		fmt.Sprintf("key: %d (%s, %s)", key.ID, key.Name, key.Prefix))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked successfully",
+		"api_key": key.ToResponse(),
+	})
+}
+
+// NotifyUsers emails every user matching a role and active filter, or with
+// dry_run set reports how many users would be emailed
+func (h *AdminHandler) NotifyUsers(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.NotifyUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	filter := models.NotificationFilter{Role: req.Role, Active: req.Active}
+	count, err := h.adminService.NotifyUsers(adminUser, filter, req.Subject, req.Body, req.DryRun)
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrInvalidRole {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role specified"})
+			return
+		}
+		if err == services.ErrTooManyRecipients {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":      "Notification matches too many recipients; narrow the filter",
+				"recipients": count,
+			})
+			return
+		}
+		if err == services.ErrNotifyThrottled {
+			retryAfter := h.adminService.NotifyRetryAfter(adminUser)
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many notifications sent. Please try again later."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send notification"})
+		return
+	}
+
+	if req.DryRun {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "recipients": count})
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionNotifyUsers, 0,
+		fmt.Sprintf("role: %q, active: %s, recipients: %d, subject: %q", req.Role, formatOptionalBool(req.Active), count, req.Subject))
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Notification queued",
+		"recipients": count,
+	})
+}
+
+// formatOptionalBool renders an optional filter value for audit details
+func formatOptionalBool(value *bool) string {
+	if value == nil {
+		return "any"
+	}
+	return strconv.FormatBool(*value)
+}
+
+// SignupTrend returns signup counts per day, week or month over the last
+// ?days= days (default 30), today included
+func (h *AdminHandler) SignupTrend(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive integer"})
+		return
+	}
+
+	to := timeutil.Now()
+	from := to.AddDate(0, 0, 1-days)
+	trend, err := h.adminService.GetSignupTrend(adminUser, from, to, c.DefaultQuery("granularity", services.GranularityDay))
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrInvalidGranularity || err == services.ErrTooManyBuckets {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load signup trend"})
+		return
+	}
+
+	c.JSON(http.StatusOK, trend)
+}
+
+// UsersByIDs returns the users named in ?ids=1,2,3 for resolving many references
+// at once. IDs without a user are left out of the response.
+func (h *AdminHandler) UsersByIDs(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	var ids []uint
+	for _, value := range strings.Split(c.Query("ids"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID: " + value})
+			return
+		}
+		ids = append(ids, uint(id))
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids is required"})
+		return
+	}
+
+	users, err := h.adminService.GetUsersByIDs(adminUser, ids)
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		if err == services.ErrTooManyIDs {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   fmt.Sprintf("At most %d user IDs may be requested at once", services.MaxBatchUserIDs),
+				"max_ids": services.MaxBatchUserIDs,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load users"})
+		return
+	}
+
+	responses := make([]models.UserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, user.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": responses})
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/services"
+)
+
+func TestSystemHealthRequiresAdmin(t *testing.T) {
+	server := newTestServer(t, map[string]string{"GOOGLE_CLIENT_ID": "google-client", "GOOGLE_CLIENT_SECRET": "secret"})
+	_, adminToken := server.createUser("admin@example.com", "admin")
+	_, moderatorToken := server.createUser("moderator@example.com", "moderator")
+	_, userToken := server.createUser("user@example.com", "user")
+
+	tests := []struct {
+		name   string
+		token  string
+		accept string
+		want   int
+	}{
+		{"admin, json", adminToken, "application/json", http.StatusOK},
+		{"admin, html", adminToken, "text/html", http.StatusOK},
+		{"moderator", moderatorToken, "application/json", http.StatusForbidden},
+		{"user, json", userToken, "application/json", http.StatusForbidden},
+		{"user, html", userToken, "text/html", http.StatusForbidden},
+		{"signed out", "", "application/json", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := server.do(http.MethodGet, "/admin/system", tt.token, "", "Accept", tt.accept)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want == http.StatusOK && tt.accept == "text/html" && !strings.Contains(rec.Header().Get("Content-Type"), "text/html") {
+				t.Errorf("Content-Type = %q, want HTML", rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestSystemHealthJSONShape(t *testing.T) {
+	server := newTestServer(t, map[string]string{"GOOGLE_CLIENT_ID": "google-client", "GOOGLE_CLIENT_SECRET": "secret"})
+	_, adminToken := server.createUser("admin@example.com", "admin")
+
+	rec := server.do(http.MethodGet, "/admin/system", adminToken, "", "Accept", "application/json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for _, key := range []string{"database", "sessions", "runtime", "providers", "uptime", "started_at"} {
+		if _, ok := body[key]; !ok {
+			t.Errorf("response has no %q", key)
+		}
+	}
+
+	var health struct {
+		Database struct {
+			Connected bool `json:"connected"`
+		} `json:"database"`
+		Providers map[string]bool `json:"providers"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !health.Database.Connected {
+		t.Error("database reported disconnected")
+	}
+	if !health.Providers["google"] || health.Providers["github"] {
+		t.Errorf("providers = %v, want only google configured", health.Providers)
+	}
+}
+
+func TestAdminUpdateUserRejectsStaleEdits(t *testing.T) {
+	s := newTestServer(t, nil)
+	_, firstAdmin := s.createUser("first@example.com", "admin")
+	_, secondAdmin := s.createUser("second@example.com", "admin")
+	user, _ := s.createUser("edited@example.com", "user")
+
+	// Both admins open the edit form at the same version
+	path := fmt.Sprintf("/admin/api/users/%d", user.ID)
+	edit := func(token, firstName string, version uint) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"first_name": %q, "last_name": "User", "email": %q, "role": "user", "version": %d}`, firstName, user.Email, version)
+		return s.do(http.MethodPut, path, token, body)
+	}
+
+	if rec := edit(firstAdmin, "First", user.Version); rec.Code != http.StatusOK {
+		t.Fatalf("first edit = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec := edit(secondAdmin, "Second", user.Version); rec.Code != http.StatusConflict {
+		t.Fatalf("stale edit = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body)
+	}
+
+	stored, err := repository.NewUserRepository().GetByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if stored.FirstName != "First" {
+		t.Errorf("first name = %q, want the first edit kept", stored.FirstName)
+	}
+
+	// Reloading picks up the new version, and the retried edit goes through
+	if rec := edit(secondAdmin, "Second", stored.Version); rec.Code != http.StatusOK {
+		t.Errorf("edit at the current version = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestAdminLinkedProviders(t *testing.T) {
+	s := newTestServer(t, nil)
+	_, adminToken := s.createUser("admin@example.com", "admin")
+	user, _ := s.createUser("linked@example.com", "user")
+
+	// Signs in only through Google and GitHub
+	if err := repository.GetDB().Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"password":   "",
+		"google_id":  "google-1",
+		"git_hub_id": "github-1",
+	}).Error; err != nil {
+		t.Fatalf("link providers: %v", err)
+	}
+
+	path := fmt.Sprintf("/admin/api/users/%d/providers", user.ID)
+	rec := s.do(http.MethodGet, path, adminToken, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d: %s", path, rec.Code, rec.Body)
+	}
+	var listed models.LinkedProvidersResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if listed.UserID != user.ID || listed.HasPassword || len(listed.Providers) != 2 {
+		t.Fatalf("providers = %+v, want google and github without a password", listed)
+	}
+
+	if rec := s.do(http.MethodDelete, path+"/github", adminToken, ""); rec.Code != http.StatusOK {
+		t.Fatalf("unlink github = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := s.do(http.MethodDelete, path+"/github", adminToken, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unlink github again = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	// Google is now the only way in
+	if rec := s.do(http.MethodDelete, path+"/google", adminToken, ""); rec.Code != http.StatusConflict {
+		t.Errorf("unlink the last sign-in method = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	stored, err := repository.NewUserRepository().GetByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if stored.GoogleID == nil || stored.GitHubID != nil {
+		t.Errorf("stored links = google %v, github %v; want only google", stored.GoogleID, stored.GitHubID)
+	}
+
+	_, userToken := s.createUser("other@example.com", "user")
+	if rec := s.do(http.MethodGet, path, userToken, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("GET %s as a user = %d, want %d", path, rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestAdminRevokeUserAPIKey(t *testing.T) {
+	s := newTestServer(t, nil)
+	_, adminToken := s.createUser("admin@example.com", "admin")
+	user, _ := s.createUser("owner@example.com", "user")
+	other, _ := s.createUser("other@example.com", "user")
+
+	key, created, err := s.svc.APIKeys.CreateKey(user.ID, models.CreateAPIKeyRequest{Name: "ci", Scopes: []string{models.ScopeUsersRead}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	if rec := s.do(http.MethodGet, "/api/v1/user", "", "", "X-API-Key", key); rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/v1/user with the key = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	path := fmt.Sprintf("/admin/api/users/%d/api-keys", user.ID)
+	rec := s.do(http.MethodGet, path, adminToken, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d: %s", path, rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), key) {
+		t.Error("the listing includes the key's secret")
+	}
+	var listed struct {
+		APIKeys []models.APIKeyResponse `json:"api_keys"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(listed.APIKeys) != 1 || listed.APIKeys[0].ID != created.ID || listed.APIKeys[0].Name != "ci" {
+		t.Fatalf("api_keys = %+v, want the ci key", listed.APIKeys)
+	}
+
+	// A key can only be revoked through the user who owns it
+	wrongOwner := fmt.Sprintf("/admin/api/users/%d/api-keys/%d", other.ID, created.ID)
+	if rec := s.do(http.MethodDelete, wrongOwner, adminToken, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE %s = %d, want %d", wrongOwner, rec.Code, http.StatusNotFound)
+	}
+
+	revoke := fmt.Sprintf("%s/%d", path, created.ID)
+	if rec := s.do(http.MethodDelete, revoke, adminToken, ""); rec.Code != http.StatusOK {
+		t.Fatalf("DELETE %s = %d: %s", revoke, rec.Code, rec.Body)
+	}
+	if rec := s.do(http.MethodGet, "/api/v1/user", "", "", "X-API-Key", key); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /api/v1/user with the revoked key = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAdminUsersByIDs(t *testing.T) {
+	s := newTestServer(t, nil)
+	admin, adminToken := s.createUser("admin@example.com", "admin")
+	first, userToken := s.createUser("first@example.com", "user")
+	second, _ := s.createUser("second@example.com", "user")
+
+	get := func(token, ids string) *httptest.ResponseRecorder {
+		return s.do(http.MethodGet, "/admin/api/users?ids="+ids, token, "")
+	}
+
+	// Missing IDs are left out, a repeated one is returned once, and the order follows the request
+	rec := get(adminToken, fmt.Sprintf("%d,9999,%d,%d,", second.ID, admin.ID, second.ID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var body struct {
+		Users []models.UserResponse `json:"users"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var got []string
+	for _, user := range body.Users {
+		got = append(got, user.Email)
+	}
+	if strings.Join(got, ",") != "second@example.com,admin@example.com" {
+		t.Errorf("users = %v, want second@example.com and admin@example.com", got)
+	}
+
+	ids := make([]string, services.MaxBatchUserIDs+1)
+	for i := range ids {
+		ids[i] = fmt.Sprint(first.ID)
+	}
+	if rec := get(adminToken, strings.Join(ids, ",")); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"max_ids"`) {
+		t.Errorf("over the cap = %d, want %d with max_ids: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+
+	for _, ids := range []string{"", "1,abc", "-1"} {
+		if rec := get(adminToken, ids); rec.Code != http.StatusBadRequest {
+			t.Errorf("ids=%q: status = %d, want %d", ids, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	if rec := get(userToken, fmt.Sprint(first.ID)); rec.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestAdminUnlockUser(t *testing.T) {
+	s := newTestServer(t, map[string]string{"LOGIN_THROTTLE_ACCOUNT_ATTEMPTS": "3", "LOGIN_THROTTLE_IP_ATTEMPTS": "100"})
+	_, adminToken := s.createUser("admin@example.com", "admin")
+	user, _ := s.createUser("locked@example.com", "user")
+	idle, _ := s.createUser("idle@example.com", "user")
+
+	login := func(password string) int {
+		body := fmt.Sprintf(`{"email": %q, "password": %q}`, user.Email, password)
+		return s.do(http.MethodPost, "/login", "", body).Code
+	}
+	lockout := func(id uint) models.LockoutResponse {
+		rec := s.do(http.MethodGet, fmt.Sprintf("/admin/api/users/%d/lockout", id), adminToken, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET lockout = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		var state models.LockoutResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return state
+	}
+	unlockAudits := func() int64 {
+		var count int64
+		if err := repository.GetDB().Model(&models.AuditLog{}).Where("action = ?", models.AuditActionLockoutClear).Count(&count).Error; err != nil {
+			t.Fatalf("count audit entries: %v", err)
+		}
+		return count
+	}
+
+	for i := 0; i < 3; i++ {
+		login("wrong-password")
+	}
+	if code := login(testPassword); code != http.StatusTooManyRequests {
+		t.Fatalf("sign-in while locked = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if state := lockout(user.ID); !state.Locked || state.FailedAttempts < 3 || state.LockedUntil == nil {
+		t.Fatalf("lockout = %+v, want locked with 3 failed attempts", state)
+	}
+
+	if rec := s.do(http.MethodPost, fmt.Sprintf("/admin/api/users/%d/unlock", user.ID), adminToken, ""); rec.Code != http.StatusOK {
+		t.Fatalf("unlock = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if state := lockout(user.ID); state.Locked || state.FailedAttempts != 0 {
+		t.Errorf("lockout after unlocking = %+v, want cleared", state)
+	}
+	if got := unlockAudits(); got != 1 {
+		t.Errorf("%d unlock audit entries, want 1", got)
+	}
+	if code := login(testPassword); code != http.StatusOK {
+		t.Errorf("sign-in after unlocking = %d, want %d", code, http.StatusOK)
+	}
+
+	// Unlocking an account without failed sign-ins changes nothing and is not audited
+	rec := s.do(http.MethodPost, fmt.Sprintf("/admin/api/users/%d/unlock", idle.ID), adminToken, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "nothing to clear") {
+		t.Errorf("unlock without a lockout = %d: %s, want 200 and nothing to clear", rec.Code, rec.Body)
+	}
+	if got := unlockAudits(); got != 1 {
+		t.Errorf("%d unlock audit entries after the no-op, want 1", got)
+	}
+
+	if rec := s.do(http.MethodPost, "/admin/api/users/9999/unlock", adminToken, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unlock of a missing user = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
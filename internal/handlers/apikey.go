@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/internal/middleware"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/services"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// ListKeys returns the current user's API keys without their secrets
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	keys, err := h.apiKeyService.ListKeys(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API keys"})
+		return
+	}
+
+	responses := make([]models.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		responses = append(responses, key.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": responses})
+}
+
+// CreateKey creates an API key and returns it once
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	rawKey, key, err := h.apiKeyService.CreateKey(user.ID, req)
+	if err != nil {
+		if err == services.ErrInvalidScope {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope specified"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "API key created. Copy it now; it will not be shown again.",
+		"api_key": key.ToResponse(),
+		"key":     rawKey,
+	})
+}
+
+// RevokeKey revokes one of the current user's API keys
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	key, err := h.apiKeyService.RevokeKey(user.ID, uint(keyID))
+	if err != nil {
+		if err == services.ErrAPIKeyNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked successfully",
+		"api_key": key.ToResponse(),
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/internal/middleware"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/services"
+	"sso-web-app/internal/timeutil"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// AuditLogs returns audit entries filtered by actor_id, target_id, action (comma-separated),
+// from and to (RFC 3339 or YYYY-MM-DD; to is exclusive), sorted by sort=newest|oldest
+// and paginated by page and page_size
+func (h *AuditHandler) AuditLogs(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	var filter models.AuditLogFilter
+	var err error
+	if filter.ActorID, err = parseOptionalID(c.Query("actor_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor_id"})
+		return
+	}
+	if filter.TargetID, err = parseOptionalID(c.Query("target_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target_id"})
+		return
+	}
+	if filter.From, err = parseOptionalTime(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return
+	}
+	if filter.To, err = parseOptionalTime(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return
+	}
+	if action := c.Query("action"); action != "" {
+		filter.Actions = strings.Split(action, ",")
+	}
+
+	switch c.DefaultQuery("sort", "newest") {
+	case "newest":
+	case "oldest":
+		filter.Oldest = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort: must be newest or oldest"})
+		return
+	}
+
+	logs, err := h.auditService.Query(adminUser, filter, page, pageSize)
+	if err != nil {
+		if err == services.ErrNotAuthorized {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Super admin privileges required"})
+			return
+		}
+		if err == services.ErrInvalidAuditAction || err == services.ErrInvalidDateRange {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, logs)
+}
+
+// LoginHistory returns the signed-in user's sign-in attempts, newest first,
+// paginated by page and page_size and filtered by outcome=success|failure
+func (h *AuditHandler) LoginHistory(c *gin.Context) {
+	user, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	history, err := h.auditService.LoginHistory(user.ID, c.Query("outcome"), page, pageSize)
+	if err != nil {
+		h.respondLoginHistoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// UserLoginHistory returns a user's sign-in attempts for admins, with the same
+// parameters as LoginHistory
+func (h *AuditHandler) UserLoginHistory(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	history, err := h.auditService.UserLoginHistory(adminUser, uint(userID), c.Query("outcome"), page, pageSize)
+	if err != nil {
+		h.respondLoginHistoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// respondLoginHistoryError maps login history errors to JSON responses
+func (h *AuditHandler) respondLoginHistoryError(c *gin.Context, err error) {
+	switch err {
+	case services.ErrInvalidLoginOutcome:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid outcome: must be success or failure"})
+	case services.ErrNotAuthorized:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+	case services.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load login history"})
+	}
+}
+
+// recordAudit stores an audit entry for the current request, attributed to the signed-in user
+func recordAudit(auditService *services.AuditService, c *gin.Context, action string, targetID uint, details string) {
+	actorID, _ := middleware.CurrentUserID(c)
+	auditService.Record(actorID, targetID, action, c.ClientIP(), details)
+}
+
+// parseOptionalID parses an ID query parameter, returning 0 when it is empty
+func parseOptionalID(value string) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	return uint(id), err
+}
+
+// parseOptionalTime parses an RFC 3339 timestamp or a YYYY-MM-DD date in the
+// application timezone, returning nil when value is empty
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, timeutil.Location())
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
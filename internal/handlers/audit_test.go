@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/services"
+)
+
+// loginHistoryPage decodes a login history response
+func loginHistoryPage(t *testing.T, body []byte) models.PageResponse[models.LoginHistoryResponse] {
+	t.Helper()
+	var page models.PageResponse[models.LoginHistoryResponse]
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatalf("decode login history: %v", err)
+	}
+	return page
+}
+
+func TestLoginRecordsHistory(t *testing.T) {
+	s := newTestServer(t, nil)
+	user, token := s.createUser("history@example.com", "user")
+
+	wrong := fmt.Sprintf(`{"email": %q, "password": "not-the-password"}`, user.Email)
+	if rec := s.do(http.MethodPost, "/login", "", wrong, "User-Agent", "history-test"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("POST /login with a wrong password = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	right := fmt.Sprintf(`{"email": %q, "password": %q}`, user.Email, testPassword)
+	if rec := s.do(http.MethodPost, "/login", "", right, "User-Agent", "history-test"); rec.Code != http.StatusOK {
+		t.Fatalf("POST /login = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	rec := s.do(http.MethodGet, "/profile/login-history", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /profile/login-history = %d, want %d", rec.Code, http.StatusOK)
+	}
+	page := loginHistoryPage(t, rec.Body.Bytes())
+
+	// Newest first
+	want := []string{models.LoginOutcomeSuccess, models.LoginOutcomeFailure}
+	if len(page.Items) != len(want) {
+		t.Fatalf("login history has %d entries, want %d", len(page.Items), len(want))
+	}
+	for i, outcome := range want {
+		entry := page.Items[i]
+		if entry.Outcome != outcome || entry.Method != services.AuthProviderPassword {
+			t.Errorf("entry %d = %s by %s, want %s by %s", i, entry.Outcome, entry.Method, outcome, services.AuthProviderPassword)
+		}
+		if entry.IPAddress != "192.0.2.1" || entry.UserAgent != "history-test" {
+			t.Errorf("entry %d came from %s (%s), want 192.0.2.1 (history-test)", i, entry.IPAddress, entry.UserAgent)
+		}
+	}
+	if page.Items[1].Reason != models.FailedLoginInvalidPassword {
+		t.Errorf("failure reason = %q, want %q", page.Items[1].Reason, models.FailedLoginInvalidPassword)
+	}
+}
+
+func TestLoginHistoryEndpoints(t *testing.T) {
+	s := newTestServer(t, nil)
+	alice, aliceToken := s.createUser("alice@example.com", "user")
+	bob, bobToken := s.createUser("bob@example.com", "user")
+	_, adminToken := s.createUser("admin@example.com", "admin")
+
+	history := repository.NewLoginHistoryRepository()
+	ids := make(map[uint][]uint)
+	for _, entry := range []*models.LoginHistory{
+		{UserID: alice.ID, Method: services.AuthProviderPassword, Outcome: models.LoginOutcomeFailure, Reason: models.FailedLoginInvalidPassword},
+		{UserID: bob.ID, Method: services.AuthProviderGoogle, Outcome: models.LoginOutcomeSuccess},
+		{UserID: alice.ID, Method: services.AuthProviderPassword, Outcome: models.LoginOutcomeSuccess},
+		{UserID: bob.ID, Method: services.AuthProviderPassword, Outcome: models.LoginOutcomeFailure, Reason: models.FailedLoginInvalidPassword},
+	} {
+		if err := history.Create(entry); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		// Prepended, so each user's list is newest first
+		ids[entry.UserID] = append([]uint{entry.ID}, ids[entry.UserID]...)
+	}
+
+	adminPath := func(id uint) string { return fmt.Sprintf("/admin/api/users/%d/login-history", id) }
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+		ids   []uint
+	}{
+		{"own history", "/profile/login-history", aliceToken, http.StatusOK, ids[alice.ID]},
+		{"another user's own history", "/profile/login-history", bobToken, http.StatusOK, ids[bob.ID]},
+		{"own failures", "/profile/login-history?outcome=failure", bobToken, http.StatusOK, ids[bob.ID][:1]},
+		{"own, invalid outcome", "/profile/login-history?outcome=bogus", aliceToken, http.StatusBadRequest, nil},
+		{"admin reading a user", adminPath(bob.ID), adminToken, http.StatusOK, ids[bob.ID]},
+		{"admin reading successes", adminPath(alice.ID) + "?outcome=success", adminToken, http.StatusOK, ids[alice.ID][:1]},
+		{"admin, invalid outcome", adminPath(alice.ID) + "?outcome=bogus", adminToken, http.StatusBadRequest, nil},
+		{"admin, unknown user", adminPath(9999), adminToken, http.StatusNotFound, nil},
+		{"user on the admin route", adminPath(bob.ID), aliceToken, http.StatusForbidden, nil},
+		{"signed out", "/profile/login-history", "", http.StatusUnauthorized, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodGet, tt.path, tt.token, "", "Accept", "application/json")
+			if rec.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d: %s", tt.path, rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			page := loginHistoryPage(t, rec.Body.Bytes())
+			got := make([]uint, 0, len(page.Items))
+			for _, entry := range page.Items {
+				got = append(got, entry.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.ids) || page.Total != int64(len(tt.ids)) {
+				t.Errorf("entries = %v (total %d), want %v", got, page.Total, tt.ids)
+			}
+		})
+	}
+}
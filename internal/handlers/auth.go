@@ -1,187 +1,1016 @@
 package handlers
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"sso-web-app/internal/cryptoutil"
 	"sso-web-app/internal/middleware"
 	"sso-web-app/internal/models"
 	"sso-web-app/internal/services"
 )
 
 type AuthHandler struct {
-	authService  *services.AuthService
-	oauthService *services.OAuthService
+	authService    *services.AuthService
+	oauthService   *services.OAuthService
+	deviceService  *services.DeviceService
+	sessionService *services.SessionService
+	auditService   *services.AuditService
 }
 
-func NewAuthHandler(authService *services.AuthService, oauthService *services.OAuthService) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService, oauthService *services.OAuthService, deviceService *services.DeviceService, sessionService *services.SessionService, auditService *services.AuditService) *AuthHandler {
 	return &AuthHandler{
-		authService:  authService,
-		oauthService: oauthService,
+		authService:    authService,
+		oauthService:   oauthService,
+		deviceService:  deviceService,
+		sessionService: sessionService,
+		auditService:   auditService,
 	}
 }
 
 // Home renders the home page
 func (h *AuthHandler) Home(c *gin.Context) {
 	c.HTML(http.StatusOK, "index.html", gin.H{
-		"title": "SSO Web Application",
+		"title":                "SSO Web Application",
+		"registrationDisabled": !h.authService.RegistrationEnabled(),
 	})
 }
 
 // LoginPage renders the login page
 func (h *AuthHandler) LoginPage(c *gin.Context) {
-	c.HTML(http.StatusOK, "login.html", gin.H{
-		"title": "Login",
-	})
+	c.HTML(http.StatusOK, "login.html", h.authPage(gin.H{
+		"title":                "Login",
+		"registrationDisabled": !h.authService.RegistrationEnabled(),
+	}))
 }
 
 // RegisterPage renders the registration page
 func (h *AuthHandler) RegisterPage(c *gin.Context) {
-	c.HTML(http.StatusOK, "register.html", gin.H{
-		"title": "Register",
+	if !h.authService.RegistrationEnabled() {
+		c.HTML(http.StatusForbidden, "register.html", h.authPage(gin.H{
+			"title":                "Register",
+			"registrationDisabled": true,
+		}))
+		return
+	}
+
+	if h.authService.InviteOnly() {
+		token := c.Query("invite")
+		invitation, err := h.authService.LookupInvitation(token)
+		if err != nil {
+			c.HTML(http.StatusForbidden, "register.html", h.authPage(gin.H{
+				"title":       "Register",
+				"inviteError": invitationErrorMessage(err),
+			}))
+			return
+		}
+
+		c.HTML(http.StatusOK, "register.html", h.authPage(gin.H{
+			"title":         "Register",
+			"inviteToken":   token,
+			"inviteEmail":   invitation.Email,
+			"honeypotField": h.authService.HoneypotField(),
+		}))
+		return
+	}
+
+	c.HTML(http.StatusOK, "register.html", h.authPage(gin.H{
+		"title":         "Register",
+		"honeypotField": h.authService.HoneypotField(),
+	}))
+}
+
+// Login handles user login
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, err := h.authService.Login(req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if err == services.ErrLoginThrottled {
+			h.respondLoginThrottled(c, req.Email)
+			return
+		}
+		if err == services.ErrPasswordExpired {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":    "Your password has expired. Please choose a new one.",
+				"code":     "password_expired",
+				"redirect": "/password/change",
+			})
+			return
+		}
+		if err == services.ErrEmailNotVerified {
+			respondEmailNotVerified(c)
+			return
+		}
+		if err == services.ErrAccountNotYetActive {
+			middleware.RespondNotYetActive(c, user)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	evicted, err := h.startSession(c, user, token)
+	if err != nil {
+		h.respondSessionError(c, err)
+		return
+	}
+
+	h.auditService.RecordLogin(user.ID, services.AuthProviderPassword, c.ClientIP(), c.Request.UserAgent())
+
+	response := gin.H{
+		"message":          "Login successful",
+		"user":             user.ToResponse(),
+		"evicted_sessions": evicted,
+	}
+	h.addToken(response, token)
+
+	// API clients that identify their device get a refresh token bound to it
+	if deviceID := c.GetHeader(deviceIDHeader); deviceID != "" {
+		refreshToken, err := h.sessionService.IssueRefreshToken(user, token, deviceID, c.Request.UserAgent())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue refresh token"})
+			return
+		}
+		response["refresh_token"] = refreshToken
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// deviceIDHeader carries the client-generated ID refresh tokens are bound to
+const deviceIDHeader = "X-Device-ID"
+
+// RefreshSession exchanges a refresh token for a new session token and refresh
+// token. It must come from the device the refresh token was issued to.
+func (h *AuthHandler) RefreshSession(c *gin.Context) {
+	var req models.RefreshSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refresh, err := h.sessionService.Refresh(req.RefreshToken, c.GetHeader(deviceIDHeader), c.Request.UserAgent())
+	if err != nil {
+		if err == services.ErrRefreshDeviceMismatch {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "This refresh token belongs to another device. Please sign in again.",
+				"code":  "device_mismatch",
+			})
+			return
+		}
+		if err == services.ErrInvalidRefreshToken {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Refresh token is invalid or has expired. Please sign in again.",
+				"code":  "invalid_refresh_token",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":          refresh.User.ToResponse(),
+		"token":         refresh.Token,
+		"refresh_token": refresh.RefreshToken,
+	})
+}
+
+// Register handles user registration
+func (h *AuthHandler) Register(c *gin.Context) {
+	if !h.authService.RegistrationEnabled() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is disabled. Please sign in with a linked provider or contact an administrator."})
+		return
+	}
+
+	var req models.RegisterRequest
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A filled honeypot means a bot: pretend the sign-up worked so it moves on
+	if h.honeypotFilled(c) {
+		log.Printf("Registration honeypot filled from %s; sign-up discarded", c.ClientIP())
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Account created. Check your email for a link to verify your address, then sign in.",
+		})
+		return
+	}
+
+	user, err := h.authService.Register(req)
+
+	// Taken and new emails get the same answer, without a session, so the response
+	// cannot be used to discover registered addresses
+	if h.authService.EnumerationSafeSignup() && (err == nil || err == services.ErrUserExists) {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Thanks for signing up. Please sign in to continue. If you already have an account, we've sent you an email.",
+		})
+		return
+	}
+
+	if err != nil {
+		if err == services.ErrPasswordBreached {
+			respondPasswordBreached(c)
+			return
+		}
+		if err == services.ErrPasswordTooShort || err == services.ErrPasswordTooLong {
+			respondPasswordPolicy(c, err)
+			return
+		}
+		if err == services.ErrUserExists {
+			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+			return
+		}
+		if err == services.ErrEmailDeleted {
+			respondEmailDeleted(c)
+			return
+		}
+		if err == services.ErrDisposableEmail {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == services.ErrTermsNotAccepted {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "terms_not_accepted"})
+			return
+		}
+		if message := invitationErrorMessage(err); message != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Accounts that must verify their email get no session until they do
+	if h.authService.VerificationPending(user) {
+		h.auditService.Record(user.ID, user.ID, models.AuditActionRegister, c.ClientIP(), "")
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Account created. Check your email for a link to verify your address, then sign in.",
+		})
+		return
+	}
+
+	// Generate JWT token for the new user
+	token, err := h.authService.GenerateJWT(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	evicted, err := h.startSession(c, user, token)
+	if err != nil {
+		h.respondSessionError(c, err)
+		return
+	}
+
+	h.auditService.Record(user.ID, user.ID, models.AuditActionRegister, c.ClientIP(), "")
+
+	c.JSON(http.StatusCreated, h.addToken(gin.H{
+		"message":          "Registration successful",
+		"user":             user.ToResponse(),
+		"evicted_sessions": evicted,
+	}, token))
+}
+
+// honeypotFilled reports whether the registration body has a non-empty value in the
+// honeypot field. It is always false when the honeypot is disabled.
+func (h *AuthHandler) honeypotFilled(c *gin.Context) bool {
+	field := h.authService.HoneypotField()
+	if field == "" {
+		return false
+	}
+
+	var body map[string]interface{}
+	if err := c.ShouldBindBodyWith(&body, binding.JSON); err != nil {
+		return false
+	}
+	value, ok := body[field]
+	if !ok || value == nil {
+		return false
+	}
+	if s, isString := value.(string); isString {
+		return strings.TrimSpace(s) != ""
+	}
+	return true
+}
+
+// VerifyEmail confirms an email address from the link in the verification email
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	if _, err := h.authService.VerifyEmail(c.Query("token")); err != nil {
+		if err == services.ErrInvalidVerificationToken {
+			h.renderLoginError(c, http.StatusBadRequest, "This verification link is invalid or has expired. Sign in to request a new one.")
+			return
+		}
+		h.renderLoginError(c, http.StatusInternalServerError, "Failed to verify your email address. Please try again.")
+		return
+	}
+
+	c.HTML(http.StatusOK, "login.html", h.authPage(gin.H{
+		"title":                "Login",
+		"notice":               "Your email address is verified. You can now sign in.",
+		"registrationDisabled": !h.authService.RegistrationEnabled(),
+	}))
+}
+
+// VerifyRecoveryEmail confirms a recovery email from the link mailed to it
+func (h *AuthHandler) VerifyRecoveryEmail(c *gin.Context) {
+	if _, err := h.authService.VerifyRecoveryEmail(c.Query("token")); err != nil {
+		if err == services.ErrInvalidVerificationToken {
+			h.renderLoginError(c, http.StatusBadRequest, "This verification link is invalid or has expired. Set your recovery email again from your profile to get a new one.")
+			return
+		}
+		h.renderLoginError(c, http.StatusInternalServerError, "Failed to verify your recovery email. Please try again.")
+		return
+	}
+
+	c.HTML(http.StatusOK, "login.html", h.authPage(gin.H{
+		"title":                "Login",
+		"notice":               "Your recovery email is verified.",
+		"registrationDisabled": !h.authService.RegistrationEnabled(),
+	}))
+}
+
+// ResendVerification mails a new verification link. The answer is the same whether
+// or not the address has an account awaiting verification.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req models.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.authService.ResendVerification(req.Email)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If that address has an account awaiting verification, we've sent a new link.",
+	})
+}
+
+// ChangePasswordPage renders the change password page
+func (h *AuthHandler) ChangePasswordPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "change-password.html", gin.H{
+		"title":   "Change Password",
+		"email":   c.Query("email"),
+		"expired": c.Query("expired") != "",
+	})
+}
+
+// ChangePassword replaces the current password and signs the user in
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, err := h.authService.ChangePassword(req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if err == services.ErrLoginThrottled {
+			h.respondLoginThrottled(c, req.Email)
+			return
+		}
+		if err == services.ErrInvalidCredentials {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current email or password is incorrect"})
+			return
+		}
+		if err == services.ErrPasswordUnchanged {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "New password must differ from the current password"})
+			return
+		}
+		if err == services.ErrPasswordBreached {
+			respondPasswordBreached(c)
+			return
+		}
+		if err == services.ErrPasswordTooShort || err == services.ErrPasswordTooLong {
+			respondPasswordPolicy(c, err)
+			return
+		}
+		if err == services.ErrEmailNotVerified {
+			respondEmailNotVerified(c)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	evicted, err := h.startSession(c, user, token)
+	if err != nil {
+		h.respondSessionError(c, err)
+		return
+	}
+
+	h.auditService.Record(user.ID, user.ID, models.AuditActionPasswordChange, c.ClientIP(), "")
+
+	c.JSON(http.StatusOK, h.addToken(gin.H{
+		"message":          "Password changed successfully",
+		"user":             user.ToResponse(),
+		"evicted_sessions": evicted,
+	}, token))
+}
+
+// Logout handles user logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	// Revoke the current token so copies of it stop working too
+	if token, err := c.Cookie("jwt"); err == nil && token != "" {
+		if claims, err := h.authService.ValidateJWT(token); err == nil {
+			h.auditService.Record(claims.UserID, claims.UserID, models.AuditActionLogout, c.ClientIP(), "")
+		}
+		h.authService.RevokeJWT(token)
+	}
+
+	// Clear JWT cookie
+	h.clearSessionCookie(c)
+	
+	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
+}
+
+// Reauth verifies the current password and refreshes the session's auth time
+func (h *AuthHandler) Reauth(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req models.ReauthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.authService.Reauthenticate(user.ID, req.Password)
+	if err != nil {
+		h.respondPasswordCheckError(c, user.ID, err)
+		return
+	}
+
+	if !h.refreshSession(c, token) {
+		return
+	}
+
+	c.JSON(http.StatusOK, h.addToken(gin.H{
+		"message": "Re-authentication successful",
+	}, token))
+}
+
+// VerifyPassword confirms the signed-in user knows their password, for step-up
+// prompts in the frontend. A correct password also counts as re-authentication:
+// the session moves to a token with a fresh auth time, as with Reauth. Wrong
+// passwords share Reauth's per-user limit.
+func (h *AuthHandler) VerifyPassword(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req models.ReauthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.authService.Reauthenticate(user.ID, req.Password)
+	if err == services.ErrInvalidCredentials {
+		c.JSON(http.StatusOK, gin.H{"valid": false})
+		return
+	}
+	if err != nil {
+		h.respondPasswordCheckError(c, user.ID, err)
+		return
+	}
+
+	if !h.refreshSession(c, token) {
+		return
+	}
+
+	c.JSON(http.StatusOK, h.addToken(gin.H{
+		"valid": true,
+	}, token))
+}
+
+// respondPasswordCheckError answers a failed Reauthenticate call
+func (h *AuthHandler) respondPasswordCheckError(c *gin.Context, userID uint, err error) {
+	switch err {
+	case services.ErrPasswordCheckThrottled:
+		retryAfter := h.authService.PasswordCheckRetryAfter(userID)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "Too many password attempts. Please wait and try again.",
+			"code":  "password_check_throttled",
+		})
+	case services.ErrNoLocalPassword:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "This account has no password. Sign in again with a linked Google or GitHub account instead.",
+			"code":  "no_local_password",
+		})
+	default:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	}
+}
+
+// refreshSession moves the current session to a freshly authenticated token and
+// replaces the session cookie. It responds and returns false on failure.
+func (h *AuthHandler) refreshSession(c *gin.Context, token string) bool {
+	// The session moves to the new token; the old one stops working
+	if claims := middleware.CurrentClaims(c); claims != nil {
+		if err := h.sessionService.Rotate(claims, token); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session"})
+			return false
+		}
+	}
+
+	// Replace the session cookie with the freshly authenticated token
+	h.setSessionCookie(c, token)
+	return true
+}
+
+// Dashboard renders the user dashboard
+func (h *AuthHandler) Dashboard(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	c.HTML(http.StatusOK, "dashboard.html", gin.H{
+		"title": "Dashboard",
+		"user":  user.ToResponse(),
+	})
+}
+
+// Profile renders the user profile page
+func (h *AuthHandler) Profile(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	claims := middleware.CurrentClaims(c)
+
+	c.HTML(http.StatusOK, "profile.html", gin.H{
+		"title":              "Profile",
+		"user":               user.ToResponse(),
+		"canRecoverPassword": claims != nil && h.authService.PasswordRecoveryAvailable(claims),
+		"emailMissing":       user.EmailMissing,
+		"recoveryEmail":      user.ToResponse().RecoveryEmail,
+		"recoveryVerified":   user.RecoveryEmailVerified,
+		"recoveryNotify":     user.RecoveryNotifications,
+	})
+}
+
+// RecoverPassword sets a new password for a user who has just signed in through a
+// linked provider, without asking for the lost one
+func (h *AuthHandler) RecoverPassword(c *gin.Context) {
+	claims := middleware.CurrentClaims(c)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req models.RecoverPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, err := h.authService.RecoverPassword(claims, req.NewPassword)
+	if err != nil {
+		if err == services.ErrProviderReauthRequired {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Sign in again with a linked Google or GitHub account to reset your password",
+				"code":  "provider_reauth_required",
+			})
+			return
+		}
+		if err == services.ErrNoLocalPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This account has no password to reset"})
+			return
+		}
+		if err == services.ErrPasswordBreached {
+			respondPasswordBreached(c)
+			return
+		}
+		if err == services.ErrPasswordTooShort || err == services.ErrPasswordTooLong {
+			respondPasswordPolicy(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	// The session moves to the new token; the old one stops working
+	if err := h.sessionService.Rotate(claims, token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session"})
+		return
+	}
+	h.setSessionCookie(c, token)
+
+	h.auditService.Record(user.ID, user.ID, models.AuditActionPasswordChange, c.ClientIP(), "recovered via "+claims.AuthProvider)
+
+	c.JSON(http.StatusOK, h.addToken(gin.H{
+		"message": "Password reset successfully",
+		"user":    user.ToResponse(),
+	}, token))
+}
+
+// UpdateProfile handles profile updates
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedUser, err := h.authService.UpdateProfile(user.ID, req)
+	if err != nil {
+		if err == services.ErrInvalidWebsite || err == services.ErrWebsiteTooLong {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Profile updated successfully",
+		"user":    updatedUser.ToResponse(),
+	})
+}
+
+// AddEmail sets the email of an account that was created without one
+func (h *AuthHandler) AddEmail(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req models.AddEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedUser, err := h.authService.AddMissingEmail(user.ID, req.Email)
+	if err != nil {
+		if err == services.ErrEmailAlreadySet {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Your account already has an email address"})
+			return
+		}
+		if err == services.ErrUserExists {
+			c.JSON(http.StatusConflict, gin.H{"error": "That email address is already in use"})
+			return
+		}
+		if err == services.ErrEmailDeleted {
+			respondEmailDeleted(c)
+			return
+		}
+		if err == services.ErrDisposableEmail {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email added. Check your inbox for a verification link.",
+		"user":    updatedUser.ToResponse(),
+	})
+}
+
+// SetRecoveryEmail sets or removes the signed-in user's recovery email
+func (h *AuthHandler) SetRecoveryEmail(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req models.SetRecoveryEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedUser, err := h.authService.SetRecoveryEmail(user.ID, req.Email, req.Notify)
+	if err != nil {
+		if err == services.ErrRecoveryEmailIsPrimary || err == services.ErrRecoveryEmailInUse || err == services.ErrDisposableEmail {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == services.ErrVerificationCooldown {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(services.RecoveryEmailRetryAfter(user).Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "A verification link was sent recently. Please wait before requesting another."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set recovery email"})
+		return
+	}
+
+	message := "Recovery email removed."
+	if updatedUser.RecoveryEmail != nil {
+		message = "Recovery email saved."
+		if !updatedUser.RecoveryEmailVerified {
+			message = "Check your recovery email for a verification link."
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"user":    updatedUser.ToResponse(),
 	})
 }
 
-// Login handles user login
-func (h *AuthHandler) Login(c *gin.Context) {
-	var req models.LoginRequest
+// SetPhoneNumber sets or removes the user's phone number; a new number is sent a
+// verification code by SMS
+func (h *AuthHandler) SetPhoneNumber(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var req models.SetPhoneRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	token, user, err := h.authService.Login(req)
+	updatedUser, err := h.authService.SetPhoneNumber(user.ID, req.Phone)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		if err == services.ErrPhoneVerificationDisabled {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err == services.ErrInvalidPhoneNumber {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err == services.ErrVerificationCooldown {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(services.PhoneCodeRetryAfter(user).Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "A verification code was sent recently. Please wait before requesting another."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set phone number"})
 		return
 	}
 
-	// Set JWT token as HTTP-only cookie
-	c.SetCookie("jwt", token, int(time.Hour*24*7/time.Second), "/", "", false, true)
+	message := "Phone number removed."
+	if updatedUser.PhoneNumber != nil {
+		message = "Phone number saved."
+		if !updatedUser.PhoneVerified {
+			message = "Enter the code we texted to your phone to verify it."
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"user":    user.ToResponse(),
-		"token":   token,
+		"message": message,
+		"user":    updatedUser.ToResponse(),
 	})
 }
 
-// Register handles user registration
-func (h *AuthHandler) Register(c *gin.Context) {
-	var req models.RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// VerifyPhoneNumber confirms the user's phone number with the code texted to it
+func (h *AuthHandler) VerifyPhoneNumber(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
 		return
 	}
 
-	user, err := h.authService.Register(req)
-	if err != nil {
-		if err == services.ErrUserExists {
-			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var req models.VerifyPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Enter the 6-digit code from the text message"})
 		return
 	}
 
-	// Generate JWT token for the new user
-	token, err := h.authService.GenerateJWT(user)
+	updatedUser, err := h.authService.VerifyPhoneNumber(user.ID, req.Code)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		switch err {
+		case services.ErrPhoneVerificationDisabled:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case services.ErrInvalidPhoneCode:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "invalid_phone_code"})
+		case services.ErrPhoneCodeExpired:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "phone_code_expired"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify phone number"})
+		}
 		return
 	}
 
-	// Set JWT token as HTTP-only cookie
-	c.SetCookie("jwt", token, int(time.Hour*24*7/time.Second), "/", "", false, true)
-
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Registration successful",
-		"user":    user.ToResponse(),
-		"token":   token,
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Phone number verified.",
+		"user":    updatedUser.ToResponse(),
 	})
 }
 
-// Logout handles user logout
-func (h *AuthHandler) Logout(c *gin.Context) {
-	// Clear JWT cookie
-	c.SetCookie("jwt", "", -1, "/", "", false, true)
-	
-	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
-}
-
-// Dashboard renders the user dashboard
-func (h *AuthHandler) Dashboard(c *gin.Context) {
-	user := middleware.GetUserFromContext(c)
+// TermsPage shows the current terms of service and whether the user has accepted
+// them, as a page with an accept button for browsers and as JSON otherwise
+func (h *AuthHandler) TermsPage(c *gin.Context) {
+	user := middleware.CurrentUser(c)
 	if user == nil {
-		c.Redirect(http.StatusFound, "/login")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	if !h.authService.TermsRequired() {
+		respondError(c, http.StatusNotFound, "Not Found", "The page you requested does not exist.")
 		return
 	}
 
-	c.HTML(http.StatusOK, "dashboard.html", gin.H{
-		"title": "Dashboard",
-		"user":  user.ToResponse(),
-	})
-}
+	data := gin.H{
+		"terms_version":          h.authService.TermsVersion(),
+		"terms_url":              h.authService.TermsURL(),
+		"accepted_terms_version": user.AcceptedTermsVersion,
+		"accepted_terms_at":      models.NewTimestampPtr(user.AcceptedTermsAt),
+		"acceptance_required":    h.authService.TermsOutdated(user),
+	}
 
-// Profile renders the user profile page
-func (h *AuthHandler) Profile(c *gin.Context) {
-	user := middleware.GetUserFromContext(c)
-	if user == nil {
-		c.Redirect(http.StatusFound, "/login")
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(http.StatusOK, data)
 		return
 	}
 
-	c.HTML(http.StatusOK, "profile.html", gin.H{
-		"title": "Profile",
-		"user":  user.ToResponse(),
-	})
+	data["title"] = "Terms of Service"
+	c.HTML(http.StatusOK, "terms.html", data)
 }
 
-// UpdateProfile handles profile updates
-func (h *AuthHandler) UpdateProfile(c *gin.Context) {
-	user := middleware.GetUserFromContext(c)
+// AcceptTerms records that the user accepted the current terms of service
+func (h *AuthHandler) AcceptTerms(c *gin.Context) {
+	user := middleware.CurrentUser(c)
 	if user == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
 		return
 	}
 
-	var req models.UpdateProfileRequest
+	var req models.AcceptTermsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	updatedUser, err := h.authService.UpdateProfile(user.ID, req)
+	wasOutdated := h.authService.TermsOutdated(user)
+	updatedUser, err := h.authService.AcceptTerms(user.ID, req.TermsVersion)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err == services.ErrTermsNotRequired {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err == services.ErrTermsVersionMismatch {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":         err.Error(),
+				"code":          "terms_version_changed",
+				"terms_version": h.authService.TermsVersion(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record terms acceptance"})
 		return
 	}
 
+	if wasOutdated {
+		h.auditService.Record(user.ID, user.ID, models.AuditActionTermsAccept, c.ClientIP(), "version "+updatedUser.AcceptedTermsVersion)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Profile updated successfully",
+		"message": "Thank you for accepting the terms of service.",
 		"user":    updatedUser.ToResponse(),
 	})
 }
 
+// Providers lists the OAuth providers available for sign-in (API endpoint)
+func (h *AuthHandler) Providers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"providers": h.oauthService.AvailableProviders(),
+	})
+}
+
+// PasswordStrength scores a candidate password against the password policy so
+// sign-up forms can give live feedback (API endpoint). Nothing is stored.
+func (h *AuthHandler) PasswordStrength(c *gin.Context) {
+	var req models.PasswordStrengthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A password of at most 1024 characters is required"})
+		return
+	}
+
+	strength, err := h.authService.CheckPasswordStrength(req.Password, c.ClientIP())
+	if err != nil {
+		if err == services.ErrPasswordStrengthLimited {
+			retryAfter := h.authService.PasswordStrengthRetryAfter(c.ClientIP())
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Too many password strength checks. Please wait and try again.",
+				"code":  "password_strength_throttled",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check password strength"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, strength)
+}
+
 // GetUser returns current user information (API endpoint)
 func (h *AuthHandler) GetUser(c *gin.Context) {
-	user := middleware.GetUserFromContext(c)
+	user := middleware.CurrentUser(c)
 	if user == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
 		return
 	}
 
+	// Let polling clients revalidate cheaply instead of downloading the profile again
+	etag := user.ETag()
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, must-revalidate")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	// Clients that need only some fields can ask for them to save bandwidth
+	fields := parseFieldList(c.Query("fields"))
+	if len(fields) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"user": user.ToResponse(),
+		})
+		return
+	}
+
+	selected, unknown, err := user.ToResponse().SelectFields(fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+	if len(unknown) > 0 && h.authService.RejectUnknownFields() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":          "Unknown fields requested",
+			"unknown_fields": unknown,
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"user": user.ToResponse(),
+		"user": selected,
 	})
 }
 
+// parseFieldList splits a comma-separated fields parameter, dropping blanks
+func parseFieldList(value string) []string {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// ExportData downloads everything stored about the current user (GDPR export)
+func (h *AuthHandler) ExportData(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	export, err := h.authService.ExportUserData(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+		return
+	}
+
+	writeExport(c, user.ID, export)
+}
+
 // UpdateUser handles user updates via API
 func (h *AuthHandler) UpdateUser(c *gin.Context) {
-	user := middleware.GetUserFromContext(c)
+	user := middleware.CurrentUser(c)
 	if user == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
 		return
@@ -195,6 +1024,10 @@ func (h *AuthHandler) UpdateUser(c *gin.Context) {
 
 	updatedUser, err := h.authService.UpdateProfile(user.ID, req)
 	if err != nil {
+		if err == services.ErrInvalidWebsite || err == services.ErrWebsiteTooLong {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -207,10 +1040,26 @@ func (h *AuthHandler) UpdateUser(c *gin.Context) {
 
 // GoogleLogin initiates Google OAuth login
 func (h *AuthHandler) GoogleLogin(c *gin.Context) {
-	state := h.generateState()
-	c.SetCookie("oauth_state", state, 600, "/", "", false, true) // 10 minutes
-	
-	authURL := h.oauthService.GetGoogleAuthURL(state)
+	state, err := h.oauthService.BeginState(services.AuthProviderGoogle)
+	if err != nil {
+		h.renderLoginError(c, http.StatusInternalServerError, "Failed to start Google sign-in. Please try again.")
+		return
+	}
+	c.SetCookie("oauth_state", state, int(services.OAuthStateTTL.Seconds()), "/", "", h.sessionService.CookieSecure(), true)
+
+	authURL, err := h.oauthService.GetGoogleAuthURL(state)
+	if err != nil {
+		if errors.Is(err, services.ErrProviderNotConfigured) {
+			h.renderLoginError(c, http.StatusNotFound, "Google sign-in is not set up on this site. Please use another sign-in method.")
+			return
+		}
+		if errors.Is(err, services.ErrProviderUnavailable) {
+			h.renderLoginError(c, http.StatusServiceUnavailable, "Google is temporarily unavailable. Please try again in a few minutes or use another sign-in method.")
+			return
+		}
+		h.renderLoginError(c, http.StatusServiceUnavailable, "Google sign-in is not available right now. Please use another sign-in method.")
+		return
+	}
 	c.Redirect(http.StatusTemporaryRedirect, authURL)
 }
 
@@ -219,13 +1068,13 @@ func (h *AuthHandler) GoogleCallback(c *gin.Context) {
 	// Verify state parameter
 	state := c.Query("state")
 	savedState, err := c.Cookie("oauth_state")
-	if err != nil || state != savedState {
+	if err != nil || state == "" || !cryptoutil.Equal(state, savedState) || !h.oauthService.ConsumeState(services.AuthProviderGoogle, state) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state parameter"})
 		return
 	}
 
 	// Clear state cookie
-	c.SetCookie("oauth_state", "", -1, "/", "", false, true)
+	c.SetCookie("oauth_state", "", -1, "/", "", h.sessionService.CookieSecure(), true)
 
 	// Handle authorization code
 	code := c.Query("code")
@@ -234,14 +1083,49 @@ func (h *AuthHandler) GoogleCallback(c *gin.Context) {
 		return
 	}
 
-	token, _, err := h.oauthService.HandleGoogleCallback(code)
+	token, user, err := h.oauthService.HandleGoogleCallback(c.Request.Context(), code, middleware.CurrentUser(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, services.ErrLinkConfirmationRequired) {
+			h.renderLinkConfirmationSent(c, "Google")
+			return
+		}
+		if errors.Is(err, services.ErrProviderTimeout) {
+			log.Printf("Google sign-in failed: %v", err)
+			h.renderLoginError(c, http.StatusGatewayTimeout, "Google didn't respond in time. Please try signing in again.")
+			return
+		}
+		if errors.Is(err, services.ErrProviderUnavailable) {
+			log.Printf("Google sign-in failed: %v", err)
+			h.renderLoginError(c, http.StatusServiceUnavailable, "Google is temporarily unavailable. Please try again in a few minutes or use another sign-in method.")
+			return
+		}
+		if errors.Is(err, services.ErrDomainNotAllowed) {
+			h.renderLoginError(c, http.StatusForbidden, "Your email domain is not allowed to sign in with Google.")
+			return
+		}
+		if errors.Is(err, services.ErrRedirectHostNotAllowed) {
+			h.renderLoginError(c, http.StatusServiceUnavailable, "Google sign-in is not available right now. Please use another sign-in method.")
+			return
+		}
+		if errors.Is(err, services.ErrAccountNotYetActive) {
+			h.renderLoginError(c, http.StatusForbidden, "Your account is not active yet. Please sign in once your access begins.")
+			return
+		}
+		log.Printf("Google sign-in failed: %v", err)
+		h.renderLoginError(c, http.StatusInternalServerError, "Something went wrong signing in with Google. Please try again or use another sign-in method.")
 		return
 	}
 
-	// Set JWT token as HTTP-only cookie
-	c.SetCookie("jwt", token, int(time.Hour*24*7/time.Second), "/", "", false, true)
+	if _, err := h.startSession(c, user, token); err != nil {
+		if err == services.ErrSessionLimitReached {
+			h.renderLoginError(c, http.StatusConflict, "You have reached the maximum number of active sessions. Sign out on another device and try again.")
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	h.auditService.RecordLogin(user.ID, services.AuthProviderGoogle, c.ClientIP(), c.Request.UserAgent())
 
 	// Redirect to dashboard
 	c.Redirect(http.StatusFound, "/dashboard")
@@ -249,10 +1133,26 @@ func (h *AuthHandler) GoogleCallback(c *gin.Context) {
 
 // GitHubLogin initiates GitHub OAuth login
 func (h *AuthHandler) GitHubLogin(c *gin.Context) {
-	state := h.generateState()
-	c.SetCookie("oauth_state", state, 600, "/", "", false, true) // 10 minutes
-	
-	authURL := h.oauthService.GetGitHubAuthURL(state)
+	state, err := h.oauthService.BeginState(services.AuthProviderGitHub)
+	if err != nil {
+		h.renderLoginError(c, http.StatusInternalServerError, "Failed to start GitHub sign-in. Please try again.")
+		return
+	}
+	c.SetCookie("oauth_state", state, int(services.OAuthStateTTL.Seconds()), "/", "", h.sessionService.CookieSecure(), true)
+
+	authURL, err := h.oauthService.GetGitHubAuthURL(state)
+	if err != nil {
+		if errors.Is(err, services.ErrProviderNotConfigured) {
+			h.renderLoginError(c, http.StatusNotFound, "GitHub sign-in is not set up on this site. Please use another sign-in method.")
+			return
+		}
+		if errors.Is(err, services.ErrProviderUnavailable) {
+			h.renderLoginError(c, http.StatusServiceUnavailable, "GitHub is temporarily unavailable. Please try again in a few minutes or use another sign-in method.")
+			return
+		}
+		h.renderLoginError(c, http.StatusServiceUnavailable, "GitHub sign-in is not available right now. Please use another sign-in method.")
+		return
+	}
 	c.Redirect(http.StatusTemporaryRedirect, authURL)
 }
 
@@ -261,13 +1161,13 @@ func (h *AuthHandler) GitHubCallback(c *gin.Context) {
 	// Verify state parameter
 	state := c.Query("state")
 	savedState, err := c.Cookie("oauth_state")
-	if err != nil || state != savedState {
+	if err != nil || state == "" || !cryptoutil.Equal(state, savedState) || !h.oauthService.ConsumeState(services.AuthProviderGitHub, state) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state parameter"})
 		return
 	}
 
 	// Clear state cookie
-	c.SetCookie("oauth_state", "", -1, "/", "", false, true)
+	c.SetCookie("oauth_state", "", -1, "/", "", h.sessionService.CookieSecure(), true)
 
 	// Handle authorization code
 	code := c.Query("code")
@@ -276,22 +1176,257 @@ func (h *AuthHandler) GitHubCallback(c *gin.Context) {
 		return
 	}
 
-	token, _, err := h.oauthService.HandleGitHubCallback(code)
+	token, user, err := h.oauthService.HandleGitHubCallback(c.Request.Context(), code, middleware.CurrentUser(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, services.ErrLinkConfirmationRequired) {
+			h.renderLinkConfirmationSent(c, "GitHub")
+			return
+		}
+		if errors.Is(err, services.ErrProviderTimeout) {
+			log.Printf("GitHub sign-in failed: %v", err)
+			h.renderLoginError(c, http.StatusGatewayTimeout, "GitHub didn't respond in time. Please try signing in again.")
+			return
+		}
+		if errors.Is(err, services.ErrProviderUnavailable) {
+			log.Printf("GitHub sign-in failed: %v", err)
+			h.renderLoginError(c, http.StatusServiceUnavailable, "GitHub is temporarily unavailable. Please try again in a few minutes or use another sign-in method.")
+			return
+		}
+		if errors.Is(err, services.ErrDomainNotAllowed) {
+			h.renderLoginError(c, http.StatusForbidden, "Your email domain is not allowed to sign in with GitHub.")
+			return
+		}
+		if errors.Is(err, services.ErrRedirectHostNotAllowed) {
+			h.renderLoginError(c, http.StatusServiceUnavailable, "GitHub sign-in is not available right now. Please use another sign-in method.")
+			return
+		}
+		if errors.Is(err, services.ErrProviderEmailMissing) {
+			h.renderLoginError(c, http.StatusForbidden, "GitHub did not share an email address. Make your email public on GitHub or grant access to it, then try again.")
+			return
+		}
+		if errors.Is(err, services.ErrAccountNotYetActive) {
+			h.renderLoginError(c, http.StatusForbidden, "Your account is not active yet. Please sign in once your access begins.")
+			return
+		}
+		log.Printf("GitHub sign-in failed: %v", err)
+		h.renderLoginError(c, http.StatusInternalServerError, "Something went wrong signing in with GitHub. Please try again or use another sign-in method.")
 		return
 	}
 
-	// Set JWT token as HTTP-only cookie
-	c.SetCookie("jwt", token, int(time.Hour*24*7/time.Second), "/", "", false, true)
+	if _, err := h.startSession(c, user, token); err != nil {
+		if err == services.ErrSessionLimitReached {
+			h.renderLoginError(c, http.StatusConflict, "You have reached the maximum number of active sessions. Sign out on another device and try again.")
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	h.auditService.RecordLogin(user.ID, services.AuthProviderGitHub, c.ClientIP(), c.Request.UserAgent())
+
+	// Accounts created without an email are sent to add one first
+	if user.EmailMissing {
+		c.Redirect(http.StatusFound, "/profile")
+		return
+	}
 
 	// Redirect to dashboard
 	c.Redirect(http.StatusFound, "/dashboard")
 }
 
-// generateState generates a random state string for OAuth
-func (h *AuthHandler) generateState() string {
-	b := make([]byte, 16)
-	rand.Read(b)
-	return hex.EncodeToString(b)
+// ConfirmLink links the provider waiting on an account from the link mailed to it
+func (h *AuthHandler) ConfirmLink(c *gin.Context) {
+	user, provider, err := h.authService.ConfirmProviderLink(c.Query("token"))
+	if err != nil {
+		switch err {
+		case services.ErrInvalidLinkToken:
+			h.renderLoginError(c, http.StatusBadRequest, "This confirmation link is invalid or has expired. Sign in with the provider again to get a new one.")
+		case services.ErrProviderAlreadyLinked:
+			h.renderLoginError(c, http.StatusConflict, "That provider account is already linked to another account.")
+		default:
+			h.renderLoginError(c, http.StatusInternalServerError, "Failed to link the provider. Please try again.")
+		}
+		return
+	}
+
+	h.auditService.Record(user.ID, user.ID, models.AuditActionProviderLink, c.ClientIP(), provider)
+
+	c.HTML(http.StatusOK, "login.html", h.authPage(gin.H{
+		"title":                "Login",
+		"notice":               services.ProviderDisplayName(provider) + " is now linked to your account. You can sign in with it.",
+		"registrationDisabled": !h.authService.RegistrationEnabled(),
+	}))
+}
+
+// renderLinkConfirmationSent tells a provider sign-in held back by
+// REQUIRE_LINK_CONFIRMATION that the account's owner must confirm it first
+func (h *AuthHandler) renderLinkConfirmationSent(c *gin.Context, providerName string) {
+	c.HTML(http.StatusOK, "login.html", h.authPage(gin.H{
+		"title":                "Login",
+		"notice":               "An account with this email already exists. We've emailed it a link to confirm linking " + providerName + "; open it, then sign in with " + providerName + " again. Or sign in with your password.",
+		"registrationDisabled": !h.authService.RegistrationEnabled(),
+	}))
+}
+
+// startSession records the session, remembers the device and sets the session cookie.
+// It returns the sessions that were signed out to stay under the per-user cap.
+func (h *AuthHandler) startSession(c *gin.Context, user *models.User, token string) ([]models.SessionResponse, error) {
+	start, err := h.sessionService.Start(user, token, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return nil, err
+	}
+
+	h.deviceService.RecordSignIn(user, c.ClientIP(), c.Request.UserAgent())
+
+	// Set JWT token as HTTP-only cookie
+	h.setSessionCookie(c, token)
+
+	evicted := make([]models.SessionResponse, 0, len(start.Evicted))
+	for _, session := range start.Evicted {
+		evicted = append(evicted, session.ToResponse())
+	}
+	return evicted, nil
+}
+
+// respondSessionError writes the JSON response for a failed startSession
+func (h *AuthHandler) respondSessionError(c *gin.Context, err error) {
+	if err == services.ErrSessionLimitReached {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "You have reached the maximum number of active sessions. Sign out on another device and try again.",
+			"code":  "session_limit",
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+}
+
+// respondLoginThrottled refuses a password attempt while the account or client IP
+// is throttled, saying when to try again
+func (h *AuthHandler) respondLoginThrottled(c *gin.Context, email string) {
+	retryAfter := h.authService.LoginRetryAfter(email, c.ClientIP())
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": "Too many sign-in attempts. Please wait and try again.",
+		"code":  "login_throttled",
+	})
+}
+
+// authPage adds the configured OAuth providers to login and registration page data,
+// so the templates only offer buttons that work
+func (h *AuthHandler) authPage(data gin.H) gin.H {
+	data["providers"] = h.oauthService.ConfiguredProviders()
+	data["termsRequired"] = h.authService.TermsRequired()
+	data["termsURL"] = h.authService.TermsURL()
+	return data
+}
+
+// renderLoginError re-renders the login page with an error message
+func (h *AuthHandler) renderLoginError(c *gin.Context, status int, message string) {
+	c.HTML(status, "login.html", h.authPage(gin.H{
+		"title":                "Login",
+		"error":                message,
+		"registrationDisabled": !h.authService.RegistrationEnabled(),
+	}))
+}
+
+// setSessionCookie stores the JWT in an HTTP-only cookie. With COOKIE_DOMAIN set it is
+// shared across subdomains; otherwise it stays host-only.
+func (h *AuthHandler) setSessionCookie(c *gin.Context, token string) {
+	c.SetCookie("jwt", token, int(services.TokenLifetime/time.Second), "/", h.sessionService.CookieDomain(), h.sessionService.CookieSecure(), true)
+}
+
+// addToken adds the session token to a response that set the session cookie, when
+// RETURN_TOKEN_IN_BODY is on. Otherwise the HTTP-only cookie is the only copy, out
+// of reach of page scripts and response logging.
+func (h *AuthHandler) addToken(response gin.H, token string) gin.H {
+	if h.sessionService.TokenInBody() {
+		response["token"] = token
+	}
+	return response
+}
+
+// clearSessionCookie removes the JWT cookie set by setSessionCookie
+func (h *AuthHandler) clearSessionCookie(c *gin.Context) {
+	c.SetCookie("jwt", "", -1, "/", h.sessionService.CookieDomain(), h.sessionService.CookieSecure(), true)
+}
+
+// respondEmailNotVerified tells a user to verify their email before signing in
+func respondEmailNotVerified(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":      "Please verify your email address before signing in. Check your email for the verification link.",
+		"code":       "email_not_verified",
+		"resend_url": "/verify-email/resend",
+	})
+}
+
+// respondPasswordBreached asks the user to choose a password that has not leaked
+func respondPasswordBreached(c *gin.Context) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": "This password has appeared in a data breach and can't be used. Please choose a different one.",
+		"code":  "password_breached",
+	})
+}
+
+// respondEmailDeleted rejects an email still held by a deleted account
+func respondEmailDeleted(c *gin.Context) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error": "This email address belonged to a deleted account and can't be used again.",
+		"code":  "email_deleted",
+	})
+}
+
+// respondPasswordPolicy rejects a new password outside the policy's length limits
+func respondPasswordPolicy(c *gin.Context, err error) {
+	message := fmt.Sprintf("Password must be at least %d characters.", services.MinPasswordLength)
+	if err == services.ErrPasswordTooLong {
+		message = fmt.Sprintf("Password must be at most %d bytes.", services.MaxPasswordBytes)
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": message,
+		"code":  "password_policy",
+	})
+}
+
+// writeExport streams a user data export as a downloadable JSON file
+func writeExport(c *gin.Context, userID uint, export *models.UserDataExport) {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-export.json"`, userID))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(export)
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using the weak
+// comparison RFC 9110 prescribes for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// invitationErrorMessage returns a user-facing message for invitation errors,
+// or an empty string if err is not related to invitations
+func invitationErrorMessage(err error) string {
+	switch err {
+	case services.ErrInvitationRequired:
+		return "Registration is by invitation only. Please use the link from your invitation."
+	case services.ErrInvalidInvitation:
+		return "This invitation is invalid or has already been used."
+	case services.ErrInvitationExpired:
+		return "This invitation has expired. Please ask an administrator for a new one."
+	case services.ErrInvitationEmailMismatch:
+		return "Please register with the email address the invitation was sent to."
+	}
+	return ""
 }
@@ -0,0 +1,746 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/services"
+)
+
+func TestRegisterDisabled(t *testing.T) {
+	body := `{"email": "new@example.com", "password": "An0ther-Passw0rd", "first_name": "New", "last_name": "User"}`
+
+	tests := []struct {
+		name      string
+		disabled  string
+		wantPage  int
+		enabled   bool
+		wantCodes []int
+	}{
+		{"enabled", "false", http.StatusOK, true, []int{http.StatusCreated, http.StatusAccepted}},
+		{"disabled", "true", http.StatusForbidden, false, []int{http.StatusForbidden}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, map[string]string{"DISABLE_REGISTRATION": tt.disabled})
+
+			if rec := server.do(http.MethodGet, "/register", "", "", "Accept", "text/html"); rec.Code != tt.wantPage {
+				t.Errorf("GET /register status = %d, want %d", rec.Code, tt.wantPage)
+			}
+
+			rec := server.do(http.MethodPost, "/register", "", body)
+			if !containsCode(tt.wantCodes, rec.Code) {
+				t.Errorf("POST /register status = %d, want one of %v: %s", rec.Code, tt.wantCodes, rec.Body.String())
+			}
+
+			for _, page := range []string{"/", "/login"} {
+				rec := server.do(http.MethodGet, page, "", "", "Accept", "text/html")
+				if linked := strings.Contains(rec.Body.String(), `href="/register"`); linked != tt.enabled {
+					t.Errorf("%s links to /register = %v, want %v", page, linked, tt.enabled)
+				}
+			}
+
+			_, err := repository.NewUserRepository().GetByEmail("new@example.com")
+			if created := err == nil; created != tt.enabled {
+				t.Errorf("account created = %v, want %v", created, tt.enabled)
+			}
+		})
+	}
+}
+
+func TestRegisterHoneypot(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     string
+		honeypot    string
+		wantCreated bool
+	}{
+		{"filled", "true", `"https://spam.example"`, false},
+		{"empty", "true", `""`, true},
+		{"whitespace only", "true", `"  "`, true},
+		{"filled while disabled", "false", `"https://spam.example"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, map[string]string{"ENABLE_HONEYPOT": tt.enabled})
+
+			body := `{"email": "new@example.com", "password": "An0ther-Passw0rd", "first_name": "New", "last_name": "User", "website": ` + tt.honeypot + `}`
+			rec := server.do(http.MethodPost, "/register", "", body)
+
+			// The bot is told the sign-up worked, so both outcomes look like success
+			if !containsCode([]int{http.StatusCreated, http.StatusAccepted}, rec.Code) {
+				t.Fatalf("POST /register status = %d, want 201 or 202: %s", rec.Code, rec.Body)
+			}
+			if !tt.wantCreated && strings.Contains(rec.Header().Get("Set-Cookie"), "jwt=") {
+				t.Error("discarded sign-up got a session cookie")
+			}
+
+			_, err := repository.NewUserRepository().GetByEmail("new@example.com")
+			if created := err == nil; created != tt.wantCreated {
+				t.Errorf("account created = %v, want %v", created, tt.wantCreated)
+			}
+		})
+	}
+}
+
+func TestTokenInBody(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run("RETURN_TOKEN_IN_BODY="+strconv.FormatBool(enabled), func(t *testing.T) {
+			s := newTestServer(t, map[string]string{"RETURN_TOKEN_IN_BODY": strconv.FormatBool(enabled)})
+			s.createUser("member@example.com", "user")
+
+			responses := map[string]*httptest.ResponseRecorder{
+				"login": s.do(http.MethodPost, "/login", "", `{"email": "member@example.com", "password": "`+testPassword+`"}`),
+				"register": s.do(http.MethodPost, "/register", "",
+					`{"email": "new@example.com", "password": "An0ther-Passw0rd", "first_name": "New", "last_name": "User"}`),
+			}
+			for name, rec := range responses {
+				if !containsCode([]int{http.StatusOK, http.StatusCreated}, rec.Code) {
+					t.Fatalf("%s status = %d: %s", name, rec.Code, rec.Body)
+				}
+				cookie := sessionCookie(t, rec)
+
+				var body map[string]interface{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatalf("%s response: %v", name, err)
+				}
+				if body["user"] == nil {
+					t.Errorf("%s response has no user", name)
+				}
+				token, inBody := body["token"]
+				if inBody != enabled {
+					t.Errorf("%s response has token = %v, want %v", name, inBody, enabled)
+				}
+				if inBody && token != cookie.Value {
+					t.Errorf("%s body token does not match the session cookie", name)
+				}
+			}
+		})
+	}
+}
+
+func TestTermsAcceptance(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"REQUIRE_TERMS_ACCEPTANCE": "true",
+		"TERMS_VERSION":            "2026-01",
+	})
+	users := repository.NewUserRepository()
+	signUp := func(email, acceptTerms string) *httptest.ResponseRecorder {
+		return s.do(http.MethodPost, "/register", "",
+			`{"email": "`+email+`", "password": "An0ther-Passw0rd", "first_name": "New", "last_name": "User"`+acceptTerms+`}`)
+	}
+
+	t.Run("registration without acceptance", func(t *testing.T) {
+		for name, acceptTerms := range map[string]string{"missing": "", "false": `, "accept_terms": false`} {
+			email := "declined-" + name + "@example.com"
+			rec := signUp(email, acceptTerms)
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "terms_not_accepted") {
+				t.Errorf("accept_terms %s: status = %d, want 400 terms_not_accepted: %s", name, rec.Code, rec.Body)
+			}
+			if _, err := users.GetByEmail(email); err == nil {
+				t.Errorf("accept_terms %s: account created", name)
+			}
+		}
+	})
+
+	t.Run("registration with acceptance", func(t *testing.T) {
+		rec := signUp("accepted@example.com", `, "accept_terms": true`)
+		if !containsCode([]int{http.StatusCreated, http.StatusAccepted}, rec.Code) {
+			t.Fatalf("status = %d, want 201 or 202: %s", rec.Code, rec.Body)
+		}
+		user, err := users.GetByEmail("accepted@example.com")
+		if err != nil {
+			t.Fatalf("GetByEmail: %v", err)
+		}
+		if user.AcceptedTermsVersion != "2026-01" || user.AcceptedTermsAt == nil {
+			t.Errorf("accepted terms version %q at %v, want 2026-01 with a time", user.AcceptedTermsVersion, user.AcceptedTermsAt)
+		}
+	})
+
+	t.Run("gate after a version bump", func(t *testing.T) {
+		user, token := s.createUser("member@example.com", "user")
+		if err := repository.GetDB().Model(user).Updates(map[string]interface{}{
+			"accepted_terms_version": "2025-01",
+			"accepted_terms_at":      time.Now().AddDate(-1, 0, 0),
+		}).Error; err != nil {
+			t.Fatalf("Updates: %v", err)
+		}
+
+		rec := s.do(http.MethodGet, "/api/v1/user", token, "")
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "terms_not_accepted") {
+			t.Errorf("GET /api/v1/user = %d, want 403 terms_not_accepted: %s", rec.Code, rec.Body)
+		}
+		rec = s.do(http.MethodGet, "/dashboard", token, "", "Accept", "text/html")
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/terms" {
+			t.Errorf("GET /dashboard = %d to %q, want 302 to /terms", rec.Code, rec.Header().Get("Location"))
+		}
+		// The routes to accept the terms stay open
+		if rec := s.do(http.MethodGet, "/terms", token, "", "Accept", "text/html"); rec.Code != http.StatusOK {
+			t.Errorf("GET /terms = %d, want %d", rec.Code, http.StatusOK)
+		}
+
+		// Accepting the version the user saw before the change is not enough
+		rec = s.do(http.MethodPost, "/terms/accept", token, `{"terms_version": "2025-01"}`)
+		if rec.Code != http.StatusConflict {
+			t.Errorf("accepting the old version = %d, want %d", rec.Code, http.StatusConflict)
+		}
+		rec = s.do(http.MethodPost, "/terms/accept", token, `{"terms_version": "2026-01"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("accepting the current version = %d: %s", rec.Code, rec.Body)
+		}
+
+		if rec := s.do(http.MethodGet, "/api/v1/user", token, ""); rec.Code != http.StatusOK {
+			t.Errorf("GET /api/v1/user after accepting = %d, want %d", rec.Code, http.StatusOK)
+		}
+	})
+}
+
+// providerDown answers every request with 503, standing in for a provider outage
+type providerDown struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *providerDown) RoundTrip(req *http.Request) (*http.Response, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Status:     "503 Service Unavailable",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"error": "backend_error", "detail": "upstream shard 7 unreachable"}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestOAuthProviderOutage(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"GOOGLE_CLIENT_ID":        "google-client",
+		"GOOGLE_CLIENT_SECRET":    "google-secret",
+		"OAUTH_BREAKER_THRESHOLD": "2",
+		"OAUTH_BREAKER_COOLDOWN":  "1m",
+	})
+	// Provider calls go through the default transport, so swapping it takes Google down
+	down := &providerDown{}
+	transport := http.DefaultTransport
+	http.DefaultTransport = down
+	t.Cleanup(func() { http.DefaultTransport = transport })
+
+	// signIn starts a Google sign-in and returns the response to its callback
+	signIn := func() *httptest.ResponseRecorder {
+		t.Helper()
+		rec := s.do(http.MethodGet, "/auth/google", "", "", "Accept", "text/html")
+		if rec.Code != http.StatusTemporaryRedirect {
+			t.Fatalf("GET /auth/google = %d, want %d", rec.Code, http.StatusTemporaryRedirect)
+		}
+		var state string
+		for _, cookie := range rec.Result().Cookies() {
+			if cookie.Name == "oauth_state" {
+				state = cookie.Value
+			}
+		}
+		return s.do(http.MethodGet, "/auth/google/callback?code=auth-code&state="+url.QueryEscape(state), "", "",
+			"Accept", "text/html", "Cookie", "oauth_state="+state)
+	}
+
+	for i := 0; i < 2; i++ {
+		rec := signIn()
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("callback %d = %d, want %d", i+1, rec.Code, http.StatusServiceUnavailable)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, "Google is temporarily unavailable") || !strings.Contains(body, "use another sign-in method") {
+			t.Errorf("callback %d does not show the friendly outage message", i+1)
+		}
+		for _, detail := range []string{"backend_error", "shard 7", "oauth2:", "503 Service Unavailable"} {
+			if strings.Contains(body, detail) {
+				t.Errorf("callback %d page leaks %q from the provider error", i+1, detail)
+			}
+		}
+		if strings.Contains(rec.Header().Get("Set-Cookie"), "jwt=") {
+			t.Errorf("callback %d set a session cookie", i+1)
+		}
+	}
+	down.mu.Lock()
+	calls := down.calls
+	down.mu.Unlock()
+	if calls == 0 {
+		t.Fatal("the provider was never called")
+	}
+
+	// After repeated outages new sign-ins are refused without calling Google
+	rec := s.do(http.MethodGet, "/auth/google", "", "", "Accept", "text/html")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Location") != "" {
+		t.Fatalf("GET /auth/google with the breaker open = %d to %q, want 503 without a redirect", rec.Code, rec.Header().Get("Location"))
+	}
+	if !strings.Contains(rec.Body.String(), "Google is temporarily unavailable") {
+		t.Error("login page does not explain that Google is unavailable")
+	}
+	down.mu.Lock()
+	defer down.mu.Unlock()
+	if down.calls != calls {
+		t.Errorf("provider called %d more times with the breaker open", down.calls-calls)
+	}
+}
+
+// containsCode reports whether code is one of codes
+func containsCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUnconfiguredProviderLogin(t *testing.T) {
+	// Only Google is set up
+	s := newTestServer(t, map[string]string{
+		"GOOGLE_CLIENT_ID":     "google-client",
+		"GOOGLE_CLIENT_SECRET": "google-secret",
+	})
+
+	rec := s.do(http.MethodGet, "/auth/github", "", "", "Accept", "text/html")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET /auth/github = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if location := rec.Header().Get("Location"); location != "" {
+		t.Errorf("redirected to %q, want no redirect", location)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "GitHub sign-in is not set up on this site") {
+		t.Error("login page does not explain that GitHub sign-in is not set up")
+	}
+
+	// The sign-in page offers only the configured provider
+	page := s.do(http.MethodGet, "/login", "", "").Body.String()
+	if !strings.Contains(page, `href="/auth/google"`) {
+		t.Error("login page does not offer Google sign-in")
+	}
+	if strings.Contains(page, `href="/auth/github"`) {
+		t.Error("login page offers GitHub sign-in, which is not configured")
+	}
+}
+
+func TestGetUserETag(t *testing.T) {
+	s := newTestServer(t, nil)
+	_, token := s.createUser("etag@example.com", "user")
+
+	first := s.do(http.MethodGet, "/api/v1/user", token, "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("GET /api/v1/user = %d with ETag %q, want 200 with a weak ETag", first.Code, etag)
+	}
+	if got := first.Header().Get("Cache-Control"); got != "private, must-revalidate" {
+		t.Errorf("Cache-Control = %q, want %q", got, "private, must-revalidate")
+	}
+
+	for _, ifNoneMatch := range []string{etag, strings.TrimPrefix(etag, "W/"), `W/"other", ` + etag, "*"} {
+		rec := s.do(http.MethodGet, "/api/v1/user", token, "", "If-None-Match", ifNoneMatch)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: status = %d, want %d", ifNoneMatch, rec.Code, http.StatusNotModified)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: 304 carried a body", ifNoneMatch)
+		}
+		if got := rec.Header().Get("ETag"); got != etag {
+			t.Errorf("If-None-Match %s: ETag = %q, want %q", ifNoneMatch, got, etag)
+		}
+	}
+
+	// A profile change makes the old tag stale
+	update := `{"first_name": "Changed", "last_name": "User"}`
+	if rec := s.do(http.MethodPut, "/api/v1/user", token, update); rec.Code != http.StatusOK {
+		t.Fatalf("PUT /api/v1/user = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	rec := s.do(http.MethodGet, "/api/v1/user", token, "", "If-None-Match", etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("stale If-None-Match: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("ETag"); got == "" || got == etag {
+		t.Errorf("ETag after the change = %q, want a new tag", got)
+	}
+	if !strings.Contains(rec.Body.String(), "Changed") {
+		t.Error("response does not carry the changed profile")
+	}
+}
+
+func TestGetUserFields(t *testing.T) {
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+		t.Helper()
+		var body struct {
+			User map[string]interface{} `json:"user"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode %s: %v", rec.Body, err)
+		}
+		return body.User
+	}
+
+	t.Run("subset", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		_, token := s.createUser("fields@example.com", "user")
+
+		rec := s.do(http.MethodGet, "/api/v1/user?fields=email,+first_name,,", token, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		user := decode(t, rec)
+		if len(user) != 2 || user["email"] != "fields@example.com" || user["first_name"] == nil {
+			t.Errorf("user = %v, want only email and first_name", user)
+		}
+
+		// Without the parameter the full response comes back
+		full := decode(t, s.do(http.MethodGet, "/api/v1/user", token, ""))
+		if _, ok := full["role"]; !ok || len(full) <= 2 {
+			t.Errorf("user without fields = %v, want the full response", full)
+		}
+	})
+
+	t.Run("unknown field ignored", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		_, token := s.createUser("fields@example.com", "user")
+
+		// password is a User column but not part of UserResponse, so it never projects
+		rec := s.do(http.MethodGet, "/api/v1/user?fields=email,password,nope", token, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		if user := decode(t, rec); len(user) != 1 || user["email"] != "fields@example.com" {
+			t.Errorf("user = %v, want only email", user)
+		}
+	})
+
+	t.Run("unknown field rejected", func(t *testing.T) {
+		s := newTestServer(t, map[string]string{"REJECT_UNKNOWN_FIELDS": "true"})
+		_, token := s.createUser("fields@example.com", "user")
+
+		rec := s.do(http.MethodGet, "/api/v1/user?fields=email,password", token, "")
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+		}
+		if !strings.Contains(rec.Body.String(), `"unknown_fields":["password"]`) {
+			t.Errorf("body = %s, want password listed as unknown", rec.Body)
+		}
+	})
+}
+
+func TestSessionCookieDomain(t *testing.T) {
+	tests := []struct {
+		name       string
+		domain     string
+		wantDomain string
+	}{
+		{"host only", "", ""},
+		{"shared with subdomains", ".example.com", "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, map[string]string{"COOKIE_DOMAIN": tt.domain})
+			user, _ := s.createUser("cookie@example.com", "user")
+
+			login := fmt.Sprintf(`{"email": %q, "password": %q}`, user.Email, testPassword)
+			rec := s.do(http.MethodPost, "/login", "", login)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("POST /login = %d, want %d", rec.Code, http.StatusOK)
+			}
+			cookie := sessionCookie(t, rec)
+			if cookie.Domain != tt.wantDomain {
+				t.Errorf("login cookie domain = %q, want %q", cookie.Domain, tt.wantDomain)
+			}
+
+			// Signing out must clear the same cookie, so it needs the same domain
+			rec = s.do(http.MethodGet, "/logout", "", "", "Cookie", "jwt="+cookie.Value)
+			if cookie := sessionCookie(t, rec); cookie.Domain != tt.wantDomain || cookie.MaxAge >= 0 {
+				t.Errorf("logout cookie = domain %q, max-age %d; want domain %q, expired", cookie.Domain, cookie.MaxAge, tt.wantDomain)
+			}
+		})
+	}
+}
+
+// sessionCookie returns the jwt cookie a response sets
+func sessionCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "jwt" {
+			return cookie
+		}
+	}
+	t.Fatal("no jwt cookie set")
+	return nil
+}
+
+func TestRecoverPassword(t *testing.T) {
+	const newPassword = `{"new_password": "N3wPassw0rd!x"}`
+
+	// providerSession creates a user with Google linked, optionally without a
+	// password, and returns a session token from a Google sign-in. A non-zero id
+	// replaces the one the database assigned.
+	providerSession := func(s *testServer, email string, withPassword bool, id uint) (*models.User, string) {
+		user, _ := s.createUser(email, "user")
+		updates := map[string]interface{}{"google_id": "google-" + email}
+		if !withPassword {
+			updates["password"] = ""
+		}
+		if id != 0 {
+			updates["id"] = id
+		}
+		if err := repository.GetDB().Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			t.Fatalf("link Google: %v", err)
+		}
+		if id != 0 {
+			user.ID = id
+		}
+		user, err := s.svc.Auth.GetUserByID(user.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		token, err := s.svc.Auth.GenerateProviderJWT(user, services.AuthProviderGoogle)
+		if err != nil {
+			t.Fatalf("GenerateProviderJWT: %v", err)
+		}
+		if _, err := s.svc.Sessions.Start(user, token, "192.0.2.1", "test"); err != nil {
+			t.Fatalf("Start session: %v", err)
+		}
+		return user, token
+	}
+
+	t.Run("recent provider sign-in", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		// A password change rejects older tokens for the user ID across the whole
+		// process, so this user gets an ID no other test's database hands out
+		user, token := providerSession(s, "recover@example.com", true, 1000)
+
+		rec := s.do(http.MethodPost, "/profile/recover-password", token, newPassword)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST /profile/recover-password = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		if rec := s.do(http.MethodGet, "/api/v1/user", token, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("old token after recovery = %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+		if rec := s.do(http.MethodGet, "/api/v1/user", sessionCookie(t, rec).Value, ""); rec.Code != http.StatusOK {
+			t.Errorf("new token after recovery = %d, want %d", rec.Code, http.StatusOK)
+		}
+
+		login := fmt.Sprintf(`{"email": %q, "password": "N3wPassw0rd!x"}`, user.Email)
+		if rec := s.do(http.MethodPost, "/login", "", login); rec.Code != http.StatusOK {
+			t.Errorf("login with the new password = %d, want %d", rec.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("password session", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		_, token := s.createUser("password@example.com", "user")
+
+		rec := s.do(http.MethodPost, "/profile/recover-password", token, newPassword)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "provider_reauth_required") {
+			t.Errorf("POST /profile/recover-password = %d %s, want %d provider_reauth_required", rec.Code, rec.Body, http.StatusForbidden)
+		}
+	})
+
+	t.Run("provider not linked", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		user, _ := s.createUser("unlinked@example.com", "user")
+		token, err := s.svc.Auth.GenerateProviderJWT(user, services.AuthProviderGitHub)
+		if err != nil {
+			t.Fatalf("GenerateProviderJWT: %v", err)
+		}
+		if _, err := s.svc.Sessions.Start(user, token, "192.0.2.1", "test"); err != nil {
+			t.Fatalf("Start session: %v", err)
+		}
+
+		if rec := s.do(http.MethodPost, "/profile/recover-password", token, newPassword); rec.Code != http.StatusForbidden {
+			t.Errorf("POST /profile/recover-password = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("stale provider sign-in", func(t *testing.T) {
+		s := newTestServer(t, map[string]string{"REAUTH_WINDOW": "1ms"})
+		_, token := providerSession(s, "stale@example.com", true, 0)
+		time.Sleep(5 * time.Millisecond)
+
+		if rec := s.do(http.MethodPost, "/profile/recover-password", token, newPassword); rec.Code != http.StatusForbidden {
+			t.Errorf("POST /profile/recover-password = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("no local password", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		_, token := providerSession(s, "oauthonly@example.com", false, 0)
+
+		if rec := s.do(http.MethodPost, "/profile/recover-password", token, newPassword); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /profile/recover-password = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("signed out", func(t *testing.T) {
+		s := newTestServer(t, nil)
+
+		if rec := s.do(http.MethodPost, "/profile/recover-password", "", newPassword); rec.Code == http.StatusOK {
+			t.Errorf("POST /profile/recover-password without a session = %d, want a refusal", rec.Code)
+		}
+	})
+}
+
+func TestProvidersList(t *testing.T) {
+	google := map[string]string{"GOOGLE_CLIENT_ID": "google-client", "GOOGLE_CLIENT_SECRET": "secret"}
+	both := map[string]string{
+		"GOOGLE_CLIENT_ID": "google-client", "GOOGLE_CLIENT_SECRET": "secret",
+		"GITHUB_CLIENT_ID": "github-client", "GITHUB_CLIENT_SECRET": "secret",
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want map[string]string // provider ID to auth URL
+	}{
+		{"none configured", nil, map[string]string{}},
+		{"google only", google, map[string]string{"google": "/auth/google"}},
+		{"google and github", both, map[string]string{"google": "/auth/google", "github": "/auth/github"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.env)
+
+			rec := s.do(http.MethodGet, "/api/v1/auth/providers", "", "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET /api/v1/auth/providers = %d, want %d", rec.Code, http.StatusOK)
+			}
+			var body struct {
+				Providers []models.OAuthProviderInfo `json:"providers"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Providers == nil {
+				t.Error("providers is null, want a list")
+			}
+
+			got := make(map[string]string)
+			for _, provider := range body.Providers {
+				if provider.Name == "" || provider.Icon == "" {
+					t.Errorf("provider %s is missing its name or icon: %+v", provider.ID, provider)
+				}
+				got[provider.ID] = provider.AuthURL
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("providers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	verify := func(password string) string {
+		return fmt.Sprintf(`{"password": %q}`, password)
+	}
+
+	tests := []struct {
+		name      string
+		password  string
+		noLocal   bool
+		want      int
+		wantValid string
+	}{
+		{"correct", testPassword, false, http.StatusOK, `"valid":true`},
+		{"incorrect", "wrong-passw0rd", false, http.StatusOK, `"valid":false`},
+		{"no password", testPassword, true, http.StatusBadRequest, `"no_local_password"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, nil)
+			user, token := s.createUser("verify@example.com", "user")
+			if tt.noLocal {
+				if err := repository.GetDB().Model(&models.User{}).Where("id = ?", user.ID).Update("password", "").Error; err != nil {
+					t.Fatalf("clear password: %v", err)
+				}
+			}
+
+			rec := s.do(http.MethodPost, "/api/v1/me/verify-password", token, verify(tt.password))
+			if rec.Code != tt.want || !strings.Contains(rec.Body.String(), tt.wantValid) {
+				t.Errorf("POST /api/v1/me/verify-password = %d %s, want %d %s", rec.Code, rec.Body, tt.want, tt.wantValid)
+			}
+		})
+	}
+
+	t.Run("rate limited", func(t *testing.T) {
+		s := newTestServer(t, map[string]string{"PASSWORD_CHECK_ATTEMPTS": "3"})
+		_, token := s.createUser("verify@example.com", "user")
+
+		for i := 0; i < 3; i++ {
+			rec := s.do(http.MethodPost, "/api/v1/me/verify-password", token, verify("wrong-passw0rd"))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("guess %d = %d, want %d", i+1, rec.Code, http.StatusOK)
+			}
+		}
+
+		// Once limited, even the right password is not checked
+		rec := s.do(http.MethodPost, "/api/v1/me/verify-password", token, verify(testPassword))
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+			t.Errorf("after the limit = %d with Retry-After %q, want %d with a Retry-After", rec.Code, rec.Header().Get("Retry-After"), http.StatusTooManyRequests)
+		}
+	})
+}
+
+func TestUpdateProfileLengthLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		value string
+		want  int
+	}{
+		{"bio at the limit", "bio", strings.Repeat("é", models.MaxBioLength), http.StatusOK},
+		{"bio over the limit", "bio", strings.Repeat("a", models.MaxBioLength+1), http.StatusBadRequest},
+		{"website over the limit", "website", "https://example.com/" + strings.Repeat("a", models.MaxWebsiteLength), http.StatusBadRequest},
+		{"location at the limit", "location", strings.Repeat("a", models.MaxLocationLength), http.StatusOK},
+		{"location over the limit", "location", strings.Repeat("a", models.MaxLocationLength+1), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, nil)
+			user, token := s.createUser("profile@example.com", "user")
+
+			body, _ := json.Marshal(map[string]string{"first_name": "Test", "last_name": "User", tt.field: tt.value})
+			rec := s.do(http.MethodPost, "/profile", token, string(body))
+			if rec.Code != tt.want {
+				t.Fatalf("POST /profile = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusBadRequest {
+				return
+			}
+
+			// The error names the field, and nothing reached the database
+			var resp struct {
+				Error string `json:"error"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &resp)
+			if !strings.Contains(strings.ToLower(resp.Error), tt.field) {
+				t.Errorf("error = %q, want it to name %s", resp.Error, tt.field)
+			}
+			stored, err := repository.NewUserRepository().GetByID(user.ID)
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if stored.Bio != nil || stored.Website != nil || stored.Location != nil {
+				t.Error("an over-limit value was stored")
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/internal/services"
+)
+
+type DirectoryHandler struct {
+	directoryService *services.DirectoryService
+}
+
+func NewDirectoryHandler(directoryService *services.DirectoryService) *DirectoryHandler {
+	return &DirectoryHandler{
+		directoryService: directoryService,
+	}
+}
+
+// Search lists directory entries whose name matches the q parameter
+func (h *DirectoryHandler) Search(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	users, err := h.directoryService.Search(c.Query("q"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// GetUser returns one user's directory entry
+func (h *DirectoryHandler) GetUser(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.directoryService.GetUser(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// PublicProfile renders a user's public profile page. Users who have not opted
+// in get the same 404 as users who do not exist.
+func (h *DirectoryHandler) PublicProfile(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Not Found", "The page you requested does not exist.")
+		return
+	}
+
+	profile, err := h.directoryService.GetPublicProfile(uint(userID))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Not Found", "The page you requested does not exist.")
+		return
+	}
+
+	c.HTML(http.StatusOK, "public-profile.html", gin.H{
+		"title":   profile.FirstName + " " + profile.LastName,
+		"profile": profile,
+	})
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+func TestPublicProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		updates map[string]interface{}
+		want    int
+	}{
+		{"public", map[string]interface{}{"profile_public": true}, http.StatusOK},
+		{"private", map[string]interface{}{"profile_public": false}, http.StatusNotFound},
+		{"public but unverified", map[string]interface{}{"profile_public": true, "is_verified": false}, http.StatusNotFound},
+		{"public but deactivated", map[string]interface{}{"profile_public": true, "is_active": false}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, nil)
+			user, _ := s.createUser("public@example.com", "admin")
+
+			updates := map[string]interface{}{
+				"bio":          "Writes Go",
+				"website":      "https://jane.example.com",
+				"location":     "Lisbon",
+				"phone_number": "+15555550100",
+			}
+			for key, value := range tt.updates {
+				updates[key] = value
+			}
+			if err := repository.GetDB().Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+				t.Fatalf("update profile: %v", err)
+			}
+
+			rec := s.do(http.MethodGet, fmt.Sprintf("/u/%d", user.ID), "", "", "Accept", "text/html")
+			if rec.Code != tt.want {
+				t.Fatalf("GET /u/%d = %d, want %d", user.ID, rec.Code, tt.want)
+			}
+			body := rec.Body.String()
+			if tt.want != http.StatusOK {
+				if strings.Contains(body, "Writes Go") {
+					t.Error("a hidden profile was rendered")
+				}
+				return
+			}
+
+			for _, shown := range []string{"Test User", "Writes Go", "https://jane.example.com", "Lisbon"} {
+				if !strings.Contains(body, shown) {
+					t.Errorf("profile page is missing %q", shown)
+				}
+			}
+			for _, hidden := range []string{user.Email, "+15555550100"} {
+				if strings.Contains(body, hidden) {
+					t.Errorf("profile page shows %q", hidden)
+				}
+			}
+		})
+	}
+
+	t.Run("missing user", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		for _, path := range []string{"/u/999", "/u/not-a-number"} {
+			if rec := s.do(http.MethodGet, path, "", "", "Accept", "text/html"); rec.Code != http.StatusNotFound {
+				t.Errorf("GET %s = %d, want %d", path, rec.Code, http.StatusNotFound)
+			}
+		}
+	})
+}
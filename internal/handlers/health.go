@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/internal/services"
+)
+
+// Healthz is a liveness probe for load balancers and monitoring. It answers GET and
+// HEAD with 200 while the database is reachable and 503 otherwise.
+func Healthz(c *gin.Context) {
+	if err := services.PingDatabase(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "database unreachable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
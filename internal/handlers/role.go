@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sso-web-app/internal/middleware"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/services"
+)
+
+type RoleHandler struct {
+	roleService  *services.RoleService
+	auditService *services.AuditService
+}
+
+func NewRoleHandler(roleService *services.RoleService, auditService *services.AuditService) *RoleHandler {
+	return &RoleHandler{
+		roleService:  roleService,
+		auditService: auditService,
+	}
+}
+
+// ListRoles returns all custom roles
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	roles, err := h.roleService.ListRoles(adminUser)
+	if err != nil {
+		h.respondError(c, err, "Failed to load roles")
+		return
+	}
+
+	responses := make([]models.RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		responses = append(responses, role.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": responses})
+}
+
+// GetRole returns a single custom role
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	role, err := h.roleService.GetRole(adminUser, roleID)
+	if err != nil {
+		h.respondError(c, err, "Failed to load role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role.ToResponse()})
+}
+
+// RolePermissions returns the permissions a built-in or custom role grants, so
+// admins can preview a role change before making it
+func (h *RoleHandler) RolePermissions(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	// The route shares its wildcard with /roles/:id, but here it may also be a built-in role name
+	permissions, err := h.roleService.RolePermissions(adminUser, c.Param("id"))
+	if err != nil {
+		h.respondError(c, err, "Failed to load role permissions")
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// CreateRole creates a custom role
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	role, err := h.roleService.CreateRole(adminUser, req)
+	if err != nil {
+		h.respondError(c, err, "Failed to create role")
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionRoleCreate, 0, fmt.Sprintf("role %d %s: %s", role.ID, role.Name, role.Permissions))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Role created successfully",
+		"role":    role.ToResponse(),
+	})
+}
+
+// UpdateRole updates a custom role
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	role, err := h.roleService.UpdateRole(adminUser, roleID, req)
+	if err != nil {
+		h.respondError(c, err, "Failed to update role")
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionRoleUpdate, 0, fmt.Sprintf("role %d %s: %s", role.ID, role.Name, role.Permissions))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role updated successfully",
+		"role":    role.ToResponse(),
+	})
+}
+
+// DeleteRole deletes a custom role and its assignments
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.roleService.DeleteRole(adminUser, roleID); err != nil {
+		h.respondError(c, err, "Failed to delete role")
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionRoleDelete, 0, fmt.Sprintf("role %d", roleID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// AssignRole assigns a role to a list of users and reports the result per user
+func (h *RoleHandler) AssignRole(c *gin.Context) {
+	adminUser, ok := middleware.MustGetUser(c)
+	if !ok {
+		return
+	}
+
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	var req models.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	results, err := h.roleService.AssignRoleToUsers(adminUser, roleID, req.UserIDs)
+	if err != nil {
+		h.respondError(c, err, "Failed to assign role")
+		return
+	}
+
+	assigned := 0
+	for _, result := range results {
+		if result.Success {
+			assigned++
+			recordAudit(h.auditService, c, models.AuditActionRoleAssign, result.UserID, fmt.Sprintf("role %d", roleID))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Role assignment completed",
+		"assigned": assigned,
+		"failed":   len(results) - assigned,
+		"results":  results,
+	})
+}
+
+// respondError maps role service errors to JSON responses
+func (h *RoleHandler) respondError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case services.ErrNotAuthorized:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient privileges for this role"})
+	case services.ErrRoleNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+	case services.ErrRoleExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "A role with this name already exists"})
+	case services.ErrInvalidPermission:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid permission specified"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// parseRoleID reads the role ID from the URL, responding with 400 if it is invalid
+func parseRoleID(c *gin.Context) (uint, bool) {
+	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return 0, false
+	}
+	return uint(roleID), true
+}
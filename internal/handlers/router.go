@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/configs"
+	"sso-web-app/internal/middleware"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/services"
+)
+
+// Services are the application services the routes are served by
+type Services struct {
+	Auth        *services.AuthService
+	OAuth       *services.OAuthService
+	Admin       *services.AdminService
+	Roles       *services.RoleService
+	APIKeys     *services.APIKeyService
+	Directory   *services.DirectoryService
+	Devices     *services.DeviceService
+	Sessions    *services.SessionService
+	Audit       *services.AuditService
+	SigningKeys *services.SigningKeyService
+}
+
+// RegisterRoutes adds every application route to router, with the authentication
+// and authorization each needs, followed by the fallback routes. Templates and
+// static files are left to the caller.
+func RegisterRoutes(router *gin.Engine, cfg *configs.Config, svc Services) error {
+	// Initialize handlers
+	authHandler := NewAuthHandler(svc.Auth, svc.OAuth, svc.Devices, svc.Sessions, svc.Audit)
+	adminHandler := NewAdminHandler(svc.Admin, svc.Audit)
+	roleHandler := NewRoleHandler(svc.Roles, svc.Audit)
+	auditHandler := NewAuditHandler(svc.Audit)
+	apiKeyHandler := NewAPIKeyHandler(svc.APIKeys)
+	directoryHandler := NewDirectoryHandler(svc.Directory)
+	signingKeyHandler := NewSigningKeyHandler(svc.SigningKeys, svc.Audit)
+
+	// Liveness probe for load balancers and monitoring
+	router.GET("/healthz", Healthz)
+	router.HEAD("/healthz", Healthz)
+
+	// Public keys for verifying session tokens when JWT_SIGNING_METHOD is RS256
+	router.GET("/.well-known/jwks.json", signingKeyHandler.JWKS)
+
+	// Public routes
+	public := router.Group("/")
+	{
+		public.GET("/", authHandler.Home)
+		public.GET("/login", authHandler.LoginPage)
+		public.POST("/login", authHandler.Login)
+		public.GET("/register", authHandler.RegisterPage)
+		public.POST("/register", authHandler.Register)
+		public.GET("/logout", authHandler.Logout)
+		public.GET("/password/change", authHandler.ChangePasswordPage)
+		public.POST("/password/change", authHandler.ChangePassword)
+		public.GET("/verify-email", authHandler.VerifyEmail)
+		public.POST("/verify-email/resend", authHandler.ResendVerification)
+		public.GET("/verify-recovery-email", authHandler.VerifyRecoveryEmail)
+		public.GET("/confirm-link", authHandler.ConfirmLink)
+		public.GET("/u/:id", directoryHandler.PublicProfile)
+
+		// OAuth routes. The callbacks see who is signed in, so a provider can be
+		// linked to that account.
+		oauthCallback := middleware.OptionalAuthMiddleware(svc.Auth)
+		public.GET("/auth/google", authHandler.GoogleLogin)
+		public.GET("/auth/google/callback", oauthCallback, authHandler.GoogleCallback)
+		public.GET("/auth/github", authHandler.GitHubLogin)
+		public.GET("/auth/github/callback", oauthCallback, authHandler.GitHubCallback)
+	}
+
+	// Cookie sessions near expiry get a fresh token when SESSION_REFRESH_WINDOW is set
+	slidingSession := middleware.WithSlidingSession(svc.Sessions)
+
+	// Signed-in users who have not accepted the current terms may only accept them or sign out
+	termsGate := middleware.RequireTermsAccepted(svc.Auth)
+
+	terms := router.Group("/terms")
+	terms.Use(middleware.AuthMiddleware(svc.Auth, slidingSession))
+	{
+		terms.GET("", authHandler.TermsPage)
+		terms.POST("/accept", authHandler.AcceptTerms)
+	}
+
+	// Protected routes
+	protected := router.Group("/")
+	protected.Use(middleware.AuthMiddleware(svc.Auth, slidingSession), termsGate)
+	{
+		protected.GET("/dashboard", authHandler.Dashboard)
+		protected.GET("/profile", authHandler.Profile)
+		protected.POST("/profile", authHandler.UpdateProfile)
+		protected.POST("/reauth", authHandler.Reauth)
+		protected.POST("/profile/recover-password", authHandler.RecoverPassword)
+		protected.POST("/profile/email", authHandler.AddEmail)
+		protected.POST("/profile/recovery-email", authHandler.SetRecoveryEmail)
+		protected.POST("/profile/phone", authHandler.SetPhoneNumber)
+		protected.POST("/profile/phone/verify", authHandler.VerifyPhoneNumber)
+		protected.GET("/profile/login-history", auditHandler.LoginHistory)
+	}
+
+	// Sign-in options for single-page frontends; public like the login page
+	router.GET("/api/v1/auth/providers", authHandler.Providers)
+
+	// Live password feedback for sign-up forms; public and limited per client IP
+	router.POST("/api/v1/password/strength", authHandler.PasswordStrength)
+
+	// Refresh tokens stand in for the session, so this route needs no session of its own
+	router.POST("/api/v1/auth/refresh", authHandler.RefreshSession)
+
+	// API routes accept a session token or an API key; keys are limited to their scopes
+	api := router.Group("/api/v1")
+	api.Use(middleware.APIKeyMiddleware(svc.APIKeys, middleware.AuthMiddleware(svc.Auth, slidingSession)), termsGate)
+	{
+		api.GET("/user", middleware.RequireScope(models.ScopeUsersRead), authHandler.GetUser)
+		api.HEAD("/user", middleware.RequireScope(models.ScopeUsersRead), authHandler.GetUser)
+		api.PUT("/user", middleware.RequireScope(models.ScopeUsersWrite), authHandler.UpdateUser)
+		api.GET("/me/export", middleware.RequireScope(models.ScopeUsersRead), authHandler.ExportData)
+	}
+
+	// Directory lookups only need to know who is asking, so they trust the account
+	// snapshot in the session token instead of loading the user on every request
+	directory := router.Group("/api/v1/users")
+	directory.Use(middleware.APIKeyMiddleware(svc.APIKeys, middleware.AuthMiddleware(svc.Auth, slidingSession, middleware.WithDBRefresh(false))), termsGate)
+	{
+		directory.GET("/search", middleware.RequireScope(models.ScopeUsersRead), directoryHandler.Search)
+		directory.GET("/:id", middleware.RequireScope(models.ScopeUsersRead), directoryHandler.GetUser)
+	}
+
+	// Password checks need a session, so an API key cannot be used to test passwords
+	me := router.Group("/api/v1/me")
+	me.Use(middleware.AuthMiddleware(svc.Auth, slidingSession), termsGate)
+	{
+		me.POST("/verify-password", authHandler.VerifyPassword)
+	}
+
+	// API key management needs a session, so a leaked key cannot mint or revoke keys
+	apiKeys := router.Group("/api/v1/api-keys")
+	apiKeys.Use(middleware.AuthMiddleware(svc.Auth, slidingSession), termsGate)
+	{
+		apiKeys.GET("", apiKeyHandler.ListKeys)
+		apiKeys.HEAD("", apiKeyHandler.ListKeys)
+		apiKeys.POST("", middleware.RequireRecentAuth(svc.Auth), apiKeyHandler.CreateKey)
+		apiKeys.DELETE("/:id", middleware.RequireRecentAuth(svc.Auth), apiKeyHandler.RevokeKey)
+	}
+
+	// Admin routes are limited to ADMIN_IP_ALLOWLIST before any role check
+	adminIPAllowlist, err := middleware.IPAllowlist(cfg.AdminIPAllowlistEntries(), cfg.TrustedProxyList())
+	if err != nil {
+		return fmt.Errorf("invalid ADMIN_IP_ALLOWLIST: %v", err)
+	}
+
+	// Admin routes
+	admin := router.Group("/admin")
+	admin.Use(adminIPAllowlist, middleware.AuthMiddleware(svc.Auth, slidingSession), termsGate, middleware.AdminRequired())
+	{
+		admin.GET("/dashboard", adminHandler.Dashboard)
+		admin.GET("/users", adminHandler.UsersList)
+		admin.GET("/users/inactive", adminHandler.InactiveUsers)
+		admin.GET("/users/:id", adminHandler.UserDetail)
+		admin.GET("/system", adminHandler.SystemHealth)
+		admin.GET("/invitations", adminHandler.Invitations)
+	}
+
+	// Admin API routes
+	adminAPI := router.Group("/admin/api")
+	adminAPI.Use(adminIPAllowlist, middleware.AuthMiddleware(svc.Auth, slidingSession), termsGate, middleware.AdminAPIRequired())
+	{
+		adminAPI.GET("/users", adminHandler.UsersByIDs)
+		adminAPI.PUT("/users/:id", adminHandler.UpdateUser)
+		adminAPI.POST("/users/:id/activate", adminHandler.ActivateUser)
+		adminAPI.POST("/users/:id/deactivate", adminHandler.DeactivateUser)
+		adminAPI.POST("/users/:id/cancel-deactivation", adminHandler.CancelDeactivation)
+		adminAPI.POST("/users/:id/logout", adminHandler.ForceLogout)
+		adminAPI.POST("/users/:id/deactivate-and-logout", adminHandler.DeactivateAndLogout)
+		adminAPI.DELETE("/users/:id", middleware.RequireRecentAuth(svc.Auth), adminHandler.DeleteUser)
+		adminAPI.POST("/users/:id/promote", adminHandler.PromoteToAdmin)
+		adminAPI.POST("/users/:id/demote", adminHandler.DemoteFromAdmin)
+		adminAPI.POST("/users/:id/resend-verification", adminHandler.ResendVerification)
+		adminAPI.GET("/users/:id/lockout", adminHandler.UserLockout)
+		adminAPI.POST("/users/:id/unlock", adminHandler.UnlockUser)
+		adminAPI.POST("/users/:id/merge", middleware.RequireRecentAuth(svc.Auth), adminHandler.MergeUser)
+		adminAPI.GET("/users/:id/export", middleware.RequireRecentAuth(svc.Auth), adminHandler.ExportUserData)
+		adminAPI.GET("/users/:id/providers", adminHandler.LinkedProviders)
+		adminAPI.DELETE("/users/:id/providers/:provider", adminHandler.UnlinkProvider)
+		adminAPI.GET("/users/:id/api-keys", adminHandler.UserAPIKeys)
+		adminAPI.GET("/users/:id/login-history", auditHandler.UserLoginHistory)
+		adminAPI.DELETE("/users/:id/api-keys/:keyID", adminHandler.RevokeUserAPIKey)
+
+		adminAPI.POST("/invitations", adminHandler.CreateInvitation)
+		adminAPI.POST("/notify", adminHandler.NotifyUsers)
+		adminAPI.GET("/stats/signups", adminHandler.SignupTrend)
+
+		adminAPI.GET("/roles", roleHandler.ListRoles)
+		adminAPI.POST("/roles", roleHandler.CreateRole)
+		adminAPI.GET("/roles/:id", roleHandler.GetRole)
+		adminAPI.GET("/roles/:id/permissions", roleHandler.RolePermissions)
+		adminAPI.PUT("/roles/:id", roleHandler.UpdateRole)
+		adminAPI.DELETE("/roles/:id", roleHandler.DeleteRole)
+		adminAPI.POST("/roles/:id/assign", roleHandler.AssignRole)
+
+		adminAPI.GET("/audit-logs", middleware.SuperAdminAPIRequired(), auditHandler.AuditLogs)
+		adminAPI.GET("/signing-keys", middleware.SuperAdminAPIRequired(), signingKeyHandler.ListSigningKeys)
+		adminAPI.POST("/signing-keys/rotate", middleware.SuperAdminAPIRequired(), middleware.RequireRecentAuth(svc.Auth), signingKeyHandler.RotateSigningKey)
+	}
+
+	// OPTIONS, 404 and 405 responses for every path
+	RegisterFallbackRoutes(router)
+	return nil
+}
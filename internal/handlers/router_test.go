@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/services"
+	"sso-web-app/internal/timeutil"
+)
+
+// testServer is the application's router on a fresh in-memory database
+type testServer struct {
+	t      *testing.T
+	cfg    *configs.Config
+	svc    Services
+	router *gin.Engine
+}
+
+// newTestServer opens a fresh, migrated in-memory database and serves every route
+// the way cmd/server does, with env applied on top of the default configuration
+func newTestServer(t *testing.T, env map[string]string) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("DB_LOG_LEVEL", "silent")
+	// Escaped so characters such as # in subtest names cannot end the path and
+	// drop mode=memory, which would leave a database file behind
+	t.Setenv("DATABASE_URL", "file:"+url.PathEscape(t.Name())+"?mode=memory&cache=shared")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if err := repository.InitDB(cfg); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	if err := repository.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	mailer := services.NewMailer(cfg)
+	auditService := services.NewAuditService(cfg, services.NewAlertService(cfg, mailer))
+	signingKeys, err := services.NewSigningKeyService(cfg)
+	if err != nil {
+		t.Fatalf("NewSigningKeyService: %v", err)
+	}
+	authService := services.NewAuthService(cfg, mailer, services.NewSMSSender(cfg), signingKeys, auditService)
+	oauthService := services.NewOAuthService(cfg, authService)
+	svc := Services{
+		Auth:        authService,
+		OAuth:       oauthService,
+		Admin:       services.NewAdminService(cfg, authService, oauthService),
+		Roles:       services.NewRoleService(),
+		APIKeys:     services.NewAPIKeyService(),
+		Directory:   services.NewDirectoryService(cfg),
+		Devices:     services.NewDeviceService(cfg, mailer),
+		Sessions:    services.NewSessionService(cfg, authService),
+		Audit:       auditService,
+		SigningKeys: signingKeys,
+	}
+
+	router := gin.New()
+	router.SetFuncMap(template.FuncMap{"localTime": timeutil.FormatLocal})
+	router.LoadHTMLGlob("../../templates/*.html")
+	if err := RegisterRoutes(router, cfg, svc); err != nil {
+		t.Fatalf("RegisterRoutes: %v", err)
+	}
+
+	return &testServer{t: t, cfg: cfg, svc: svc, router: router}
+}
+
+// createUser stores an active, verified user with a local password and the given
+// role ("admin" also sets IsAdmin) and returns it with a signed-in session token
+func (s *testServer) createUser(email, role string) (*models.User, string) {
+	s.t.Helper()
+	hashed, err := s.svc.Auth.HashPassword(testPassword)
+	if err != nil {
+		s.t.Fatalf("HashPassword: %v", err)
+	}
+	user, err := repository.NewUserRepository().Create(&models.User{
+		Email:      email,
+		Password:   hashed,
+		FirstName:  "Test",
+		LastName:   "User",
+		Role:       role,
+		IsAdmin:    role == "admin",
+		IsActive:   true,
+		IsVerified: true,
+	})
+	if err != nil {
+		s.t.Fatalf("Create %s: %v", email, err)
+	}
+
+	token, err := s.svc.Auth.GenerateJWT(user)
+	if err != nil {
+		s.t.Fatalf("GenerateJWT: %v", err)
+	}
+	if _, err := s.svc.Sessions.Start(user, token, "192.0.2.1", "test"); err != nil {
+		s.t.Fatalf("Start session: %v", err)
+	}
+	return user, token
+}
+
+// testPassword is the password of every user made by createUser
+const testPassword = "Passw0rd!x"
+
+// do serves one request. A token is sent as a bearer token, a non-empty body as
+// JSON, and headers are given as name, value pairs.
+func (s *testServer) do(method, path, token, body string, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeTable maps each registered path pattern to the methods it serves
+type routeTable map[string][]string
+
+// allowed returns the methods served for a concrete request path. When several
+// patterns match, the one with the fewest wildcards wins, as in gin's router.
+func (t routeTable) allowed(path string) []string {
+	var best []string
+	bestWildcards := -1
+	for pattern, methods := range t {
+		if !routeMatches(pattern, path) {
+			continue
+		}
+		wildcards := strings.Count(pattern, ":") + strings.Count(pattern, "*")
+		if bestWildcards < 0 || wildcards < bestWildcards {
+			best, bestWildcards = methods, wildcards
+		}
+	}
+	return best
+}
+
+// routeMatches reports whether path fits a gin route pattern, where ":name" matches
+// one segment and "*name" matches the rest of the path
+func routeMatches(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(pathParts) {
+			return false
+		}
+		if strings.HasPrefix(part, ":") {
+			if pathParts[i] == "" {
+				return false
+			}
+			continue
+		}
+		if part != pathParts[i] {
+			return false
+		}
+	}
+	return len(patternParts) == len(pathParts)
+}
+
+// RegisterFallbackRoutes must be called after all other routes are registered. It
+// answers OPTIONS on every path with 204 and an Allow header, and replaces gin's
+// plain-text 404 and 405 responses with error.html for browsers and a JSON error for
+// API clients. The OPTIONS handlers run without authentication so clients can probe
+// before signing in.
+func RegisterFallbackRoutes(router *gin.Engine) {
+	table := make(routeTable)
+	for _, route := range router.Routes() {
+		table[route.Path] = append(table[route.Path], route.Method)
+	}
+
+	for path, methods := range table {
+		hasOptions := false
+		for _, method := range methods {
+			if method == http.MethodOptions {
+				hasOptions = true
+			}
+		}
+		if !hasOptions {
+			methods = append(methods, http.MethodOptions)
+		}
+		sort.Strings(methods)
+		table[path] = methods
+
+		if hasOptions {
+			continue
+		}
+
+		allow := strings.Join(methods, ", ")
+		router.OPTIONS(path, func(c *gin.Context) {
+			c.Header("Allow", allow)
+			c.Status(http.StatusNoContent)
+		})
+	}
+
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(c *gin.Context) {
+		respondError(c, http.StatusNotFound, "Not Found", "The page you requested does not exist.")
+	})
+	router.NoMethod(func(c *gin.Context) {
+		c.Header("Allow", strings.Join(table.allowed(c.Request.URL.Path), ", "))
+		respondError(c, http.StatusMethodNotAllowed, "Method Not Allowed", "This method is not supported for the requested resource.")
+	})
+}
+
+// respondError writes an error as JSON for API paths and clients that ask for JSON,
+// and as the error page otherwise
+func respondError(c *gin.Context, status int, title, message string) {
+	path := c.Request.URL.Path
+	if strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/admin/api/") ||
+		c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.HTML(status, "error.html", gin.H{
+		"title": title,
+		"error": message,
+	})
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHeadMatchesGet(t *testing.T) {
+	s := newTestServer(t, nil)
+	_, token := s.createUser("head@example.com", "user")
+
+	// A real server, so HEAD responses go through net/http's body suppression
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"healthz", "/healthz", "", http.StatusOK},
+		{"user signed in", "/api/v1/user", token, http.StatusOK},
+		{"user signed out", "/api/v1/user", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			get := s.do(http.MethodGet, tt.path, tt.token, "")
+			if get.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d", tt.path, get.Code, tt.want)
+			}
+
+			req, err := http.NewRequest(http.MethodHead, server.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("HEAD %s: %v", tt.path, err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.want {
+				t.Errorf("HEAD %s = %d, want %d", tt.path, resp.StatusCode, tt.want)
+			}
+			if got, want := resp.Header.Get("Content-Type"), get.Header().Get("Content-Type"); got != want {
+				t.Errorf("HEAD Content-Type = %q, want %q as for GET", got, want)
+			}
+			if body, _ := io.ReadAll(resp.Body); len(body) != 0 {
+				t.Errorf("HEAD %s returned a %d byte body", tt.path, len(body))
+			}
+		})
+	}
+}
+
+func TestOptionsListsAllowedMethods(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/healthz", "GET, HEAD, OPTIONS"},
+		{"/api/v1/user", "GET, HEAD, OPTIONS, PUT"},
+		{"/login", "GET, OPTIONS, POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// No credentials: clients probe before signing in
+			rec := s.do(http.MethodOptions, tt.path, "", "")
+			if rec.Code != http.StatusNoContent {
+				t.Fatalf("OPTIONS %s = %d, want %d", tt.path, rec.Code, http.StatusNoContent)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.want {
+				t.Errorf("Allow = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFallbackErrors(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		accept   string
+		want     int
+		wantJSON bool
+		allow    string
+	}{
+		{"unknown page from a browser", http.MethodGet, "/no-such-page", "text/html", http.StatusNotFound, false, ""},
+		{"unknown page asking for JSON", http.MethodGet, "/no-such-page", "application/json", http.StatusNotFound, true, ""},
+		{"unknown API path", http.MethodGet, "/api/v1/no-such-thing", "text/html", http.StatusNotFound, true, ""},
+		{"wrong method from a browser", http.MethodDelete, "/login", "text/html", http.StatusMethodNotAllowed, false, "GET, OPTIONS, POST"},
+		{"wrong method on an API path", http.MethodPost, "/healthz", "application/json", http.StatusMethodNotAllowed, true, "GET, HEAD, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(tt.method, tt.path, "", "", "Accept", tt.accept)
+			if rec.Code != tt.want {
+				t.Fatalf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+			}
+
+			contentType := rec.Header().Get("Content-Type")
+			if tt.wantJSON {
+				var body map[string]string
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] == "" {
+					t.Errorf("body = %q, want a JSON error", rec.Body.String())
+				}
+			} else if !strings.HasPrefix(contentType, "text/html") || !strings.Contains(rec.Body.String(), "<html") {
+				t.Errorf("Content-Type = %q, want the error page", contentType)
+			}
+
+			if got := rec.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/internal/middleware"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/services"
+)
+
+type SigningKeyHandler struct {
+	signingKeyService *services.SigningKeyService
+	auditService      *services.AuditService
+}
+
+func NewSigningKeyHandler(signingKeyService *services.SigningKeyService, auditService *services.AuditService) *SigningKeyHandler {
+	return &SigningKeyHandler{
+		signingKeyService: signingKeyService,
+		auditService:      auditService,
+	}
+}
+
+// JWKS publishes the public keys session tokens are verified with under RS256.
+// Under HS256 there is nothing to publish.
+func (h *SigningKeyHandler) JWKS(c *gin.Context) {
+	if !h.signingKeyService.Enabled() {
+		respondError(c, http.StatusNotFound, "Not Found", "The page you requested does not exist.")
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(services.JWKSMaxAge.Seconds())))
+	c.JSON(http.StatusOK, h.signingKeyService.JWKS())
+}
+
+// ListSigningKeys returns the active signing key and the retired ones still published
+func (h *SigningKeyHandler) ListSigningKeys(c *gin.Context) {
+	keys, err := h.signingKeyService.List()
+	if err != nil {
+		h.respondError(c, err, "Failed to load signing keys")
+		return
+	}
+
+	responses := make([]models.SigningKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		responses = append(responses, key.ToResponse(h.signingKeyService.GracePeriod()))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"signing_keys": responses})
+}
+
+// RotateSigningKey starts signing with a new key. The old one keeps verifying the
+// tokens it signed until its grace period ends.
+func (h *SigningKeyHandler) RotateSigningKey(c *gin.Context) {
+	if _, ok := middleware.MustGetUser(c); !ok {
+		return
+	}
+
+	key, err := h.signingKeyService.Rotate()
+	if err != nil {
+		h.respondError(c, err, "Failed to rotate signing key")
+		return
+	}
+
+	recordAudit(h.auditService, c, models.AuditActionSigningKeyRotate, 0, fmt.Sprintf("new kid %s", key.KID))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Signing key rotated successfully",
+		"signing_key": key.ToResponse(h.signingKeyService.GracePeriod()),
+	})
+}
+
+func (h *SigningKeyHandler) respondError(c *gin.Context, err error, fallback string) {
+	if err == services.ErrSigningKeysDisabled {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/services"
+)
+
+func TestJWKSServesPublishedKeys(t *testing.T) {
+	s := newTestServer(t, map[string]string{"JWT_SIGNING_METHOD": "RS256"})
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.svc.SigningKeys.Rotate(); err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+	}
+	published, err := s.svc.SigningKeys.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	rec := s.do(http.MethodGet, "/.well-known/jwks.json", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /.well-known/jwks.json = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got, want := rec.Header().Get("Cache-Control"), fmt.Sprintf("public, max-age=%d", int(services.JWKSMaxAge.Seconds())); got != want {
+		t.Errorf("Cache-Control = %q, want %q", got, want)
+	}
+
+	var jwks models.JSONWebKeySet
+	if err := json.Unmarshal(rec.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("decode JWKS: %v", err)
+	}
+	if len(jwks.Keys) != 3 || len(published) != 3 {
+		t.Fatalf("JWKS has %d keys and %d are published, want 3", len(jwks.Keys), len(published))
+	}
+	for i, key := range jwks.Keys {
+		if key.KID != published[i].KID {
+			t.Errorf("key %d kid = %s, want %s", i, key.KID, published[i].KID)
+		}
+		if key.KeyType != "RSA" || key.Algorithm != "RS256" || key.Use != "sig" || key.N == "" || key.E == "" {
+			t.Errorf("key %d = %+v, want an RS256 signing key", i, key)
+		}
+	}
+}
+
+func TestJWKSNotFoundUnderHS256(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	rec := s.do(http.MethodGet, "/.well-known/jwks.json", "", "", "Accept", "application/json")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /.well-known/jwks.json = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -4,16 +4,14 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-
-	"sso-web-app/internal/models"
 )
 
 // AdminRequired middleware checks if the authenticated user has admin privileges
 func AdminRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check if user is authenticated
-		user, exists := c.Get("user")
-		if !exists {
+		authUser := CurrentUser(c)
+		if authUser == nil {
 			c.HTML(http.StatusUnauthorized, "error.html", gin.H{
 				"title": "Unauthorized",
 				"error": "Authentication required",
@@ -22,16 +20,6 @@ func AdminRequired() gin.HandlerFunc {
 			return
 		}
 
-		authUser, ok := user.(*models.User)
-		if !ok {
-			c.HTML(http.StatusInternalServerError, "error.html", gin.H{
-				"title": "Error",
-				"error": "Invalid user data",
-			})
-			c.Abort()
-			return
-		}
-
 		// Check if user has admin privileges
 		if !authUser.IsAdmin && authUser.Role != "admin" {
 			c.HTML(http.StatusForbidden, "error.html", gin.H{
@@ -51,8 +39,8 @@ func AdminRequired() gin.HandlerFunc {
 func SuperAdminRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check if user is authenticated
-		user, exists := c.Get("user")
-		if !exists {
+		authUser := CurrentUser(c)
+		if authUser == nil {
 			c.HTML(http.StatusUnauthorized, "error.html", gin.H{
 				"title": "Unauthorized",
 				"error": "Authentication required",
@@ -61,16 +49,6 @@ func SuperAdminRequired() gin.HandlerFunc {
 			return
 		}
 
-		authUser, ok := user.(*models.User)
-		if !ok {
-			c.HTML(http.StatusInternalServerError, "error.html", gin.H{
-				"title": "Error",
-				"error": "Invalid user data",
-			})
-			c.Abort()
-			return
-		}
-
 		// Check if user has super admin privileges
 		if authUser.Role != "admin" {
 			c.HTML(http.StatusForbidden, "error.html", gin.H{
@@ -90,8 +68,8 @@ func SuperAdminRequired() gin.HandlerFunc {
 func AdminAPIRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check if user is authenticated
-		user, exists := c.Get("user")
-		if !exists {
+		authUser := CurrentUser(c)
+		if authUser == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Authentication required",
 			})
@@ -99,15 +77,6 @@ func AdminAPIRequired() gin.HandlerFunc {
 			return
 		}
 
-		authUser, ok := user.(*models.User)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Invalid user data",
-			})
-			c.Abort()
-			return
-		}
-
 		// Check if user has admin privileges
 		if !authUser.IsAdmin && authUser.Role != "admin" {
 			c.JSON(http.StatusForbidden, gin.H{
@@ -126,8 +95,8 @@ func AdminAPIRequired() gin.HandlerFunc {
 func SuperAdminAPIRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check if user is authenticated
-		user, exists := c.Get("user")
-		if !exists {
+		authUser := CurrentUser(c)
+		if authUser == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Authentication required",
 			})
@@ -135,15 +104,6 @@ func SuperAdminAPIRequired() gin.HandlerFunc {
 			return
 		}
 
-		authUser, ok := user.(*models.User)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Invalid user data",
-			})
-			c.Abort()
-			return
-		}
-
 		// Check if user has super admin privileges
 		if authUser.Role != "admin" {
 			c.JSON(http.StatusForbidden, gin.H{
@@ -162,8 +122,8 @@ func SuperAdminAPIRequired() gin.HandlerFunc {
 func RoleRequired(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check if user is authenticated
-		user, exists := c.Get("user")
-		if !exists {
+		authUser := CurrentUser(c)
+		if authUser == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Authentication required",
 			})
@@ -171,15 +131,6 @@ func RoleRequired(allowedRoles ...string) gin.HandlerFunc {
 			return
 		}
 
-		authUser, ok := user.(*models.User)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Invalid user data",
-			})
-			c.Abort()
-			return
-		}
-
 		// Check if user has any of the allowed roles
 		hasRole := false
 		for _, role := range allowedRoles {
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/services"
+)
+
+func TestRequireScope(t *testing.T) {
+	authService, token := newTestAuthService(t, setupTestDB(t, nil))
+	apiKeys := services.NewAPIKeyService()
+	user, err := repository.NewUserRepository().GetByEmail("bench@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+
+	readKey, _, err := apiKeys.CreateKey(user.ID, models.CreateAPIKeyRequest{Name: "read", Scopes: []string{models.ScopeUsersRead}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	writeKey, _, err := apiKeys.CreateKey(user.ID, models.CreateAPIKeyRequest{Name: "write", Scopes: []string{models.ScopeUsersWrite}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/user", APIKeyMiddleware(apiKeys, AuthMiddleware(authService)), RequireScope(models.ScopeUsersRead), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"key with the scope", "X-API-Key", readKey, http.StatusOK},
+		{"key without the scope", "X-API-Key", writeKey, http.StatusForbidden},
+		{"unknown key", "X-API-Key", "not-a-key", http.StatusUnauthorized},
+		{"session token", "Authorization", "Bearer " + token, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			req.Header.Set(tt.header, tt.value)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Code == http.StatusForbidden {
+				var body map[string]string
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decode body: %v", err)
+				}
+				if body["required_scope"] != models.ScopeUsersRead {
+					t.Errorf("required_scope = %q, want %q", body["required_scope"], models.ScopeUsersRead)
+				}
+			}
+		})
+	}
+}
@@ -1,22 +1,60 @@
 package middleware
 
 import (
+	"log"
+	"math"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"sso-web-app/internal/models"
 	"sso-web-app/internal/services"
+	"sso-web-app/internal/timeutil"
 )
 
+// authOptions configures AuthMiddleware
+type authOptions struct {
+	dbRefresh bool
+	sessions  *services.SessionService
+}
+
+// AuthOption customizes AuthMiddleware behavior
+type AuthOption func(*authOptions)
+
+// WithDBRefresh controls whether the user is reloaded from the database on each request.
+// Disabling it trusts the account snapshot in the token, which saves a query per request
+// but means role or status changes only take effect once the user gets a new token.
+// Revoked tokens are still rejected. Keep the refresh enabled for sensitive routes.
+// While terms acceptance is required the user is always reloaded.
+func WithDBRefresh(enabled bool) AuthOption {
+	return func(o *authOptions) {
+		o.dbRefresh = enabled
+	}
+}
+
+// WithSlidingSession lets cookie sessions near expiry move to a fresh token, sent
+// back in the cookie, so active users stay signed in. Whether and when a session
+// slides is decided by sessions; bearer tokens are never replaced, since the
+// client would have no way to pick up the new one.
+func WithSlidingSession(sessions *services.SessionService) AuthOption {
+	return func(o *authOptions) {
+		o.sessions = sessions
+	}
+}
+
 // AuthMiddleware validates JWT tokens and sets user context
-func AuthMiddleware() gin.HandlerFunc {
-	authService := services.NewAuthService()
+func AuthMiddleware(authService *services.AuthService, opts ...AuthOption) gin.HandlerFunc {
+	options := authOptions{dbRefresh: true}
+	for _, opt := range opts {
+		opt(&options)
+	}
 
 	return gin.HandlerFunc(func(c *gin.Context) {
 		// Try to get token from header
 		authHeader := c.GetHeader("Authorization")
 		var tokenString string
+		fromCookie := false
 
 		if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
 			tokenString = strings.TrimPrefix(authHeader, "Bearer ")
@@ -29,6 +67,7 @@ func AuthMiddleware() gin.HandlerFunc {
 				return
 			}
 			tokenString = cookie
+			fromCookie = true
 		}
 
 		// Validate token
@@ -39,33 +78,102 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Get user from database
-		user, err := authService.GetUserByID(claims.UserID)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-			c.Abort()
+		var user *models.User
+		if !options.dbRefresh && claims.HasSnapshot && !authService.TermsRequired() {
+			// Fast path: trust the account snapshot embedded in the token. The snapshot
+			// does not record accepted terms, so the terms gate needs the stored user.
+			user = authService.UserFromClaims(claims)
+		} else {
+			// Get user from database
+			user, err = authService.GetUserByID(claims.UserID)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+				c.Abort()
+				return
+			}
+		}
+
+		if rejectInactiveUser(c, user) {
 			return
 		}
 
-		if !user.IsActive {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
+		if fromCookie && options.sessions != nil {
+			claims = slideSession(c, options.sessions, claims)
+		}
+
+		setCurrentUser(c, user, claims)
+
+		c.Next()
+	})
+}
+
+// slideSession replaces the session cookie when the session is due a fresh token
+// and returns the claims of the token now in use. A failed refresh is logged and
+// the current token, which is still valid, keeps being used.
+func slideSession(c *gin.Context, sessions *services.SessionService, claims *models.JWTClaims) *models.JWTClaims {
+	token, newClaims, err := sessions.Slide(claims)
+	if err != nil {
+		log.Printf("Failed to refresh session for user %d: %v", claims.UserID, err)
+		return claims
+	}
+	if token == "" {
+		return claims
+	}
+
+	// The new token's lifetime depends on the role and the session's age, so the
+	// cookie lasts exactly as long as the token does
+	maxAge := int(math.Ceil(time.Until(newClaims.ExpiresAt).Seconds()))
+	c.SetCookie("jwt", token, maxAge, "/", sessions.CookieDomain(), sessions.CookieSecure(), true)
+	return newClaims
+}
+
+// rejectInactiveUser aborts the request when the account is deactivated (or its
+// scheduled deactivation has come) or its scheduled access has not started, and
+// reports whether it did
+func rejectInactiveUser(c *gin.Context, user *models.User) bool {
+	if !user.IsActive || user.DeactivationDue(timeutil.Now()) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
+		c.Abort()
+		return true
+	}
+
+	if user.NotYetActive(timeutil.Now()) {
+		RespondNotYetActive(c, user)
+		c.Abort()
+		return true
+	}
+
+	return false
+}
+
+// RespondNotYetActive tells the client when a scheduled account's access begins
+func RespondNotYetActive(c *gin.Context, user *models.User) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":       "Your account is not active yet. Access begins " + timeutil.FormatLocal(*user.ActiveFrom, "Jan 2, 2006 at 15:04 MST") + ".",
+		"code":        "account_not_yet_active",
+		"active_from": user.ActiveFrom,
+	})
+}
+
+// RequireRecentAuth middleware demands re-authentication for sensitive actions
+// when the session's credentials are older than the configured window
+func RequireRecentAuth(authService *services.AuthService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if err := authService.RequireRecentAuth(CurrentClaims(c)); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Please confirm your password to continue",
+				"code":  "reauth_required",
+			})
 			c.Abort()
 			return
 		}
 
-		// Set user in context
-		c.Set("user", user)
-		c.Set("user_id", user.ID)
-		c.Set("user_email", user.Email)
-
 		c.Next()
 	})
 }
 
 // OptionalAuthMiddleware checks for authentication but doesn't require it
-func OptionalAuthMiddleware() gin.HandlerFunc {
-	authService := services.NewAuthService()
-
+func OptionalAuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		// Try to get token from header
 		authHeader := c.GetHeader("Authorization")
@@ -97,34 +205,34 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		if !user.IsActive {
+		if now := timeutil.Now(); !user.IsActive || user.NotYetActive(now) || user.DeactivationDue(now) {
 			c.Next()
 			return
 		}
 
-		// Set user in context
-		c.Set("user", user)
-		c.Set("user_id", user.ID)
-		c.Set("user_email", user.Email)
+		setCurrentUser(c, user, claims)
 
 		c.Next()
 	})
 }
 
-// GetUserFromContext extracts user from Gin context
-func GetUserFromContext(c *gin.Context) *models.User {
-	if user, exists := c.Get("user"); exists {
-		if u, ok := user.(*models.User); ok {
-			return u
-		}
+// respondUserError aborts with an error page for browsers and JSON for everything else
+func respondUserError(c *gin.Context, status int, title, message string) {
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.HTML(status, "error.html", gin.H{
+			"title": title,
+			"error": message,
+		})
+	} else {
+		c.JSON(status, gin.H{"error": message})
 	}
-	return nil
+	c.Abort()
 }
 
 // RequireVerified middleware ensures user is verified
 func RequireVerified() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
-		user := GetUserFromContext(c)
+		user := CurrentUser(c)
 		if user == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
 			c.Abort()
@@ -146,7 +254,7 @@ func CORSMiddleware() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Device-ID, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
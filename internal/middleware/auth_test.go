@@ -0,0 +1,406 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/services"
+)
+
+// setupTestDB opens a fresh, migrated in-memory database for one test and returns
+// the configuration, with env applied on top of the defaults
+func setupTestDB(tb testing.TB, env map[string]string) *configs.Config {
+	tb.Helper()
+	gin.SetMode(gin.TestMode)
+
+	tb.Setenv("APP_ENV", "development")
+	tb.Setenv("DB_LOG_LEVEL", "silent")
+	// Escaped so characters such as # in subtest names cannot end the path and
+	// drop mode=memory, which would leave a database file behind
+	tb.Setenv("DATABASE_URL", "file:"+url.PathEscape(tb.Name())+"?mode=memory&cache=shared")
+	for key, value := range env {
+		tb.Setenv(key, value)
+	}
+
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		tb.Fatalf("LoadConfig: %v", err)
+	}
+	if err := repository.InitDB(cfg); err != nil {
+		tb.Fatalf("InitDB: %v", err)
+	}
+	if err := repository.Migrate(); err != nil {
+		tb.Fatalf("Migrate: %v", err)
+	}
+	return cfg
+}
+
+// newTestAuthService returns an auth service on the test database, with a
+// signed-in user's session token
+func newTestAuthService(tb testing.TB, cfg *configs.Config) (*services.AuthService, string) {
+	tb.Helper()
+	mailer := services.NewMailer(cfg)
+	signingKeys, err := services.NewSigningKeyService(cfg)
+	if err != nil {
+		tb.Fatalf("NewSigningKeyService: %v", err)
+	}
+	authService := services.NewAuthService(cfg, mailer, services.NewSMSSender(cfg), signingKeys, nil)
+
+	user, err := repository.NewUserRepository().Create(&models.User{
+		Email:     "bench@example.com",
+		FirstName: "Bench",
+		LastName:  "User",
+		Role:      "user",
+		IsActive:  true,
+	})
+	if err != nil {
+		tb.Fatalf("Create user: %v", err)
+	}
+	token, err := authService.GenerateJWT(user)
+	if err != nil {
+		tb.Fatalf("GenerateJWT: %v", err)
+	}
+	return authService, token
+}
+
+func TestAuthMiddlewareSetsUser(t *testing.T) {
+	authService, token := newTestAuthService(t, setupTestDB(t, nil))
+
+	tests := []struct {
+		name   string
+		opts   []AuthOption
+		header string
+		want   int
+	}{
+		{"db refresh", nil, "Bearer " + token, http.StatusOK},
+		{"claims only", []AuthOption{WithDBRefresh(false)}, "Bearer " + token, http.StatusOK},
+		{"missing token", nil, "", http.StatusUnauthorized},
+		{"invalid token", []AuthOption{WithDBRefresh(false)}, "Bearer not-a-token", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", AuthMiddleware(authService, tt.opts...), func(c *gin.Context) {
+				if _, ok := CurrentUserID(c); !ok {
+					c.Status(http.StatusInternalServerError)
+					return
+				}
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareOrgID(t *testing.T) {
+	tests := []struct {
+		name  string
+		orgID uint // zero leaves the user outside any organization
+		opts  []AuthOption
+	}{
+		{"organization, db refresh", 42, nil},
+		{"organization, claims only", 42, []AuthOption{WithDBRefresh(false)}},
+		{"no organization", 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, token := newTestAuthService(t, setupTestDB(t, nil))
+
+			if tt.orgID != 0 {
+				users := repository.NewUserRepository()
+				user, err := users.GetByEmail("bench@example.com")
+				if err != nil {
+					t.Fatalf("GetByEmail: %v", err)
+				}
+				user.OrganizationID = &tt.orgID
+				if user, err = users.Update(user); err != nil {
+					t.Fatalf("Update: %v", err)
+				}
+				// A fresh token, so the claims-only path sees the organization too
+				if token, err = authService.GenerateJWT(user); err != nil {
+					t.Fatalf("GenerateJWT: %v", err)
+				}
+			}
+
+			var gotOrgID uint
+			var gotOK bool
+			router := gin.New()
+			router.GET("/", AuthMiddleware(authService, tt.opts...), func(c *gin.Context) {
+				gotOrgID, gotOK = GetOrgIDFromContext(c)
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if gotOK != (tt.orgID != 0) || gotOrgID != tt.orgID {
+				t.Errorf("GetOrgIDFromContext = %d, %v; want %d, %v", gotOrgID, gotOK, tt.orgID, tt.orgID != 0)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareActiveFrom(t *testing.T) {
+	tests := []struct {
+		name       string
+		activeFrom time.Duration // from now; zero leaves ActiveFrom unset
+		want       int
+	}{
+		{"no schedule", 0, http.StatusOK},
+		{"started an hour ago", -time.Hour, http.StatusOK},
+		{"starts tomorrow", 24 * time.Hour, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, token := newTestAuthService(t, setupTestDB(t, nil))
+
+			// Scheduled after the token was issued, as an admin edit would be
+			if tt.activeFrom != 0 {
+				activeFrom := time.Now().Add(tt.activeFrom)
+				if err := repository.GetDB().Model(&models.User{}).Where("email = ?", "bench@example.com").
+					Update("active_from", activeFrom).Error; err != nil {
+					t.Fatalf("set active_from: %v", err)
+				}
+			}
+
+			router := gin.New()
+			router.GET("/", AuthMiddleware(authService), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusForbidden && !strings.Contains(rec.Body.String(), `"account_not_yet_active"`) {
+				t.Errorf("body = %s, want code account_not_yet_active", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareScheduledDeactivation(t *testing.T) {
+	tests := []struct {
+		name         string
+		deactivateAt time.Duration // from now
+		want         int
+	}{
+		{"due tomorrow", 24 * time.Hour, http.StatusOK},
+		{"came an hour ago", -time.Hour, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, token := newTestAuthService(t, setupTestDB(t, nil))
+
+			// The background job has not run yet, so the user is still stored as active
+			if err := repository.GetDB().Model(&models.User{}).Where("email = ?", "bench@example.com").
+				Update("deactivate_at", time.Now().Add(tt.deactivateAt)).Error; err != nil {
+				t.Fatalf("set deactivate_at: %v", err)
+			}
+
+			router := gin.New()
+			router.GET("/", AuthMiddleware(authService), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireRecentAuth(t *testing.T) {
+	authService, token := newTestAuthService(t, setupTestDB(t, nil))
+
+	tests := []struct {
+		name    string
+		authAge time.Duration
+		want    int
+	}{
+		{"just signed in", 0, http.StatusOK},
+		{"inside the window", 4 * time.Minute, http.StatusOK},
+		{"past the window", 6 * time.Minute, http.StatusUnauthorized},
+		{"long ago", 24 * time.Hour, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A refreshed token keeps the auth time of the sign-in it came from
+			claims, err := authService.ValidateJWT(token)
+			if err != nil {
+				t.Fatalf("ValidateJWT: %v", err)
+			}
+			claims.AuthTime = time.Now().Add(-tt.authAge)
+			aged, err := authService.RefreshJWT(claims, time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatalf("RefreshJWT: %v", err)
+			}
+
+			router := gin.New()
+			router.POST("/", AuthMiddleware(authService), RequireRecentAuth(authService), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+aged)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && !strings.Contains(rec.Body.String(), `"reauth_required"`) {
+				t.Errorf("body = %s, want code reauth_required", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareSlidesSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		authAge    time.Duration
+		expiresIn  time.Duration
+		bearer     bool
+		wantMaxAge time.Duration // 0 means no new cookie
+	}{
+		{"near expiry", time.Hour, 10 * time.Minute, false, 2 * time.Hour},
+		{"near expiry, capped by the session lifetime", 23 * time.Hour, 10 * time.Minute, false, time.Hour},
+		{"far from expiry", time.Hour, 90 * time.Minute, false, 0},
+		{"near expiry, bearer token", time.Hour, 10 * time.Minute, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, map[string]string{
+				"SESSION_REFRESH_WINDOW": "30m",
+				"SESSION_TTL_USER":       "2h",
+				"SESSION_MAX_LIFETIME":   "24h",
+			})
+			authService, token := newTestAuthService(t, cfg)
+			sessions := services.NewSessionService(cfg, authService)
+
+			claims, err := authService.ValidateJWT(token)
+			if err != nil {
+				t.Fatalf("ValidateJWT: %v", err)
+			}
+			claims.AuthTime = time.Now().Add(-tt.authAge)
+			aging, err := authService.RefreshJWT(claims, time.Now().Add(tt.expiresIn))
+			if err != nil {
+				t.Fatalf("RefreshJWT: %v", err)
+			}
+
+			router := gin.New()
+			router.GET("/", AuthMiddleware(authService, WithSlidingSession(sessions)), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer "+aging)
+			} else {
+				req.AddCookie(&http.Cookie{Name: "jwt", Value: aging})
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+
+			var cookie *http.Cookie
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == "jwt" {
+					cookie = c
+				}
+			}
+			if tt.wantMaxAge == 0 {
+				if cookie != nil {
+					t.Errorf("session cookie reissued with max-age %d", cookie.MaxAge)
+				}
+				return
+			}
+			if cookie == nil {
+				t.Fatal("session cookie not reissued")
+			}
+
+			newClaims, err := authService.ValidateJWT(cookie.Value)
+			if err != nil {
+				t.Fatalf("reissued token rejected: %v", err)
+			}
+			// The cookie expires with the token it carries
+			if diff := time.Until(newClaims.ExpiresAt) - time.Duration(cookie.MaxAge)*time.Second; diff < -time.Second || diff > time.Second {
+				t.Errorf("cookie max-age %ds, token expires in %s", cookie.MaxAge, time.Until(newClaims.ExpiresAt))
+			}
+			if got := time.Duration(cookie.MaxAge) * time.Second; got < tt.wantMaxAge-5*time.Second || got > tt.wantMaxAge+5*time.Second {
+				t.Errorf("cookie max-age = %s, want about %s", got, tt.wantMaxAge)
+			}
+		})
+	}
+}
+
+// BenchmarkAuthMiddleware compares reloading the user on every request with
+// trusting the account snapshot in the token
+func BenchmarkAuthMiddleware(b *testing.B) {
+	authService, token := newTestAuthService(b, setupTestDB(b, nil))
+
+	modes := []struct {
+		name string
+		opts []AuthOption
+	}{
+		{"db_refresh", nil},
+		{"claims_only", []AuthOption{WithDBRefresh(false)}},
+	}
+
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			router := gin.New()
+			router.GET("/", AuthMiddleware(authService, mode.opts...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					b.Fatalf("status = %d", rec.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConcurrencyLimit handles at most maxInFlight requests at once, which keeps
+// bursts from piling up behind SQLite's single writer. Up to queueDepth further
+// requests wait for a slot, each for at most queueTimeout; requests beyond that, or
+// that wait too long, get 503 with Retry-After instead of hanging. A request whose
+// client goes away while queued is dropped. Paths in exempt are never limited, so
+// health probes keep answering under load.
+func ConcurrencyLimit(maxInFlight, queueDepth int, queueTimeout time.Duration, exempt ...string) gin.HandlerFunc {
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+
+	// admitted holds a token for every request running or queued, slots one for
+	// every request running
+	admitted := make(chan struct{}, maxInFlight+queueDepth)
+	slots := make(chan struct{}, maxInFlight)
+	retryAfter := strconv.Itoa(int(math.Ceil(queueTimeout.Seconds())))
+
+	return func(c *gin.Context) {
+		if exemptPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		select {
+		case admitted <- struct{}{}:
+		default:
+			respondOverloaded(c, retryAfter)
+			return
+		}
+		defer func() { <-admitted }()
+
+		timer := time.NewTimer(queueTimeout)
+		defer timer.Stop()
+
+		select {
+		case slots <- struct{}{}:
+		case <-timer.C:
+			respondOverloaded(c, retryAfter)
+			return
+		case <-c.Request.Context().Done():
+			c.Abort()
+			return
+		}
+		defer func() { <-slots }()
+
+		c.Next()
+	}
+}
+
+// respondOverloaded sheds a request the server has no capacity for
+func respondOverloaded(c *gin.Context, retryAfter string) {
+	c.Header("Retry-After", retryAfter)
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error": "The server is busy. Please try again shortly.",
+		"code":  "server_busy",
+	})
+	c.Abort()
+}
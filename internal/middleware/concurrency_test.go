@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// blockingRouter serves /work through limit, holding each request until release is
+// closed and signalling started as each one begins
+func blockingRouter(limit gin.HandlerFunc) (router *gin.Engine, started chan struct{}, release chan struct{}) {
+	gin.SetMode(gin.TestMode)
+	started = make(chan struct{}, 16)
+	release = make(chan struct{})
+
+	router = gin.New()
+	router.Use(limit)
+	router.GET("/work", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	router.GET("/healthz", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router, started, release
+}
+
+// serveAsync serves a GET of path in the background and returns its response once done
+func serveAsync(router *gin.Engine, path string) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		done <- rec
+	}()
+	return done
+}
+
+// waitFor returns the response from done, failing the test if it takes too long
+func waitFor(t *testing.T, done <-chan *httptest.ResponseRecorder) *httptest.ResponseRecorder {
+	t.Helper()
+	select {
+	case rec := <-done:
+		return rec
+	case <-time.After(2 * time.Second):
+		t.Fatal("request still hanging")
+		return nil
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	const maxInFlight, queueDepth = 2, 1
+	router, started, release := blockingRouter(ConcurrencyLimit(maxInFlight, queueDepth, 5*time.Second, "/healthz"))
+
+	var running []<-chan *httptest.ResponseRecorder
+	for i := 0; i < maxInFlight; i++ {
+		running = append(running, serveAsync(router, "/work"))
+		<-started
+	}
+	queued := serveAsync(router, "/work")
+	// Give the queued request time to take its place in the queue
+	time.Sleep(50 * time.Millisecond)
+
+	// With every slot and the queue taken, further requests are shed at once
+	var shed []<-chan *httptest.ResponseRecorder
+	for i := 0; i < 5; i++ {
+		shed = append(shed, serveAsync(router, "/work"))
+	}
+	for _, done := range shed {
+		rec := waitFor(t, done)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("excess request status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+		}
+		if rec.Header().Get("Retry-After") != "5" {
+			t.Errorf("Retry-After = %q, want 5", rec.Header().Get("Retry-After"))
+		}
+	}
+
+	// Health checks are never limited
+	if rec := waitFor(t, serveAsync(router, "/healthz")); rec.Code != http.StatusOK {
+		t.Errorf("GET /healthz under load = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// Once the running requests finish, the queued one is served
+	close(release)
+	for _, done := range append(running, queued) {
+		if rec := waitFor(t, done); rec.Code != http.StatusOK {
+			t.Errorf("admitted request status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestConcurrencyLimitQueueTimeout(t *testing.T) {
+	router, started, release := blockingRouter(ConcurrencyLimit(1, 1, 100*time.Millisecond))
+	defer close(release)
+
+	running := serveAsync(router, "/work")
+	<-started
+
+	// A queued request gives up after the queue timeout rather than waiting for
+	// the running one
+	rec := waitFor(t, serveAsync(router, "/work"))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("queued request status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Retry-After = %q, want 1", rec.Header().Get("Retry-After"))
+	}
+
+	select {
+	case <-running:
+		t.Error("running request finished before it was released")
+	default:
+	}
+}
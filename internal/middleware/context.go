@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/internal/models"
+)
+
+// Context keys the authentication middlewares store the current user under. Read
+// them through the accessors below rather than with c.Get.
+const (
+	// UserKey holds the *models.User
+	UserKey = "user"
+	// ClaimsKey holds the *models.JWTClaims of the session token, when the request
+	// was authenticated with one rather than an API key
+	ClaimsKey = "claims"
+	// OrgIDKey holds the user's organization ID, for users who belong to one
+	OrgIDKey = "org_id"
+)
+
+// setCurrentUser records the authenticated user for the rest of the request.
+// Every authentication middleware goes through it, so the keys are always set
+// together; claims is nil for API-key requests.
+func setCurrentUser(c *gin.Context, user *models.User, claims *models.JWTClaims) {
+	c.Set(UserKey, user)
+	if user.OrganizationID != nil {
+		c.Set(OrgIDKey, *user.OrganizationID)
+	}
+	if claims != nil {
+		c.Set(ClaimsKey, claims)
+	}
+}
+
+// CurrentUser returns the authenticated user, or nil for anonymous requests
+func CurrentUser(c *gin.Context) *models.User {
+	if value, exists := c.Get(UserKey); exists {
+		if user, ok := value.(*models.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// CurrentUserID returns the authenticated user's ID, and false for anonymous requests
+func CurrentUserID(c *gin.Context) (uint, bool) {
+	if user := CurrentUser(c); user != nil {
+		return user.ID, true
+	}
+	return 0, false
+}
+
+// IsAuthenticated reports whether a middleware signed the request's user in
+func IsAuthenticated(c *gin.Context) bool {
+	return CurrentUser(c) != nil
+}
+
+// CurrentClaims returns the claims of the session token the request was
+// authenticated with, or nil for anonymous and API-key requests
+func CurrentClaims(c *gin.Context) *models.JWTClaims {
+	if value, exists := c.Get(ClaimsKey); exists {
+		if claims, ok := value.(*models.JWTClaims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+// GetOrgIDFromContext returns the organization of the authenticated user, and
+// false when there is none, as in single-tenant deployments
+func GetOrgIDFromContext(c *gin.Context) (uint, bool) {
+	if value, exists := c.Get(OrgIDKey); exists {
+		orgID, ok := value.(uint)
+		return orgID, ok
+	}
+	return 0, false
+}
+
+// MustGetUser returns the authenticated user for handlers behind the auth
+// middleware. When there is none it answers the request itself (JSON, or the
+// error page for browsers) and reports false, so the handler only has to return.
+func MustGetUser(c *gin.Context) (*models.User, bool) {
+	user := CurrentUser(c)
+	if user == nil {
+		respondUserError(c, http.StatusUnauthorized, "Unauthorized", "Authentication required")
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/services"
+)
+
+func TestMustGetUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	signedIn := &models.User{ID: 7, Email: "jane@example.com"}
+
+	tests := []struct {
+		name     string
+		stored   interface{} // value under UserKey; nil stores nothing
+		accept   string
+		wantOK   bool
+		wantCode int
+		wantType string
+	}{
+		{"signed in", signedIn, "application/json", true, http.StatusOK, ""},
+		{"anonymous, API client", nil, "application/json", false, http.StatusUnauthorized, "application/json"},
+		{"anonymous, browser", nil, "text/html", false, http.StatusUnauthorized, "text/html"},
+		{"wrong type stored", models.User{ID: 7}, "application/json", false, http.StatusUnauthorized, "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUser *models.User
+			var gotOK, reached bool
+
+			router := gin.New()
+			router.SetHTMLTemplate(template.Must(template.New("error.html").Parse("{{.title}}: {{.error}}")))
+			router.GET("/", func(c *gin.Context) {
+				if tt.stored != nil {
+					c.Set(UserKey, tt.stored)
+				}
+			}, func(c *gin.Context) {
+				gotUser, gotOK = MustGetUser(c)
+				if !gotOK {
+					return
+				}
+				c.Status(http.StatusOK)
+			}, func(c *gin.Context) {
+				reached = true
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if gotOK != tt.wantOK || rec.Code != tt.wantCode {
+				t.Fatalf("MustGetUser ok = %v with status %d, want %v with %d", gotOK, rec.Code, tt.wantOK, tt.wantCode)
+			}
+			if tt.wantOK {
+				if gotUser != signedIn {
+					t.Errorf("user = %+v, want the signed-in user", gotUser)
+				}
+				return
+			}
+
+			if gotUser != nil {
+				t.Errorf("user = %+v, want nil", gotUser)
+			}
+			if reached {
+				t.Error("the handler chain was not aborted")
+			}
+			if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("Content-Type = %q, want %s", got, tt.wantType)
+			}
+			if !strings.Contains(rec.Body.String(), "Authentication required") {
+				t.Errorf("body = %s, want the authentication message", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCurrentUserAccessors(t *testing.T) {
+	authService, token := newTestAuthService(t, setupTestDB(t, nil))
+	apiKeys := services.NewAPIKeyService()
+	user, err := repository.NewUserRepository().GetByEmail("bench@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	apiKey, _, err := apiKeys.CreateKey(user.ID, models.CreateAPIKeyRequest{Name: "read", Scopes: []string{models.ScopeUsersRead}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+
+	type seen struct {
+		authenticated bool
+		userID        uint
+		hasID         bool
+		hasClaims     bool
+	}
+	var got seen
+	report := func(c *gin.Context) {
+		id, ok := CurrentUserID(c)
+		got = seen{IsAuthenticated(c), id, ok, CurrentClaims(c) != nil}
+		if (CurrentUser(c) != nil) != got.authenticated {
+			t.Error("CurrentUser and IsAuthenticated disagree")
+		}
+		c.Status(http.StatusOK)
+	}
+
+	router := gin.New()
+	router.GET("/required", AuthMiddleware(authService), report)
+	router.GET("/optional", OptionalAuthMiddleware(authService), report)
+	router.GET("/api-key", APIKeyMiddleware(apiKeys, AuthMiddleware(authService)), report)
+	router.GET("/public", report)
+
+	signedIn := seen{true, user.ID, true, true}
+	anonymous := seen{}
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		value    string
+		cookie   string
+		wantCode int
+		want     seen
+	}{
+		{"required, bearer token", "/required", "Authorization", "Bearer " + token, "", http.StatusOK, signedIn},
+		{"required, cookie", "/required", "", "", token, http.StatusOK, signedIn},
+		{"optional, bearer token", "/optional", "Authorization", "Bearer " + token, "", http.StatusOK, signedIn},
+		{"optional, cookie", "/optional", "", "", token, http.StatusOK, signedIn},
+		{"optional, no token", "/optional", "", "", "", http.StatusOK, anonymous},
+		{"optional, invalid token", "/optional", "Authorization", "Bearer not-a-token", "", http.StatusOK, anonymous},
+		{"API key", "/api-key", "X-API-Key", apiKey, "", http.StatusOK, seen{true, user.ID, true, false}},
+		{"no middleware", "/public", "Authorization", "Bearer " + token, "", http.StatusOK, anonymous},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = seen{}
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "jwt", Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got != tt.want {
+				t.Errorf("accessors = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// The required middleware stops anonymous requests before the handler
+	got = seen{}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/required", nil))
+	if rec.Code != http.StatusUnauthorized || got != anonymous {
+		t.Errorf("required, no token = %d with %+v, want 401 without reaching the handler", rec.Code, got)
+	}
+}
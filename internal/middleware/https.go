@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForceHTTPS redirects plain-HTTP requests to the same URL over HTTPS and sets
+// Strict-Transport-Security on HTTPS responses. GET and HEAD are redirected with
+// 301; other methods get 308 so the method and body survive the redirect.
+//
+// A request is HTTPS if it arrived over TLS, or if X-Forwarded-Proto says so and
+// the peer is one of trustedProxies (IPs or CIDRs). With no trusted proxies every
+// peer is believed, matching gin's default. Paths in exempt are served over either
+// scheme, so health probes over plain HTTP keep working.
+func ForceHTTPS(hstsMaxAge time.Duration, trustedProxies []string, exempt ...string) (gin.HandlerFunc, error) {
+	proxies, err := parseNetworks(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+	hsts := "max-age=" + strconv.Itoa(int(hstsMaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		if exemptPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		if isHTTPS(c, proxies) {
+			c.Header("Strict-Transport-Security", hsts)
+			c.Next()
+			return
+		}
+
+		status := http.StatusMovedPermanently
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		target := *c.Request.URL
+		target.Scheme = "https"
+		target.Host = c.Request.Host
+		c.Redirect(status, target.String())
+		c.Abort()
+	}, nil
+}
+
+// isHTTPS reports whether the client reached the server over HTTPS
+func isHTTPS(c *gin.Context, proxies []*net.IPNet) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+
+	proto := c.GetHeader("X-Forwarded-Proto")
+	if proto == "" || !trustedPeer(c.RemoteIP(), proxies) {
+		return false
+	}
+	// A chain of proxies appends its schemes; the first is the client's
+	first, _, _ := strings.Cut(proto, ",")
+	return strings.EqualFold(strings.TrimSpace(first), "https")
+}
+
+// trustedPeer reports whether ip belongs to one of proxies; an empty list trusts all
+func trustedPeer(ip string, proxies []*net.IPNet) bool {
+	if len(proxies) == 0 {
+		return true
+	}
+	return ipInNetworks(ip, proxies)
+}
+
+// parseNetworks turns IPs and CIDRs into networks; a bare IP matches only itself
+func parseNetworks(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestForceHTTPS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	proxy := []string{"10.0.0.1"}
+
+	tests := []struct {
+		name     string
+		proxies  []string
+		method   string
+		path     string
+		peer     string
+		proto    string
+		tls      bool
+		want     int
+		wantHSTS bool
+	}{
+		{"plain HTTP", nil, http.MethodGet, "/dashboard?tab=1", "192.0.2.1", "", false, http.StatusMovedPermanently, false},
+		{"plain HTTP POST", nil, http.MethodPost, "/login", "192.0.2.1", "", false, http.StatusPermanentRedirect, false},
+		{"TLS", nil, http.MethodGet, "/dashboard", "192.0.2.1", "", true, http.StatusOK, true},
+		{"HTTPS behind a proxy", nil, http.MethodGet, "/dashboard", "192.0.2.1", "https", false, http.StatusOK, true},
+		{"HTTP behind a proxy", nil, http.MethodGet, "/dashboard", "192.0.2.1", "http", false, http.StatusMovedPermanently, false},
+		{"proxy chain", nil, http.MethodGet, "/dashboard", "192.0.2.1", "HTTPS, http", false, http.StatusOK, true},
+		{"trusted proxy", proxy, http.MethodGet, "/dashboard", "10.0.0.1", "https", false, http.StatusOK, true},
+		{"header from an untrusted peer", proxy, http.MethodGet, "/dashboard", "192.0.2.1", "https", false, http.StatusMovedPermanently, false},
+		{"health check over HTTP", nil, http.MethodGet, "/healthz", "192.0.2.1", "", false, http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forceHTTPS, err := ForceHTTPS(time.Hour, tt.proxies, "/healthz")
+			if err != nil {
+				t.Fatalf("ForceHTTPS: %v", err)
+			}
+
+			router := gin.New()
+			router.Use(forceHTTPS)
+			router.Any("/*path", func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(tt.method, "http://sso.example.com"+tt.path, nil)
+			req.RemoteAddr = tt.peer + ":1234"
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Code != http.StatusOK {
+				if got, want := rec.Header().Get("Location"), "https://sso.example.com"+tt.path; got != want {
+					t.Errorf("Location = %q, want %q", got, want)
+				}
+			}
+			hsts := rec.Header().Get("Strict-Transport-Security")
+			if tt.wantHSTS && hsts != "max-age=3600" {
+				t.Errorf("Strict-Transport-Security = %q, want max-age=3600", hsts)
+			}
+			if !tt.wantHSTS && hsts != "" {
+				t.Errorf("Strict-Transport-Security = %q over plain HTTP", hsts)
+			}
+		})
+	}
+}
+
+func TestForceHTTPSInvalidProxy(t *testing.T) {
+	if _, err := ForceHTTPS(time.Hour, []string{"not-an-ip"}); err == nil || !strings.Contains(err.Error(), "not-an-ip") {
+		t.Errorf("ForceHTTPS(bad proxy) error = %v, want it to name the entry", err)
+	}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIPAllowlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	office := []string{"203.0.113.0/24", "2001:db8::/32", "198.51.100.7"}
+	proxy := []string{"10.0.0.1"}
+
+	tests := []struct {
+		name      string
+		allowed   []string
+		proxies   []string
+		peer      string
+		forwarded string
+		want      int
+	}{
+		{"in range", office, nil, "203.0.113.45", "", http.StatusOK},
+		{"single address", office, nil, "198.51.100.7", "", http.StatusOK},
+		{"IPv6 in range", office, nil, "[2001:db8::1]", "", http.StatusOK},
+		{"out of range", office, nil, "192.0.2.1", "", http.StatusForbidden},
+		{"next to a single address", office, nil, "198.51.100.8", "", http.StatusForbidden},
+		{"empty allowlist", nil, nil, "192.0.2.1", "", http.StatusOK},
+		{"forged header without trusted proxies", office, nil, "192.0.2.1", "203.0.113.45", http.StatusForbidden},
+		{"header ignored for an allowed peer", office, nil, "203.0.113.45", "192.0.2.1", http.StatusOK},
+		{"client behind a trusted proxy", office, proxy, "10.0.0.1", "203.0.113.45", http.StatusOK},
+		{"blocked client behind a trusted proxy", office, proxy, "10.0.0.1", "192.0.2.1", http.StatusForbidden},
+		{"header from an untrusted peer", office, proxy, "192.0.2.1", "203.0.113.45", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowlist, err := IPAllowlist(tt.allowed, tt.proxies)
+			if err != nil {
+				t.Fatalf("IPAllowlist: %v", err)
+			}
+
+			// As cmd/server does, the router trusts only the configured proxies
+			router := gin.New()
+			if tt.proxies != nil {
+				if err := router.SetTrustedProxies(tt.proxies); err != nil {
+					t.Fatalf("SetTrustedProxies: %v", err)
+				}
+			}
+			router.GET("/admin", allowlist, func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.RemoteAddr = tt.peer + ":1234"
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("GET /admin from %s (X-Forwarded-For %q) = %d, want %d", tt.peer, tt.forwarded, rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPAllowlistRejectsInvalidEntries(t *testing.T) {
+	for _, entry := range []string{"203.0.113.0/33", "203.0.113", "office", "2001:db8::/129", " 203.0.113.1"} {
+		t.Run(entry, func(t *testing.T) {
+			_, err := IPAllowlist([]string{"198.51.100.0/24", entry}, nil)
+			if err == nil {
+				t.Fatalf("IPAllowlist accepted %q", entry)
+			}
+			if !strings.Contains(err.Error(), entry) {
+				t.Errorf("error %q does not name the entry %q", err, entry)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"sso-web-app/internal/services"
+)
+
+// RequireTermsAccepted holds back signed-in users who have not accepted the
+// current terms of service. Browsers loading a page are sent to /terms to accept
+// them; other requests get 403 with code terms_not_accepted. It must run after
+// the user is authenticated, and is left off the routes needed to accept the
+// terms or sign out.
+func RequireTermsAccepted(authService *services.AuthService) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		user := CurrentUser(c)
+		if user == nil || !authService.TermsOutdated(user) {
+			c.Next()
+			return
+		}
+
+		if c.Request.Method == http.MethodGet && c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+			c.Redirect(http.StatusFound, "/terms")
+			c.Abort()
+			return
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":         "Please accept the updated terms of service to continue",
+			"code":          "terms_not_accepted",
+			"terms_version": authService.TermsVersion(),
+			"terms_url":     authService.TermsURL(),
+		})
+		c.Abort()
+	})
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Scopes that can be granted to API keys
+const (
+	ScopeUsersRead  = "users.read"
+	ScopeUsersWrite = "users.write"
+)
+
+// ValidScopes lists every known API key scope
+var ValidScopes = map[string]bool{
+	ScopeUsersRead:  true,
+	ScopeUsersWrite: true,
+}
+
+// APIKey lets a user's scripts and services call the API without a browser session
+type APIKey struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"not null" json:"prefix"`        // Leading characters of the key, for recognizing it
+	KeyHash    string     `gorm:"not null;uniqueIndex" json:"-"` // SHA-256 of the full key
+	Scopes     string     `gorm:"not null;default:''" json:"-"`  // Comma-separated scope names
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ScopeList returns the key's scopes as a slice
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// SetScopes stores the given scopes on the key
+func (k *APIKey) SetScopes(scopes []string) {
+	k.Scopes = strings.Join(scopes, ",")
+}
+
+// HasScope reports whether the key grants scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, granted := range k.ScopeList() {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyResponse represents API key metadata returned to clients. The key itself
+// is only returned once, when it is created.
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  Timestamp  `json:"created_at"`
+	LastUsedAt *Timestamp `json:"last_used_at,omitempty"`
+	RevokedAt  *Timestamp `json:"revoked_at,omitempty"`
+}
+
+// ToResponse converts APIKey to APIKeyResponse
+func (k *APIKey) ToResponse() APIKeyResponse {
+	return APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.ScopeList(),
+		CreatedAt:  NewTimestamp(k.CreatedAt),
+		LastUsedAt: NewTimestampPtr(k.LastUsedAt),
+		RevokedAt:  NewTimestampPtr(k.RevokedAt),
+	}
+}
+
+// CreateAPIKeyRequest represents a request to create an API key
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"time"
+)
+
+// Audit log actions
+const (
+	AuditActionRegister       = "user.register"
+	AuditActionLogin          = "user.login"
+	AuditActionLoginFailed    = "user.login_failed"
+	AuditActionLogout         = "user.logout"
+	AuditActionPasswordChange = "user.password_change"
+	AuditActionTermsAccept    = "user.terms_accept"
+	AuditActionProviderLink   = "user.provider_link"
+
+	AuditActionUserUpdate         = "admin.user_update"
+	AuditActionUserActivate       = "admin.user_activate"
+	AuditActionUserDeactivate     = "admin.user_deactivate"
+	AuditActionDeactivationCancel = "admin.deactivation_cancel"
+	AuditActionUserDelete         = "admin.user_delete"
+	AuditActionUserPromote        = "admin.user_promote"
+	AuditActionUserDemote         = "admin.user_demote"
+	AuditActionUserMerge          = "admin.user_merge"
+	AuditActionUserExport         = "admin.user_export"
+	AuditActionProviderUnlink     = "admin.provider_unlink"
+	AuditActionVerificationSend   = "admin.verification_send"
+	AuditActionLockoutClear       = "admin.lockout_clear"
+	AuditActionForceLogout        = "admin.force_logout"
+	AuditActionAPIKeyRevoke       = "admin.api_key_revoke"
+	AuditActionNotifyUsers        = "admin.notify_users"
+	AuditActionInvitationCreate   = "admin.invitation_create"
+	AuditActionRoleCreate         = "admin.role_create"
+	AuditActionRoleUpdate         = "admin.role_update"
+	AuditActionRoleDelete         = "admin.role_delete"
+	AuditActionRoleAssign         = "admin.role_assign"
+	AuditActionSigningKeyRotate   = "admin.signing_key_rotate"
+)
+
+// ValidAuditActions lists every action that can be recorded or filtered on
+var ValidAuditActions = map[string]bool{
+	AuditActionRegister:           true,
+	AuditActionLogin:              true,
+	AuditActionLoginFailed:        true,
+	AuditActionLogout:             true,
+	AuditActionPasswordChange:     true,
+	AuditActionTermsAccept:        true,
+	AuditActionProviderLink:       true,
+	AuditActionUserUpdate:         true,
+	AuditActionUserActivate:       true,
+	AuditActionUserDeactivate:     true,
+	AuditActionDeactivationCancel: true,
+	AuditActionUserDelete:         true,
+	AuditActionUserPromote:        true,
+	AuditActionUserDemote:         true,
+	AuditActionUserMerge:          true,
+	AuditActionUserExport:         true,
+	AuditActionProviderUnlink:     true,
+	AuditActionVerificationSend:   true,
+	AuditActionLockoutClear:       true,
+	AuditActionForceLogout:        true,
+	AuditActionAPIKeyRevoke:       true,
+	AuditActionNotifyUsers:        true,
+	AuditActionInvitationCreate:   true,
+	AuditActionRoleCreate:         true,
+	AuditActionRoleUpdate:         true,
+	AuditActionRoleDelete:         true,
+	AuditActionRoleAssign:         true,
+	AuditActionSigningKeyRotate:   true,
+}
+
+// AuditLog records a security-relevant action
+type AuditLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	ActorID   uint   `gorm:"index" json:"actor_id,omitempty"`  // User who acted; 0 when nobody was signed in
+	TargetID  uint   `gorm:"index" json:"target_id,omitempty"` // User acted upon, if any
+	Action    string `gorm:"not null;index" json:"action"`
+	IPAddress string `json:"ip_address,omitempty"`
+	Details   string `json:"details,omitempty"`
+}
+
+// AuditLogFilter narrows an audit log query. Zero values mean "no filter".
+type AuditLogFilter struct {
+	ActorID  uint
+	TargetID uint
+	Actions  []string
+	From     *time.Time
+	To       *time.Time
+	Oldest   bool // Sort oldest first instead of newest first
+	Limit    int
+	Offset   int
+}
+
+// AuditLogResponse represents an audit entry returned to admins
+type AuditLogResponse struct {
+	ID        uint      `json:"id"`
+	ActorID   uint      `json:"actor_id,omitempty"`
+	TargetID  uint      `json:"target_id,omitempty"`
+	Action    string    `json:"action"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt Timestamp `json:"created_at"`
+}
+
+// ToResponse converts AuditLog to AuditLogResponse
+func (l *AuditLog) ToResponse() AuditLogResponse {
+	return AuditLogResponse{
+		ID:        l.ID,
+		ActorID:   l.ActorID,
+		TargetID:  l.TargetID,
+		Action:    l.Action,
+		IPAddress: l.IPAddress,
+		Details:   l.Details,
+		CreatedAt: NewTimestamp(l.CreatedAt),
+	}
+}
+
+// Reasons a sign-in attempt failed, as reported in FailedLoginEvent
+const (
+	FailedLoginUnknownEmail     = "unknown_email"
+	FailedLoginInvalidPassword  = "invalid_password"
+	FailedLoginThrottled        = "throttled"
+	FailedLoginEmailNotVerified = "email_not_verified"
+	FailedLoginNotYetActive     = "account_not_yet_active"
+	FailedLoginPasswordExpired  = "password_expired"
+)
+
+// FailedLoginEvent describes one failed password sign-in for the audit log and the
+// failed-login webhook. It never carries the submitted password or the stored hash.
+type FailedLoginEvent struct {
+	Email     string    `json:"email"`
+	UserID    uint      `json:"user_id,omitempty"` // Set when the email matched an account
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Reason    string    `json:"reason"`
+	Time      time.Time `json:"time"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// Gravatar fallback settings, configured once at startup
+var (
+	gravatarEnabled bool
+	gravatarDefault = "identicon"
+)
+
+// ConfigureGravatar enables Gravatar fallback avatars for users without an avatar.
+// defaultStyle is Gravatar's "d" parameter (identicon, mp, retro, ...).
+func ConfigureGravatar(enabled bool, defaultStyle string) {
+	gravatarEnabled = enabled
+	if defaultStyle != "" {
+		gravatarDefault = defaultStyle
+	}
+}
+
+// GravatarURL returns the Gravatar image URL for an email address
+func GravatarURL(email, defaultStyle string) string {
+	normalized := strings.ToLower(strings.TrimSpace(email))
+	hash := md5.Sum([]byte(normalized))
+	return "https://www.gravatar.com/avatar/" + hex.EncodeToString(hash[:]) + "?d=" + url.QueryEscape(defaultStyle)
+}
+
+// avatarURL returns the user's explicit avatar, falling back to Gravatar when enabled.
+// The fallback is computed on the fly so it follows email changes.
+func (u *User) avatarURL() string {
+	if u.AvatarURL != nil && *u.AvatarURL != "" {
+		return *u.AvatarURL
+	}
+	if gravatarEnabled && u.Email != "" {
+		return GravatarURL(u.Email, gravatarDefault)
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package models
+
+import "testing"
+
+func TestGravatarURL(t *testing.T) {
+	// Gravatar's documented example: the address is trimmed and lowercased first
+	got := GravatarURL(" MyEmailAddress@example.com ", "identicon")
+	want := "https://www.gravatar.com/avatar/0bc83cb571cd1c50ba6f3e8a78ef1346?d=identicon"
+	if got != want {
+		t.Errorf("GravatarURL = %q, want %q", got, want)
+	}
+}
+
+func TestUserResponseAvatar(t *testing.T) {
+	defer ConfigureGravatar(false, "identicon")
+
+	custom := "https://cdn.example.com/me.png"
+	empty := ""
+	gravatar := "https://www.gravatar.com/avatar/0bc83cb571cd1c50ba6f3e8a78ef1346?d=retro"
+
+	tests := []struct {
+		name    string
+		enabled bool
+		avatar  *string
+		want    string
+	}{
+		{"set avatar wins", true, &custom, custom},
+		{"no avatar", true, nil, gravatar},
+		{"empty avatar", true, &empty, gravatar},
+		{"gravatar disabled", false, nil, ""},
+		{"gravatar disabled, set avatar", false, &custom, custom},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ConfigureGravatar(tt.enabled, "retro")
+			user := &User{Email: "myemailaddress@example.com", AvatarURL: tt.avatar}
+
+			if got := user.ToResponse().AvatarURL; got != tt.want {
+				t.Errorf("avatar_url = %q, want %q", got, tt.want)
+			}
+			if tt.avatar == nil && user.AvatarURL != nil {
+				t.Error("ToResponse stored the fallback on the user")
+			}
+		})
+	}
+}
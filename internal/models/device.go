@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// UserDevice records a device (IP + user agent) a user has signed in from
+type UserDevice struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_user_device" json:"user_id"`
+	Fingerprint string    `gorm:"not null;uniqueIndex:idx_user_device" json:"-"` // SHA-256 of IP and user agent
+	IPAddress   string    `json:"ip_address"`
+	UserAgent   string    `json:"user_agent"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Profile fields the user directory can be configured to show
+const (
+	DirectoryFieldName     = "name"
+	DirectoryFieldAvatar   = "avatar"
+	DirectoryFieldRole     = "role"
+	DirectoryFieldLocation = "location"
+	DirectoryFieldBio      = "bio"
+)
+
+// directoryFieldAllowlist lists every field the directory may show. Contact and
+// account details such as the email address are deliberately absent.
+var directoryFieldAllowlist = map[string]bool{
+	DirectoryFieldName:     true,
+	DirectoryFieldAvatar:   true,
+	DirectoryFieldRole:     true,
+	DirectoryFieldLocation: true,
+	DirectoryFieldBio:      true,
+}
+
+// directoryFields is the configured field set, name only until configured
+var directoryFields = map[string]bool{DirectoryFieldName: true}
+
+// ConfigureDirectory sets which fields ToPublicResponse fills. It rejects names
+// outside the allowlist, so a typo cannot silently hide a field and no setting
+// can expose anything sensitive. It is called once at startup.
+func ConfigureDirectory(fields []string) error {
+	configured := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		if !directoryFieldAllowlist[field] {
+			return fmt.Errorf("unknown directory field %q: must be one of name, avatar, role, location, bio", field)
+		}
+		configured[field] = true
+	}
+	directoryFields = configured
+	return nil
+}
+
+// PublicUserResponse is a user as other signed-in users see them in the
+// directory. Only the ID and the configured fields are set.
+type PublicUserResponse struct {
+	ID        uint   `json:"id"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Location  string `json:"location,omitempty"`
+	Bio       string `json:"bio,omitempty"`
+}
+
+// ToPublicResponse converts User to its directory entry
+func (u *User) ToPublicResponse() PublicUserResponse {
+	response := PublicUserResponse{ID: u.ID}
+
+	if directoryFields[DirectoryFieldName] {
+		response.FirstName = u.FirstName
+		response.LastName = u.LastName
+	}
+	if directoryFields[DirectoryFieldAvatar] {
+		response.AvatarURL = u.avatarURL()
+	}
+	if directoryFields[DirectoryFieldRole] {
+		response.Role = u.Role
+	}
+	if directoryFields[DirectoryFieldLocation] && u.Location != nil {
+		response.Location = *u.Location
+	}
+	if directoryFields[DirectoryFieldBio] && u.Bio != nil {
+		response.Bio = *u.Bio
+	}
+
+	return response
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestToPublicResponseFields(t *testing.T) {
+	defer ConfigureDirectory([]string{DirectoryFieldName})
+
+	location, bio, avatar := "Berlin", "Hello", "https://cdn.example.com/me.png"
+	user := &User{
+		ID:        7,
+		Email:     "jane@example.com",
+		Password:  "$2a$10$hash",
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Role:      "admin",
+		IsAdmin:   true,
+		Location:  &location,
+		Bio:       &bio,
+		AvatarURL: &avatar,
+	}
+
+	tests := []struct {
+		name   string
+		fields []string
+		want   []string
+	}{
+		{"minimal", []string{"name"}, []string{"first_name", "id", "last_name"}},
+		{"none", nil, []string{"id"}},
+		{"location and bio", []string{" Location ", "bio"}, []string{"bio", "id", "location"}},
+		{"everything allowed", []string{"name", "avatar", "role", "location", "bio"},
+			[]string{"avatar_url", "bio", "first_name", "id", "last_name", "location", "role"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ConfigureDirectory(tt.fields); err != nil {
+				t.Fatalf("ConfigureDirectory: %v", err)
+			}
+
+			raw, err := json.Marshal(user.ToPublicResponse())
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var serialized map[string]interface{}
+			json.Unmarshal(raw, &serialized)
+			var got []string
+			for key := range serialized {
+				got = append(got, key)
+			}
+			sort.Strings(got)
+
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("fields = %v, want %v", got, tt.want)
+			}
+			if strings.Contains(string(raw), user.Email) || strings.Contains(string(raw), user.Password) {
+				t.Errorf("directory entry leaks account details: %s", raw)
+			}
+		})
+	}
+}
+
+func TestConfigureDirectoryRejectsSensitiveFields(t *testing.T) {
+	defer ConfigureDirectory([]string{DirectoryFieldName})
+
+	for _, field := range []string{"email", "password", "is_admin", "phone_number", "google_id", "nmae"} {
+		t.Run(field, func(t *testing.T) {
+			if err := ConfigureDirectory([]string{"name", field}); err == nil {
+				t.Errorf("ConfigureDirectory accepted %q", field)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package models
+
+// UserDataExport is everything stored about one user, as returned by a data export.
+// Password hashes, token IDs and device fingerprints are never included.
+type UserDataExport struct {
+	ExportedAt      Timestamp              `json:"exported_at"`
+	Profile         ExportProfile          `json:"profile"`
+	LinkedProviders []LinkedProvider       `json:"linked_providers"`
+	Roles           []RoleResponse         `json:"roles"`
+	Sessions        []ExportSession        `json:"sessions"`
+	Devices         []*UserDevice          `json:"devices"`
+	Activity        []*AuditLog            `json:"activity"`
+	LoginHistory    []LoginHistoryResponse `json:"login_history"`
+}
+
+// ExportProfile holds the account and profile fields of a data export
+type ExportProfile struct {
+	UserResponse
+	UpdatedAt         Timestamp  `json:"updated_at"`
+	HasPassword       bool       `json:"has_password"`
+	PasswordChangedAt *Timestamp `json:"password_changed_at,omitempty"`
+	PasswordResetAt   *Timestamp `json:"password_reset_at,omitempty"`
+}
+
+// LinkedProvider is an external identity linked to an account
+type LinkedProvider struct {
+	Provider       string `json:"provider"`
+	ProviderUserID string `json:"provider_user_id"`
+}
+
+// ExportSession is a session as it appears in a data export
+type ExportSession struct {
+	SessionResponse
+	RevokedAt *Timestamp `json:"revoked_at,omitempty"`
+}
+
+// HasLinkedProvider reports whether the named provider is linked to the user
+func (u *User) HasLinkedProvider(provider string) bool {
+	for _, linked := range u.LinkedProviders() {
+		if linked.Provider == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// CanUnlinkProvider reports whether the user keeps a way to sign in once provider
+// is unlinked: a local password or another linked provider
+func (u *User) CanUnlinkProvider(provider string) bool {
+	if u.Password != "" {
+		return true
+	}
+	for _, linked := range u.LinkedProviders() {
+		if linked.Provider != provider {
+			return true
+		}
+	}
+	return false
+}
+
+// LinkProvider sets the named provider's identity on the user
+func (u *User) LinkProvider(provider, providerID string) {
+	switch provider {
+	case "google":
+		u.GoogleID = &providerID
+	case "github":
+		u.GitHubID = &providerID
+	}
+}
+
+// UnlinkProvider clears the named provider's identity from the user
+func (u *User) UnlinkProvider(provider string) {
+	switch provider {
+	case "google":
+		u.GoogleID = nil
+	case "github":
+		u.GitHubID = nil
+	}
+}
+
+// LinkedProvidersResponse lists how a user can sign in, for admins
+type LinkedProvidersResponse struct {
+	UserID      uint             `json:"user_id"`
+	HasPassword bool             `json:"has_password"`
+	Providers   []LinkedProvider `json:"providers"`
+}
+
+// LinkedProvidersResponse describes the user's sign-in methods
+func (u *User) LinkedProvidersResponse() LinkedProvidersResponse {
+	return LinkedProvidersResponse{
+		UserID:      u.ID,
+		HasPassword: u.Password != "",
+		Providers:   u.LinkedProviders(),
+	}
+}
+
+// LinkedProviders returns the external identities linked to the user
+func (u *User) LinkedProviders() []LinkedProvider {
+	providers := []LinkedProvider{}
+	if u.GoogleID != nil {
+		providers = append(providers, LinkedProvider{Provider: "google", ProviderUserID: *u.GoogleID})
+	}
+	if u.GitHubID != nil {
+		providers = append(providers, LinkedProvider{Provider: "github", ProviderUserID: *u.GitHubID})
+	}
+	return providers
+}
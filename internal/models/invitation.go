@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+// Invitation allows one person to register while signup is invite-only
+type Invitation struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Email       string     `gorm:"not null;index" json:"email"`
+	Role        string     `gorm:"not null;default:'user'" json:"role"` // Role given to the registered user
+	TokenHash   string     `gorm:"not null;uniqueIndex" json:"-"`       // SHA-256 of the invite token
+	CreatedByID uint       `gorm:"not null" json:"created_by_id"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at,omitempty"`
+	UsedByID    *uint      `json:"used_by_id,omitempty"`
+}
+
+// Status reports whether the invitation is pending, used or expired at the given time
+func (i *Invitation) Status(now time.Time) string {
+	switch {
+	case i.UsedAt != nil:
+		return "used"
+	case now.After(i.ExpiresAt):
+		return "expired"
+	default:
+		return "pending"
+	}
+}
+
+// InvitationResponse represents invitation data returned to admins
+type InvitationResponse struct {
+	ID          uint       `json:"id"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	Status      string     `json:"status"`
+	CreatedByID uint       `json:"created_by_id"`
+	CreatedAt   Timestamp  `json:"created_at"`
+	ExpiresAt   Timestamp  `json:"expires_at"`
+	UsedAt      *Timestamp `json:"used_at,omitempty"`
+	UsedByID    *uint      `json:"used_by_id,omitempty"`
+}
+
+// ToResponse converts Invitation to InvitationResponse, with its status at the given time
+func (i *Invitation) ToResponse(now time.Time) InvitationResponse {
+	return InvitationResponse{
+		ID:          i.ID,
+		Email:       i.Email,
+		Role:        i.Role,
+		Status:      i.Status(now),
+		CreatedByID: i.CreatedByID,
+		CreatedAt:   NewTimestamp(i.CreatedAt),
+		ExpiresAt:   NewTimestamp(i.ExpiresAt),
+		UsedAt:      NewTimestampPtr(i.UsedAt),
+		UsedByID:    i.UsedByID,
+	}
+}
+
+// CreateInvitationRequest represents an admin request to invite a user
+type CreateInvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role"`
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// redacted replaces secret values in log output
+const redacted = "[REDACTED]"
+
+// logMaskEmails controls whether email addresses are masked in log output,
+// configured once at startup
+var logMaskEmails = true
+
+// ConfigureLogRedaction sets whether email addresses are masked when users and
+// requests are logged. Passwords and tokens are always redacted.
+func ConfigureLogRedaction(maskEmails bool) {
+	logMaskEmails = maskEmails
+}
+
+// logEmail returns an email address as it may appear in logs: the first letter
+// of the local part and the domain, e.g. j***@example.com
+func logEmail(email string) string {
+	if !logMaskEmails || email == "" {
+		return email
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return "***"
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// LogValue implements slog.LogValuer. Only identity and access fields are
+// logged; password hashes, verification tokens and profile data are left out.
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Uint64("id", uint64(u.ID)),
+		slog.String("email", logEmail(u.Email)),
+		slog.String("role", u.Role),
+		slog.Bool("is_active", u.IsActive),
+		slog.Bool("is_admin", u.IsAdmin),
+		slog.Bool("is_verified", u.IsVerified),
+	)
+}
+
+// LogValue implements slog.LogValuer, redacting the password
+func (r LoginRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("email", logEmail(r.Email)),
+		slog.String("password", redacted),
+	)
+}
+
+// LogValue implements slog.LogValuer, redacting the password and invite token
+func (r RegisterRequest) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("email", logEmail(r.Email)),
+		slog.String("password", redacted),
+	}
+	if r.InviteToken != "" {
+		attrs = append(attrs, slog.String("invite_token", redacted))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LogValue implements slog.LogValuer, redacting both passwords
+func (r ChangePasswordRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("email", logEmail(r.Email)),
+		slog.String("current_password", redacted),
+		slog.String("new_password", redacted),
+	)
+}
+
+// LogValue implements slog.LogValuer, redacting the password
+func (r ReauthRequest) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("password", redacted))
+}
+
+// LogValue implements slog.LogValuer, redacting the new password
+func (r RecoverPasswordRequest) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("new_password", redacted))
+}
+
+// LogValue implements slog.LogValuer, leaving out the token ID so a logged
+// claim set cannot be matched to a live session
+func (c JWTClaims) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Uint64("user_id", uint64(c.UserID)),
+		slog.String("email", logEmail(c.Email)),
+		slog.String("auth_provider", c.AuthProvider),
+		slog.String("role", c.Role),
+	)
+}
+
+// LogValue implements slog.LogValuer, masking the email when configured
+func (e FailedLoginEvent) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("email", logEmail(e.Email)),
+		slog.String("ip_address", e.IPAddress),
+		slog.String("reason", e.Reason),
+	)
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogRedaction(t *testing.T) {
+	defer ConfigureLogRedaction(true)
+
+	user := User{
+		ID:                    1,
+		Email:                 "jane@example.com",
+		Password:              "$2a$10$abcdefghijklmnopqrstuv",
+		Role:                  "admin",
+		VerificationTokenHash: "verify-secret",
+	}
+	login := LoginRequest{Email: "jane@example.com", Password: "Passw0rd!x"}
+
+	tests := []struct {
+		name       string
+		maskEmails bool
+		wantEmail  string
+		hideEmail  bool
+	}{
+		{"emails masked", true, "j***@example.com", true},
+		{"emails shown", false, "jane@example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ConfigureLogRedaction(tt.maskEmails)
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+			logger.Info("sign-in", "user", user, "request", login)
+			out := buf.String()
+
+			for _, secret := range []string{user.Password, user.VerificationTokenHash, login.Password} {
+				if strings.Contains(out, secret) {
+					t.Errorf("log output contains %q: %s", secret, out)
+				}
+			}
+			if !strings.Contains(out, tt.wantEmail) {
+				t.Errorf("log output = %s, want email %s", out, tt.wantEmail)
+			}
+			if tt.hideEmail && strings.Contains(out, user.Email) {
+				t.Errorf("log output contains the unmasked email: %s", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Outcomes of a sign-in attempt in the login history
+const (
+	LoginOutcomeSuccess = "success"
+	LoginOutcomeFailure = "failure"
+)
+
+// LoginHistory records one sign-in attempt on an account, successful or not.
+// Failed attempts on emails with no account are only in the audit log.
+type LoginHistory struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	UserID    uint   `gorm:"not null;index" json:"user_id"`
+	Method    string `gorm:"not null" json:"method"`  // password, google or github
+	Outcome   string `gorm:"not null" json:"outcome"` // success or failure
+	Reason    string `json:"reason,omitempty"`        // Why a failed attempt failed
+	IPAddress string `json:"ip_address"`
+	UserAgent string `json:"user_agent"`
+}
+
+func (LoginHistory) TableName() string {
+	return "login_history"
+}
+
+// LoginHistoryResponse represents a login history entry returned to clients
+type LoginHistoryResponse struct {
+	ID        uint      `json:"id"`
+	Method    string    `json:"method"`
+	Outcome   string    `json:"outcome"`
+	Reason    string    `json:"reason,omitempty"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt Timestamp `json:"created_at"`
+}
+
+// ToResponse converts LoginHistory to LoginHistoryResponse
+func (h *LoginHistory) ToResponse() LoginHistoryResponse {
+	return LoginHistoryResponse{
+		ID:        h.ID,
+		Method:    h.Method,
+		Outcome:   h.Outcome,
+		Reason:    h.Reason,
+		IPAddress: h.IPAddress,
+		UserAgent: h.UserAgent,
+		CreatedAt: NewTimestamp(h.CreatedAt),
+	}
+}
@@ -0,0 +1,8 @@
+package models
+
+// NotificationFilter selects the recipients of an admin notification. Empty
+// fields match everyone.
+type NotificationFilter struct {
+	Role   string
+	Active *bool
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// OAuthToken holds the tokens one OAuth provider issued to a user, so the app can
+// call that provider's APIs for them later. Both tokens are stored encrypted.
+type OAuthToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID       uint       `gorm:"not null;uniqueIndex:idx_oauth_token_user_provider" json:"user_id"`
+	Provider     string     `gorm:"not null;uniqueIndex:idx_oauth_token_user_provider" json:"provider"`
+	AccessToken  string     `gorm:"not null" json:"-"` // Encrypted
+	RefreshToken string     `json:"-"`                 // Encrypted; empty when the provider issued none
+	TokenType    string     `json:"token_type"`
+	Expiry       *time.Time `json:"expiry,omitempty"` // Nil for tokens that never expire
+	Scopes       string     `json:"scopes"`           // As granted by the provider
+}
+
+func (OAuthToken) TableName() string {
+	return "oauth_tokens"
+}
@@ -0,0 +1,71 @@
+package models
+
+// PageParams is a validated page position. Build it with NewPageParams so page
+// and size are always in range.
+type PageParams struct {
+	Page     int
+	PageSize int
+}
+
+// NewPageParams clamps a requested page and size: pages start at 1, a missing
+// size falls back to defaultSize and sizes above maxSize are capped
+func NewPageParams(page, pageSize, defaultSize, maxSize int) PageParams {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultSize
+	}
+	if pageSize > maxSize {
+		pageSize = maxSize
+	}
+	return PageParams{Page: page, PageSize: pageSize}
+}
+
+// Offset returns the number of rows before the page
+func (p PageParams) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// PageResponse is one page of a list along with enough totals to navigate it
+type PageResponse[T any] struct {
+	Items      []T   `json:"items"`
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+	HasNext    bool  `json:"has_next"`
+	HasPrev    bool  `json:"has_prev"`
+}
+
+// NewPageResponse wraps the items loaded for params out of total matching rows
+func NewPageResponse[T any](items []T, params PageParams, total int64) *PageResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	totalPages := 0
+	if params.PageSize > 0 {
+		totalPages = int((total + int64(params.PageSize) - 1) / int64(params.PageSize))
+	}
+
+	return &PageResponse[T]{
+		Items:      items,
+		Page:       params.Page,
+		PageSize:   params.PageSize,
+		Total:      total,
+		TotalPages: totalPages,
+		HasNext:    params.Page < totalPages,
+		HasPrev:    params.Page > 1,
+	}
+}
+
+// PrevPage returns the number of the page before this one
+func (p *PageResponse[T]) PrevPage() int {
+	return p.Page - 1
+}
+
+// NextPage returns the number of the page after this one
+func (p *PageResponse[T]) NextPage() int {
+	return p.Page + 1
+}
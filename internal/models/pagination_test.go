@@ -0,0 +1,59 @@
+package models
+
+import "testing"
+
+func TestNewPageParams(t *testing.T) {
+	tests := []struct {
+		name           string
+		page, pageSize int
+		want           PageParams
+	}{
+		{"in range", 3, 10, PageParams{Page: 3, PageSize: 10}},
+		{"page zero", 0, 10, PageParams{Page: 1, PageSize: 10}},
+		{"negative page", -2, 10, PageParams{Page: 1, PageSize: 10}},
+		{"missing size", 1, 0, PageParams{Page: 1, PageSize: 20}},
+		{"negative size", 1, -5, PageParams{Page: 1, PageSize: 20}},
+		{"size at the cap", 1, 100, PageParams{Page: 1, PageSize: 100}},
+		{"size over the cap", 1, 101, PageParams{Page: 1, PageSize: 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewPageParams(tt.page, tt.pageSize, 20, 100); got != tt.want {
+				t.Errorf("NewPageParams(%d, %d) = %+v, want %+v", tt.page, tt.pageSize, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPageResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     []int
+		page      int
+		total     int64
+		wantPages int
+		wantNext  bool
+		wantPrev  bool
+	}{
+		{"no rows", nil, 1, 0, 0, false, false},
+		{"single full page", []int{1, 2, 3}, 1, 3, 1, false, false},
+		{"first of several", []int{1, 2, 3}, 1, 7, 3, true, false},
+		{"middle page", []int{4, 5, 6}, 2, 7, 3, true, true},
+		{"partial last page", []int{7}, 3, 7, 3, false, true},
+		{"exact last page", []int{4, 5, 6}, 2, 6, 2, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewPageResponse(tt.items, PageParams{Page: tt.page, PageSize: 3}, tt.total)
+			if got.Items == nil {
+				t.Error("Items is nil, want an empty list")
+			}
+			if got.TotalPages != tt.wantPages || got.HasNext != tt.wantNext || got.HasPrev != tt.wantPrev {
+				t.Errorf("got total_pages=%d has_next=%v has_prev=%v, want %d %v %v",
+					got.TotalPages, got.HasNext, got.HasPrev, tt.wantPages, tt.wantNext, tt.wantPrev)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package models
+
+// OAuthProviderInfo describes a sign-in provider so clients can render a button for it
+type OAuthProviderInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	AuthURL string `json:"auth_url"`
+	Icon    string `json:"icon"`  // Font Awesome class, as used by the built-in pages
+	Color   string `json:"color"` // Brand color for the button
+}
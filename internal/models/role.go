@@ -0,0 +1,132 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Permissions that can be granted through custom roles
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionUsersWrite  = "users:write"
+	PermissionUsersDelete = "users:delete"
+	PermissionRolesManage = "roles:manage"
+	PermissionAdminAccess = "admin:access"
+)
+
+// ValidPermissions lists every known permission
+var ValidPermissions = map[string]bool{
+	PermissionUsersRead:   true,
+	PermissionUsersWrite:  true,
+	PermissionUsersDelete: true,
+	PermissionRolesManage: true,
+	PermissionAdminAccess: true,
+}
+
+// AdminPermissions are admin-level permissions only super admins may grant
+var AdminPermissions = map[string]bool{
+	PermissionRolesManage: true,
+	PermissionAdminAccess: true,
+}
+
+// BuiltInRolePermissions gives the permissions each built-in user role carries.
+// Only admins pass the admin and super admin checks, so they hold every permission;
+// moderators and users hold none.
+var BuiltInRolePermissions = map[string][]string{
+	"user":      {},
+	"moderator": {},
+	"admin": {
+		PermissionAdminAccess,
+		PermissionRolesManage,
+		PermissionUsersDelete,
+		PermissionUsersRead,
+		PermissionUsersWrite,
+	},
+}
+
+// Role represents a custom role grouping a set of permissions
+type Role struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name        string `gorm:"uniqueIndex;not null" json:"name"`
+	Description string `json:"description"`
+	Permissions string `gorm:"not null;default:''" json:"-"` // Comma-separated permission names
+}
+
+// UserRole assigns a custom role to a user
+type UserRole struct {
+	UserID    uint      `gorm:"primaryKey" json:"user_id"`
+	RoleID    uint      `gorm:"primaryKey;index" json:"role_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PermissionList returns the role's permissions as a slice
+func (r *Role) PermissionList() []string {
+	if r.Permissions == "" {
+		return []string{}
+	}
+	return strings.Split(r.Permissions, ",")
+}
+
+// SetPermissions stores the given permissions on the role
+func (r *Role) SetPermissions(permissions []string) {
+	r.Permissions = strings.Join(permissions, ",")
+}
+
+// HasAdminPermissions reports whether the role grants any admin-level permission
+func (r *Role) HasAdminPermissions() bool {
+	for _, permission := range r.PermissionList() {
+		if AdminPermissions[permission] {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleResponse represents role data returned to clients
+type RoleResponse struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   Timestamp `json:"created_at"`
+}
+
+// ToResponse converts Role to RoleResponse
+func (r *Role) ToResponse() RoleResponse {
+	return RoleResponse{
+		ID:          r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: r.PermissionList(),
+		CreatedAt:   NewTimestamp(r.CreatedAt),
+	}
+}
+
+// RolePermissionsResponse is the permission set a role would grant
+type RolePermissionsResponse struct {
+	Role        string   `json:"role"`
+	BuiltIn     bool     `json:"built_in"`
+	Permissions []string `json:"permissions"`
+}
+
+// RoleRequest represents role create/update request data
+type RoleRequest struct {
+	Name        string   `json:"name" binding:"required,min=2,max=50"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// AssignRoleRequest represents a bulk role assignment request
+type AssignRoleRequest struct {
+	UserIDs []uint `json:"user_ids" binding:"required,min=1"`
+}
+
+// RoleAssignmentResult reports the outcome of assigning a role to one user
+type RoleAssignmentResult struct {
+	UserID  uint   `json:"user_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+)
+
+// UserSession records an issued session token so sessions can be listed, capped and revoked
+type UserSession struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenID   string     `gorm:"not null;uniqueIndex" json:"-"` // jti of the current token
+	IPAddress string     `json:"ip_address"`
+	UserAgent string     `json:"user_agent"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// RevokedToken records the ID of a session token that was replaced before it expired,
+// so the denylist survives restarts. Rows are only needed until ExpiresAt.
+type RevokedToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TokenID   string    `gorm:"not null;uniqueIndex" json:"-"` // jti of the replaced token
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// SessionResponse represents session data returned to clients
+type SessionResponse struct {
+	ID        uint      `json:"id"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt Timestamp `json:"created_at"`
+	ExpiresAt Timestamp `json:"expires_at"`
+}
+
+// ToResponse converts UserSession to SessionResponse
+func (s *UserSession) ToResponse() SessionResponse {
+	return SessionResponse{
+		ID:        s.ID,
+		IPAddress: s.IPAddress,
+		UserAgent: s.UserAgent,
+		CreatedAt: NewTimestamp(s.CreatedAt),
+		ExpiresAt: NewTimestamp(s.ExpiresAt),
+	}
+}
+
+// RefreshToken lets an API client renew its session token without signing in again.
+// Each use replaces it with a new token in the same family, and the token only works
+// from the device it was issued to. Only hashes of the token and device are stored.
+type RefreshToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	SessionID    uint       `gorm:"not null;index" json:"session_id"`
+	FamilyID     string     `gorm:"not null;index" json:"-"` // shared by every rotation of one sign-in
+	TokenHash    string     `gorm:"not null;uniqueIndex" json:"-"`
+	Fingerprint  string     `gorm:"not null" json:"-"` // SHA-256 of user agent and device ID
+	DeviceHash   string     `gorm:"not null" json:"-"` // SHA-256 of device ID alone, for lenient binding
+	TokenVersion uint       `gorm:"not null;default:0" json:"-"`
+	AuthTime     time.Time  `json:"auth_time"`
+	AuthProvider string     `json:"auth_provider"`
+	ExpiresAt    time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+// RefreshSessionRequest exchanges a refresh token for a new session token
+type RefreshSessionRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// SigningKey is an RSA key session tokens are signed with when JWT_SIGNING_METHOD
+// is RS256. The newest unretired key signs; retired keys stay published in the
+// JWKS for a grace period so tokens they signed still verify.
+type SigningKey struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	KID        string     `gorm:"not null;uniqueIndex" json:"kid"`
+	Algorithm  string     `gorm:"not null" json:"alg"`
+	PrivateKey string     `gorm:"not null" json:"-"` // PKCS#8, PEM-encoded
+	RetiredAt  *time.Time `gorm:"index" json:"retired_at,omitempty"`
+}
+
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
+
+// SigningKeyResponse describes a signing key to admins
+type SigningKeyResponse struct {
+	KID            string     `json:"kid"`
+	Algorithm      string     `json:"alg"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Active         bool       `json:"active"`
+	RetiredAt      *time.Time `json:"retired_at,omitempty"`
+	PublishedUntil *time.Time `json:"published_until,omitempty"` // Nil while the key is active
+}
+
+// ToResponse describes the key, which stays published for gracePeriod after it retires
+func (k *SigningKey) ToResponse(gracePeriod time.Duration) SigningKeyResponse {
+	response := SigningKeyResponse{
+		KID:       k.KID,
+		Algorithm: k.Algorithm,
+		CreatedAt: k.CreatedAt,
+		Active:    k.RetiredAt == nil,
+		RetiredAt: k.RetiredAt,
+	}
+	if k.RetiredAt != nil {
+		until := k.RetiredAt.Add(gracePeriod)
+		response.PublishedUntil = &until
+	}
+	return response
+}
+
+// JSONWebKey is the public half of an RSA signing key in JWK form (RFC 7517)
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KID       string `json:"kid"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
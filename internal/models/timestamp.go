@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// Timestamp is a time that JSON-encodes as RFC 3339 in UTC to the second, such as
+// "2024-05-01T09:30:00Z". The zero time encodes as null, so a response never shows
+// Go's 0001-01-01 placeholder. Optional times are *Timestamp with omitempty, which
+// leaves them out when unset.
+//
+// Response types use Timestamp; database models keep time.Time.
+type Timestamp struct {
+	time.Time
+}
+
+// NewTimestamp wraps t
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t}
+}
+
+// NewTimestampPtr wraps t, returning nil when t is nil or the zero time
+func NewTimestampPtr(t *time.Time) *Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return &Timestamp{Time: *t}
+}
+
+// MarshalJSON implements json.Marshaler
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.UTC().Format(time.RFC3339) + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler; null decodes to the zero time
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	return t.Time.UnmarshalJSON(data)
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimestampJSON(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		time time.Time
+		want string
+	}{
+		{"UTC", time.Date(2024, time.May, 1, 9, 30, 0, 0, time.UTC), `"2024-05-01T09:30:00Z"`},
+		{"nanoseconds dropped", time.Date(2024, time.May, 1, 9, 30, 0, 123456789, time.UTC), `"2024-05-01T09:30:00Z"`},
+		{"offset converted to UTC", time.Date(2024, time.May, 1, 11, 30, 0, 0, paris), `"2024-05-01T09:30:00Z"`},
+		{"zero time", time.Time{}, `null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(NewTimestamp(tt.time))
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Marshal = %s, want %s", data, tt.want)
+			}
+
+			var decoded Timestamp
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !decoded.Equal(tt.time.Truncate(time.Second)) {
+				t.Errorf("round trip = %v, want %v", decoded.Time, tt.time.Truncate(time.Second))
+			}
+		})
+	}
+}
+
+func TestUserResponseTimes(t *testing.T) {
+	created := time.Date(2024, time.May, 1, 9, 30, 0, 500, time.UTC)
+	user := &User{ID: 1, Email: "times@example.com", CreatedAt: created, UpdatedAt: created}
+
+	data, err := json.Marshal(user.ToResponse())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	body := string(data)
+	if !strings.Contains(body, `"created_at":"2024-05-01T09:30:00Z"`) {
+		t.Errorf("created_at not in RFC 3339 UTC: %s", body)
+	}
+	if strings.Contains(body, "last_login_at") {
+		t.Errorf("unset last_login_at is present: %s", body)
+	}
+	if strings.Contains(body, "0001-01-01") {
+		t.Errorf("response shows the Go zero time: %s", body)
+	}
+
+	// A zero time stored in an optional field counts as unset
+	var zero time.Time
+	user.LastLoginAt = &zero
+	if data, _ := json.Marshal(user.ToResponse()); strings.Contains(string(data), "last_login_at") {
+		t.Errorf("zero last_login_at is present: %s", data)
+	}
+
+	login := created.Add(time.Hour)
+	user.LastLoginAt = &login
+	data, _ = json.Marshal(user.ToResponse())
+	if !strings.Contains(string(data), `"last_login_at":"2024-05-01T10:30:00Z"`) {
+		t.Errorf("last_login_at not in RFC 3339 UTC: %s", data)
+	}
+}
@@ -1,17 +1,31 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Profile field limits, matching the column sizes on User
+const (
+	MaxBioLength      = 1000
+	MaxWebsiteLength  = 500
+	MaxLocationLength = 200
+)
+
 // User represents a user in the system
 type User struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	Version   uint           `gorm:"not null;default:0" json:"version"` // Incremented on every save for optimistic locking
 	
 	Email       string `gorm:"uniqueIndex;not null" json:"email"`
 	Password    string `gorm:"not null" json:"-"` // Never include password in JSON
@@ -21,6 +35,9 @@ type User struct {
 	IsVerified  bool   `gorm:"default:false" json:"is_verified"`
 	IsAdmin     bool   `gorm:"default:false" json:"is_admin"`
 	Role        string `gorm:"default:'user'" json:"role"` // user, admin, moderator
+
+	// Organization the user belongs to in multi-tenant deployments; nil when unused
+	OrganizationID *uint `gorm:"index" json:"org_id,omitempty"`
 	
 	// OAuth fields
 	GoogleID  *string `gorm:"uniqueIndex" json:"google_id,omitempty"`
@@ -28,13 +45,56 @@ type User struct {
 	AvatarURL *string `json:"avatar_url,omitempty"`
 	
 	// Profile fields
-	Bio       *string `json:"bio,omitempty"`
-	Website   *string `json:"website,omitempty"`
-	Location  *string `json:"location,omitempty"`
+	Bio       *string `gorm:"size:1000" json:"bio,omitempty"`
+	Website   *string `gorm:"size:500" json:"website,omitempty"`
+	Location  *string `gorm:"size:200" json:"location,omitempty"`
+
+	// Shows the profile at /u/:id to anyone; only honored for verified accounts
+	ProfilePublic bool `gorm:"default:false" json:"profile_public"`
 	
 	// Security fields
-	LastLoginAt     *time.Time `json:"last_login_at,omitempty"`
-	PasswordResetAt *time.Time `json:"password_reset_at,omitempty"`
+	LastLoginAt       *time.Time `json:"last_login_at,omitempty"`
+	PasswordResetAt   *time.Time `json:"password_reset_at,omitempty"`
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"` // When the local password was last set
+	PasswordPeppered  bool       `gorm:"default:false" json:"-"`        // Hash was made with PASSWORD_PEPPER applied
+	TokenVersion      uint       `gorm:"not null;default:0" json:"-"`   // Bumped to invalidate every token issued before
+	ActiveFrom        *time.Time `json:"active_from,omitempty"`         // Scheduled start of access; sign-in is refused before it
+	DeactivateAt      *time.Time `json:"deactivate_at,omitempty"`       // Scheduled deactivation; access ends at this time
+
+	// Created through OAuth without an email; Email holds a placeholder until one is added
+	EmailMissing bool `gorm:"default:false" json:"email_missing"`
+
+	// Provider identity waiting for the owner to confirm it by the link mailed to
+	// Email, when REQUIRE_LINK_CONFIRMATION kept it from being linked right away
+	PendingLinkProvider   string     `json:"-"`
+	PendingLinkProviderID string     `json:"-"`
+	LinkTokenHash         string     `gorm:"index" json:"-"`
+	LinkSentAt            *time.Time `json:"-"`
+
+	// Email verification
+	VerificationRequired  bool       `gorm:"default:false" json:"-"` // Signed up while REQUIRE_VERIFIED_LOGIN was on
+	VerificationTokenHash string     `gorm:"index" json:"-"`
+	VerificationSentAt    *time.Time `json:"-"`
+
+	// Backup address for account notices, verified through its own link. Security
+	// notices also go to it once verified, if RecoveryNotifications is set.
+	RecoveryEmail         *string    `json:"recovery_email,omitempty"`
+	RecoveryEmailVerified bool       `gorm:"default:false" json:"recovery_email_verified"`
+	RecoveryNotifications bool       `gorm:"default:false" json:"recovery_notifications"`
+	RecoveryTokenHash     string     `gorm:"index" json:"-"`
+	RecoverySentAt        *time.Time `json:"-"`
+
+	// Optional phone number in E.164 form, verified with a code sent by SMS. The
+	// code's hash is kept until it is used, expires or runs out of attempts.
+	PhoneNumber       *string    `json:"phone_number,omitempty"`
+	PhoneVerified     bool       `gorm:"default:false" json:"phone_verified"`
+	PhoneCodeHash     string     `json:"-"`
+	PhoneCodeSentAt   *time.Time `json:"-"`
+	PhoneCodeAttempts int        `gorm:"not null;default:0" json:"-"`
+
+	// Latest terms of service the user accepted, and when
+	AcceptedTermsAt      *time.Time `json:"accepted_terms_at,omitempty"`
+	AcceptedTermsVersion string     `json:"accepted_terms_version,omitempty"`
 }
 
 // UserResponse represents user data returned to clients
@@ -47,12 +107,67 @@ type UserResponse struct {
 	IsVerified  bool      `json:"is_verified"`
 	IsAdmin     bool      `json:"is_admin"`
 	Role        string    `json:"role"`
+	OrgID       *uint     `json:"org_id,omitempty"`
 	AvatarURL   string    `json:"avatar_url,omitempty"`
 	Bio         string    `json:"bio,omitempty"`
 	Website     string    `json:"website,omitempty"`
 	Location    string    `json:"location,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	ProfilePublic bool    `json:"profile_public"`
+	CreatedAt   Timestamp `json:"created_at"`
+	LastLoginAt *Timestamp `json:"last_login_at,omitempty"`
+	ActiveFrom  *Timestamp `json:"active_from,omitempty"`
+	DeactivateAt *Timestamp `json:"deactivate_at,omitempty"`
+	EmailMissing bool     `json:"email_missing,omitempty"`
+	RecoveryEmail string  `json:"recovery_email,omitempty"`
+	RecoveryEmailVerified bool `json:"recovery_email_verified"`
+	PhoneNumber string    `json:"phone_number,omitempty"`
+	PhoneVerified bool    `json:"phone_verified"`
+	AcceptedTermsAt *Timestamp `json:"accepted_terms_at,omitempty"`
+	AcceptedTermsVersion string `json:"accepted_terms_version,omitempty"`
+	Version     uint      `json:"version"`
+}
+
+// userResponseFields holds the JSON names of UserResponse's fields, the only
+// fields SelectFields can pick
+var userResponseFields = jsonFieldNames(reflect.TypeOf(UserResponse{}))
+
+// jsonFieldNames returns the JSON names of a struct type's fields
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// SelectFields returns only the named fields of the response, keyed by their JSON
+// names, and the requested names UserResponse has no field for. Fields the full
+// response omits when empty are omitted here too.
+func (r UserResponse) SelectFields(fields []string) (map[string]interface{}, []string, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var full map[string]interface{}
+	if err := json.Unmarshal(data, &full); err != nil {
+		return nil, nil, err
+	}
+
+	selected := make(map[string]interface{}, len(fields))
+	var unknown []string
+	for _, field := range fields {
+		if !userResponseFields[field] {
+			unknown = append(unknown, field)
+			continue
+		}
+		if value, ok := full[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected, unknown, nil
 }
 
 // ToResponse converts User to UserResponse
@@ -66,17 +181,31 @@ func (u *User) ToResponse() UserResponse {
 		IsVerified:  u.IsVerified,
 		IsAdmin:     u.IsAdmin,
 		Role:        u.Role,
-		CreatedAt:   u.CreatedAt,
-		LastLoginAt: u.LastLoginAt,
+		OrgID:       u.OrganizationID,
+		CreatedAt:   NewTimestamp(u.CreatedAt),
+		LastLoginAt: NewTimestampPtr(u.LastLoginAt),
+		ActiveFrom:  NewTimestampPtr(u.ActiveFrom),
+		DeactivateAt: NewTimestampPtr(u.DeactivateAt),
+		AcceptedTermsAt: NewTimestampPtr(u.AcceptedTermsAt),
+		AcceptedTermsVersion: u.AcceptedTermsVersion,
+		EmailMissing: u.EmailMissing,
+		ProfilePublic: u.ProfilePublic,
+		AvatarURL:   u.avatarURL(),
+		Version:     u.Version,
 	}
 	
 	// Handle pointer fields
-	if u.AvatarURL != nil {
-		response.AvatarURL = *u.AvatarURL
-	}
 	if u.Bio != nil {
 		response.Bio = *u.Bio
 	}
+	if u.RecoveryEmail != nil {
+		response.RecoveryEmail = *u.RecoveryEmail
+		response.RecoveryEmailVerified = u.RecoveryEmailVerified
+	}
+	if u.PhoneNumber != nil {
+		response.PhoneNumber = *u.PhoneNumber
+		response.PhoneVerified = u.PhoneVerified
+	}
 	if u.Website != nil {
 		response.Website = *u.Website
 	}
@@ -87,6 +216,76 @@ func (u *User) ToResponse() UserResponse {
 	return response
 }
 
+// PublicProfile is what anyone can see on a user's public profile page. It
+// leaves out the email address and every account and admin field.
+type PublicProfile struct {
+	ID         uint
+	FirstName  string
+	LastName   string
+	AvatarURL  string
+	Bio        string
+	Website    string
+	Location   string
+	IsVerified bool
+	CreatedAt  time.Time
+}
+
+// ToPublicProfile converts User to its public profile
+func (u *User) ToPublicProfile() PublicProfile {
+	profile := PublicProfile{
+		ID:         u.ID,
+		FirstName:  u.FirstName,
+		LastName:   u.LastName,
+		AvatarURL:  u.avatarURL(),
+		IsVerified: u.IsVerified,
+		CreatedAt:  u.CreatedAt,
+	}
+
+	if u.Bio != nil {
+		profile.Bio = *u.Bio
+	}
+	if u.Website != nil {
+		profile.Website = *u.Website
+	}
+	if u.Location != nil {
+		profile.Location = *u.Location
+	}
+
+	return profile
+}
+
+// NotYetActive reports whether the user's scheduled access starts after now
+func (u *User) NotYetActive(now time.Time) bool {
+	return u.ActiveFrom != nil && now.Before(*u.ActiveFrom)
+}
+
+// DeactivationDue reports whether the user's scheduled deactivation has come,
+// even if the background job has not applied it yet
+func (u *User) DeactivationDue(now time.Time) bool {
+	return u.DeactivateAt != nil && !now.Before(*u.DeactivateAt)
+}
+
+// SecurityNoticeEmails returns the addresses security notices go to: the primary
+// email, when the account has one, and the recovery email if it is verified and
+// the user asked for notices there
+func (u *User) SecurityNoticeEmails() []string {
+	var emails []string
+	if u.Email != "" && !u.EmailMissing {
+		emails = append(emails, u.Email)
+	}
+	if u.RecoveryEmail != nil && u.RecoveryEmailVerified && u.RecoveryNotifications {
+		emails = append(emails, *u.RecoveryEmail)
+	}
+	return emails
+}
+
+// ETag returns a weak entity tag for the user's representation. Every save bumps
+// Version and UpdatedAt, so the tag changes whenever the profile changes.
+func (u *User) ETag() string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d:%d:%d:%s", u.ID, u.Version, u.UpdatedAt.UnixNano(), u.avatarURL())))
+	return `W/"` + hex.EncodeToString(hash[:8]) + `"`
+}
+
 // LoginRequest represents login request data
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -99,21 +298,124 @@ type RegisterRequest struct {
 	Password  string `json:"password" binding:"required,min=6"`
 	FirstName string `json:"first_name" binding:"required,min=2"`
 	LastName  string `json:"last_name" binding:"required,min=2"`
+
+	InviteToken string `json:"invite_token"` // Required when signup is invite-only
+	AcceptTerms bool   `json:"accept_terms"` // Must be true when REQUIRE_TERMS_ACCEPTANCE is on
 }
 
 // UpdateProfileRequest represents profile update request data
 type UpdateProfileRequest struct {
 	FirstName string `json:"first_name" binding:"required,min=2"`
 	LastName  string `json:"last_name" binding:"required,min=2"`
-	Bio       string `json:"bio"`
-	Website   string `json:"website"`
-	Location  string `json:"location"`
+	Bio       string `json:"bio" binding:"max=1000"`
+	Website   string `json:"website" binding:"max=500"`
+	Location  string `json:"location" binding:"max=200"`
+
+	// Omit to leave the public profile setting unchanged
+	ProfilePublic *bool `json:"profile_public"`
+}
+
+// NotifyUsersRequest asks to email every user matching a filter. With DryRun set
+// nothing is sent and only the recipient count is returned. The subject becomes a
+// mail header, so line breaks are refused.
+type NotifyUsersRequest struct {
+	Role    string `json:"role"`
+	Active  *bool  `json:"active"`
+	Subject string `json:"subject" binding:"required,max=200,excludesall=\r\n"`
+	Body    string `json:"body" binding:"required,max=20000"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+// ResendVerificationRequest asks for a new email verification link
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ChangePasswordRequest represents a request to replace the current password.
+// It carries the email so it also works before a session exists, e.g. after expiry.
+type ChangePasswordRequest struct {
+	Email           string `json:"email" binding:"required,email"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// AddEmailRequest sets the email of an account created without one
+type AddEmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// SetRecoveryEmailRequest sets, or with an empty email removes, the recovery email.
+// Notify also sends security notices to it once it is verified.
+type SetRecoveryEmailRequest struct {
+	Email  string `json:"email" binding:"omitempty,email"`
+	Notify bool   `json:"notify"`
+}
+
+// SetPhoneRequest sets, or with an empty phone removes, the user's phone number
+type SetPhoneRequest struct {
+	Phone string `json:"phone" binding:"max=32"`
+}
+
+// AcceptTermsRequest accepts the terms of service. The version is the one the user
+// was shown, so terms that change in the meantime are not accepted unseen.
+type AcceptTermsRequest struct {
+	TermsVersion string `json:"terms_version" binding:"required"`
+}
+
+// VerifyPhoneRequest confirms the phone number with the code sent to it
+type VerifyPhoneRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// ReauthRequest represents a request to confirm the current password
+type ReauthRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// RecoverPasswordRequest sets a new password after signing in through a linked provider
+type RecoverPasswordRequest struct {
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// PasswordStrengthRequest carries a candidate password to score; it is never stored
+type PasswordStrengthRequest struct {
+	Password string `json:"password" binding:"required,max=1024"`
+}
+
+// PasswordRequirement is a password policy rule a candidate password breaks
+type PasswordRequirement struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordStrengthResponse scores a candidate password from 0 to 4. A password
+// with any unmet requirement scores 0 and would be rejected; suggestions only
+// raise the score.
+type PasswordStrengthResponse struct {
+	Score       int                   `json:"score"`
+	Valid       bool                  `json:"valid"`
+	Unmet       []PasswordRequirement `json:"unmet"`
+	Suggestions []string              `json:"suggestions"`
 }
 
 // JWTClaims represents JWT token claims
 type JWTClaims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
+	ID        string    `json:"jti"`
+	UserID    uint      `json:"user_id"`
+	Email     string    `json:"email"`
+	AuthTime  time.Time `json:"auth_time"`
+	ExpiresAt time.Time `json:"exp"`
+	// How the credentials behind auth_time were presented: password, google or github
+	AuthProvider string `json:"auth_provider"`
+	TokenVersion uint   `json:"token_version"`
+
+	// Account snapshot taken when the token was issued
+	Role        string `json:"role"`
+	IsAdmin     bool   `json:"is_admin"`
+	IsActive    bool   `json:"is_active"`
+	IsVerified  bool   `json:"is_verified"`
+	OrgID       *uint  `json:"org_id,omitempty"` // only present for users in an organization
+	HasSnapshot bool   `json:"-"`                // false for tokens issued before the snapshot existed
 }
 
 // AdminUpdateUserRequest represents admin user update request
@@ -125,9 +427,45 @@ type AdminUpdateUserRequest struct {
 	IsVerified *bool  `json:"is_verified"`
 	IsAdmin    *bool  `json:"is_admin"`
 	Role       string `json:"role" binding:"oneof=user admin moderator"`
-	Bio        string `json:"bio"`
-	Website    string `json:"website"`
-	Location   string `json:"location"`
+	Bio        string `json:"bio" binding:"max=1000"`
+	Website    string `json:"website" binding:"max=500"`
+	Location   string `json:"location" binding:"max=200"`
+	Version    *uint  `json:"version"` // Version the edit was based on; stale versions are rejected
+
+	// Scheduled start of access in RFC 3339. Omit to leave unchanged, send "" to clear.
+	ActiveFrom *string `json:"active_from"`
+
+	// Organization to move the user to. Omit to leave unchanged, send 0 to clear.
+	OrgID *uint `json:"org_id"`
+}
+
+// DeactivateUserRequest optionally schedules a deactivation instead of applying
+// it immediately
+type DeactivateUserRequest struct {
+	EffectiveAt string `json:"effective_at"` // RFC 3339; empty or past deactivates now
+}
+
+// MergeUsersRequest represents a request to merge a duplicate account into a user
+type MergeUsersRequest struct {
+	DuplicateID uint `json:"duplicate_id" binding:"required"`
+}
+
+// LockoutResponse is the sign-in throttle state of one account. Failed attempts
+// are counted in memory over the login throttle window, so a restart clears them.
+type LockoutResponse struct {
+	UserID         uint       `json:"user_id"`
+	FailedAttempts int        `json:"failed_attempts"`
+	Locked         bool       `json:"locked"`
+	LockedUntil    *Timestamp `json:"locked_until,omitempty"`
+}
+
+// UserListFilter narrows the admin user list. Search takes precedence over Role.
+// The creation-date bounds are inclusive and apply alongside either.
+type UserListFilter struct {
+	Role          string
+	Search        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
 }
 
 // UserStatsResponse represents user statistics for admin dashboard
@@ -140,3 +478,64 @@ type UserStatsResponse struct {
 	NewUsersWeek   int64 `json:"new_users_week"`
 	NewUsersMonth  int64 `json:"new_users_month"`
 }
+
+// SignupBucket is the number of users who signed up in one period starting at Start
+type SignupBucket struct {
+	Start Timestamp `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// SignupTrendResponse is a continuous series of signup counts; periods without
+// signups are included with a count of 0
+type SignupTrendResponse struct {
+	Granularity string         `json:"granularity"`
+	From        Timestamp      `json:"from"`
+	To          Timestamp      `json:"to"`
+	Buckets     []SignupBucket `json:"buckets"`
+}
+
+// SystemHealthResponse represents the system overview for the admin health page
+type SystemHealthResponse struct {
+	Database  DatabaseHealth                `json:"database"`
+	Sessions  SessionStats                  `json:"sessions"`
+	Runtime   RuntimeStats                  `json:"runtime"`
+	Providers map[string]bool               `json:"providers"`
+	OAuth     map[string]OAuthProviderStats `json:"oauth"`
+	Uptime    string                        `json:"uptime"`
+	StartedAt Timestamp                     `json:"started_at"`
+}
+
+// OAuthProviderStats counts OAuth sign-ins through one provider since startup.
+// Completed sign-ins came back with a valid state; abandoned ones never came back
+// before the state expired. The abandonment rate is over sign-ins that ended.
+type OAuthProviderStats struct {
+	Initiated       int64   `json:"initiated"`
+	Completed       int64   `json:"completed"`
+	Abandoned       int64   `json:"abandoned"`
+	Pending         int64   `json:"pending"`
+	AbandonmentRate float64 `json:"abandonment_rate"`
+}
+
+// DatabaseHealth represents database connectivity and size
+type DatabaseHealth struct {
+	Connected bool    `json:"connected"`
+	LatencyMS float64 `json:"latency_ms"`
+	SizeBytes int64   `json:"size_bytes,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// SessionStats approximates live sessions from recent logins, since JWTs are stateless
+type SessionStats struct {
+	RecentLogins int64  `json:"recent_logins"`
+	Window       string `json:"window"`
+}
+
+// RuntimeStats represents Go runtime memory statistics
+type RuntimeStats struct {
+	GoVersion    string `json:"go_version"`
+	Goroutines   int    `json:"goroutines"`
+	HeapAllocMB  uint64 `json:"heap_alloc_mb"`
+	HeapSysMB    uint64 `json:"heap_sys_mb"`
+	TotalAllocMB uint64 `json:"total_alloc_mb"`
+	NumGC        uint32 `json:"num_gc"`
+}
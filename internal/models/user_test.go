@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestNotifyUsersRequestSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		wantErr bool
+	}{
+		{"plain", "Scheduled maintenance", false},
+		{"non-ascii", "Maintenance prévue", false},
+		{"empty", "", true},
+		{"crlf", "Hello\r\nBcc: victim@example.com", true},
+		{"newline", "Hello\nBcc: victim@example.com", true},
+		{"carriage return", "Hello\rBcc: victim@example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := binding.Validator.ValidateStruct(&NotifyUsersRequest{Subject: tt.subject, Body: "Body"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateStruct error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
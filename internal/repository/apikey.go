@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+)
+
+type APIKeyRepository interface {
+	Create(key *models.APIKey) (*models.APIKey, error)
+	GetByHash(keyHash string) (*models.APIKey, error)
+	GetByUserAndID(userID, id uint) (*models.APIKey, error)
+	ListByUser(userID uint) ([]*models.APIKey, error)
+	Update(key *models.APIKey) (*models.APIKey, error)
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository() APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(key *models.APIKey) (*models.APIKey, error) {
+	if err := r.db.Create(key).Error; err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *apiKeyRepository) GetByHash(keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) GetByUserAndID(userID, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Where("user_id = ? AND id = ?", userID, id).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) ListByUser(userID uint) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepository) Update(key *models.APIKey) (*models.APIKey, error) {
+	if err := r.db.Save(key).Error; err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+)
+
+type AuditLogRepository interface {
+	Create(entry *models.AuditLog) error
+	Query(filter models.AuditLogFilter) ([]*models.AuditLog, int64, error)
+	ListByUser(userID uint) ([]*models.AuditLog, error)
+	ListBefore(cutoff time.Time, limit int) ([]*models.AuditLog, error)
+	DeleteByIDs(ids []uint) error
+}
+
+type auditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository() AuditLogRepository {
+	return &auditLogRepository{db: db}
+}
+
+func (r *auditLogRepository) Create(entry *models.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+// Query returns one page of entries matching filter and the total number of matches
+func (r *auditLogRepository) Query(filter models.AuditLogFilter) ([]*models.AuditLog, int64, error) {
+	query := r.db.Model(&models.AuditLog{})
+
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if len(filter.Actions) > 0 {
+		query = query.Where("action IN ?", filter.Actions)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "created_at DESC, id DESC"
+	if filter.Oldest {
+		order = "created_at ASC, id ASC"
+	}
+
+	var entries []*models.AuditLog
+	err := query.Order(order).Limit(filter.Limit).Offset(filter.Offset).Find(&entries).Error
+	return entries, total, err
+}
+
+// ListByUser returns entries where the user acted or was acted upon, newest first
+func (r *auditLogRepository) ListByUser(userID uint) ([]*models.AuditLog, error) {
+	var entries []*models.AuditLog
+	err := r.db.Where("actor_id = ? OR target_id = ?", userID, userID).
+		Order("created_at DESC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// ListBefore returns up to limit of the oldest entries created before cutoff
+func (r *auditLogRepository) ListBefore(cutoff time.Time, limit int) ([]*models.AuditLog, error) {
+	var entries []*models.AuditLog
+	err := r.db.Where("created_at < ?", cutoff).Order("id").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// DeleteByIDs removes the given entries
+func (r *auditLogRepository) DeleteByIDs(ids []uint) error {
+	return r.db.Delete(&models.AuditLog{}, ids).Error
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+)
+
+type DeviceRepository interface {
+	GetByFingerprint(userID uint, fingerprint string) (*models.UserDevice, error)
+	CountByUser(userID uint) (int64, error)
+	ListByUser(userID uint) ([]*models.UserDevice, error)
+	Create(device *models.UserDevice) (*models.UserDevice, error)
+	Update(device *models.UserDevice) (*models.UserDevice, error)
+}
+
+type deviceRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceRepository() DeviceRepository {
+	return &deviceRepository{db: db}
+}
+
+func (r *deviceRepository) GetByFingerprint(userID uint, fingerprint string) (*models.UserDevice, error) {
+	var device models.UserDevice
+	if err := r.db.Where("user_id = ? AND fingerprint = ?", userID, fingerprint).First(&device).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+func (r *deviceRepository) CountByUser(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.UserDevice{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *deviceRepository) ListByUser(userID uint) ([]*models.UserDevice, error) {
+	var devices []*models.UserDevice
+	err := r.db.Where("user_id = ?", userID).Order("last_seen_at DESC").Find(&devices).Error
+	return devices, err
+}
+
+func (r *deviceRepository) Create(device *models.UserDevice) (*models.UserDevice, error) {
+	if err := r.db.Create(device).Error; err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+func (r *deviceRepository) Update(device *models.UserDevice) (*models.UserDevice, error) {
+	if err := r.db.Save(device).Error; err != nil {
+		return nil, err
+	}
+	return device, nil
+}
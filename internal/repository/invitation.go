@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/timeutil"
+)
+
+// ErrInvitationUsed is returned when an invitation was redeemed concurrently
+var ErrInvitationUsed = errors.New("invitation has already been used")
+
+type InvitationRepository interface {
+	Create(invitation *models.Invitation) (*models.Invitation, error)
+	GetByTokenHash(tokenHash string) (*models.Invitation, error)
+	List(limit, offset int) ([]*models.Invitation, error)
+	RedeemWithUser(invitation *models.Invitation, user *models.User) (*models.User, error)
+}
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository() InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+func (r *invitationRepository) Create(invitation *models.Invitation) (*models.Invitation, error) {
+	if err := r.db.Create(invitation).Error; err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (r *invitationRepository) GetByTokenHash(tokenHash string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&invitation).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) List(limit, offset int) ([]*models.Invitation, error) {
+	var invitations []*models.Invitation
+	err := r.db.Order("created_at DESC").Limit(limit).Offset(offset).Find(&invitations).Error
+	return invitations, err
+}
+
+// RedeemWithUser creates the user and marks the invitation used in one transaction.
+// The invitation is claimed with a conditional update so it can only be used once.
+func (r *invitationRepository) RedeemWithUser(invitation *models.Invitation, user *models.User) (*models.User, error) {
+	err := withRetry(func() error {
+		return r.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+
+			now := timeutil.Now()
+			result := tx.Model(&models.Invitation{}).
+				Where("id = ? AND used_at IS NULL", invitation.ID).
+				Updates(map[string]interface{}{"used_at": now, "used_by_id": user.ID})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrInvitationUsed
+			}
+
+			invitation.UsedAt = &now
+			invitation.UsedByID = &user.ID
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	InvalidateUserStats()
+	return user, nil
+}
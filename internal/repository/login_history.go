@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+)
+
+type LoginHistoryRepository interface {
+	Create(entry *models.LoginHistory) error
+	ListPage(userID uint, outcome string, params models.PageParams) (*models.PageResponse[*models.LoginHistory], error)
+	ListByUser(userID uint) ([]*models.LoginHistory, error)
+	ListBefore(cutoff time.Time, limit int) ([]*models.LoginHistory, error)
+	DeleteByIDs(ids []uint) error
+}
+
+type loginHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginHistoryRepository() LoginHistoryRepository {
+	return &loginHistoryRepository{db: db}
+}
+
+func (r *loginHistoryRepository) Create(entry *models.LoginHistory) error {
+	return r.db.Create(entry).Error
+}
+
+// ListPage returns one page of the user's sign-in attempts, newest first, limited
+// to one outcome unless outcome is empty
+func (r *loginHistoryRepository) ListPage(userID uint, outcome string, params models.PageParams) (*models.PageResponse[*models.LoginHistory], error) {
+	query := r.db.Model(&models.LoginHistory{}).Where("user_id = ?", userID)
+	if outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+	return Paginate[*models.LoginHistory](query.Order("created_at DESC, id DESC"), params)
+}
+
+// ListByUser returns all of the user's sign-in attempts, newest first
+func (r *loginHistoryRepository) ListByUser(userID uint) ([]*models.LoginHistory, error) {
+	var entries []*models.LoginHistory
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&entries).Error
+	return entries, err
+}
+
+// ListBefore returns up to limit of the oldest entries created before cutoff
+func (r *loginHistoryRepository) ListBefore(cutoff time.Time, limit int) ([]*models.LoginHistory, error) {
+	var entries []*models.LoginHistory
+	err := r.db.Where("created_at < ?", cutoff).Order("id").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// DeleteByIDs removes the given entries
+func (r *loginHistoryRepository) DeleteByIDs(ids []uint) error {
+	return r.db.Delete(&models.LoginHistory{}, ids).Error
+}
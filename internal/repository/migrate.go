@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+)
+
+// migration is one numbered schema or data change. Each runs once, inside a
+// transaction, and is recorded in schema_migrations when it succeeds.
+type migration struct {
+	ID      string
+	Migrate func(tx *gorm.DB) error
+}
+
+// schemaMigration records an applied migration
+type schemaMigration struct {
+	ID        string `gorm:"primarykey"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// migrations run in order. Append new ones with the next number and never edit or
+// reorder applied ones. The table-creating migrations build tables from the
+// current models, so a later migration that adds a column must check
+// Migrator().HasColumn first: on a fresh database the column already exists.
+var migrations = []migration{
+	{
+		ID: "0001_create_users",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.User{})
+		},
+	},
+	{
+		ID: "0002_create_supporting_tables",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.UserDevice{}, &models.Role{}, &models.UserRole{}, &models.Invitation{},
+				&models.UserSession{}, &models.APIKey{}, &models.AuditLog{})
+		},
+	},
+	{
+		ID:      "0003_fit_profile_fields",
+		Migrate: truncateProfileFields,
+	},
+	{
+		ID: "0004_add_profile_public",
+		Migrate: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&models.User{}, "ProfilePublic") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&models.User{}, "ProfilePublic")
+		},
+	},
+	{
+		ID: "0005_create_refresh_tokens",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.RefreshToken{})
+		},
+	},
+	{
+		ID: "0006_add_deactivate_at",
+		Migrate: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&models.User{}, "DeactivateAt") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&models.User{}, "DeactivateAt")
+		},
+	},
+	{
+		ID: "0007_add_recovery_email",
+		Migrate: func(tx *gorm.DB) error {
+			fields := []string{"RecoveryEmail", "RecoveryEmailVerified", "RecoveryNotifications", "RecoveryTokenHash", "RecoverySentAt"}
+			for _, field := range fields {
+				if tx.Migrator().HasColumn(&models.User{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&models.User{}, field); err != nil {
+					return err
+				}
+			}
+			// AddColumn does not create the token hash's index
+			if tx.Migrator().HasIndex(&models.User{}, "RecoveryTokenHash") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&models.User{}, "RecoveryTokenHash")
+		},
+	},
+	{
+		ID: "0008_add_organization_id",
+		Migrate: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&models.User{}, "OrganizationID") {
+				if err := tx.Migrator().AddColumn(&models.User{}, "OrganizationID"); err != nil {
+					return err
+				}
+			}
+			if tx.Migrator().HasIndex(&models.User{}, "OrganizationID") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&models.User{}, "OrganizationID")
+		},
+	},
+	{
+		ID: "0009_create_oauth_tokens",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.OAuthToken{})
+		},
+	},
+	{
+		ID: "0010_add_phone_number",
+		Migrate: func(tx *gorm.DB) error {
+			fields := []string{"PhoneNumber", "PhoneVerified", "PhoneCodeHash", "PhoneCodeSentAt", "PhoneCodeAttempts"}
+			for _, field := range fields {
+				if tx.Migrator().HasColumn(&models.User{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&models.User{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID: "0011_create_signing_keys",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.SigningKey{})
+		},
+	},
+	{
+		ID: "0012_add_terms_acceptance",
+		Migrate: func(tx *gorm.DB) error {
+			for _, field := range []string{"AcceptedTermsAt", "AcceptedTermsVersion"} {
+				if tx.Migrator().HasColumn(&models.User{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&models.User{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID: "0013_add_pending_provider_link",
+		Migrate: func(tx *gorm.DB) error {
+			fields := []string{"PendingLinkProvider", "PendingLinkProviderID", "LinkTokenHash", "LinkSentAt"}
+			for _, field := range fields {
+				if tx.Migrator().HasColumn(&models.User{}, field) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&models.User{}, field); err != nil {
+					return err
+				}
+			}
+			// AddColumn does not create the token hash's index
+			if tx.Migrator().HasIndex(&models.User{}, "LinkTokenHash") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&models.User{}, "LinkTokenHash")
+		},
+	},
+	{
+		ID: "0014_create_login_history",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.LoginHistory{})
+		},
+	},
+	{
+		ID: "0015_create_revoked_tokens",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&models.RevokedToken{})
+		},
+	},
+}
+
+// Migrate applies every migration not yet recorded in schema_migrations, in
+// order, stopping at the first failure. Databases created before migrations
+// existed already have the tables, so the table-creating migrations only add
+// missing columns there.
+func Migrate() error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return fmt.Errorf("failed to create migrations table: %v", err)
+	}
+
+	var applied []string
+	if err := db.Model(&schemaMigration{}).Pluck("id", &applied).Error; err != nil {
+		return fmt.Errorf("failed to load applied migrations: %v", err)
+	}
+	done := make(map[string]bool, len(applied))
+	for _, id := range applied {
+		done[id] = true
+	}
+
+	for _, m := range migrations {
+		if done[m.ID] {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Migrate(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{ID: m.ID, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s failed: %v", m.ID, err)
+		}
+		log.Printf("Applied migration %s", m.ID)
+	}
+
+	// Migrations may rewrite users behind the repository's back
+	InvalidateUserStats()
+	return nil
+}
+
+// truncateProfileFields shortens profile values stored before the columns had size
+// limits, so resizing the columns cannot fail on, or silently cut, existing rows.
+// Characters are counted as SQLite's length() does.
+func truncateProfileFields(tx *gorm.DB) error {
+	limits := map[string]int{
+		"bio":      models.MaxBioLength,
+		"website":  models.MaxWebsiteLength,
+		"location": models.MaxLocationLength,
+	}
+	for column, limit := range limits {
+		err := tx.Model(&models.User{}).Unscoped().
+			Where(fmt.Sprintf("length(%s) > ?", column), limit).
+			UpdateColumn(column, gorm.Expr(fmt.Sprintf("substr(%s, 1, ?)", column), limit)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"sso-web-app/internal/models"
+)
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	openTestDB(t)
+
+	if err := Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	var applied []string
+	if err := db.Model(&schemaMigration{}).Order("id").Pluck("id", &applied).Error; err != nil {
+		t.Fatalf("load applied migrations: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("%d migrations recorded, want %d", len(applied), len(migrations))
+	}
+	for i, m := range migrations {
+		if applied[i] != m.ID {
+			t.Errorf("migration %d = %s, want %s", i, applied[i], m.ID)
+		}
+	}
+
+	for _, model := range []interface{}{
+		&models.User{}, &models.UserDevice{}, &models.Role{}, &models.UserRole{}, &models.Invitation{},
+		&models.UserSession{}, &models.APIKey{}, &models.AuditLog{}, &models.RefreshToken{},
+		&models.OAuthToken{}, &models.SigningKey{}, &models.LoginHistory{}, &models.RevokedToken{},
+	} {
+		if !db.Migrator().HasTable(model) {
+			t.Errorf("no table for %T", model)
+		}
+	}
+
+	// The schema is usable straight away
+	createTestUser(t, "fresh@example.com")
+
+	// Running again applies nothing
+	if err := Migrate(); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	var count int64
+	db.Model(&schemaMigration{}).Count(&count)
+	if count != int64(len(migrations)) {
+		t.Errorf("%d migrations recorded after a second run, want %d", count, len(migrations))
+	}
+}
+
+func TestMigrateDatabaseFromBeforeMigrations(t *testing.T) {
+	openTestDB(t)
+
+	// A database made by the old AutoMigrate call: users only, an oversized bio,
+	// and no schema_migrations table
+	if err := db.Exec(`CREATE TABLE users (id integer PRIMARY KEY AUTOINCREMENT, created_at datetime,
+		updated_at datetime, deleted_at datetime, email text NOT NULL UNIQUE, password text NOT NULL,
+		first_name text NOT NULL, last_name text NOT NULL, is_active numeric DEFAULT true,
+		is_verified numeric DEFAULT false, is_admin numeric DEFAULT false, role text DEFAULT 'user',
+		google_id text, git_hub_id text, avatar_url text, bio text, website text, location text)`).Error; err != nil {
+		t.Fatalf("create legacy users table: %v", err)
+	}
+	if err := db.Exec(`INSERT INTO users (email, password, first_name, last_name, bio) VALUES (?, '', 'Old', 'User', ?)`,
+		"old@example.com", strings.Repeat("a", models.MaxBioLength+50)).Error; err != nil {
+		t.Fatalf("insert legacy user: %v", err)
+	}
+
+	if err := Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	user, err := NewUserRepository().GetByEmail("old@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	if user.Bio == nil || len(*user.Bio) != models.MaxBioLength {
+		t.Errorf("bio was not cut to %d characters", models.MaxBioLength)
+	}
+	if user.TokenVersion != 0 || user.ProfilePublic {
+		t.Errorf("new columns = token version %d, public %v; want the defaults", user.TokenVersion, user.ProfilePublic)
+	}
+}
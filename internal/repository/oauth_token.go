@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sso-web-app/internal/models"
+)
+
+type OAuthTokenRepository interface {
+	Get(userID uint, provider string) (*models.OAuthToken, error)
+	Upsert(token *models.OAuthToken) error
+	Delete(userID uint, provider string) error
+	DeleteByUser(userID uint) error
+}
+
+type oauthTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthTokenRepository() OAuthTokenRepository {
+	return &oauthTokenRepository{db: db}
+}
+
+func (r *oauthTokenRepository) Get(userID uint, provider string) (*models.OAuthToken, error) {
+	var token models.OAuthToken
+	if err := r.db.Where("user_id = ? AND provider = ?", userID, provider).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Upsert stores the user's token for the provider, replacing any previous one in
+// a single statement so concurrent sign-ins cannot race
+func (r *oauthTokenRepository) Upsert(token *models.OAuthToken) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "provider"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"access_token", "refresh_token", "token_type", "expiry", "scopes", "updated_at",
+		}),
+	}).Create(token).Error
+}
+
+func (r *oauthTokenRepository) Delete(userID uint, provider string) error {
+	return r.db.Where("user_id = ? AND provider = ?", userID, provider).Delete(&models.OAuthToken{}).Error
+}
+
+func (r *oauthTokenRepository) DeleteByUser(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.OAuthToken{}).Error
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+)
+
+// Paginate counts the rows matched by query and loads the page described by params.
+// The query should carry its own ordering so pages are stable.
+func Paginate[T any](query *gorm.DB, params models.PageParams) (*models.PageResponse[T], error) {
+	// A new session lets the count and the page query share the conditions safely
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	// Past the end, serve the last page rather than an empty one
+	if lastPage := int((total + int64(params.PageSize) - 1) / int64(params.PageSize)); params.Page > lastPage && lastPage > 0 {
+		params.Page = lastPage
+	}
+
+	var items []T
+	if total > 0 {
+		if err := query.Limit(params.PageSize).Offset(params.Offset()).Find(&items).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return models.NewPageResponse(items, params, total), nil
+}
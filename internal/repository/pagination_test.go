@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"testing"
+
+	"sso-web-app/internal/models"
+)
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      int
+		page      int
+		wantPage  int
+		wantItems int
+		wantPages int
+		wantNext  bool
+	}{
+		{"empty table", 0, 1, 1, 0, 0, false},
+		{"empty table past the end", 0, 3, 3, 0, 0, false},
+		{"exactly one page", 5, 1, 1, 5, 1, false},
+		{"first of two", 7, 1, 1, 5, 2, true},
+		{"partial last page", 7, 2, 2, 2, 2, false},
+		{"exactly two pages", 10, 2, 2, 5, 2, false},
+		{"past the end serves the last page", 7, 9, 2, 2, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			user := createTestUser(t, "paged@example.com")
+			other := createTestUser(t, "other@example.com")
+			for i := 0; i < tt.rows; i++ {
+				mustCreate(t, &models.LoginHistory{UserID: user.ID, Method: "password", Outcome: models.LoginOutcomeSuccess})
+			}
+			mustCreate(t, &models.LoginHistory{UserID: other.ID, Method: "password", Outcome: models.LoginOutcomeSuccess})
+
+			got, err := NewLoginHistoryRepository().ListPage(user.ID, "", models.PageParams{Page: tt.page, PageSize: 5})
+			if err != nil {
+				t.Fatalf("ListPage: %v", err)
+			}
+			if got.Page != tt.wantPage || len(got.Items) != tt.wantItems || got.TotalPages != tt.wantPages || got.HasNext != tt.wantNext {
+				t.Errorf("got page=%d items=%d total_pages=%d has_next=%v, want %d %d %d %v",
+					got.Page, len(got.Items), got.TotalPages, got.HasNext, tt.wantPage, tt.wantItems, tt.wantPages, tt.wantNext)
+			}
+			if got.Total != int64(tt.rows) {
+				t.Errorf("total = %d, want %d", got.Total, tt.rows)
+			}
+			for _, item := range got.Items {
+				if item.UserID != user.ID {
+					t.Errorf("page includes user %d's entry", item.UserID)
+				}
+			}
+		})
+	}
+}
+
+func TestPaginateOrdersPagesWithoutOverlap(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "paged@example.com")
+	for i := 0; i < 7; i++ {
+		mustCreate(t, &models.LoginHistory{UserID: user.ID, Method: "password", Outcome: models.LoginOutcomeSuccess})
+	}
+
+	seen := make(map[uint]bool)
+	for page := 1; page <= 3; page++ {
+		got, err := NewLoginHistoryRepository().ListPage(user.ID, "", models.PageParams{Page: page, PageSize: 3})
+		if err != nil {
+			t.Fatalf("ListPage(%d): %v", page, err)
+		}
+		for _, item := range got.Items {
+			if seen[item.ID] {
+				t.Errorf("entry %d appears on more than one page", item.ID)
+			}
+			seen[item.ID] = true
+		}
+	}
+	if len(seen) != 7 {
+		t.Errorf("pages covered %d entries, want 7", len(seen))
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+)
+
+type RefreshTokenRepository interface {
+	Create(token *models.RefreshToken) (*models.RefreshToken, error)
+	GetByHash(tokenHash string) (*models.RefreshToken, error)
+	MarkUsed(id uint, at time.Time) (bool, error)
+	RevokeFamily(familyID string, at time.Time) error
+	RevokeByUser(userID uint, at time.Time) error
+	ListExpiredBefore(cutoff time.Time, limit int) ([]*models.RefreshToken, error)
+	DeleteByIDs(ids []uint) error
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository() RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(token *models.RefreshToken) (*models.RefreshToken, error) {
+	if err := r.db.Create(token).Error; err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *refreshTokenRepository) GetByHash(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed records that a token was exchanged. It reports false if the token was
+// already used or revoked, so two concurrent refreshes cannot both succeed.
+func (r *refreshTokenRepository) MarkUsed(id uint, at time.Time) (bool, error) {
+	result := r.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", at)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyID string, at time.Time) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", at).Error
+}
+
+func (r *refreshTokenRepository) RevokeByUser(userID uint, at time.Time) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+}
+
+// ListExpiredBefore returns up to limit of the oldest refresh tokens that expired
+// before cutoff
+func (r *refreshTokenRepository) ListExpiredBefore(cutoff time.Time, limit int) ([]*models.RefreshToken, error) {
+	var tokens []*models.RefreshToken
+	err := r.db.Where("expires_at < ?", cutoff).Order("id").Limit(limit).Find(&tokens).Error
+	return tokens, err
+}
+
+// DeleteByIDs removes the given refresh tokens
+func (r *refreshTokenRepository) DeleteByIDs(ids []uint) error {
+	return r.db.Delete(&models.RefreshToken{}, ids).Error
+}
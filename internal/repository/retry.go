@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// retryPolicy says how often, and after what delay, database operations that fail
+// with a transient error are tried again. The delay doubles with each retry.
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+}
+
+// dbRetry is the policy for every repository, configured once by InitDB
+var dbRetry retryPolicy
+
+// isTransientDBError reports whether err means the database was briefly busy, so
+// the same operation may succeed if tried again. SQLite reports this when another
+// connection holds a conflicting lock for longer than its busy timeout.
+func isTransientDBError(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
+
+// delay is how long to wait before the given retry, counting from 0
+func (p retryPolicy) delay(retry int) time.Duration {
+	return p.backoff << retry
+}
+
+// withRetry runs op, and runs it again while it fails with a transient error, up
+// to dbRetry's number of retries, returning the last error as is. It is for
+// transactions and other operations made of several statements: op is run from
+// the start each time, so it must not depend on state an earlier, rolled-back
+// attempt left behind.
+func withRetry(op func() error) error {
+	err := op()
+	for retry := 0; retry < dbRetry.retries && isTransientDBError(err); retry++ {
+		time.Sleep(dbRetry.delay(retry))
+		err = op()
+	}
+	return err
+}
+
+// registerRetryCallbacks makes GORM try single statements again when they fail with
+// a transient error: queries, and creates, updates and deletes in the transaction
+// GORM opens for each of them. A statement inside a caller's transaction is not
+// retried on its own, since the transaction may no longer be usable; such
+// transactions are retried whole with withRetry.
+func registerRetryCallbacks(db *gorm.DB) error {
+	if dbRetry.retries == 0 {
+		return nil
+	}
+
+	callbacks := []struct {
+		name      string
+		processor interface {
+			Get(name string) func(*gorm.DB)
+			Replace(name string, fn func(*gorm.DB)) error
+		}
+	}{
+		{"gorm:query", db.Callback().Query()},
+		{"gorm:create", db.Callback().Create()},
+		{"gorm:update", db.Callback().Update()},
+		{"gorm:delete", db.Callback().Delete()},
+	}
+	for _, cb := range callbacks {
+		if err := cb.processor.Replace(cb.name, dbRetry.statement(cb.processor.Get(cb.name))); err != nil {
+			return fmt.Errorf("failed to register retry for %s: %v", cb.name, err)
+		}
+	}
+	return nil
+}
+
+// statement wraps a GORM callback that runs one SQL statement so it runs again
+// while the statement fails with a transient error before returning any rows.
+// The statement is built once and reused.
+func (p retryPolicy) statement(callback func(*gorm.DB)) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil {
+			callback(db)
+			return
+		}
+
+		callback(db)
+		for retry := 0; retry < p.retries && retryableStatement(db); retry++ {
+			time.Sleep(p.delay(retry))
+			db.Error = nil
+			db.RowsAffected = 0
+			callback(db)
+		}
+	}
+}
+
+// retryableStatement reports whether the statement just run by db failed with a
+// transient error, touched no rows, and is not part of a caller's transaction
+func retryableStatement(db *gorm.DB) bool {
+	if !isTransientDBError(db.Error) || db.RowsAffected != 0 {
+		return false
+	}
+	if _, ok := db.InstanceGet("gorm:started_transaction"); ok {
+		// GORM began this transaction for the statement alone, and SQLite releases
+		// its locks when the first statement of a transaction fails to get them
+		return true
+	}
+	_, inTransaction := db.Statement.ConnPool.(gorm.TxCommitter)
+	return !inTransaction
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var errBusy = sqlite3.Error{Code: sqlite3.ErrBusy}
+
+// useRetryPolicy sets dbRetry for one test
+func useRetryPolicy(t *testing.T, retries int) {
+	t.Helper()
+	saved := dbRetry
+	dbRetry = retryPolicy{retries: retries, backoff: time.Millisecond}
+	t.Cleanup(func() { dbRetry = saved })
+}
+
+// retryRecord is a table for exercising the retry callbacks
+type retryRecord struct {
+	ID   uint
+	Name string
+}
+
+// openFlakyDB opens an in-memory database whose GORM callback name fails with
+// failures[i] on its i-th run and runs normally once failures run out. It counts
+// the runs in calls.
+func openFlakyDB(t *testing.T, name string, failures []error, calls *int) *gorm.DB {
+	t.Helper()
+	flaky, err := gorm.Open(sqlite.Open("file:"+url.PathEscape(t.Name())+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := flaky.AutoMigrate(&retryRecord{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+
+	processor := flaky.Callback().Create()
+	if name == "gorm:query" {
+		processor = flaky.Callback().Query()
+	}
+	next := processor.Get(name)
+	if err := processor.Replace(name, func(db *gorm.DB) {
+		*calls++
+		if *calls <= len(failures) && failures[*calls-1] != nil {
+			db.AddError(failures[*calls-1])
+			return
+		}
+		next(db)
+	}); err != nil {
+		t.Fatalf("Replace %s: %v", name, err)
+	}
+
+	if err := registerRetryCallbacks(flaky); err != nil {
+		t.Fatalf("registerRetryCallbacks: %v", err)
+	}
+	return flaky
+}
+
+func TestWithRetry(t *testing.T) {
+	useRetryPolicy(t, 3)
+	errPermanent := errors.New("constraint failed")
+
+	tests := []struct {
+		name      string
+		failures  []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"succeeds at once", nil, 1, nil},
+		{"busy, then succeeds", []error{errBusy}, 2, nil},
+		{"locked and wrapped, then succeeds", []error{fmt.Errorf("commit: %w", sqlite3.Error{Code: sqlite3.ErrLocked})}, 2, nil},
+		{"permanent error", []error{errPermanent}, 1, errPermanent},
+		{"busy past the retry budget", []error{errBusy, errBusy, errBusy, errBusy, errBusy}, 4, errBusy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := withRetry(func() error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("withRetry = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("op ran %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryCallbacks(t *testing.T) {
+	errPermanent := errors.New("constraint failed")
+
+	tests := []struct {
+		name      string
+		callback  string
+		failures  []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"create busy, then succeeds", "gorm:create", []error{errBusy}, 2, nil},
+		{"query busy, then succeeds", "gorm:query", []error{errBusy, errBusy}, 3, nil},
+		{"create permanent error", "gorm:create", []error{errPermanent}, 1, errPermanent},
+		{"query busy past the retry budget", "gorm:query", []error{errBusy, errBusy, errBusy, errBusy}, 3, errBusy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useRetryPolicy(t, 2)
+			calls := 0
+			flaky := openFlakyDB(t, tt.callback, tt.failures, &calls)
+
+			var err error
+			if tt.callback == "gorm:create" {
+				err = flaky.Create(&retryRecord{Name: "created"}).Error
+			} else {
+				var records []retryRecord
+				err = flaky.Find(&records).Error
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("statement error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("%s ran %d times, want %d", tt.callback, calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryCallbacksLeaveCallerTransactions(t *testing.T) {
+	useRetryPolicy(t, 2)
+	calls := 0
+	flaky := openFlakyDB(t, "gorm:create", []error{errBusy}, &calls)
+
+	// The statement fails inside the transaction and is not retried there; the
+	// transaction as a whole is, by withRetry
+	attempts := 0
+	err := withRetry(func() error {
+		attempts++
+		return flaky.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(&retryRecord{Name: "in transaction"}).Error
+		})
+	})
+	if err != nil {
+		t.Fatalf("withRetry = %v", err)
+	}
+	if attempts != 2 || calls != 2 {
+		t.Errorf("transaction ran %d times and the insert %d times, want 2 and 2", attempts, calls)
+	}
+
+	var count int64
+	if err := flaky.Model(&retryRecord{}).Count(&count).Error; err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("%d rows stored, want 1", count)
+	}
+}
+
+func TestRetryCallbacksDisabled(t *testing.T) {
+	useRetryPolicy(t, 0)
+	calls := 0
+	flaky := openFlakyDB(t, "gorm:create", []error{errBusy}, &calls)
+
+	if err := flaky.Create(&retryRecord{Name: "once"}).Error; !errors.Is(err, errBusy) {
+		t.Errorf("Create = %v, want %v", err, errBusy)
+	}
+	if calls != 1 {
+		t.Errorf("gorm:create ran %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sso-web-app/internal/models"
+)
+
+type RoleRepository interface {
+	List() ([]*models.Role, error)
+	GetByID(id uint) (*models.Role, error)
+	GetByName(name string) (*models.Role, error)
+	Create(role *models.Role) (*models.Role, error)
+	Update(role *models.Role) (*models.Role, error)
+	Delete(id uint) error
+	AssignToUsers(roleID uint, userIDs []uint) ([]uint, error)
+	ListByUser(userID uint) ([]*models.Role, error)
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository() RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) List() ([]*models.Role, error) {
+	var roles []*models.Role
+	if err := r.db.Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) GetByID(id uint) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.First(&role, id).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) GetByName(name string) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) Create(role *models.Role) (*models.Role, error) {
+	if err := r.db.Create(role).Error; err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (r *roleRepository) Update(role *models.Role) (*models.Role, error) {
+	if err := r.db.Save(role).Error; err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// Delete removes a role together with all of its assignments
+func (r *roleRepository) Delete(id uint) error {
+	return withRetry(func() error {
+		return r.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("role_id = ?", id).Delete(&models.UserRole{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.Role{}, id).Error
+		})
+	})
+}
+
+// AssignToUsers assigns a role to every existing user in userIDs within one
+// transaction and returns the IDs that were found. Existing assignments are kept.
+func (r *roleRepository) AssignToUsers(roleID uint, userIDs []uint) ([]uint, error) {
+	var found []uint
+	err := withRetry(func() error {
+		return r.db.Transaction(func(tx *gorm.DB) error {
+			found = nil
+			if err := tx.Model(&models.User{}).Where("id IN ?", userIDs).Pluck("id", &found).Error; err != nil {
+				return err
+			}
+			if len(found) == 0 {
+				return nil
+			}
+
+			assignments := make([]models.UserRole, 0, len(found))
+			for _, userID := range found {
+				assignments = append(assignments, models.UserRole{UserID: userID, RoleID: roleID})
+			}
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&assignments).Error
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// ListByUser returns the custom roles assigned to a user
+func (r *roleRepository) ListByUser(userID uint) ([]*models.Role, error) {
+	var roles []*models.Role
+	err := r.db.Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").
+		Find(&roles).Error
+	return roles, err
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// likeEscaper escapes LIKE wildcards so user input only ever matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Columns searched by the admin user search and by the directory. The directory
+// leaves out email so it cannot be used to check who has an address.
+var (
+	adminSearchColumns     = []string{"first_name", "last_name", "email"}
+	directorySearchColumns = []string{"first_name", "last_name"}
+)
+
+// matchUserSearch narrows query to users matching every whitespace-separated term
+// of search in at least one of columns, so "John Doe" finds John Doe even though
+// neither column holds the whole phrase. Terms are matched literally: % and _ in
+// the input are not wildcards. SQLite's LIKE ignores ASCII case.
+func matchUserSearch(query *gorm.DB, search string, columns []string) *gorm.DB {
+	conditions := make([]string, len(columns))
+	for i, column := range columns {
+		conditions[i] = column + ` LIKE ? ESCAPE '\'`
+	}
+	condition := strings.Join(conditions, " OR ")
+
+	for _, term := range strings.Fields(search) {
+		pattern := "%" + likeEscaper.Replace(term) + "%"
+		args := make([]interface{}, len(columns))
+		for i := range args {
+			args[i] = pattern
+		}
+		query = query.Where(condition, args...)
+	}
+	return query
+}
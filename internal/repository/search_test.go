@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"sso-web-app/internal/models"
+)
+
+func TestSearchUsers(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserRepository()
+
+	for _, u := range []struct{ email, first, last string }{
+		{"john.doe@example.com", "John", "Doe"},
+		{"john.smith@example.com", "John", "Smith"},
+		{"jane.doe@example.com", "Jane", "Doe"},
+		{"percent@example.com", "100%", "Pure"},
+		{"onehundred@example.com", "1000", "Pure"},
+		{"snake@example.com", "snake_case", "Coder"},
+		{"snakes@example.com", "snakeXcase", "Coder"},
+		{"backslash@example.com", `back\slash`, "Coder"},
+	} {
+		if _, err := users.Create(&models.User{Email: u.email, FirstName: u.first, LastName: u.last, Role: "user", IsActive: true}); err != nil {
+			t.Fatalf("Create %s: %v", u.email, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"John Doe", []string{"john.doe@example.com"}},
+		{"doe john", []string{"john.doe@example.com"}},
+		{"  john   DOE ", []string{"john.doe@example.com"}},
+		{"Doe", []string{"jane.doe@example.com", "john.doe@example.com"}},
+		{"john example.com", []string{"john.doe@example.com", "john.smith@example.com"}},
+		{"John Nobody", nil},
+		{"100%", []string{"percent@example.com"}},
+		{"%", []string{"percent@example.com"}},
+		{"snake_case", []string{"snake@example.com"}},
+		{"_", []string{"snake@example.com"}},
+		{`back\slash`, []string{"backslash@example.com"}},
+		{`\`, []string{"backslash@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			found, err := users.SearchUsers(tt.query, 20, 0)
+			if err != nil {
+				t.Fatalf("SearchUsers: %v", err)
+			}
+			var got []string
+			for _, user := range found {
+				got = append(got, user.Email)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("SearchUsers(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+)
+
+type SessionRepository interface {
+	Create(session *models.UserSession) (*models.UserSession, error)
+	GetByID(id uint) (*models.UserSession, error)
+	GetByTokenID(tokenID string) (*models.UserSession, error)
+	Update(session *models.UserSession) (*models.UserSession, error)
+	ReplaceToken(session *models.UserSession, tokenID string, expiresAt time.Time) (*models.UserSession, error)
+	ListByUser(userID uint) ([]*models.UserSession, error)
+	ListActiveByUser(userID uint, now time.Time) ([]*models.UserSession, error)
+	ListRevokedUnexpired(now time.Time) ([]*models.UserSession, error)
+	ListRevokedTokens(now time.Time) ([]*models.RevokedToken, error)
+	DeleteExpiredRevokedTokens(now time.Time) error
+	Revoke(ids []uint, at time.Time) error
+	RevokeByTokenID(tokenID string, at time.Time) error
+	ListExpiredBefore(cutoff, now time.Time, limit int) ([]*models.UserSession, error)
+	DeleteByIDs(ids []uint) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository() SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(session *models.UserSession) (*models.UserSession, error) {
+	if err := r.db.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *sessionRepository) GetByID(id uint) (*models.UserSession, error) {
+	var session models.UserSession
+	if err := r.db.First(&session, id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) GetByTokenID(tokenID string) (*models.UserSession, error) {
+	var session models.UserSession
+	if err := r.db.Where("token_id = ?", tokenID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) Update(session *models.UserSession) (*models.UserSession, error) {
+	if err := r.db.Save(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ReplaceToken moves the session to the token tokenID expiring at expiresAt. The
+// token it used until now is recorded as revoked in the same transaction, so it
+// stays rejected after a restart even though no session row names it any more.
+func (r *sessionRepository) ReplaceToken(session *models.UserSession, tokenID string, expiresAt time.Time) (*models.UserSession, error) {
+	updated := *session
+	updated.TokenID = tokenID
+	updated.ExpiresAt = expiresAt
+
+	err := withRetry(func() error {
+		return r.db.Transaction(func(tx *gorm.DB) error {
+			revoked := &models.RevokedToken{TokenID: session.TokenID, ExpiresAt: session.ExpiresAt}
+			if err := tx.Create(revoked).Error; err != nil {
+				return err
+			}
+			return tx.Save(&updated).Error
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	*session = updated
+	return session, nil
+}
+
+// ListByUser returns all of the user's sessions, newest first
+func (r *sessionRepository) ListByUser(userID uint) ([]*models.UserSession, error) {
+	var sessions []*models.UserSession
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// ListActiveByUser returns the user's unrevoked, unexpired sessions, oldest first
+func (r *sessionRepository) ListActiveByUser(userID uint, now time.Time) ([]*models.UserSession, error) {
+	var sessions []*models.UserSession
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("created_at ASC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// ListRevokedUnexpired returns revoked sessions whose tokens would otherwise still be valid
+func (r *sessionRepository) ListRevokedUnexpired(now time.Time) ([]*models.UserSession, error) {
+	var sessions []*models.UserSession
+	err := r.db.Where("revoked_at IS NOT NULL AND expires_at > ?", now).Find(&sessions).Error
+	return sessions, err
+}
+
+// ListRevokedTokens returns the replaced tokens that would otherwise still be valid
+func (r *sessionRepository) ListRevokedTokens(now time.Time) ([]*models.RevokedToken, error) {
+	var tokens []*models.RevokedToken
+	err := r.db.Where("expires_at > ?", now).Find(&tokens).Error
+	return tokens, err
+}
+
+// DeleteExpiredRevokedTokens removes replaced tokens that have expired by now,
+// since expired tokens are rejected anyway
+func (r *sessionRepository) DeleteExpiredRevokedTokens(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&models.RevokedToken{}).Error
+}
+
+func (r *sessionRepository) Revoke(ids []uint, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.UserSession{}).
+		Where("id IN ? AND revoked_at IS NULL", ids).
+		Update("revoked_at", at).Error
+}
+
+func (r *sessionRepository) RevokeByTokenID(tokenID string, at time.Time) error {
+	return r.db.Model(&models.UserSession{}).
+		Where("token_id = ? AND revoked_at IS NULL", tokenID).
+		Update("revoked_at", at).Error
+}
+
+// ListExpiredBefore returns up to limit of the oldest sessions whose token expired
+// before cutoff, skipping any that still have a usable refresh token
+func (r *sessionRepository) ListExpiredBefore(cutoff, now time.Time, limit int) ([]*models.UserSession, error) {
+	live := r.db.Model(&models.RefreshToken{}).Select("session_id").
+		Where("revoked_at IS NULL AND used_at IS NULL AND expires_at > ?", now)
+
+	var sessions []*models.UserSession
+	err := r.db.Where("expires_at < ? AND id NOT IN (?)", cutoff, live).
+		Order("id").
+		Limit(limit).
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// DeleteByIDs removes the given sessions
+func (r *sessionRepository) DeleteByIDs(ids []uint) error {
+	return r.db.Delete(&models.UserSession{}, ids).Error
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+)
+
+type SigningKeyRepository interface {
+	ListPublished(retiredAfter time.Time) ([]*models.SigningKey, error)
+	Rotate(key *models.SigningKey, now time.Time) error
+	DeleteRetiredBefore(cutoff time.Time) (int64, error)
+}
+
+type signingKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository() SigningKeyRepository {
+	return &signingKeyRepository{db: db}
+}
+
+// ListPublished returns the active key and every key retired after retiredAfter,
+// newest first
+func (r *signingKeyRepository) ListPublished(retiredAfter time.Time) ([]*models.SigningKey, error) {
+	var keys []*models.SigningKey
+	err := r.db.Where("retired_at IS NULL OR retired_at > ?", retiredAfter).
+		Order("created_at DESC, id DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// Rotate retires the active key at now and stores key as the new one, in a single
+// transaction so there is never more than one active key
+func (r *signingKeyRepository) Rotate(key *models.SigningKey, now time.Time) error {
+	return withRetry(func() error {
+		return r.db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&models.SigningKey{}).
+				Where("retired_at IS NULL").
+				Update("retired_at", now).Error
+			if err != nil {
+				return err
+			}
+			return tx.Create(key).Error
+		})
+	})
+}
+
+// DeleteRetiredBefore removes keys retired before cutoff, which no longer verify anything
+func (r *signingKeyRepository) DeleteRetiredBefore(cutoff time.Time) (int64, error) {
+	result := r.db.Where("retired_at IS NOT NULL AND retired_at <= ?", cutoff).Delete(&models.SigningKey{})
+	return result.RowsAffected, result.Error
+}
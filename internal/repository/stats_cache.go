@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"sso-web-app/internal/models"
+)
+
+// userStatsCache keeps the admin dashboard statistics for a short time. It is
+// package-level so writes through any repository instance invalidate it.
+type userStatsCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	stats    *models.UserStatsResponse
+	loadedAt time.Time
+}
+
+var statsCache = &userStatsCache{}
+
+// get returns a copy of the cached statistics if they are younger than the TTL
+func (c *userStatsCache) get() (*models.UserStatsResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stats == nil || time.Since(c.loadedAt) >= c.ttl {
+		return nil, false
+	}
+	stats := *c.stats
+	return &stats, true
+}
+
+// set stores a copy of freshly loaded statistics. A zero TTL disables caching.
+func (c *userStatsCache) set(stats *models.UserStatsResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 {
+		return
+	}
+	cached := *stats
+	c.stats = &cached
+	c.loadedAt = time.Now()
+}
+
+// InvalidateUserStats drops the cached dashboard statistics so the next request
+// recounts. Every user write calls it.
+func InvalidateUserStats() {
+	statsCache.mu.Lock()
+	defer statsCache.mu.Unlock()
+
+	statsCache.stats = nil
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"sso-web-app/internal/models"
+)
+
+func TestUserStatsCache(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserRepository()
+	createTestUser(t, "first@example.com")
+
+	// addBehindCache inserts a user without going through the repository, so
+	// nothing invalidates the cache
+	addBehindCache := func(email string) {
+		t.Helper()
+		if err := db.Create(&models.User{Email: email, FirstName: "Test", LastName: "User", IsActive: true}).Error; err != nil {
+			t.Fatalf("insert %s: %v", email, err)
+		}
+	}
+	total := func() int64 {
+		t.Helper()
+		stats, err := users.GetUserStats()
+		if err != nil {
+			t.Fatalf("GetUserStats: %v", err)
+		}
+		return stats.TotalUsers
+	}
+
+	if got := total(); got != 1 {
+		t.Fatalf("total users = %d, want 1", got)
+	}
+
+	// Within the TTL the cached counts are served without querying again
+	addBehindCache("second@example.com")
+	if got := total(); got != 1 {
+		t.Errorf("total users within the TTL = %d, want the cached 1", got)
+	}
+
+	// Invalidation forces a recount
+	InvalidateUserStats()
+	if got := total(); got != 2 {
+		t.Errorf("total users after invalidation = %d, want 2", got)
+	}
+
+	// Writes through the repository invalidate on their own
+	createTestUser(t, "third@example.com")
+	if got := total(); got != 3 {
+		t.Errorf("total users after Create = %d, want 3", got)
+	}
+
+	// Once the TTL has passed the counts are loaded again
+	addBehindCache("fourth@example.com")
+	statsCache.mu.Lock()
+	statsCache.loadedAt = time.Now().Add(-statsCache.ttl)
+	statsCache.mu.Unlock()
+	if got := total(); got != 4 {
+		t.Errorf("total users after the TTL = %d, want 4", got)
+	}
+}
@@ -1,26 +1,64 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"os"
+	"strings"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"sso-web-app/configs"
 	"sso-web-app/internal/models"
+	"sso-web-app/internal/timeutil"
 )
 
+// ErrStaleUpdate is returned when a user row was modified after it was read
+var ErrStaleUpdate = errors.New("user was modified by another request")
+
+// ErrLastAdmin is returned when a change would leave no active admin
+var ErrLastAdmin = errors.New("cannot remove the last active admin")
+
 type UserRepository interface {
 	Create(user *models.User) (*models.User, error)
+	CreateWithProvider(user *models.User, column, providerID string) (*models.User, bool, error)
+	UpsertByEmail(user *models.User) (*models.User, bool, error)
 	GetByID(id uint) (*models.User, error)
+	GetByIDs(ids []uint) ([]*models.User, error)
 	GetByEmail(email string) (*models.User, error)
 	GetByGoogleID(googleID string) (*models.User, error)
 	GetByGitHubID(githubID string) (*models.User, error)
+	ExistsByEmail(email string) (bool, error)
+	ExistsByEmailFold(email string) (bool, error)
+	ExistsDeletedByEmail(email string) (bool, error)
+	ScrubDeletedByEmail(email string) error
+	ListPage(filter models.UserListFilter, params models.PageParams) (*models.PageResponse[*models.User], error)
+	CountNotificationRecipients(filter models.NotificationFilter) (int64, error)
+	ListDueDeactivations(now time.Time) ([]*models.User, error)
+	ListNotificationRecipients(filter models.NotificationFilter) ([]*models.User, error)
+	ExistsByGoogleID(googleID string) (bool, error)
+	ExistsByGitHubID(githubID string) (bool, error)
+	GetByVerificationTokenHash(hash string) (*models.User, error)
+	GetByRecoveryTokenHash(hash string) (*models.User, error)
+	GetByLinkTokenHash(hash string) (*models.User, error)
+	ListTokenVersions() (map[uint]uint, error)
 	Update(user *models.User) (*models.User, error)
+	UpdateUnlessLastAdmin(user *models.User) (*models.User, error)
 	Delete(id uint) error
+	DeleteUnlessLastAdmin(id uint) error
 	List(limit, offset int) ([]*models.User, error)
 	GetUserStats() (*models.UserStatsResponse, error)
+	CountSignupsByBucket(bounds []time.Time) ([]int64, error)
 	GetUsersByRole(role string, limit, offset int) ([]*models.User, error)
 	SearchUsers(query string, limit, offset int) ([]*models.User, error)
+	DirectoryPage(search string, params models.PageParams) (*models.PageResponse[*models.User], error)
 	GetRecentUsers(days int, limit, offset int) ([]*models.User, error)
+	FindInactiveSince(lastLoginBefore time.Time, limit, offset int) ([]*models.User, error)
+	MergeUsers(primary, duplicate *models.User) (*models.User, error)
 }
 
 type userRepository struct {
@@ -29,20 +67,56 @@ type userRepository struct {
 
 var db *gorm.DB
 
-func init() {
+// InitDB opens the database described by cfg. It must be called before any
+// repository is used; call Migrate afterwards to bring the schema up to date.
+func InitDB(cfg *configs.Config) error {
 	var err error
-	dbPath := os.Getenv("DATABASE_URL")
-	if dbPath == "" {
-		dbPath = "sso_app.db"
+	db, err = gorm.Open(sqlite.Open(cfg.DatabaseURL), &gorm.Config{
+		// Store all timestamps in UTC regardless of the server's local zone
+		NowFunc: timeutil.Now,
+		Logger:  newDBLogger(cfg),
+		// Report unique index violations as gorm.ErrDuplicatedKey
+		TranslateError: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %v", err)
 	}
+	statsCache.ttl = cfg.AdminStatsCacheTTL
 
-	db, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
-	if err != nil {
-		panic("Failed to connect to database: " + err.Error())
+	dbRetry = retryPolicy{retries: cfg.DBRetryAttempts, backoff: cfg.DBRetryBackoff}
+	return registerRetryCallbacks(db)
+}
+
+// newDBLogger builds the GORM logger from the configured log level (silent, error,
+// warn, info) and slow-query threshold. Queries slower than the threshold are logged
+// with their SQL and duration at warn level and above; info logs every statement.
+func newDBLogger(cfg *configs.Config) logger.Interface {
+	return logger.New(log.New(os.Stdout, "", log.LstdFlags), logger.Config{
+		SlowThreshold:             time.Duration(cfg.DBSlowQueryMS) * time.Millisecond,
+		LogLevel:                  dbLogLevel(cfg),
+		IgnoreRecordNotFoundError: true, // lookups by email/provider ID miss routinely
+		Colorful:                  false,
+	})
+}
+
+// dbLogLevel is DB_LOG_LEVEL when it names a level, otherwise info in development
+// and warn elsewhere
+func dbLogLevel(cfg *configs.Config) logger.LogLevel {
+	levels := map[string]logger.LogLevel{
+		"silent": logger.Silent,
+		"error":  logger.Error,
+		"warn":   logger.Warn,
+		"info":   logger.Info,
 	}
 
-	// Auto migrate the schema
-	db.AutoMigrate(&models.User{})
+	level := logger.Warn
+	if cfg.IsDevelopment() {
+		level = logger.Info
+	}
+	if configured, ok := levels[strings.ToLower(cfg.DBLogLevel)]; ok {
+		level = configured
+	}
+	return level
 }
 
 func NewUserRepository() UserRepository {
@@ -53,9 +127,76 @@ func (r *userRepository) Create(user *models.User) (*models.User, error) {
 	if err := r.db.Create(user).Error; err != nil {
 		return nil, err
 	}
+	InvalidateUserStats()
 	return user, nil
 }
 
+// CreateWithProvider creates a user signing in through a provider for the first
+// time, where column holds the provider's user ID. The ID is checked again inside
+// the transaction, and if a concurrent sign-in created the account first, that
+// account is returned instead with created false.
+func (r *userRepository) CreateWithProvider(user *models.User, column, providerID string) (*models.User, bool, error) {
+	var existing models.User
+	created := false
+	err := withRetry(func() error {
+		return r.db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Where(column+" = ?", providerID).First(&existing).Error
+			if err == nil {
+				created = false
+				return nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			created = true
+			return tx.Create(user).Error
+		})
+	})
+
+	// A sign-in that raced past the check above loses on the unique index
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		if lookupErr := r.db.Where(column+" = ?", providerID).First(&existing).Error; lookupErr == nil {
+			return &existing, false, nil
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !created {
+		return &existing, false, nil
+	}
+	InvalidateUserStats()
+	return user, true, nil
+}
+
+// upsertColumns are the only columns UpsertByEmail overwrites on an existing user.
+// Everything else, such as the password, sign-in methods and role, stays local.
+var upsertColumns = []string{"first_name", "last_name", "updated_at"}
+
+// UpsertByEmail inserts user, or if a user with the same email exists, updates
+// that user's upsertColumns, in a single statement so concurrent syncs of one
+// email cannot race. It returns the stored user and whether it was created.
+func (r *userRepository) UpsertByEmail(user *models.User) (*models.User, bool, error) {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "email"}},
+		DoUpdates: append(clause.AssignmentColumns(upsertColumns),
+			clause.Assignment{Column: clause.Column{Name: "version"}, Value: gorm.Expr("version + 1")}),
+	}).Create(user).Error
+	if err != nil {
+		return nil, false, err
+	}
+
+	// An update keeps the stored creation time, which tells the two outcomes apart
+	stored, err := r.GetByEmail(user.Email)
+	if err != nil {
+		return nil, false, err
+	}
+	created := stored.CreatedAt.Equal(user.CreatedAt)
+	InvalidateUserStats()
+	return stored, created, nil
+}
+
 func (r *userRepository) GetByID(id uint) (*models.User, error) {
 	var user models.User
 	if err := r.db.First(&user, id).Error; err != nil {
@@ -64,6 +205,16 @@ func (r *userRepository) GetByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
+// GetByIDs returns the users with the given IDs, in ID order; missing IDs are skipped
+func (r *userRepository) GetByIDs(ids []uint) ([]*models.User, error) {
+	var users []*models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("id IN ?", ids).Order("id").Find(&users).Error
+	return users, err
+}
+
 func (r *userRepository) GetByEmail(email string) (*models.User, error) {
 	var user models.User
 	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
@@ -88,17 +239,180 @@ func (r *userRepository) GetByGitHubID(githubID string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) Update(user *models.User) (*models.User, error) {
-	if err := r.db.Save(user).Error; err != nil {
+// ExistsByEmail reports whether a user has the email, without loading the row.
+// Unlike GetByEmail, absence is not an error.
+func (r *userRepository) ExistsByEmail(email string) (bool, error) {
+	return r.exists("email = ?", email)
+}
+
+// ExistsByEmailFold reports whether any user's primary email matches, ignoring case
+func (r *userRepository) ExistsByEmailFold(email string) (bool, error) {
+	return r.exists("LOWER(email) = LOWER(?)", email)
+}
+
+// ExistsDeletedByEmail reports whether a soft-deleted user still holds the email.
+// Such rows are invisible to ExistsByEmail but still occupy the unique index.
+func (r *userRepository) ExistsDeletedByEmail(email string) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&models.User{}).
+		Where("email = ? AND deleted_at IS NOT NULL", email).
+		Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+// ScrubDeletedByEmail frees the email held by a soft-deleted user. The row keeps
+// its ID, so audit entries still resolve, but its email becomes
+// deleted-<id>@deleted.invalid and its password, provider links and personal
+// details are cleared, which also frees the provider IDs' unique indexes.
+func (r *userRepository) ScrubDeletedByEmail(email string) error {
+	return r.db.Unscoped().Model(&models.User{}).
+		Where("email = ? AND deleted_at IS NOT NULL", email).
+		Updates(map[string]interface{}{
+			"email":                   gorm.Expr("'deleted-' || id || '@deleted.invalid'"),
+			"password":                "",
+			"first_name":              "",
+			"last_name":               "",
+			"google_id":               nil,
+			"git_hub_id":              nil,
+			"avatar_url":              nil,
+			"bio":                     nil,
+			"website":                 nil,
+			"location":                nil,
+			"recovery_email":          nil,
+			"recovery_token_hash":     "",
+			"verification_token_hash": "",
+		}).Error
+}
+
+// ExistsByGoogleID reports whether a user is linked to the Google account
+func (r *userRepository) ExistsByGoogleID(googleID string) (bool, error) {
+	return r.exists("google_id = ?", googleID)
+}
+
+// ExistsByGitHubID reports whether a user is linked to the GitHub account
+func (r *userRepository) ExistsByGitHubID(githubID string) (bool, error) {
+	return r.exists("git_hub_id = ?", githubID)
+}
+
+// exists reports whether any user matches the condition
+func (r *userRepository) exists(query string, args ...interface{}) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.User{}).Where(query, args...).Limit(1).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *userRepository) GetByVerificationTokenHash(hash string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("verification_token_hash = ?", hash).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetByRecoveryTokenHash(hash string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("recovery_token_hash = ?", hash).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetByLinkTokenHash(hash string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("link_token_hash = ?", hash).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListTokenVersions returns the token version of every user who has bumped it
+func (r *userRepository) ListTokenVersions() (map[uint]uint, error) {
+	var rows []struct {
+		ID           uint
+		TokenVersion uint
+	}
+	if err := r.db.Model(&models.User{}).Select("id, token_version").Where("token_version > 0").Scan(&rows).Error; err != nil {
 		return nil, err
 	}
+
+	versions := make(map[uint]uint, len(rows))
+	for _, row := range rows {
+		versions[row.ID] = row.TokenVersion
+	}
+	return versions, nil
+}
+
+// Update saves the user only if its version still matches the stored row,
+// so concurrent read-modify-write cycles cannot silently overwrite each other
+func (r *userRepository) Update(user *models.User) (*models.User, error) {
+	expectedVersion := user.Version
+	user.Version++
+
+	result := r.db.Model(user).Where("version = ?", expectedVersion).Select("*").Updates(user)
+	if result.Error != nil {
+		user.Version = expectedVersion
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		user.Version = expectedVersion
+		return nil, ErrStaleUpdate
+	}
+	InvalidateUserStats()
 	return user, nil
 }
 
 func (r *userRepository) Delete(id uint) error {
+	defer InvalidateUserStats()
 	return r.db.Delete(&models.User{}, id).Error
 }
 
+// otherActiveAdminExists is a condition that holds while an active admin other
+// than the row being written remains. It is part of the write statement itself,
+// so two admins removing each other concurrently cannot both succeed.
+const otherActiveAdminExists = `EXISTS (SELECT 1 FROM users AS other WHERE other.id <> users.id
+	AND other.deleted_at IS NULL AND other.is_active = ? AND (other.is_admin = ? OR other.role = ?))`
+
+// UpdateUnlessLastAdmin saves user like Update, but returns ErrLastAdmin instead
+// if no other active admin would remain
+func (r *userRepository) UpdateUnlessLastAdmin(user *models.User) (*models.User, error) {
+	expectedVersion := user.Version
+	user.Version++
+
+	result := r.db.Model(user).
+		Where("version = ?", expectedVersion).
+		Where(otherActiveAdminExists, true, true, "admin").
+		Select("*").Updates(user)
+	if result.Error != nil {
+		user.Version = expectedVersion
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		user.Version = expectedVersion
+		if current, err := r.GetByID(user.ID); err == nil && current.Version != expectedVersion {
+			return nil, ErrStaleUpdate
+		}
+		return nil, ErrLastAdmin
+	}
+	InvalidateUserStats()
+	return user, nil
+}
+
+// DeleteUnlessLastAdmin deletes the user like Delete, but returns ErrLastAdmin
+// instead if no other active admin would remain
+func (r *userRepository) DeleteUnlessLastAdmin(id uint) error {
+	result := r.db.Where(otherActiveAdminExists, true, true, "admin").Delete(&models.User{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLastAdmin
+	}
+	InvalidateUserStats()
+	return nil
+}
+
 func (r *userRepository) List(limit, offset int) ([]*models.User, error) {
 	var users []*models.User
 	if err := r.db.Limit(limit).Offset(offset).Find(&users).Error; err != nil {
@@ -107,39 +421,136 @@ func (r *userRepository) List(limit, offset int) ([]*models.User, error) {
 	return users, nil
 }
 
+// ListPage returns one page of users matching filter, in sign-up order
+func (r *userRepository) ListPage(filter models.UserListFilter, params models.PageParams) (*models.PageResponse[*models.User], error) {
+	query := r.db.Model(&models.User{})
+	if strings.TrimSpace(filter.Search) != "" {
+		query = matchUserSearch(query, filter.Search, adminSearchColumns)
+	} else if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	switch {
+	case filter.CreatedAfter != nil && filter.CreatedBefore != nil:
+		query = query.Where("created_at BETWEEN ? AND ?", *filter.CreatedAfter, *filter.CreatedBefore)
+	case filter.CreatedAfter != nil:
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	case filter.CreatedBefore != nil:
+		query = query.Where("created_at <= ?", *filter.CreatedBefore)
+	}
+
+	return Paginate[*models.User](query.Order("id"), params)
+}
+
+// notificationRecipients matches users selected by filter who have a real email address
+func (r *userRepository) notificationRecipients(filter models.NotificationFilter) *gorm.DB {
+	query := r.db.Model(&models.User{}).Where("email_missing = ? AND email <> ''", false)
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Active != nil {
+		query = query.Where("is_active = ?", *filter.Active)
+	}
+	return query
+}
+
+func (r *userRepository) CountNotificationRecipients(filter models.NotificationFilter) (int64, error) {
+	var count int64
+	err := r.notificationRecipients(filter).Count(&count).Error
+	return count, err
+}
+
+func (r *userRepository) ListNotificationRecipients(filter models.NotificationFilter) ([]*models.User, error) {
+	var users []*models.User
+	err := r.notificationRecipients(filter).Order("id").Find(&users).Error
+	return users, err
+}
+
+// ListDueDeactivations returns users whose scheduled deactivation is at or before now
+func (r *userRepository) ListDueDeactivations(now time.Time) ([]*models.User, error) {
+	var users []*models.User
+	err := r.db.Where("deactivate_at IS NOT NULL AND deactivate_at <= ?", now).Order("id").Find(&users).Error
+	return users, err
+}
+
+// DirectoryPage returns one page of active users whose name matches search, in
+// name order. An empty search lists everyone.
+func (r *userRepository) DirectoryPage(search string, params models.PageParams) (*models.PageResponse[*models.User], error) {
+	query := matchUserSearch(r.db.Model(&models.User{}).Where("is_active = ?", true), search, directorySearchColumns)
+	return Paginate[*models.User](query.Order("first_name, last_name, id"), params)
+}
+
 // GetDB returns the database instance for migrations or direct queries
 func GetDB() *gorm.DB {
 	return db
 }
 
-// GetUserStats returns user statistics for admin dashboard
+// GetUserStats returns user statistics for admin dashboard. The counts come from
+// one query using conditional aggregation and are cached for ADMIN_STATS_CACHE_TTL.
 func (r *userRepository) GetUserStats() (*models.UserStatsResponse, error) {
+	if stats, ok := statsCache.get(); ok {
+		return stats, nil
+	}
+
+	// New users are counted from midnight in the configured timezone
+	todayStart := timeutil.StartOfDay(timeutil.Now(), timeutil.Location())
+
 	var stats models.UserStatsResponse
-	
-	// Total users
-	r.db.Model(&models.User{}).Count(&stats.TotalUsers)
-	
-	// Active users
-	r.db.Model(&models.User{}).Where("is_active = ?", true).Count(&stats.ActiveUsers)
-	
-	// Verified users
-	r.db.Model(&models.User{}).Where("is_verified = ?", true).Count(&stats.VerifiedUsers)
-	
-	// Admin users
-	r.db.Model(&models.User{}).Where("is_admin = ?", true).Count(&stats.AdminUsers)
-	
-	// New users today
-	r.db.Model(&models.User{}).Where("DATE(created_at) = DATE('now')").Count(&stats.NewUsersToday)
-	
-	// New users this week
-	r.db.Model(&models.User{}).Where("created_at >= datetime('now', '-7 days')").Count(&stats.NewUsersWeek)
-	
-	// New users this month
-	r.db.Model(&models.User{}).Where("created_at >= datetime('now', '-30 days')").Count(&stats.NewUsersMonth)
-	
+	err := r.db.Model(&models.User{}).Select(`COUNT(*) AS total_users,
+		COUNT(CASE WHEN is_active = ? THEN 1 END) AS active_users,
+		COUNT(CASE WHEN is_verified = ? THEN 1 END) AS verified_users,
+		COUNT(CASE WHEN is_admin = ? THEN 1 END) AS admin_users,
+		COUNT(CASE WHEN created_at >= ? THEN 1 END) AS new_users_today,
+		COUNT(CASE WHEN created_at >= ? THEN 1 END) AS new_users_week,
+		COUNT(CASE WHEN created_at >= ? THEN 1 END) AS new_users_month`,
+		true, true, true, todayStart, todayStart.AddDate(0, 0, -7), todayStart.AddDate(0, 0, -30)).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+
+	statsCache.set(&stats)
 	return &stats, nil
 }
 
+// CountSignupsByBucket counts users created in each period between consecutive
+// bounds, in one grouped query. The bucket of each row is picked by comparing
+// created_at with the bounds, so no driver-specific date functions are needed.
+func (r *userRepository) CountSignupsByBucket(bounds []time.Time) ([]int64, error) {
+	if len(bounds) < 2 {
+		return nil, nil
+	}
+
+	var bucket strings.Builder
+	args := make([]interface{}, 0, len(bounds)-1)
+	bucket.WriteString("CASE")
+	for i, bound := range bounds[1:] {
+		fmt.Fprintf(&bucket, " WHEN created_at < ? THEN %d", i)
+		args = append(args, bound)
+	}
+	bucket.WriteString(" END")
+
+	var rows []struct {
+		Bucket int
+		Count  int64
+	}
+	err := r.db.Model(&models.User{}).
+		Select(bucket.String()+" AS bucket, COUNT(*) AS count", args...).
+		Where("created_at >= ? AND created_at < ?", bounds[0], bounds[len(bounds)-1]).
+		Group("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]int64, len(bounds)-1)
+	for _, row := range rows {
+		if row.Bucket >= 0 && row.Bucket < len(counts) {
+			counts[row.Bucket] = row.Count
+		}
+	}
+	return counts, nil
+}
+
 // GetUsersByRole returns users filtered by role
 func (r *userRepository) GetUsersByRole(role string, limit, offset int) ([]*models.User, error) {
 	var users []*models.User
@@ -149,12 +560,10 @@ func (r *userRepository) GetUsersByRole(role string, limit, offset int) ([]*mode
 	return users, nil
 }
 
-// SearchUsers searches users by name or email
+// SearchUsers returns users whose name or email contains every term of query
 func (r *userRepository) SearchUsers(query string, limit, offset int) ([]*models.User, error) {
 	var users []*models.User
-	searchPattern := "%" + query + "%"
-	if err := r.db.Where("first_name LIKE ? OR last_name LIKE ? OR email LIKE ?", 
-		searchPattern, searchPattern, searchPattern).
+	if err := matchUserSearch(r.db, query, adminSearchColumns).
 		Limit(limit).Offset(offset).Find(&users).Error; err != nil {
 		return nil, err
 	}
@@ -164,10 +573,123 @@ func (r *userRepository) SearchUsers(query string, limit, offset int) ([]*models
 // GetRecentUsers returns users created within the specified number of days
 func (r *userRepository) GetRecentUsers(days int, limit, offset int) ([]*models.User, error) {
 	var users []*models.User
-	if err := r.db.Where("created_at >= datetime('now', '-? days')", days).
+	since := timeutil.Now().AddDate(0, 0, -days)
+	if err := r.db.Where("created_at >= ?", since).
 		Order("created_at DESC").
 		Limit(limit).Offset(offset).Find(&users).Error; err != nil {
 		return nil, err
 	}
 	return users, nil
 }
+
+// FindInactiveSince returns users who last signed in before lastLoginBefore, and
+// users who never signed in and signed up before it, longest inactive first
+func (r *userRepository) FindInactiveSince(lastLoginBefore time.Time, limit, offset int) ([]*models.User, error) {
+	var users []*models.User
+	if err := r.db.Where("last_login_at < ? OR (last_login_at IS NULL AND created_at < ?)", lastLoginBefore, lastLoginBefore).
+		Order("COALESCE(last_login_at, created_at) ASC, id ASC").
+		Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// MergeUsers saves the primary user (already carrying the duplicate's provider IDs)
+// and soft-deletes the duplicate in one transaction. The duplicate's provider IDs are
+// cleared first because the unique indexes also cover soft-deleted rows. Everything
+// recorded about the duplicate moves to the primary: its sessions, refresh tokens and
+// API keys, which are revoked since they were issued for the other account, audit
+// entries, login history, role assignments, devices and provider tokens. The
+// duplicate's token version is bumped so its outstanding tokens stop working.
+func (r *userRepository) MergeUsers(primary, duplicate *models.User) (*models.User, error) {
+	expectedVersion := primary.Version
+	err := withRetry(func() error {
+		return r.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.User{}).
+				Where("id = ? AND version = ?", duplicate.ID, duplicate.Version).
+				Updates(map[string]interface{}{
+					"google_id":     nil,
+					"git_hub_id":    nil,
+					"token_version": gorm.Expr("token_version + 1"),
+					"version":       duplicate.Version + 1,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrStaleUpdate
+			}
+
+			primary.Version = expectedVersion + 1
+			result = tx.Model(primary).Where("version = ?", expectedVersion).Select("*").Updates(primary)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrStaleUpdate
+			}
+
+			if err := moveUserRecords(tx, duplicate.ID, primary.ID); err != nil {
+				return err
+			}
+
+			return tx.Delete(&models.User{}, duplicate.ID).Error
+		})
+	})
+	if err != nil {
+		primary.Version = expectedVersion
+		return nil, err
+	}
+	InvalidateUserStats()
+	return primary, nil
+}
+
+// moveUserRecords reassigns the rows belonging to user fromID to user toID, as part
+// of tx. Rows that can only exist once per user (a role assignment, a device, a
+// provider token) are moved unless toID already has one, and otherwise deleted.
+func moveUserRecords(tx *gorm.DB, fromID, toID uint) error {
+	now := timeutil.Now()
+	// Credentials issued to the duplicate must not start acting for the primary
+	for _, model := range []interface{}{&models.UserSession{}, &models.RefreshToken{}, &models.APIKey{}} {
+		if err := tx.Model(model).Where("user_id = ? AND revoked_at IS NULL", fromID).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+	}
+
+	moves := []struct {
+		model  interface{}
+		column string
+	}{
+		{&models.UserSession{}, "user_id"},
+		{&models.RefreshToken{}, "user_id"},
+		{&models.AuditLog{}, "actor_id"},
+		{&models.AuditLog{}, "target_id"},
+		{&models.LoginHistory{}, "user_id"},
+		{&models.APIKey{}, "user_id"},
+	}
+	for _, move := range moves {
+		if err := tx.Model(move.model).Where(move.column+" = ?", fromID).Update(move.column, toID).Error; err != nil {
+			return err
+		}
+	}
+
+	// GORM writes updated primary keys back into the model, so each statement gets its own
+	uniqueMoves := []struct {
+		model func() interface{}
+		key   string
+	}{
+		{func() interface{} { return &models.UserRole{} }, "role_id"},
+		{func() interface{} { return &models.UserDevice{} }, "fingerprint"},
+		{func() interface{} { return &models.OAuthToken{} }, "provider"},
+	}
+	for _, move := range uniqueMoves {
+		existing := tx.Model(move.model()).Select(move.key).Where("user_id = ?", toID)
+		if err := tx.Model(move.model()).Where("user_id = ? AND "+move.key+" NOT IN (?)", fromID, existing).Update("user_id", toID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", fromID).Delete(move.model()).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
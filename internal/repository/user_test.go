@@ -0,0 +1,499 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/timeutil"
+)
+
+// setupTestDB opens a fresh, migrated in-memory database for one test
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	openTestDB(t)
+	if err := Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+}
+
+// openTestDB opens a fresh, empty in-memory database for one test
+func openTestDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("DB_LOG_LEVEL", "silent")
+	// Escaped so characters such as # in subtest names cannot end the path and
+	// drop mode=memory, which would leave a database file behind
+	t.Setenv("DATABASE_URL", "file:"+url.PathEscape(t.Name())+"?mode=memory&cache=shared")
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if err := InitDB(cfg); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+}
+
+// createTestUser stores a user with the given email
+func createTestUser(t *testing.T, email string) *models.User {
+	t.Helper()
+	user, err := NewUserRepository().Create(&models.User{
+		Email:     email,
+		FirstName: "Test",
+		LastName:  "User",
+		Role:      "user",
+		IsActive:  true,
+	})
+	if err != nil {
+		t.Fatalf("Create %s: %v", email, err)
+	}
+	return user
+}
+
+func TestMergeUsersMovesRecords(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserRepository()
+	primary := createTestUser(t, "primary@example.com")
+	duplicate := createTestUser(t, "duplicate@example.com")
+	other := createTestUser(t, "other@example.com")
+
+	expires := time.Now().Add(time.Hour)
+	role := &models.Role{Name: "support"}
+	mustCreate(t, role)
+	mustCreate(t,
+		&models.UserSession{UserID: duplicate.ID, TokenID: "dup-session", ExpiresAt: expires},
+		&models.RefreshToken{UserID: duplicate.ID, SessionID: 1, FamilyID: "f", TokenHash: "dup-refresh", Fingerprint: "fp", DeviceHash: "dh", ExpiresAt: expires},
+		&models.AuditLog{ActorID: duplicate.ID, TargetID: duplicate.ID, Action: models.AuditActionUserUpdate},
+		&models.AuditLog{ActorID: other.ID, TargetID: duplicate.ID, Action: models.AuditActionUserUpdate},
+		&models.LoginHistory{UserID: duplicate.ID, Method: "password", Outcome: models.LoginOutcomeSuccess},
+		&models.APIKey{UserID: duplicate.ID, Name: "ci", Prefix: "sso_dup", KeyHash: "dup-key"},
+		&models.UserRole{UserID: duplicate.ID, RoleID: role.ID},
+		&models.UserRole{UserID: primary.ID, RoleID: role.ID},
+		&models.UserDevice{UserID: duplicate.ID, Fingerprint: "shared", LastSeenAt: expires},
+		&models.UserDevice{UserID: primary.ID, Fingerprint: "shared", LastSeenAt: expires},
+		&models.UserDevice{UserID: duplicate.ID, Fingerprint: "laptop", LastSeenAt: expires},
+	)
+
+	if _, err := users.MergeUsers(primary, duplicate); err != nil {
+		t.Fatalf("MergeUsers: %v", err)
+	}
+
+	counts := []struct {
+		name  string
+		model interface{}
+		query string
+		want  int64
+	}{
+		{"sessions", &models.UserSession{}, "user_id = ?", 1},
+		{"revoked sessions", &models.UserSession{}, "user_id = ? AND revoked_at IS NOT NULL", 1},
+		{"refresh tokens", &models.RefreshToken{}, "user_id = ? AND revoked_at IS NOT NULL", 1},
+		{"audit entries as actor", &models.AuditLog{}, "actor_id = ?", 1},
+		{"audit entries as target", &models.AuditLog{}, "target_id = ?", 2},
+		{"login history", &models.LoginHistory{}, "user_id = ?", 1},
+		{"api keys", &models.APIKey{}, "user_id = ?", 1},
+		{"revoked api keys", &models.APIKey{}, "user_id = ? AND revoked_at IS NOT NULL", 1},
+		{"role assignments", &models.UserRole{}, "user_id = ?", 1},
+		{"devices", &models.UserDevice{}, "user_id = ?", 2},
+	}
+	for _, tt := range counts {
+		t.Run(tt.name, func(t *testing.T) {
+			var got int64
+			db.Model(tt.model).Where(tt.query, primary.ID).Count(&got)
+			if got != tt.want {
+				t.Errorf("primary has %d, want %d", got, tt.want)
+			}
+
+			var left int64
+			db.Model(tt.model).Where(strings.Split(tt.query, " AND ")[0], duplicate.ID).Count(&left)
+			if left != 0 {
+				t.Errorf("duplicate still has %d", left)
+			}
+		})
+	}
+
+	if _, err := users.GetByID(duplicate.ID); err == nil {
+		t.Error("duplicate was not deleted")
+	}
+	var stored models.User
+	db.Unscoped().First(&stored, duplicate.ID)
+	if stored.TokenVersion != duplicate.TokenVersion+1 {
+		t.Errorf("duplicate token version = %d, want %d", stored.TokenVersion, duplicate.TokenVersion+1)
+	}
+}
+
+func TestMergeUsersStaleDuplicate(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserRepository()
+	primary := createTestUser(t, "primary@example.com")
+	duplicate := createTestUser(t, "duplicate@example.com")
+	mustCreate(t, &models.LoginHistory{UserID: duplicate.ID, Method: "password", Outcome: models.LoginOutcomeSuccess})
+
+	stale := *duplicate
+	duplicate.FirstName = "Changed"
+	if _, err := users.Update(duplicate); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if _, err := users.MergeUsers(primary, &stale); err != ErrStaleUpdate {
+		t.Fatalf("MergeUsers error = %v, want ErrStaleUpdate", err)
+	}
+	var moved int64
+	db.Model(&models.LoginHistory{}).Where("user_id = ?", primary.ID).Count(&moved)
+	if moved != 0 {
+		t.Errorf("%d rows moved by a rolled-back merge", moved)
+	}
+}
+
+func TestUpdateRejectsStaleCopy(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserRepository()
+	user := createTestUser(t, "stale@example.com")
+
+	// Two editors load the same row
+	first, err := users.GetByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	second, err := users.GetByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+
+	first.FirstName = "First"
+	if _, err := users.Update(first); err != nil {
+		t.Fatalf("first Update: %v", err)
+	}
+	second.FirstName = "Second"
+	if _, err := users.Update(second); err != ErrStaleUpdate {
+		t.Fatalf("second Update = %v, want %v", err, ErrStaleUpdate)
+	}
+	if second.Version != user.Version {
+		t.Errorf("rejected copy's version = %d, want it left at %d", second.Version, user.Version)
+	}
+
+	stored, err := users.GetByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if stored.FirstName != "First" || stored.Version != user.Version+1 {
+		t.Errorf("stored = %s at version %d, want First at %d", stored.FirstName, stored.Version, user.Version+1)
+	}
+}
+
+func TestDBLogLevel(t *testing.T) {
+	tests := []struct {
+		env   string
+		level string
+		want  logger.LogLevel
+	}{
+		{"development", "", logger.Info},
+		{"production", "", logger.Warn},
+		{"production", "info", logger.Info},
+		{"development", "SILENT", logger.Silent},
+		{"development", "error", logger.Error},
+		{"production", "verbose", logger.Warn},
+	}
+
+	for _, tt := range tests {
+		cfg := &configs.Config{AppEnv: tt.env, DBLogLevel: tt.level}
+		if got := dbLogLevel(cfg); got != tt.want {
+			t.Errorf("dbLogLevel(APP_ENV=%s, DB_LOG_LEVEL=%q) = %v, want %v", tt.env, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestDBLoggerReportsSlowQueries(t *testing.T) {
+	// The logger writes to stdout, so capture it while the logger is built and used
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	dbLogger := newDBLogger(&configs.Config{AppEnv: "production", DBLogLevel: "warn", DBSlowQueryMS: 200})
+	os.Stdout = stdout
+
+	ctx := context.Background()
+	dbLogger.Trace(ctx, time.Now().Add(-time.Second), func() (string, int64) { return "SELECT 'slow'", 1 }, nil)
+	dbLogger.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 'fast'", 1 }, nil)
+	dbLogger.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 'missing'", 0 }, gorm.ErrRecordNotFound)
+	writer.Close()
+
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := string(output)
+	if !strings.Contains(logged, "SLOW SQL >= 200ms") || !strings.Contains(logged, "SELECT 'slow'") {
+		t.Errorf("slow query not reported with its SQL and threshold:\n%s", logged)
+	}
+	if strings.Contains(logged, "SELECT 'fast'") {
+		t.Error("fast query logged at warn level")
+	}
+	if strings.Contains(logged, "SELECT 'missing'") {
+		t.Error("record-not-found lookup logged as an error")
+	}
+}
+
+func TestGetUserStatsCountsFromLocalMidnight(t *testing.T) {
+	for _, zone := range []string{"UTC", "America/New_York", "Asia/Kolkata", "Pacific/Kiritimati"} {
+		t.Run(zone, func(t *testing.T) {
+			setupTestDB(t)
+			if err := timeutil.SetLocation(zone); err != nil {
+				t.Skipf("timezone data unavailable: %v", err)
+			}
+			t.Cleanup(func() { timeutil.SetLocation("") })
+
+			todayStart := timeutil.StartOfDay(timeutil.Now(), timeutil.Location())
+			createdAt := map[string]time.Time{
+				"yesterday@example.com":   todayStart.Add(-time.Second),
+				"midnight@example.com":    todayStart,
+				"today@example.com":       todayStart.Add(time.Second),
+				"last-month@example.com":  todayStart.AddDate(0, 0, -31),
+				"month-start@example.com": todayStart.AddDate(0, 0, -30),
+			}
+			for email, at := range createdAt {
+				user := createTestUser(t, email)
+				db.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("created_at", at)
+			}
+			InvalidateUserStats()
+
+			stats, err := NewUserRepository().GetUserStats()
+			if err != nil {
+				t.Fatalf("GetUserStats: %v", err)
+			}
+			if stats.NewUsersToday != 2 || stats.NewUsersWeek != 3 || stats.NewUsersMonth != 4 {
+				t.Errorf("new users today/week/month = %d/%d/%d, want 2/3/4",
+					stats.NewUsersToday, stats.NewUsersWeek, stats.NewUsersMonth)
+			}
+		})
+	}
+}
+
+func TestUpsertByEmail(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserRepository()
+
+	inserted, created, err := users.UpsertByEmail(&models.User{
+		Email:     "sync@example.com",
+		FirstName: "Sam",
+		LastName:  "Sync",
+		Role:      "user",
+		IsActive:  true,
+	})
+	if err != nil {
+		t.Fatalf("UpsertByEmail insert: %v", err)
+	}
+	if !created || inserted.ID == 0 || inserted.FirstName != "Sam" {
+		t.Fatalf("insert = %+v, created %v; want a new user named Sam", inserted, created)
+	}
+
+	// Local-only fields set after the first sync must survive later ones
+	local := *inserted
+	local.Password = "local-hash"
+	local.Role = "moderator"
+	if _, err := users.Update(&local); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	updated, created, err := users.UpsertByEmail(&models.User{
+		Email:     "sync@example.com",
+		FirstName: "Samantha",
+		LastName:  "Synced",
+		Password:  "directory-hash",
+		Role:      "user",
+	})
+	if err != nil {
+		t.Fatalf("UpsertByEmail update: %v", err)
+	}
+	if created {
+		t.Error("update reported the user as created")
+	}
+	if updated.ID != inserted.ID {
+		t.Errorf("update stored user %d, want %d", updated.ID, inserted.ID)
+	}
+	if updated.FirstName != "Samantha" || updated.LastName != "Synced" {
+		t.Errorf("name = %s %s, want Samantha Synced", updated.FirstName, updated.LastName)
+	}
+	if updated.Password != "local-hash" || updated.Role != "moderator" || !updated.IsActive {
+		t.Errorf("local fields = password %q, role %q, active %v; want them kept", updated.Password, updated.Role, updated.IsActive)
+	}
+	if updated.Version != local.Version+1 {
+		t.Errorf("version = %d, want %d", updated.Version, local.Version+1)
+	}
+}
+
+func TestUpsertByEmailConcurrent(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserRepository()
+
+	const syncs = 8
+	var wg sync.WaitGroup
+	results := make(chan bool, syncs)
+	errs := make(chan error, syncs)
+	for i := 0; i < syncs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, created, err := users.UpsertByEmail(&models.User{
+				Email:     "race@example.com",
+				FirstName: fmt.Sprintf("Sync %d", i),
+				LastName:  "Race",
+				Role:      "user",
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- created
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("UpsertByEmail: %v", err)
+	}
+	createdCount := 0
+	for created := range results {
+		if created {
+			createdCount++
+		}
+	}
+	if createdCount != 1 {
+		t.Errorf("%d upserts reported creating the user, want 1", createdCount)
+	}
+
+	var count int64
+	if err := db.Model(&models.User{}).Where("email = ?", "race@example.com").Count(&count).Error; err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("%d users stored for the email, want 1", count)
+	}
+}
+
+// mustCreate stores each record or fails the test
+func mustCreate(t *testing.T, records ...interface{}) {
+	t.Helper()
+	for _, record := range records {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("Create %T: %v", record, err)
+		}
+	}
+}
+
+func TestExistsBy(t *testing.T) {
+	setupTestDB(t)
+	users := NewUserRepository()
+
+	googleID, githubID := "google-1", "github-1"
+	if _, err := users.Create(&models.User{
+		Email:     "linked@example.com",
+		FirstName: "Test",
+		LastName:  "User",
+		GoogleID:  &googleID,
+		GitHubID:  &githubID,
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	deleted := createTestUser(t, "deleted@example.com")
+	if err := users.Delete(deleted.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		exists func(string) (bool, error)
+		value  string
+		want   bool
+	}{
+		{"email", users.ExistsByEmail, "linked@example.com", true},
+		{"unknown email", users.ExistsByEmail, "nobody@example.com", false},
+		{"deleted email", users.ExistsByEmail, "deleted@example.com", false},
+		{"email in another case", users.ExistsByEmailFold, "Linked@Example.com", true},
+		{"Google ID", users.ExistsByGoogleID, googleID, true},
+		{"unknown Google ID", users.ExistsByGoogleID, "google-2", false},
+		{"GitHub ID", users.ExistsByGitHubID, githubID, true},
+		{"unknown GitHub ID", users.ExistsByGitHubID, "github-2", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.exists(tt.value)
+			if err != nil {
+				t.Fatalf("error = %v, want none", err)
+			}
+			if got != tt.want {
+				t.Errorf("exists(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindInactiveSince(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now()
+	daysAgo := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+
+	// setActivity backdates a user's sign-up and last sign-in; a zero lastLogin
+	// means the user never signed in
+	setActivity := func(user *models.User, created, lastLogin time.Time) {
+		t.Helper()
+		updates := map[string]interface{}{"created_at": created, "last_login_at": nil}
+		if !lastLogin.IsZero() {
+			updates["last_login_at"] = lastLogin
+		}
+		if err := db.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumns(updates).Error; err != nil {
+			t.Fatalf("UpdateColumns: %v", err)
+		}
+	}
+
+	stale := createTestUser(t, "stale@example.com")
+	setActivity(stale, daysAgo(400), daysAgo(200))
+	neverSignedIn := createTestUser(t, "never@example.com")
+	setActivity(neverSignedIn, daysAgo(300), time.Time{})
+	recent := createTestUser(t, "recent@example.com")
+	setActivity(recent, daysAgo(400), daysAgo(10))
+	newSignup := createTestUser(t, "new@example.com")
+	setActivity(newSignup, daysAgo(5), time.Time{})
+
+	tests := []struct {
+		name          string
+		limit, offset int
+		want          []uint
+	}{
+		// Longest inactive first: the never-signed-in account counts from sign-up
+		{"all", 50, 0, []uint{neverSignedIn.ID, stale.ID}},
+		{"first page", 1, 0, []uint{neverSignedIn.ID}},
+		{"second page", 1, 1, []uint{stale.ID}},
+		{"past the end", 50, 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := NewUserRepository().FindInactiveSince(daysAgo(180), tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("FindInactiveSince: %v", err)
+			}
+			var got []uint
+			for _, user := range users {
+				got = append(got, user.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("FindInactiveSince = users %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
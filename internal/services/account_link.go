@@ -0,0 +1,129 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/timeutil"
+)
+
+var (
+	ErrLinkConfirmationRequired = errors.New("linking this provider to the existing account must be confirmed by email")
+	ErrInvalidLinkToken         = errors.New("link confirmation is invalid or has expired")
+	ErrProviderAlreadyLinked    = errors.New("provider account is already linked to another account")
+)
+
+// linkExistingUser attaches a provider identity to user, the existing account with
+// the provider's email. With REQUIRE_LINK_CONFIRMATION on, that only happens at
+// once when we have verified the account's email ourselves, or when signedIn, the
+// account the browser is signed in to, is that account. Otherwise the owner is
+// mailed a link to confirm the provider and ErrLinkConfirmationRequired is
+// returned, so a provider account merely claiming the email cannot take it over.
+func (s *OAuthService) linkExistingUser(user, signedIn *models.User, provider, providerID, avatarURL string) (*models.User, error) {
+	if s.confirmLinks && !user.IsVerified && (signedIn == nil || signedIn.ID != user.ID) {
+		if err := s.authService.requestProviderLink(user, provider, providerID); err != nil {
+			return nil, err
+		}
+		return nil, ErrLinkConfirmationRequired
+	}
+
+	user.LinkProvider(provider, providerID)
+	if user.AvatarURL == nil || *user.AvatarURL == "" {
+		user.AvatarURL = stringPtr(avatarURL)
+	}
+	return s.userRepo.Update(user)
+}
+
+// requestProviderLink stores the provider identity waiting to be linked to user
+// and mails the account's email a link to confirm it. A link already sent for the
+// same identity within verificationResendInterval is not sent again.
+func (s *AuthService) requestProviderLink(user *models.User, provider, providerID string) error {
+	samePending := user.PendingLinkProvider == provider && user.PendingLinkProviderID == providerID
+	if samePending && user.LinkSentAt != nil && timeutil.Now().Sub(*user.LinkSentAt) < verificationResendInterval {
+		return nil
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		log.Printf("Failed to generate link confirmation token for user %d: %v", user.ID, err)
+		return err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	now := timeutil.Now()
+	user.PendingLinkProvider = provider
+	user.PendingLinkProviderID = providerID
+	user.LinkTokenHash = HashInviteToken(token)
+	user.LinkSentAt = &now
+	if _, err := s.userRepo.Update(user); err != nil {
+		log.Printf("Failed to store link confirmation token for user %d: %v", user.ID, err)
+		return err
+	}
+
+	name := ProviderDisplayName(provider)
+	body := fmt.Sprintf(`Hi %s,
+
+Someone tried to sign in to your account %s with a %s account that uses the same email address. To let that %s account sign in to yours, open this link:
+
+%s/confirm-link?token=%s
+
+The link expires in %d hours. If this was not you, ignore this email; the %s account stays unlinked.
+`, user.FirstName, user.Email, name, name, s.baseURL, url.QueryEscape(token), int(verificationTTL.Hours()), name)
+
+	go func(email string) {
+		if err := s.mailer.Send(email, fmt.Sprintf("Confirm linking %s to your account", name), body); err != nil {
+			log.Printf("Failed to send link confirmation to %s: %v", email, err)
+		}
+	}(user.Email)
+	return nil
+}
+
+// ConfirmProviderLink links the provider identity waiting on the account owning
+// token. Opening the link proves the account's email is its owner's, so the email
+// is marked verified too. It returns the user and the linked provider.
+func (s *AuthService) ConfirmProviderLink(token string) (*models.User, string, error) {
+	if token == "" {
+		return nil, "", ErrInvalidLinkToken
+	}
+
+	user, err := s.userRepo.GetByLinkTokenHash(HashInviteToken(token))
+	if err != nil || user.PendingLinkProvider == "" {
+		return nil, "", ErrInvalidLinkToken
+	}
+	if user.LinkSentAt == nil || timeutil.Now().Sub(*user.LinkSentAt) > verificationTTL {
+		return nil, "", ErrInvalidLinkToken
+	}
+
+	provider := user.PendingLinkProvider
+	user.LinkProvider(provider, user.PendingLinkProviderID)
+	user.IsVerified = true
+	user.PendingLinkProvider = ""
+	user.PendingLinkProviderID = ""
+	user.LinkTokenHash = ""
+	user.LinkSentAt = nil
+	user, err = s.userRepo.Update(user)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// The provider account signed up on its own since the link was mailed
+		return nil, "", ErrProviderAlreadyLinked
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return user, provider, nil
+}
+
+// ProviderDisplayName is the name shown to users for an OAuth provider
+func ProviderDisplayName(provider string) string {
+	for _, info := range providerInfo {
+		if info.ID == provider {
+			return info.Name
+		}
+	}
+	return provider
+}
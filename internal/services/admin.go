@@ -1,10 +1,18 @@
 package services
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"time"
 
+	"sso-web-app/configs"
 	"sso-web-app/internal/models"
 	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
 )
 
 // Helper function to convert string to string pointer
@@ -18,16 +26,63 @@ func stringPtrAdmin(s string) *string {
 var (
 	ErrNotAuthorized = errors.New("user not authorized for this action")
 	ErrInvalidRole   = errors.New("invalid role specified")
+	ErrStaleUpdate   = repository.ErrStaleUpdate
+	ErrLastAdmin     = repository.ErrLastAdmin
+	ErrInvalidMerge  = errors.New("accounts cannot be merged")
+	ErrMergeConflict = errors.New("both accounts are linked to different provider identities")
+
+	ErrInvalidActiveFrom       = errors.New("active_from must be an RFC 3339 timestamp")
+	ErrInvalidEffectiveAt      = errors.New("effective_at must be an RFC 3339 timestamp")
+	ErrNoScheduledDeactivation = errors.New("user has no scheduled deactivation")
+	ErrNoEmailAddress          = errors.New("user has no email address")
+
+	ErrProviderNotLinked = errors.New("provider is not linked to this user")
+	ErrLastLoginMethod   = errors.New("cannot unlink the user's only sign-in method")
+
+	ErrTooManyRecipients = errors.New("notification matches more recipients than allowed")
+	ErrNotifyThrottled   = errors.New("too many notifications sent")
+
+	ErrTooManyIDs = errors.New("too many user IDs requested")
+
+	ErrInvalidInactiveDays = errors.New("days must be a positive number")
 )
 
+// startedAt records process start for uptime reporting
+var startedAt = time.Now()
+
+// sessionWindow matches the JWT lifetime, so logins inside it may still hold a valid token
+const sessionWindow = TokenLifetime
+
 type AdminService struct {
-	userRepo repository.UserRepository
+	userRepo       repository.UserRepository
+	invitationRepo repository.InvitationRepository
+	authService    *AuthService
+	apiKeyService  *APIKeyService
+	oauthService   *OAuthService
+	notifyThrottle *attemptThrottle
+	config         *configs.Config
 }
 
-func NewAdminService() *AdminService {
-	return &AdminService{
-		userRepo: repository.NewUserRepository(),
+func NewAdminService(cfg *configs.Config, authService *AuthService, oauthService *OAuthService) *AdminService {
+	s := &AdminService{
+		userRepo:       repository.NewUserRepository(),
+		invitationRepo: repository.NewInvitationRepository(),
+		authService:    authService,
+		apiKeyService:  NewAPIKeyService(),
+		oauthService:   oauthService,
+		notifyThrottle: newAttemptThrottle(cfg.NotifySendsPerHour, time.Hour),
+		config:         cfg,
 	}
+
+	go s.runDeactivations(cfg.DeactivationCheckInterval)
+
+	return s
+}
+
+// PageParams validates a requested page for admin lists. The size is clamped to
+// MAX_PAGE_SIZE, or DEFAULT_PAGE_SIZE when none was requested.
+func (s *AdminService) PageParams(page, pageSize int) models.PageParams {
+	return models.NewPageParams(page, pageSize, s.config.DefaultPageSize, s.config.MaxPageSize)
 }
 
 // IsAdmin checks if user has admin privileges
@@ -35,6 +90,21 @@ func (s *AdminService) IsAdmin(user *models.User) bool {
 	return user.IsAdmin || user.Role == "admin"
 }
 
+// isActiveAdmin reports whether user can currently use the admin panel
+func isActiveAdmin(user *models.User) bool {
+	return user.IsActive && (user.IsAdmin || user.Role == "admin")
+}
+
+// saveUser stores changes to a user. When the changes take away an active admin,
+// the write is refused with ErrLastAdmin if no other active admin would remain,
+// so the admin panel cannot be locked out.
+func (s *AdminService) saveUser(user *models.User, wasActiveAdmin bool) (*models.User, error) {
+	if wasActiveAdmin && !isActiveAdmin(user) {
+		return s.userRepo.UpdateUnlessLastAdmin(user)
+	}
+	return s.userRepo.Update(user)
+}
+
 // GetUserStats returns dashboard statistics
 func (s *AdminService) GetUserStats(adminUser *models.User) (*models.UserStatsResponse, error) {
 	if !s.IsAdmin(adminUser) {
@@ -44,6 +114,82 @@ func (s *AdminService) GetUserStats(adminUser *models.User) (*models.UserStatsRe
 	return s.userRepo.GetUserStats()
 }
 
+// RefreshUserStats recounts the dashboard statistics instead of using the cache
+func (s *AdminService) RefreshUserStats(adminUser *models.User) (*models.UserStatsResponse, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+	
+	repository.InvalidateUserStats()
+	return s.userRepo.GetUserStats()
+}
+
+// PingDatabase reports whether the database is reachable
+func PingDatabase() error {
+	sqlDB, err := repository.GetDB().DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
+
+// GetSystemHealth returns database, session, runtime and provider status
+func (s *AdminService) GetSystemHealth(adminUser *models.User) (*models.SystemHealthResponse, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+	
+	health := &models.SystemHealthResponse{
+		StartedAt: models.NewTimestamp(startedAt),
+		Uptime:    time.Since(startedAt).Round(time.Second).String(),
+		Providers: map[string]bool{
+			"google": s.config.GoogleClientID != "" && s.config.GoogleClientSecret != "",
+			"github": s.config.GitHubClientID != "" && s.config.GitHubClientSecret != "",
+		},
+		OAuth: s.oauthService.StateStats(),
+	}
+	
+	// Database connectivity and latency
+	db := repository.GetDB()
+	sqlDB, err := db.DB()
+	if err == nil {
+		start := time.Now()
+		err = sqlDB.Ping()
+		health.Database.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	}
+	if err != nil {
+		health.Database.Error = err.Error()
+	} else {
+		health.Database.Connected = true
+		
+		// SQLite reports its size as page_count * page_size
+		var pageCount, pageSize int64
+		if db.Raw("PRAGMA page_count").Scan(&pageCount).Error == nil &&
+			db.Raw("PRAGMA page_size").Scan(&pageSize).Error == nil {
+			health.Database.SizeBytes = pageCount * pageSize
+		}
+		
+		db.Model(&models.User{}).
+			Where("last_login_at >= ?", timeutil.Now().Add(-sessionWindow)).
+			Count(&health.Sessions.RecentLogins)
+	}
+	health.Sessions.Window = sessionWindow.String()
+	
+	// Go runtime memory statistics
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	health.Runtime = models.RuntimeStats{
+		GoVersion:    runtime.Version(),
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAllocMB:  mem.HeapAlloc / 1024 / 1024,
+		HeapSysMB:    mem.HeapSys / 1024 / 1024,
+		TotalAllocMB: mem.TotalAlloc / 1024 / 1024,
+		NumGC:        mem.NumGC,
+	}
+	
+	return health, nil
+}
+
 // GetAllUsers returns paginated list of all users
 func (s *AdminService) GetAllUsers(adminUser *models.User, limit, offset int) ([]*models.User, error) {
 	if !s.IsAdmin(adminUser) {
@@ -53,6 +199,30 @@ func (s *AdminService) GetAllUsers(adminUser *models.User, limit, offset int) ([
 	return s.userRepo.List(limit, offset)
 }
 
+// ListUsers returns one page of users matching filter
+func (s *AdminService) ListUsers(adminUser *models.User, filter models.UserListFilter, params models.PageParams) (*models.PageResponse[*models.User], error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+	
+	validRoles := map[string]bool{
+		"":          true,
+		"user":      true,
+		"admin":     true,
+		"moderator": true,
+	}
+	
+	if filter.Search == "" && !validRoles[filter.Role] {
+		return nil, ErrInvalidRole
+	}
+	
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && filter.CreatedAfter.After(*filter.CreatedBefore) {
+		return nil, ErrInvalidDateRange
+	}
+	
+	return s.userRepo.ListPage(filter, params)
+}
+
 // GetUsersByRole returns users filtered by role
 func (s *AdminService) GetUsersByRole(adminUser *models.User, role string, limit, offset int) ([]*models.User, error) {
 	if !s.IsAdmin(adminUser) {
@@ -90,6 +260,22 @@ func (s *AdminService) GetRecentUsers(adminUser *models.User, days, limit, offse
 	return s.userRepo.GetRecentUsers(days, limit, offset)
 }
 
+// ListInactiveUsers returns users with no sign-in in the last days days, counting
+// accounts that never signed in from when they signed up, along with the cutoff used
+func (s *AdminService) ListInactiveUsers(adminUser *models.User, days, limit, offset int) ([]*models.User, time.Time, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, time.Time{}, ErrNotAuthorized
+	}
+	
+	if days <= 0 {
+		return nil, time.Time{}, ErrInvalidInactiveDays
+	}
+	
+	cutoff := timeutil.Now().AddDate(0, 0, -days)
+	users, err := s.userRepo.FindInactiveSince(cutoff, limit, offset)
+	return users, cutoff, err
+}
+
 // GetUserByID returns a specific user by ID
 func (s *AdminService) GetUserByID(adminUser *models.User, userID uint) (*models.User, error) {
 	if !s.IsAdmin(adminUser) {
@@ -99,6 +285,133 @@ func (s *AdminService) GetUserByID(adminUser *models.User, userID uint) (*models
 	return s.userRepo.GetByID(userID)
 }
 
+// MaxBatchUserIDs caps how many users GetUsersByIDs looks up at once
+const MaxBatchUserIDs = 100
+
+// GetUsersByIDs returns the users with the given IDs in the order requested,
+// skipping IDs that do not exist
+func (s *AdminService) GetUsersByIDs(adminUser *models.User, ids []uint) ([]*models.User, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+	
+	if len(ids) > MaxBatchUserIDs {
+		return nil, ErrTooManyIDs
+	}
+	
+	users, err := s.userRepo.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	
+	byID := make(map[uint]*models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	ordered := make([]*models.User, 0, len(users))
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			ordered = append(ordered, user)
+			delete(byID, id) // a repeated ID is returned once
+		}
+	}
+	return ordered, nil
+}
+
+// GetLinkedProviders returns the user whose sign-in methods an admin wants to review
+func (s *AdminService) GetLinkedProviders(adminUser *models.User, userID uint) (*models.User, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+	
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// UnlinkProvider removes an OAuth identity from a user. It refuses to remove the
+// account's last sign-in method.
+func (s *AdminService) UnlinkProvider(adminUser *models.User, userID uint, provider string) (*models.User, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+	
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	
+	// Prevent non-super-admin from modifying other admins
+	if user.IsAdmin && adminUser.Role != "admin" {
+		return nil, ErrNotAuthorized
+	}
+	
+	if !user.HasLinkedProvider(provider) {
+		return nil, ErrProviderNotLinked
+	}
+	
+	if !user.CanUnlinkProvider(provider) {
+		return nil, ErrLastLoginMethod
+	}
+	
+	user.UnlinkProvider(provider)
+	updated, err := s.userRepo.Update(user)
+	if err != nil {
+		return nil, err
+	}
+	s.oauthService.forgetProviderTokens(user.ID, provider)
+	return updated, nil
+}
+
+// ListUserAPIKeys returns a user's API keys, including revoked ones
+func (s *AdminService) ListUserAPIKeys(adminUser *models.User, userID uint) ([]*models.APIKey, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+	
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+	
+	return s.apiKeyService.ListKeys(userID)
+}
+
+// RevokeUserAPIKey revokes one of a user's API keys, e.g. one that has leaked.
+// APIKeyMiddleware looks keys up on every request, so it stops working immediately.
+func (s *AdminService) RevokeUserAPIKey(adminUser *models.User, userID, keyID uint) (*models.APIKey, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+	
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	
+	// Prevent non-super-admin from modifying other admins
+	if user.IsAdmin && adminUser.Role != "admin" {
+		return nil, ErrNotAuthorized
+	}
+	
+	return s.apiKeyService.RevokeKey(userID, keyID)
+}
+
+// ExportUserData returns a copy of everything stored about a user
+func (s *AdminService) ExportUserData(adminUser *models.User, userID uint) (*models.UserDataExport, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+	
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	
+	return buildUserExport(user)
+}
+
 // UpdateUser updates user information (admin operation)
 func (s *AdminService) UpdateUser(adminUser *models.User, userID uint, req models.AdminUpdateUserRequest) (*models.User, error) {
 	if !s.IsAdmin(adminUser) {
@@ -116,22 +429,56 @@ func (s *AdminService) UpdateUser(adminUser *models.User, userID uint, req model
 		return nil, ErrNotAuthorized
 	}
 	
+	// Reject edits made against an outdated copy of the user
+	if req.Version != nil && *req.Version != user.Version {
+		return nil, ErrStaleUpdate
+	}
+	
+	website, err := normalizeWebsite(req.Website)
+	if err != nil {
+		return nil, err
+	}
+	
+	wasActiveAdmin := isActiveAdmin(user)
+	
 	// Update fields
 	user.FirstName = req.FirstName
 	user.LastName = req.LastName
 	user.Email = req.Email
 	user.Bio = stringPtrAdmin(req.Bio)
-	user.Website = stringPtrAdmin(req.Website)
+	user.Website = stringPtrAdmin(website)
 	user.Location = stringPtrAdmin(req.Location)
 	
 	if req.IsActive != nil {
 		user.IsActive = *req.IsActive
 	}
 	
+	if req.ActiveFrom != nil {
+		if *req.ActiveFrom == "" {
+			user.ActiveFrom = nil
+		} else {
+			activeFrom, err := time.Parse(time.RFC3339, *req.ActiveFrom)
+			if err != nil {
+				return nil, ErrInvalidActiveFrom
+			}
+			activeFrom = activeFrom.UTC()
+			user.ActiveFrom = &activeFrom
+		}
+	}
+	
 	if req.IsVerified != nil {
 		user.IsVerified = *req.IsVerified
 	}
 	
+	if req.OrgID != nil {
+		if *req.OrgID == 0 {
+			user.OrganizationID = nil
+		} else {
+			orgID := *req.OrgID
+			user.OrganizationID = &orgID
+		}
+	}
+	
 	if req.IsAdmin != nil {
 		// Only super admins can modify admin status
 		if adminUser.Role == "admin" {
@@ -158,11 +505,13 @@ func (s *AdminService) UpdateUser(adminUser *models.User, userID uint, req model
 		user.Role = req.Role
 	}
 	
-	return s.userRepo.Update(user)
+	return s.saveUser(user, wasActiveAdmin)
 }
 
-// DeactivateUser deactivates a user account
-func (s *AdminService) DeactivateUser(adminUser *models.User, userID uint) (*models.User, error) {
+// DeactivateUser deactivates a user account. A future effectiveAt schedules the
+// deactivation instead, leaving the account active until then; the zero time
+// deactivates immediately.
+func (s *AdminService) DeactivateUser(adminUser *models.User, userID uint, effectiveAt time.Time) (*models.User, error) {
 	if !s.IsAdmin(adminUser) {
 		return nil, ErrNotAuthorized
 	}
@@ -182,8 +531,120 @@ func (s *AdminService) DeactivateUser(adminUser *models.User, userID uint) (*mod
 		return nil, errors.New("cannot deactivate your own account")
 	}
 	
+	if effectiveAt.After(timeutil.Now()) {
+		effectiveAt = effectiveAt.UTC()
+		user.DeactivateAt = &effectiveAt
+		return s.userRepo.Update(user)
+	}
+	
+	wasActiveAdmin := isActiveAdmin(user)
 	user.IsActive = false
-	return s.userRepo.Update(user)
+	user.DeactivateAt = nil
+	return s.saveUser(user, wasActiveAdmin)
+}
+
+// ForceLogout signs a user out everywhere: every session and refresh token issued
+// to them so far stops working. Like deactivation, other admins can only be
+// signed out by a super admin.
+func (s *AdminService) ForceLogout(adminUser *models.User, userID uint) (*models.User, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if user.IsAdmin && adminUser.Role != "admin" {
+		return nil, ErrNotAuthorized
+	}
+
+	return s.logoutEverywhere(user)
+}
+
+// DeactivateAndLogout deactivates a user and signs them out everywhere, for
+// compromised accounts. The checks are those of DeactivateUser.
+func (s *AdminService) DeactivateAndLogout(adminUser *models.User, userID uint) (*models.User, error) {
+	user, err := s.DeactivateUser(adminUser, userID, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return s.logoutEverywhere(user)
+}
+
+// logoutEverywhere bumps the user's token version and revokes their sessions and
+// refresh tokens, as a password change does
+func (s *AdminService) logoutEverywhere(user *models.User) (*models.User, error) {
+	user.TokenVersion++
+	user, err := s.userRepo.Update(user)
+	if err != nil {
+		return nil, err
+	}
+	s.authService.invalidateTokens(user, "")
+	return user, nil
+}
+
+// NotifyRetryAfter returns how long adminUser must wait before sending another
+// notification, or zero if they may send now
+func (s *AdminService) NotifyRetryAfter(adminUser *models.User) time.Duration {
+	return s.notifyThrottle.retryAfter(fmt.Sprint(adminUser.ID), time.Now())
+}
+
+// NotifyUsers emails every user matching filter and returns how many emails were
+// queued. With dryRun set it only counts the recipients. Each recipient gets their
+// own email, so no one sees another recipient's address.
+func (s *AdminService) NotifyUsers(adminUser *models.User, filter models.NotificationFilter, subject, body string, dryRun bool) (int64, error) {
+	if !s.IsAdmin(adminUser) {
+		return 0, ErrNotAuthorized
+	}
+	
+	validRoles := map[string]bool{
+		"user":      true,
+		"admin":     true,
+		"moderator": true,
+	}
+	
+	if filter.Role != "" && !validRoles[filter.Role] {
+		return 0, ErrInvalidRole
+	}
+	
+	count, err := s.userRepo.CountNotificationRecipients(filter)
+	if err != nil {
+		return 0, err
+	}
+	if count > int64(s.config.NotifyMaxRecipients) {
+		return count, ErrTooManyRecipients
+	}
+	if dryRun {
+		return count, nil
+	}
+	
+	if s.NotifyRetryAfter(adminUser) > 0 {
+		return 0, ErrNotifyThrottled
+	}
+	
+	recipients, err := s.userRepo.ListNotificationRecipients(filter)
+	if err != nil {
+		return 0, err
+	}
+	if len(recipients) > s.config.NotifyMaxRecipients {
+		return int64(len(recipients)), ErrTooManyRecipients
+	}
+	s.notifyThrottle.fail(fmt.Sprint(adminUser.ID), time.Now())
+	
+	// Send one at a time in the background so a large send does not hold the request
+	// open or flood the mail server
+	mailer := s.authService.mailer
+	go func() {
+		for _, user := range recipients {
+			if err := mailer.Send(user.Email, subject, body); err != nil {
+				log.Printf("Failed to send notification to user %d: %v", user.ID, err)
+			}
+		}
+	}()
+	
+	return int64(len(recipients)), nil
 }
 
 // ActivateUser activates a user account
@@ -201,6 +662,30 @@ func (s *AdminService) ActivateUser(adminUser *models.User, userID uint) (*model
 	return s.userRepo.Update(user)
 }
 
+// ResendVerification mails a user a new email verification link on their behalf,
+// replacing any earlier link. It returns ErrAlreadyVerified for verified users and
+// ErrVerificationCooldown when a link went out too recently; the user is returned
+// with both so the caller can report when to retry.
+func (s *AdminService) ResendVerification(adminUser *models.User, userID uint) (*models.User, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if user.EmailMissing {
+		return user, ErrNoEmailAddress
+	}
+
+	if err := s.authService.resendVerificationEmail(user); err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
 // DeleteUser permanently deletes a user account
 func (s *AdminService) DeleteUser(adminUser *models.User, userID uint) error {
 	if !s.IsAdmin(adminUser) {
@@ -222,7 +707,16 @@ func (s *AdminService) DeleteUser(adminUser *models.User, userID uint) error {
 		return errors.New("cannot delete your own account")
 	}
 	
-	return s.userRepo.Delete(userID)
+	if isActiveAdmin(user) {
+		err = s.userRepo.DeleteUnlessLastAdmin(userID)
+	} else {
+		err = s.userRepo.Delete(userID)
+	}
+	if err != nil {
+		return err
+	}
+	s.oauthService.forgetProviderTokens(userID, "")
+	return nil
 }
 
 // PromoteToAdmin promotes a user to admin role
@@ -257,7 +751,142 @@ func (s *AdminService) DemoteFromAdmin(adminUser *models.User, userID uint) (*mo
 		return nil, errors.New("cannot demote your own account")
 	}
 	
+	wasActiveAdmin := isActiveAdmin(user)
 	user.IsAdmin = false
 	user.Role = "user"
-	return s.userRepo.Update(user)
+	return s.saveUser(user, wasActiveAdmin)
+}
+
+// InviteOnly reports whether registration requires an invitation
+func (s *AdminService) InviteOnly() bool {
+	return s.config.InviteOnly
+}
+
+// CreateInvitation issues a single-use invitation for email and returns the raw token.
+// Only the token hash is stored, so the token cannot be shown again later.
+func (s *AdminService) CreateInvitation(adminUser *models.User, email, role string) (string, *models.Invitation, error) {
+	if !s.IsAdmin(adminUser) {
+		return "", nil, ErrNotAuthorized
+	}
+	
+	if role == "" {
+		role = "user"
+	}
+	
+	validRoles := map[string]bool{
+		"user":      true,
+		"admin":     true,
+		"moderator": true,
+	}
+	
+	if !validRoles[role] {
+		return "", nil, ErrInvalidRole
+	}
+	
+	// Only super admins can invite admins
+	if role == "admin" && adminUser.Role != "admin" {
+		return "", nil, ErrNotAuthorized
+	}
+	
+	if exists, err := s.userRepo.ExistsByEmail(email); err != nil {
+		return "", nil, err
+	} else if exists {
+		return "", nil, ErrUserExists
+	}
+	
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(tokenBytes)
+	
+	invitation := &models.Invitation{
+		Email:       email,
+		Role:        role,
+		TokenHash:   HashInviteToken(token),
+		CreatedByID: adminUser.ID,
+		ExpiresAt:   timeutil.Now().Add(s.config.InviteTTL),
+	}
+	
+	invitation, err := s.invitationRepo.Create(invitation)
+	if err != nil {
+		return "", nil, err
+	}
+	
+	return token, invitation, nil
+}
+
+// ListInvitations returns invitations, newest first
+func (s *AdminService) ListInvitations(adminUser *models.User, limit, offset int) ([]*models.Invitation, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+	
+	return s.invitationRepo.List(limit, offset)
+}
+
+// MergeUsers folds a duplicate account into the primary one. The duplicate's provider
+// links, sessions, audit and activity records, API keys and role assignments move to
+// the primary and the duplicate is soft-deleted; the duplicate's sessions are signed
+// out and its API keys revoked. Admin accounts and the acting admin's own account cannot be merged away.
+func (s *AdminService) MergeUsers(adminUser *models.User, primaryID, duplicateID uint) (*models.User, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+	
+	if primaryID == duplicateID || duplicateID == adminUser.ID {
+		return nil, ErrInvalidMerge
+	}
+	
+	primary, err := s.userRepo.GetByID(primaryID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	
+	duplicate, err := s.userRepo.GetByID(duplicateID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	
+	if duplicate.IsAdmin || duplicate.Role == "admin" {
+		return nil, ErrInvalidMerge
+	}
+	
+	// A user can only hold one identity per provider
+	googleID, err := mergeProviderID(primary.GoogleID, duplicate.GoogleID)
+	if err != nil {
+		return nil, err
+	}
+	githubID, err := mergeProviderID(primary.GitHubID, duplicate.GitHubID)
+	if err != nil {
+		return nil, err
+	}
+	
+	primary.GoogleID = googleID
+	primary.GitHubID = githubID
+	if primary.AvatarURL == nil || *primary.AvatarURL == "" {
+		primary.AvatarURL = duplicate.AvatarURL
+	}
+	
+	merged, err := s.userRepo.MergeUsers(primary, duplicate)
+	if err != nil {
+		return nil, err
+	}
+	minTokenVersions.set(duplicate.ID, duplicate.TokenVersion+1)
+	return merged, nil
+}
+
+// mergeProviderID picks the provider ID to keep, failing if the two accounts are
+// linked to different identities at the same provider
+func mergeProviderID(primaryID, duplicateID *string) (*string, error) {
+	if duplicateID == nil || *duplicateID == "" {
+		return primaryID, nil
+	}
+	if primaryID == nil || *primaryID == "" {
+		return duplicateID, nil
+	}
+	if *primaryID != *duplicateID {
+		return nil, ErrMergeConflict
+	}
+	return primaryID, nil
 }
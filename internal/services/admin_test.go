@@ -0,0 +1,449 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+func TestListUsersPageSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		pageSize int
+		want     int
+	}{
+		{"default", nil, 0, 20},
+		{"configured default", map[string]string{"DEFAULT_PAGE_SIZE": "5"}, 0, 5},
+		{"page_size param", nil, 25, 25},
+		{"over the max", map[string]string{"MAX_PAGE_SIZE": "30"}, 500, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, tt.env)
+			authService := newTestAuthService(t, cfg)
+			admin := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+
+			users := repository.NewUserRepository()
+			var adminUser *models.User
+			for i := 0; i < 40; i++ {
+				user, err := users.Create(&models.User{
+					Email:     fmt.Sprintf("user%d@example.com", i),
+					Password:  "not-a-real-hash",
+					FirstName: "Test",
+					LastName:  "User",
+					Role:      "admin",
+					IsAdmin:   true,
+					IsActive:  true,
+				})
+				if err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+				adminUser = user
+			}
+
+			page, err := admin.ListUsers(adminUser, models.UserListFilter{}, admin.PageParams(1, tt.pageSize))
+			if err != nil {
+				t.Fatalf("ListUsers: %v", err)
+			}
+			if page.PageSize != tt.want || len(page.Items) != tt.want {
+				t.Errorf("page_size = %d with %d rows, want %d", page.PageSize, len(page.Items), tt.want)
+			}
+			if page.Total != 40 {
+				t.Errorf("total = %d, want 40", page.Total)
+			}
+		})
+	}
+}
+
+// createTestAdmin stores an active admin
+func createTestAdmin(t *testing.T, email string) *models.User {
+	t.Helper()
+	user, err := repository.NewUserRepository().Create(&models.User{
+		Email:     email,
+		Password:  "not-a-real-hash",
+		FirstName: "Test",
+		LastName:  "Admin",
+		Role:      "admin",
+		IsAdmin:   true,
+		IsActive:  true,
+	})
+	if err != nil {
+		t.Fatalf("Create %s: %v", email, err)
+	}
+	return user
+}
+
+func TestDemoteLastAdmin(t *testing.T) {
+	t.Run("another admin remains", func(t *testing.T) {
+		cfg := setupTestDB(t, nil)
+		authService := newTestAuthService(t, cfg)
+		admin := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+		first := createTestAdmin(t, "first@example.com")
+		second := createTestAdmin(t, "second@example.com")
+
+		demoted, err := admin.DemoteFromAdmin(first, second.ID)
+		if err != nil {
+			t.Fatalf("DemoteFromAdmin: %v", err)
+		}
+		if demoted.IsAdmin || demoted.Role != "user" {
+			t.Errorf("demoted user = admin %v, role %q; want a plain user", demoted.IsAdmin, demoted.Role)
+		}
+	})
+
+	t.Run("no other active admin", func(t *testing.T) {
+		cfg := setupTestDB(t, nil)
+		authService := newTestAuthService(t, cfg)
+		admin := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+		first := createTestAdmin(t, "first@example.com")
+		second := createTestAdmin(t, "second@example.com")
+
+		// The acting admin was deactivated after loading their session
+		if err := repository.GetDB().Model(&models.User{}).Where("id = ?", first.ID).Update("is_active", false).Error; err != nil {
+			t.Fatalf("deactivate: %v", err)
+		}
+
+		if _, err := admin.DemoteFromAdmin(first, second.ID); err != ErrLastAdmin {
+			t.Fatalf("DemoteFromAdmin error = %v, want ErrLastAdmin", err)
+		}
+		stored, err := repository.NewUserRepository().GetByID(second.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if !stored.IsAdmin || stored.Role != "admin" {
+			t.Error("the last active admin was demoted")
+		}
+	})
+
+	t.Run("admins demoting each other at once", func(t *testing.T) {
+		cfg := setupTestDB(t, nil)
+		authService := newTestAuthService(t, cfg)
+		admin := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+		first := createTestAdmin(t, "first@example.com")
+		second := createTestAdmin(t, "second@example.com")
+
+		errs := make(chan error, 2)
+		go func() {
+			_, err := admin.DemoteFromAdmin(first, second.ID)
+			errs <- err
+		}()
+		go func() {
+			_, err := admin.DemoteFromAdmin(second, first.ID)
+			errs <- err
+		}()
+
+		var succeeded, refused int
+		for i := 0; i < 2; i++ {
+			switch err := <-errs; err {
+			case nil:
+				succeeded++
+			case ErrLastAdmin:
+				refused++
+			default:
+				t.Fatalf("DemoteFromAdmin: %v", err)
+			}
+		}
+		if succeeded != 1 || refused != 1 {
+			t.Errorf("%d demotions succeeded and %d were refused, want one of each", succeeded, refused)
+		}
+	})
+}
+
+func TestAdminResendVerification(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified bool
+		wantErr  error
+	}{
+		{"unverified", false, nil},
+		{"already verified", true, ErrAlreadyVerified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, nil)
+			mailer := newMailRecorder()
+			authService := newTestAuthServiceWithMailer(t, cfg, mailer)
+			admin := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+
+			adminUser := createTestAdmin(t, "admin@example.com")
+			user := createTestUser(t, authService, "unverified@example.com", "Passw0rd!x")
+			if err := repository.GetDB().Model(&models.User{}).Where("id = ?", user.ID).Update("is_verified", tt.verified).Error; err != nil {
+				t.Fatalf("update user: %v", err)
+			}
+
+			if _, err := admin.ResendVerification(user, adminUser.ID); err != ErrNotAuthorized {
+				t.Errorf("ResendVerification by a user: error = %v, want ErrNotAuthorized", err)
+			}
+
+			if _, err := admin.ResendVerification(adminUser, user.ID); err != tt.wantErr {
+				t.Fatalf("ResendVerification error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				time.Sleep(100 * time.Millisecond)
+				mailer.none(t)
+				return
+			}
+
+			mail := mailer.next(t)
+			if mail.to != user.Email || !strings.Contains(mail.body, "/verify-email?token=") {
+				t.Errorf("mail = %q to %s, want a verification link to %s", mail.subject, mail.to, user.Email)
+			}
+			stored, err := repository.NewUserRepository().GetByID(user.ID)
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if stored.VerificationTokenHash == "" || stored.VerificationSentAt == nil {
+				t.Error("no verification token was stored")
+			}
+
+			// The self-service cooldown applies to admins too
+			if _, err := admin.ResendVerification(adminUser, user.ID); err != ErrVerificationCooldown {
+				t.Errorf("second resend: error = %v, want ErrVerificationCooldown", err)
+			}
+		})
+	}
+}
+
+func TestForceLogout(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	authService := newTestAuthService(t, cfg)
+	admin := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+	sessions := NewSessionService(cfg, authService)
+
+	adminUser := createTestAdmin(t, "admin@example.com")
+	user := createTestUser(t, authService, "target@example.com", "Passw0rd!x")
+
+	// Signed in on two devices, one of them with a refresh token
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		token, err := authService.GenerateJWT(user)
+		if err != nil {
+			t.Fatalf("GenerateJWT: %v", err)
+		}
+		if _, err := sessions.Start(user, token, "10.0.0.1", "test"); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		tokens = append(tokens, token)
+	}
+	refresh, err := sessions.IssueRefreshToken(user, tokens[0], "device-1", "test")
+	if err != nil {
+		t.Fatalf("IssueRefreshToken: %v", err)
+	}
+
+	if _, err := admin.ForceLogout(adminUser, user.ID); err != nil {
+		t.Fatalf("ForceLogout: %v", err)
+	}
+
+	for i, token := range tokens {
+		if _, err := authService.ValidateJWT(token); err != ErrInvalidToken {
+			t.Errorf("token %d: error = %v, want ErrInvalidToken", i, err)
+		}
+	}
+	if _, err := sessions.Refresh(refresh, "device-1", "test"); err != ErrInvalidRefreshToken {
+		t.Errorf("Refresh error = %v, want ErrInvalidRefreshToken", err)
+	}
+	active, err := repository.NewSessionRepository().ListActiveByUser(user.ID, time.Now())
+	if err != nil {
+		t.Fatalf("ListActiveByUser: %v", err)
+	}
+	if len(active) != 0 {
+		t.Errorf("%d sessions still active, want none", len(active))
+	}
+
+	// The tokens stay rejected after a restart
+	resetTokenState()
+	authService = newTestAuthService(t, cfg)
+	for i, token := range tokens {
+		if _, err := authService.ValidateJWT(token); err != ErrInvalidToken {
+			t.Errorf("token %d after restart: error = %v, want ErrInvalidToken", i, err)
+		}
+	}
+
+	// Signing in again works as usual
+	if _, _, err := authService.Login(models.LoginRequest{Email: user.Email, Password: "Passw0rd!x"}, "10.0.0.1", "test"); err != nil {
+		t.Errorf("Login after ForceLogout: %v", err)
+	}
+}
+
+func TestNotifyUsers(t *testing.T) {
+	active, inactive := true, false
+
+	setup := func(t *testing.T, env map[string]string) (*AdminService, *mailRecorder, *models.User) {
+		t.Helper()
+		cfg := setupTestDB(t, env)
+		mailer := newMailRecorder()
+		authService := newTestAuthServiceWithMailer(t, cfg, mailer)
+		admin := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+		adminUser := createTestAdmin(t, "admin@example.com")
+
+		users := repository.NewUserRepository()
+		for _, u := range []struct {
+			email, role  string
+			active       bool
+			emailMissing bool
+		}{
+			{"user@example.com", "user", true, false},
+			{"idle@example.com", "user", false, false},
+			{"mod@example.com", "moderator", true, false},
+			{"github-1@users.noreply.invalid", "user", true, true},
+		} {
+			user, err := users.Create(&models.User{
+				Email:        u.email,
+				Password:     "not-a-real-hash",
+				FirstName:    "Test",
+				LastName:     "User",
+				Role:         u.role,
+				EmailMissing: u.emailMissing,
+			})
+			if err != nil {
+				t.Fatalf("Create %s: %v", u.email, err)
+			}
+			// is_active defaults to true, so a false value has to be written separately
+			if !u.active {
+				if err := repository.GetDB().Model(user).Update("is_active", false).Error; err != nil {
+					t.Fatalf("Deactivate %s: %v", u.email, err)
+				}
+			}
+		}
+		return admin, mailer, adminUser
+	}
+
+	t.Run("dry run counts by filter", func(t *testing.T) {
+		admin, mailer, adminUser := setup(t, nil)
+
+		tests := []struct {
+			name   string
+			filter models.NotificationFilter
+			want   int64
+		}{
+			{"everyone with an email", models.NotificationFilter{}, 4},
+			{"role", models.NotificationFilter{Role: "user"}, 2},
+			{"moderators", models.NotificationFilter{Role: "moderator"}, 1},
+			{"inactive", models.NotificationFilter{Active: &inactive}, 1},
+			{"active users", models.NotificationFilter{Role: "user", Active: &active}, 1},
+		}
+		for _, tt := range tests {
+			count, err := admin.NotifyUsers(adminUser, tt.filter, "Hello", "Body", true)
+			if err != nil {
+				t.Fatalf("%s: NotifyUsers: %v", tt.name, err)
+			}
+			if count != tt.want {
+				t.Errorf("%s: count = %d, want %d", tt.name, count, tt.want)
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		mailer.none(t)
+	})
+
+	t.Run("send reaches only matching users", func(t *testing.T) {
+		admin, mailer, adminUser := setup(t, nil)
+
+		count, err := admin.NotifyUsers(adminUser, models.NotificationFilter{Role: "user"}, "Hello", "Body", false)
+		if err != nil {
+			t.Fatalf("NotifyUsers: %v", err)
+		}
+		if count != 2 {
+			t.Fatalf("count = %d, want 2", count)
+		}
+
+		got := map[string]bool{}
+		for i := 0; i < 2; i++ {
+			mail := mailer.next(t)
+			if strings.Contains(mail.to, ",") {
+				t.Errorf("mail addressed to several recipients: %q", mail.to)
+			}
+			got[mail.to] = true
+		}
+		if !got["user@example.com"] || !got["idle@example.com"] {
+			t.Errorf("recipients = %v, want user@example.com and idle@example.com", got)
+		}
+		time.Sleep(50 * time.Millisecond)
+		mailer.none(t)
+	})
+
+	t.Run("over the cap", func(t *testing.T) {
+		admin, mailer, adminUser := setup(t, map[string]string{"NOTIFY_MAX_RECIPIENTS": "2"})
+
+		count, err := admin.NotifyUsers(adminUser, models.NotificationFilter{}, "Hello", "Body", false)
+		if err != ErrTooManyRecipients {
+			t.Fatalf("err = %v, want ErrTooManyRecipients", err)
+		}
+		if count != 4 {
+			t.Errorf("count = %d, want 4", count)
+		}
+		time.Sleep(50 * time.Millisecond)
+		mailer.none(t)
+	})
+
+	t.Run("not an admin", func(t *testing.T) {
+		admin, _, _ := setup(t, nil)
+		user, err := repository.NewUserRepository().GetByEmail("user@example.com")
+		if err != nil {
+			t.Fatalf("GetByEmail: %v", err)
+		}
+		if _, err := admin.NotifyUsers(user, models.NotificationFilter{}, "Hello", "Body", true); err != ErrNotAuthorized {
+			t.Errorf("err = %v, want ErrNotAuthorized", err)
+		}
+	})
+}
+
+func TestListUsersCreatedRange(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	authService := newTestAuthService(t, cfg)
+	admin := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+	adminUser := createTestAdmin(t, "admin@example.com")
+
+	// One user a day from March 1st to 5th; the admin was created today
+	day := func(n int) time.Time { return time.Date(2026, time.March, n, 12, 0, 0, 0, time.UTC) }
+	for n := 1; n <= 5; n++ {
+		user := createTestUser(t, authService, fmt.Sprintf("day%d@example.com", n), "Passw0rd!x")
+		if err := repository.GetDB().Model(user).UpdateColumn("created_at", day(n)).Error; err != nil {
+			t.Fatalf("set created_at: %v", err)
+		}
+	}
+
+	list := func(after, before *time.Time) ([]string, error) {
+		page, err := admin.ListUsers(adminUser, models.UserListFilter{Role: "user", CreatedAfter: after, CreatedBefore: before}, admin.PageParams(1, 50))
+		if err != nil {
+			return nil, err
+		}
+		var emails []string
+		for _, user := range page.Items {
+			emails = append(emails, user.Email)
+		}
+		return emails, nil
+	}
+	at := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name          string
+		after, before *time.Time
+		want          string
+	}{
+		{"inclusive range", at(day(2)), at(day(4)), "day2@example.com,day3@example.com,day4@example.com"},
+		{"after only", at(day(4)), nil, "day4@example.com,day5@example.com"},
+		{"before only", nil, at(day(1)), "day1@example.com"},
+		{"same instant", at(day(3)), at(day(3)), "day3@example.com"},
+		{"empty range", at(day(2).Add(time.Hour)), at(day(3).Add(-time.Hour)), ""},
+	}
+	for _, tt := range tests {
+		emails, err := list(tt.after, tt.before)
+		if err != nil {
+			t.Fatalf("%s: ListUsers: %v", tt.name, err)
+		}
+		if got := strings.Join(emails, ","); got != tt.want {
+			t.Errorf("%s: users = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := list(at(day(4)), at(day(2))); err != ErrInvalidDateRange {
+		t.Errorf("inverted range: err = %v, want ErrInvalidDateRange", err)
+	}
+}
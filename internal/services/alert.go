@@ -0,0 +1,187 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
+)
+
+// Alert kinds sent to admins
+const (
+	AlertFailedLoginSpike = "failed_login_spike"
+	AlertAdminPromotion   = "admin_promotion"
+	AlertBulkDelete       = "bulk_delete"
+)
+
+// Alert is a security-relevant event worth an admin's attention
+type Alert struct {
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// AlertService watches the audit stream and notifies admins by email and, when
+// configured, a webhook. Each alert kind is debounced: after an alert is sent, the
+// same kind stays quiet for the cooldown, so a brute-force attempt sends one alert
+// rather than hundreds.
+type AlertService struct {
+	userRepo             repository.UserRepository
+	mailer               Mailer
+	httpClient           *http.Client
+	recipients           []string
+	webhookURL           string
+	failedLoginThreshold int
+	bulkDeleteThreshold  int
+	window               time.Duration
+	cooldown             time.Duration
+
+	mu       sync.Mutex
+	events   map[string][]time.Time // recent events per counter, oldest first
+	lastSent map[string]time.Time   // last alert per debounce key
+}
+
+func NewAlertService(cfg *configs.Config, mailer Mailer) *AlertService {
+	var recipients []string
+	for _, email := range strings.Split(cfg.AlertEmails, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			recipients = append(recipients, email)
+		}
+	}
+
+	return &AlertService{
+		userRepo:             repository.NewUserRepository(),
+		mailer:               mailer,
+		httpClient:           &http.Client{Timeout: 5 * time.Second},
+		recipients:           recipients,
+		webhookURL:           cfg.AlertWebhookURL,
+		failedLoginThreshold: cfg.AlertFailedLoginThreshold,
+		bulkDeleteThreshold:  cfg.AlertBulkDeleteThreshold,
+		window:               cfg.AlertWindow,
+		cooldown:             cfg.AlertCooldown,
+		events:               make(map[string][]time.Time),
+		lastSent:             make(map[string]time.Time),
+	}
+}
+
+// Observe checks an audit entry against the alert rules. A zero threshold disables
+// the rule it belongs to.
+func (s *AlertService) Observe(entry *models.AuditLog) {
+	now := entry.CreatedAt
+	if now.IsZero() {
+		now = timeutil.Now()
+	}
+
+	switch entry.Action {
+	case models.AuditActionLoginFailed:
+		if s.countEvent("failed_login", now, s.failedLoginThreshold) {
+			s.trigger(AlertFailedLoginSpike, now, fmt.Sprintf(
+				"%d or more failed sign-ins in the last %s. The most recent came from %s.",
+				s.failedLoginThreshold, s.window, entry.IPAddress))
+		}
+	case models.AuditActionUserPromote:
+		s.trigger(fmt.Sprintf("%s:%d", AlertAdminPromotion, entry.TargetID), now, fmt.Sprintf(
+			"User %d was granted admin privileges by user %d.", entry.TargetID, entry.ActorID))
+	case models.AuditActionUserDelete:
+		key := fmt.Sprintf("delete:%d", entry.ActorID)
+		if s.countEvent(key, now, s.bulkDeleteThreshold) {
+			s.trigger(fmt.Sprintf("%s:%d", AlertBulkDelete, entry.ActorID), now, fmt.Sprintf(
+				"User %d deleted %d or more accounts in the last %s.",
+				entry.ActorID, s.bulkDeleteThreshold, s.window))
+		}
+	}
+}
+
+// countEvent records an event under key and reports whether at least threshold
+// events fell within the window. The counter restarts once it fires.
+func (s *AlertService) countEvent(key string, now time.Time, threshold int) bool {
+	if threshold <= 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := s.events[key]
+	cutoff := now.Add(-s.window)
+	for len(events) > 0 && !events[0].After(cutoff) {
+		events = events[1:]
+	}
+	events = append(events, now)
+
+	if len(events) >= threshold {
+		delete(s.events, key)
+		return true
+	}
+	s.events[key] = events
+	return false
+}
+
+// trigger sends an alert unless one with the same key went out within the cooldown.
+// Keys are the alert kind, optionally suffixed to debounce per subject.
+func (s *AlertService) trigger(key string, now time.Time, message string) {
+	s.mu.Lock()
+	if last, ok := s.lastSent[key]; ok && now.Sub(last) < s.cooldown {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSent[key] = now
+	s.mu.Unlock()
+
+	kind, _, _ := strings.Cut(key, ":")
+	go s.deliver(Alert{Kind: kind, Message: message, Time: now})
+}
+
+// deliver emails the alert to the configured recipients, or to every super admin
+// when none are configured, and posts it to the webhook if one is set
+func (s *AlertService) deliver(alert Alert) {
+	log.Printf("Security alert (%s): %s", alert.Kind, alert.Message)
+
+	recipients := s.recipients
+	if len(recipients) == 0 {
+		admins, err := s.userRepo.GetUsersByRole("admin", 100, 0)
+		if err != nil {
+			log.Printf("Failed to load admins for security alert: %v", err)
+		}
+		for _, admin := range admins {
+			recipients = append(recipients, admin.Email)
+		}
+	}
+
+	body := fmt.Sprintf(`Security alert: %s
+
+%s
+
+Time: %s
+
+Review the audit log for details.
+`, alert.Kind, alert.Message, timeutil.FormatLocal(alert.Time, "January 2, 2006 at 3:04 PM MST"))
+
+	for _, recipient := range recipients {
+		if err := s.mailer.Send(recipient, "Security alert: "+alert.Kind, body); err != nil {
+			log.Printf("Failed to send security alert to %s: %v", recipient, err)
+		}
+	}
+
+	if s.webhookURL != "" {
+		payload, _ := json.Marshal(alert)
+		resp, err := s.httpClient.Post(s.webhookURL, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			log.Printf("Failed to post security alert webhook: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Security alert webhook returned %s", resp.Status)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"sso-web-app/internal/models"
+)
+
+func TestFailedLoginBurstSendsOneAlert(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	cfg.AlertEmails = "security@example.com"
+	cfg.AlertFailedLoginThreshold = 5
+	mailer := newMailRecorder()
+	alerts := NewAlertService(cfg, mailer)
+
+	// A brute-force run: far more failures than the threshold within the window
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		alerts.Observe(&models.AuditLog{
+			Action:    models.AuditActionLoginFailed,
+			IPAddress: "203.0.113.7",
+			CreatedAt: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	mail := mailer.next(t)
+	if mail.to != "security@example.com" {
+		t.Errorf("alert sent to %s, want security@example.com", mail.to)
+	}
+	if !strings.Contains(mail.subject, AlertFailedLoginSpike) || !strings.Contains(mail.body, "203.0.113.7") {
+		t.Errorf("alert = %q / %q, want a failed-login spike naming the source address", mail.subject, mail.body)
+	}
+	time.Sleep(100 * time.Millisecond)
+	mailer.none(t)
+
+	// Below the threshold nothing is sent at all
+	quiet := NewAlertService(cfg, mailer)
+	for i := 0; i < cfg.AlertFailedLoginThreshold-1; i++ {
+		quiet.Observe(&models.AuditLog{Action: models.AuditActionLoginFailed, CreatedAt: start})
+	}
+	time.Sleep(100 * time.Millisecond)
+	mailer.none(t)
+}
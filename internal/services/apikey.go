@@ -0,0 +1,119 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrInvalidScope   = errors.New("invalid scope specified")
+)
+
+// apiKeyPrefix marks API keys so they are easy to recognize, e.g. in secret scanners
+const apiKeyPrefix = "sso_"
+
+type APIKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+	userRepo   repository.UserRepository
+}
+
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: repository.NewAPIKeyRepository(),
+		userRepo:   repository.NewUserRepository(),
+	}
+}
+
+// hashAPIKey returns the form of an API key that is stored in the database
+func hashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateKey creates an API key for the user and returns the raw key, which is not
+// stored and cannot be shown again
+func (s *APIKeyService) CreateKey(userID uint, req models.CreateAPIKeyRequest) (string, *models.APIKey, error) {
+	unique := make(map[string]bool, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !models.ValidScopes[scope] {
+			return "", nil, ErrInvalidScope
+		}
+		unique[scope] = true
+	}
+
+	scopes := make([]string, 0, len(unique))
+	for scope := range unique {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	rawKey := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := &models.APIKey{
+		UserID:  userID,
+		Name:    req.Name,
+		Prefix:  rawKey[:len(apiKeyPrefix)+8],
+		KeyHash: hashAPIKey(rawKey),
+	}
+	key.SetScopes(scopes)
+
+	key, err := s.apiKeyRepo.Create(key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return rawKey, key, nil
+}
+
+// ListKeys returns the user's API keys, including revoked ones
+func (s *APIKeyService) ListKeys(userID uint) ([]*models.APIKey, error) {
+	return s.apiKeyRepo.ListByUser(userID)
+}
+
+// RevokeKey revokes one of the user's API keys. It takes effect on the next request.
+func (s *APIKeyService) RevokeKey(userID, keyID uint) (*models.APIKey, error) {
+	key, err := s.apiKeyRepo.GetByUserAndID(userID, keyID)
+	if err != nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	if key.RevokedAt == nil {
+		now := timeutil.Now()
+		key.RevokedAt = &now
+		return s.apiKeyRepo.Update(key)
+	}
+	return key, nil
+}
+
+// Authenticate resolves a raw API key to its owner. Revoked keys and keys of
+// missing users are rejected.
+func (s *APIKeyService) Authenticate(rawKey string) (*models.User, *models.APIKey, error) {
+	key, err := s.apiKeyRepo.GetByHash(hashAPIKey(rawKey))
+	if err != nil || key.RevokedAt != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.GetByID(key.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	now := timeutil.Now()
+	key.LastUsedAt = &now
+	s.apiKeyRepo.Update(key)
+
+	return user, key, nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
+)
+
+var (
+	ErrInvalidAuditAction  = errors.New("invalid audit action specified")
+	ErrInvalidDateRange    = errors.New("invalid date range")
+	ErrInvalidLoginOutcome = errors.New("invalid outcome: must be success or failure")
+)
+
+type AuditService struct {
+	auditRepo             repository.AuditLogRepository
+	loginHistoryRepo      repository.LoginHistoryRepository
+	userRepo              repository.UserRepository
+	alerts                *AlertService
+	httpClient            *http.Client
+	failedLoginWebhookURL string
+	config                *configs.Config
+}
+
+func NewAuditService(cfg *configs.Config, alerts *AlertService) *AuditService {
+	return &AuditService{
+		auditRepo:             repository.NewAuditLogRepository(),
+		loginHistoryRepo:      repository.NewLoginHistoryRepository(),
+		userRepo:              repository.NewUserRepository(),
+		alerts:                alerts,
+		httpClient:            &http.Client{Timeout: 5 * time.Second},
+		failedLoginWebhookURL: cfg.FailedLoginWebhookURL,
+		config:                cfg,
+	}
+}
+
+// Record stores an audit entry. Failures are logged rather than returned so that
+// auditing never breaks the action being audited.
+func (s *AuditService) Record(actorID, targetID uint, action, ipAddress, details string) {
+	entry := &models.AuditLog{
+		ActorID:   actorID,
+		TargetID:  targetID,
+		Action:    action,
+		IPAddress: ipAddress,
+		Details:   details,
+	}
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Printf("Failed to record audit entry %s: %v", action, err)
+	}
+
+	if s.alerts != nil {
+		s.alerts.Observe(entry)
+	}
+}
+
+// RecordLogin stores a successful sign-in with method (password, google or
+// github) as a login audit entry and in the user's login history
+func (s *AuditService) RecordLogin(userID uint, method, ipAddress, userAgent string) {
+	s.Record(userID, userID, models.AuditActionLogin, ipAddress, method)
+	s.recordLoginHistory(&models.LoginHistory{
+		UserID:    userID,
+		Method:    method,
+		Outcome:   models.LoginOutcomeSuccess,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+	})
+}
+
+// RecordFailedLogin stores a failed sign-in as a login_failed audit entry whose
+// details are the event as JSON, and posts the event to the failed-login webhook
+// when one is configured. It runs whether or not sign-in throttling is enabled.
+// Attempts on an existing account also go to its login history.
+func (s *AuditService) RecordFailedLogin(event models.FailedLoginEvent) {
+	if event.Time.IsZero() {
+		event.Time = timeutil.Now()
+	}
+
+	details, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode failed sign-in event: %v", err)
+		return
+	}
+	s.Record(0, event.UserID, models.AuditActionLoginFailed, event.IPAddress, string(details))
+
+	if event.UserID != 0 {
+		s.recordLoginHistory(&models.LoginHistory{
+			CreatedAt: event.Time,
+			UserID:    event.UserID,
+			Method:    AuthProviderPassword,
+			Outcome:   models.LoginOutcomeFailure,
+			Reason:    event.Reason,
+			IPAddress: event.IPAddress,
+			UserAgent: event.UserAgent,
+		})
+	}
+
+	if s.failedLoginWebhookURL != "" {
+		go s.postFailedLogin(details)
+	}
+}
+
+// recordLoginHistory stores a login history entry, logging rather than returning
+// failures as Record does
+func (s *AuditService) recordLoginHistory(entry *models.LoginHistory) {
+	if err := s.loginHistoryRepo.Create(entry); err != nil {
+		log.Printf("Failed to record login history for user %d: %v", entry.UserID, err)
+	}
+}
+
+// postFailedLogin delivers an encoded failed sign-in event to the webhook
+func (s *AuditService) postFailedLogin(payload []byte) {
+	resp, err := s.httpClient.Post(s.failedLoginWebhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Failed to post failed sign-in webhook: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Failed sign-in webhook returned %s", resp.Status)
+	}
+}
+
+// Query returns one page of audit entries matching filter, newest first unless
+// filter.Oldest is set. Only super admins may read the audit log.
+func (s *AuditService) Query(adminUser *models.User, filter models.AuditLogFilter, page, pageSize int) (*models.PageResponse[models.AuditLogResponse], error) {
+	if adminUser.Role != "admin" {
+		return nil, ErrNotAuthorized
+	}
+
+	for _, action := range filter.Actions {
+		if !models.ValidAuditActions[action] {
+			return nil, ErrInvalidAuditAction
+		}
+	}
+
+	if filter.From != nil && filter.From.After(timeutil.Now()) {
+		return nil, ErrInvalidDateRange
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, ErrInvalidDateRange
+	}
+
+	params := models.NewPageParams(page, pageSize, s.config.DefaultPageSize, s.config.MaxPageSize)
+	filter.Limit = params.PageSize
+	filter.Offset = params.Offset()
+	entries, total, err := s.auditRepo.Query(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.AuditLogResponse, 0, len(entries))
+	for _, entry := range entries {
+		responses = append(responses, entry.ToResponse())
+	}
+	return models.NewPageResponse(responses, params, total), nil
+}
+
+// LoginHistory returns one page of the user's sign-in attempts, newest first.
+// outcome limits it to success or failure; empty returns both.
+func (s *AuditService) LoginHistory(userID uint, outcome string, page, pageSize int) (*models.PageResponse[models.LoginHistoryResponse], error) {
+	if outcome != "" && outcome != models.LoginOutcomeSuccess && outcome != models.LoginOutcomeFailure {
+		return nil, ErrInvalidLoginOutcome
+	}
+
+	params := models.NewPageParams(page, pageSize, s.config.DefaultPageSize, s.config.MaxPageSize)
+	entries, err := s.loginHistoryRepo.ListPage(userID, outcome, params)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.LoginHistoryResponse, 0, len(entries.Items))
+	for _, entry := range entries.Items {
+		responses = append(responses, entry.ToResponse())
+	}
+	return models.NewPageResponse(responses, models.PageParams{Page: entries.Page, PageSize: entries.PageSize}, entries.Total), nil
+}
+
+// UserLoginHistory returns a page of another user's login history for an admin
+func (s *AuditService) UserLoginHistory(adminUser *models.User, userID uint, outcome string, page, pageSize int) (*models.PageResponse[models.LoginHistoryResponse], error) {
+	if !adminUser.IsAdmin && adminUser.Role != "admin" {
+		return nil, ErrNotAuthorized
+	}
+
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	return s.LoginHistory(userID, outcome, page, pageSize)
+}
@@ -1,14 +1,24 @@
 package services
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"os"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"sso-web-app/configs"
 	"sso-web-app/internal/models"
 	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
 )
 
 var (
@@ -16,6 +26,20 @@ var (
 	ErrUserExists         = errors.New("user already exists")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidToken       = errors.New("invalid token")
+	ErrReauthRequired     = errors.New("re-authentication required")
+	ErrPasswordExpired    = errors.New("password expired, must change")
+	ErrPasswordUnchanged  = errors.New("new password must differ from the current password")
+	ErrEmailNotVerified   = errors.New("email address not verified")
+	ErrNoLocalPassword    = errors.New("account has no local password")
+
+	ErrProviderReauthRequired = errors.New("recent sign-in with a linked provider required")
+	ErrAccountNotYetActive    = errors.New("account access has not started yet")
+	ErrEmailAlreadySet        = errors.New("account already has an email address")
+
+	ErrInvitationRequired      = errors.New("an invitation is required to register")
+	ErrInvalidInvitation       = errors.New("invitation is invalid or has already been used")
+	ErrInvitationExpired       = errors.New("invitation has expired")
+	ErrInvitationEmailMismatch = errors.New("email does not match the invitation")
 )
 
 // Helper function to convert string to string pointer
@@ -26,67 +50,341 @@ func stringPtrAuth(s string) *string {
 	return &s
 }
 
+// TokenLifetime is how long a session token is valid after it is issued
+const TokenLifetime = 7 * 24 * time.Hour
+
 type AuthService struct {
-	userRepo  repository.UserRepository
-	jwtSecret []byte
+	userRepo             repository.UserRepository
+	invitationRepo       repository.InvitationRepository
+	sessionRepo          repository.SessionRepository
+	refreshRepo          repository.RefreshTokenRepository
+	jwtSecret            []byte
+	signingKeys          *SigningKeyService
+	reauthWindow         time.Duration
+	passwordMaxAge       time.Duration
+	passwordPepper       []byte
+	registrationDisabled bool
+	inviteOnly           bool
+	enumerationSafe      bool
+	reuseDeletedEmails   bool
+	requireVerified      bool
+	verifyExisting       bool
+	autoVerifyDomains    []string
+	rejectUnknownFields  bool
+	honeypotField        string
+	sessionTTLs          map[string]time.Duration
+	baseURL              string
+	mailer               Mailer
+	sms                  SMSSender
+	phoneVerification    bool
+	phoneCountryCode     string
+	requireTerms         bool
+	termsVersion         string
+	termsURL             string
+	accountThrottle      *attemptThrottle
+	ipThrottle           *attemptThrottle
+	passwordThrottle     *attemptThrottle
+	strengthThrottle     *attemptThrottle
+	breachChecker        *breachChecker
+	disposableEmails     *disposableEmailChecker
+	welcome              *welcomeEmail
+	audit                *AuditService
 }
 
-func NewAuthService() *AuthService {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key-change-this-in-production"
+// NewAuthService creates the auth service and restores the per-user token version
+// floors, so tokens invalidated by a password change stay invalid after a restart
+func NewAuthService(cfg *configs.Config, mailer Mailer, sms SMSSender, signingKeys *SigningKeyService, audit *AuditService) *AuthService {
+	s := &AuthService{
+		userRepo:             repository.NewUserRepository(),
+		invitationRepo:       repository.NewInvitationRepository(),
+		sessionRepo:          repository.NewSessionRepository(),
+		refreshRepo:          repository.NewRefreshTokenRepository(),
+		jwtSecret:            []byte(cfg.JWTSecret),
+		signingKeys:          signingKeys,
+		reauthWindow:         cfg.ReauthWindow,
+		passwordMaxAge:       time.Duration(cfg.PasswordMaxAgeDays) * 24 * time.Hour,
+		passwordPepper:       []byte(cfg.PasswordPepper),
+		registrationDisabled: cfg.DisableRegistration,
+		inviteOnly:           cfg.InviteOnly,
+		enumerationSafe:      cfg.EnumerationSafeSignup,
+		reuseDeletedEmails:   cfg.AllowDeletedEmailReuse,
+		requireVerified:      cfg.RequireVerifiedLogin,
+		verifyExisting:       cfg.RequireVerifiedLoginExisting,
+		autoVerifyDomains:    parseDomainList(cfg.AutoVerifyDomains),
+		rejectUnknownFields:  cfg.RejectUnknownFields,
+		honeypotField:        honeypotField(cfg),
+		sessionTTLs: map[string]time.Duration{
+			"user":      cfg.SessionTTLUser,
+			"moderator": cfg.SessionTTLModerator,
+			"admin":     cfg.SessionTTLAdmin,
+		},
+		baseURL:              strings.TrimRight(cfg.AppBaseURL, "/"),
+		mailer:               mailer,
+		sms:                  sms,
+		phoneVerification:    cfg.PhoneVerification,
+		phoneCountryCode:     cfg.PhoneCountryCode,
+		requireTerms:         cfg.RequireTermsAcceptance,
+		termsVersion:         cfg.TermsVersion,
+		termsURL:             cfg.TermsURL,
+		accountThrottle:      newAttemptThrottle(cfg.LoginThrottleAccountAttempts, cfg.LoginThrottleWindow),
+		ipThrottle:           newAttemptThrottle(cfg.LoginThrottleIPAttempts, cfg.LoginThrottleWindow),
+		passwordThrottle:     newAttemptThrottle(cfg.PasswordCheckAttempts, cfg.LoginThrottleWindow),
+		strengthThrottle:     newAttemptThrottle(cfg.PasswordStrengthRateLimit, passwordStrengthWindow),
+		breachChecker:        newBreachChecker(cfg.CheckBreachedPasswords),
+		disposableEmails:     newDisposableEmailChecker(cfg.BlockDisposableEmails, cfg.DisposableEmailDomainsFile),
+		welcome:              newWelcomeEmail(cfg, strings.TrimRight(cfg.AppBaseURL, "/"), mailer),
+		audit:                audit,
+	}
+
+	versions, err := s.userRepo.ListTokenVersions()
+	if err != nil {
+		log.Printf("Failed to restore token versions: %v", err)
+	}
+	for userID, version := range versions {
+		minTokenVersions.set(userID, version)
 	}
-	
-	return &AuthService{
-		userRepo:  repository.NewUserRepository(),
-		jwtSecret: []byte(secret),
+
+	return s
+}
+
+// HashInviteToken returns the form of an invite token that is stored in the database
+func HashInviteToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// RegistrationEnabled reports whether open self-registration is allowed.
+// OAuth sign-in and admin-created accounts are unaffected.
+func (s *AuthService) RegistrationEnabled() bool {
+	return !s.registrationDisabled
+}
+
+// EnumerationSafeSignup reports whether registration responses must not reveal
+// whether an email is already registered
+func (s *AuthService) EnumerationSafeSignup() bool {
+	return s.enumerationSafe
+}
+
+// InviteOnly reports whether registration requires an admin-issued invitation
+func (s *AuthService) InviteOnly() bool {
+	return s.inviteOnly
+}
+
+// honeypotField returns the configured honeypot field, or "" when it is disabled
+func honeypotField(cfg *configs.Config) string {
+	if !cfg.EnableHoneypot {
+		return ""
 	}
+	return cfg.HoneypotField
+}
+
+// HoneypotField returns the name of the hidden registration field that only bots
+// fill in, or "" when the honeypot is disabled
+func (s *AuthService) HoneypotField() string {
+	return s.honeypotField
+}
+
+// RejectUnknownFields reports whether a field selection naming an unknown field is
+// refused rather than ignored
+func (s *AuthService) RejectUnknownFields() bool {
+	return s.rejectUnknownFields
+}
+
+// LookupInvitation returns the pending invitation for a token, or an error if it
+// is unknown, already used or expired
+func (s *AuthService) LookupInvitation(token string) (*models.Invitation, error) {
+	if token == "" {
+		return nil, ErrInvitationRequired
+	}
+
+	invitation, err := s.invitationRepo.GetByTokenHash(HashInviteToken(token))
+	if err != nil || invitation.UsedAt != nil {
+		return nil, ErrInvalidInvitation
+	}
+
+	if timeutil.Now().After(invitation.ExpiresAt) {
+		return nil, ErrInvitationExpired
+	}
+
+	return invitation, nil
 }
 
 // Register creates a new user account
 func (s *AuthService) Register(req models.RegisterRequest) (*models.User, error) {
+	// Checked first so the answer cannot reveal whether the email is taken
+	if s.requireTerms && !req.AcceptTerms {
+		return nil, ErrTermsNotAccepted
+	}
+	if err := s.ValidatePassword(req.Password); err != nil {
+		return nil, err
+	}
+	if err := s.disposableEmails.check(req.Email); err != nil {
+		return nil, err
+	}
+
+	// In invite-only mode the invitation must be valid and addressed to this email.
+	// This comes before the email is looked up, so an uninvited sign-up is refused
+	// the same way whether or not the email is taken.
+	var invitation *models.Invitation
+	var err error
+	if s.inviteOnly {
+		invitation, err = s.LookupInvitation(req.InviteToken)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(invitation.Email, req.Email) {
+			return nil, ErrInvitationEmailMismatch
+		}
+	}
+
 	// Check if user already exists
-	existingUser, _ := s.userRepo.GetByEmail(req.Email)
-	if existingUser != nil {
+	exists, err := s.userRepo.ExistsByEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		if s.enumerationSafe {
+			// Spend the same hashing time as a real sign-up and tell the owner instead
+			s.HashPassword(req.Password)
+			if existingUser, err := s.userRepo.GetByEmail(req.Email); err == nil {
+				go s.sendExistingAccountNotice(existingUser)
+			}
+		}
 		return nil, ErrUserExists
 	}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
+	if err := s.releaseDeletedEmail(req.Email); err != nil {
+		if err == ErrUserExists {
+			// Enumeration-safe mode answers as for a taken email, and takes as long
+			s.HashPassword(req.Password)
+		}
 		return nil, err
 	}
 
 	// Create user
 	user := &models.User{
 		Email:     req.Email,
-		Password:  string(hashedPassword),
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
 		IsActive:  true,
 	}
 
-	return s.userRepo.Create(user)
+	if err := s.setPassword(user, req.Password); err != nil {
+		return nil, err
+	}
+	if req.AcceptTerms {
+		s.recordTermsAcceptance(user)
+	}
+
+	if invitation == nil {
+		user.VerificationRequired = s.requireVerified
+		// Addresses on a trusted domain skip the verification email
+		if len(s.autoVerifyDomains) > 0 && emailDomainAllowed(user.Email, s.autoVerifyDomains) {
+			user.IsVerified = true
+		}
+		user, err := s.userRepo.Create(user)
+		if err == nil {
+			s.welcome.send(user)
+			if s.VerificationPending(user) {
+				s.sendVerificationEmail(user)
+			}
+		}
+		return user, err
+	}
+
+	// The invite was delivered to this address, so it counts as verified
+	user.IsVerified = true
+	user.Role = invitation.Role
+
+	user, err = s.invitationRepo.RedeemWithUser(invitation, user)
+	if err == repository.ErrInvitationUsed {
+		return nil, ErrInvalidInvitation
+	}
+	if err == nil {
+		s.welcome.send(user)
+	}
+	return user, err
+}
+
+func (s *AuthService) sendExistingAccountNotice(user *models.User) {
+	body := fmt.Sprintf(`Hi %s,
+
+Someone tried to create a new account with this email address, but you already have one.
+
+If this was you, sign in with your existing password or linked Google or GitHub account.
+
+If this was not you, you can ignore this email. Your account has not been changed.
+`, user.FirstName)
+
+	if err := s.mailer.Send(user.Email, "You already have an account", body); err != nil {
+		log.Printf("Failed to send existing account notice to %s: %v", user.Email, err)
+	}
 }
 
-// Login authenticates a user and returns a JWT token
-func (s *AuthService) Login(req models.LoginRequest) (string, *models.User, error) {
+// Login authenticates a user and returns a JWT token. Failed attempts count
+// against both the account and clientIP; either limit returns ErrLoginThrottled.
+// Every failure, throttled or not, is recorded as a failed sign-in event.
+func (s *AuthService) Login(req models.LoginRequest, clientIP, userAgent string) (string, *models.User, error) {
+	event := models.FailedLoginEvent{
+		Email:     req.Email,
+		IPAddress: clientIP,
+		UserAgent: userAgent,
+	}
+
+	if s.LoginRetryAfter(req.Email, clientIP) > 0 {
+		s.reportFailedLogin(event, models.FailedLoginThrottled)
+		return "", nil, ErrLoginThrottled
+	}
+
 	// Get user by email
 	user, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
+		s.recordFailedLogin(req.Email, clientIP)
+		s.reportFailedLogin(event, models.FailedLoginUnknownEmail)
 		return "", nil, ErrInvalidCredentials
 	}
+	event.UserID = user.ID
 
 	// Check password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
-	if err != nil {
+	if err := s.checkPassword(user, req.Password); err != nil {
+		s.recordFailedLogin(req.Email, clientIP)
+		s.reportFailedLogin(event, models.FailedLoginInvalidPassword)
 		return "", nil, ErrInvalidCredentials
 	}
+	s.accountThrottle.reset(normalizeLoginEmail(req.Email))
 
-	// Update last login
-	now := time.Now()
-	user.LastLoginAt = &now
-	s.userRepo.Update(user)
+	if s.VerificationPending(user) {
+		s.reportFailedLogin(event, models.FailedLoginEmailNotVerified)
+		return "", nil, ErrEmailNotVerified
+	}
+
+	// Scheduled accounts are returned so the caller can say when access begins.
+	// The password was correct, so this reveals nothing to a guesser.
+	if user.NotYetActive(timeutil.Now()) {
+		s.reportFailedLogin(event, models.FailedLoginNotYetActive)
+		return "", user, ErrAccountNotYetActive
+	}
+
+	// An expired password must be changed before a session is issued
+	if s.PasswordExpired(user) {
+		s.reportFailedLogin(event, models.FailedLoginPasswordExpired)
+		return "", nil, ErrPasswordExpired
+	}
+
+	// Hashes created before the pepper was configured are upgraded on the next login
+	var rehashed string
+	if len(s.passwordPepper) > 0 && !user.PasswordPeppered {
+		if hashedPassword, err := s.HashPassword(req.Password); err == nil {
+			rehashed = hashedPassword
+		}
+	}
+
+	// Update last login; failing to is not worth failing the sign-in over
+	if updated, err := s.saveLogin(user, rehashed); err != nil {
+		log.Printf("Failed to record sign-in for user %d: %v", user.ID, err)
+	} else {
+		user = updated
+	}
 
 	// Generate JWT token
 	token, err := s.GenerateJWT(user)
@@ -97,15 +395,211 @@ func (s *AuthService) Login(req models.LoginRequest) (string, *models.User, erro
 	return token, user, nil
 }
 
-// GenerateJWT creates a JWT token for the user
+// saveLogin stores the sign-in time on user, and rehashed as its password when
+// set. If the user was changed by another request since it was read, it is
+// reloaded and the changes applied once more, so they are not lost. The new hash
+// is then only kept if the password itself was not changed meanwhile.
+func (s *AuthService) saveLogin(user *models.User, rehashed string) (*models.User, error) {
+	checkedPassword := user.Password
+	apply := func(user *models.User) {
+		now := timeutil.Now()
+		user.LastLoginAt = &now
+		if rehashed != "" && user.Password == checkedPassword {
+			user.Password = rehashed
+			user.PasswordPeppered = true
+		}
+	}
+
+	apply(user)
+	updated, err := s.userRepo.Update(user)
+	if err != repository.ErrStaleUpdate {
+		return updated, err
+	}
+
+	reloaded, err := s.userRepo.GetByID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	apply(reloaded)
+	return s.userRepo.Update(reloaded)
+}
+
+// reportFailedLogin hands a failed sign-in to the audit service. It is separate
+// from the throttle, so events are recorded even when throttling is disabled.
+func (s *AuthService) reportFailedLogin(event models.FailedLoginEvent, reason string) {
+	if s.audit == nil {
+		return
+	}
+	event.Reason = reason
+	event.Time = timeutil.Now()
+	s.audit.RecordFailedLogin(event)
+}
+
+// PasswordExpired reports whether the user's local password is older than the
+// configured maximum age. OAuth-only accounts have no local password and never expire.
+func (s *AuthService) PasswordExpired(user *models.User) bool {
+	if s.passwordMaxAge <= 0 || user.Password == "" {
+		return false
+	}
+
+	// Accounts created before the change timestamp existed age from sign-up
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+
+	return timeutil.Now().Sub(changedAt) > s.passwordMaxAge
+}
+
+// ChangePassword verifies the current password, stores the new one and returns a
+// fresh session token. It is the way out of an expired password, so it does not
+// require an existing session; wrong current passwords are throttled and recorded
+// as failed sign-ins like in Login.
+func (s *AuthService) ChangePassword(req models.ChangePasswordRequest, clientIP, userAgent string) (string, *models.User, error) {
+	event := models.FailedLoginEvent{
+		Email:     req.Email,
+		IPAddress: clientIP,
+		UserAgent: userAgent,
+	}
+
+	if s.LoginRetryAfter(req.Email, clientIP) > 0 {
+		s.reportFailedLogin(event, models.FailedLoginThrottled)
+		return "", nil, ErrLoginThrottled
+	}
+
+	user, err := s.userRepo.GetByEmail(req.Email)
+	if err != nil || user.Password == "" {
+		s.recordFailedLogin(req.Email, clientIP)
+		s.reportFailedLogin(event, models.FailedLoginUnknownEmail)
+		return "", nil, ErrInvalidCredentials
+	}
+	event.UserID = user.ID
+
+	if err := s.checkPassword(user, req.CurrentPassword); err != nil {
+		s.recordFailedLogin(req.Email, clientIP)
+		s.reportFailedLogin(event, models.FailedLoginInvalidPassword)
+		return "", nil, ErrInvalidCredentials
+	}
+	s.accountThrottle.reset(normalizeLoginEmail(req.Email))
+
+	if s.VerificationPending(user) {
+		return "", nil, ErrEmailNotVerified
+	}
+
+	if req.NewPassword == req.CurrentPassword {
+		return "", nil, ErrPasswordUnchanged
+	}
+
+	if err := s.ValidatePassword(req.NewPassword); err != nil {
+		return "", nil, err
+	}
+
+	if err := s.setPassword(user, req.NewPassword); err != nil {
+		return "", nil, err
+	}
+
+	user.LastLoginAt = user.PasswordChangedAt
+	user.TokenVersion++
+
+	user, err = s.userRepo.Update(user)
+	if err != nil {
+		return "", nil, err
+	}
+	s.invalidateTokens(user, "")
+
+	token, err := s.GenerateJWT(user)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, user, nil
+}
+
+// Ways a user can present credentials, recorded in the auth_provider claim
+const (
+	AuthProviderPassword = "password"
+	AuthProviderGoogle   = "google"
+	AuthProviderGitHub   = "github"
+)
+
+// GenerateJWT creates a JWT token for a user who signed in with their password
 func (s *AuthService) GenerateJWT(user *models.User) (string, error) {
+	return s.GenerateProviderJWT(user, AuthProviderPassword)
+}
+
+// GenerateProviderJWT creates a JWT token for a user who signed in through provider
+func (s *AuthService) GenerateProviderJWT(user *models.User, provider string) (string, error) {
+	now := time.Now()
+	return s.signJWT(user, provider, now, now.Add(s.tokenLifetime(user.Role, user.IsAdmin)))
+}
+
+// tokenLifetime returns how long a session token for the role is valid: the
+// SESSION_TTL_* setting for the role, or TokenLifetime when it is unset. The admin
+// flag counts as the admin role.
+func (s *AuthService) tokenLifetime(role string, isAdmin bool) time.Duration {
+	if isAdmin {
+		role = "admin"
+	}
+	if ttl := s.sessionTTLs[role]; ttl > 0 {
+		return ttl
+	}
+	return TokenLifetime
+}
+
+// RefreshJWT issues a replacement for a valid session token that expires at
+// expiresAt instead. The credentials are not presented again, so auth_time and
+// auth_provider carry over; the account snapshot is reloaded.
+func (s *AuthService) RefreshJWT(claims *models.JWTClaims, expiresAt time.Time) (string, error) {
+	user, err := s.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return "", ErrUserNotFound
+	}
+	if !user.IsActive {
+		return "", ErrInvalidToken
+	}
+
+	return s.signJWT(user, claims.AuthProvider, claims.AuthTime, expiresAt)
+}
+
+// signJWT creates a session token for user whose credentials were presented
+// through provider at authTime
+func (s *AuthService) signJWT(user *models.User, provider string, authTime, expiresAt time.Time) (string, error) {
+	// No sign-in path may issue a session before scheduled access starts
+	if user.NotYetActive(timeutil.Now()) {
+		return "", ErrAccountNotYetActive
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"email":   user.Email,
-		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
-		"iat":     time.Now().Unix(),
+		"jti":           hex.EncodeToString(jti),
+		"user_id":       user.ID,
+		"email":         user.Email,
+		"exp":           expiresAt.Unix(),
+		"iat":           now.Unix(),
+		"nbf":           now.Unix(),      // checked by the parser, like exp
+		"auth_time":     authTime.Unix(), // when the user last presented credentials
+		"auth_provider": provider,
+		"token_version": user.TokenVersion,
+
+		// Snapshot of account state so read-only routes can skip the database lookup
+		"role":        user.Role,
+		"is_admin":    user.IsAdmin,
+		"is_active":   user.IsActive,
+		"is_verified": user.IsVerified,
+	}
+	// Single-tenant deployments never set an organization, and their tokens stay as before
+	if user.OrganizationID != nil {
+		claims["org_id"] = *user.OrganizationID
 	}
 
+	if s.signingKeys.Enabled() {
+		return s.signingKeys.Sign(claims)
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(s.jwtSecret)
 }
@@ -113,6 +607,9 @@ func (s *AuthService) GenerateJWT(user *models.User) (string, error) {
 // ValidateJWT validates a JWT token and returns the user ID
 func (s *AuthService) ValidateJWT(tokenString string) (*models.JWTClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if s.signingKeys.Enabled() {
+			return s.signingKeys.VerificationKey(token)
+		}
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, ErrInvalidToken
 		}
@@ -134,20 +631,250 @@ func (s *AuthService) ValidateJWT(tokenString string) (*models.JWTClaims, error)
 			return nil, ErrInvalidToken
 		}
 
+		// Revoked tokens are rejected on every path, with or without a DB refresh
+		jti, _ := claims["jti"].(string)
+		if jti != "" && revokedTokens.contains(jti) {
+			return nil, ErrInvalidToken
+		}
+
+		// Tokens issued before auth_time existed fall back to iat
+		authTime, ok := claims["auth_time"].(float64)
+		if !ok {
+			authTime, _ = claims["iat"].(float64)
+		}
+		expiresAt, _ := claims["exp"].(float64)
+		authProvider, _ := claims["auth_provider"].(string)
+
+		// Tokens issued before the user's last password change are no longer valid
+		tokenVersion, _ := claims["token_version"].(float64)
+		if minTokenVersions.stale(uint(userID), uint(tokenVersion)) {
+			return nil, ErrInvalidToken
+		}
+
+		// Tokens issued before the account snapshot existed carry no flags;
+		// HasSnapshot lets the middleware fall back to a database lookup for them
+		role, _ := claims["role"].(string)
+		isAdmin, _ := claims["is_admin"].(bool)
+		isActive, hasSnapshot := claims["is_active"].(bool)
+		isVerified, _ := claims["is_verified"].(bool)
+
+		var orgID *uint
+		if value, ok := claims["org_id"].(float64); ok {
+			id := uint(value)
+			orgID = &id
+		}
+
 		return &models.JWTClaims{
-			UserID: uint(userID),
-			Email:  email,
+			ID:           jti,
+			UserID:       uint(userID),
+			Email:        email,
+			AuthTime:     time.Unix(int64(authTime), 0),
+			ExpiresAt:    time.Unix(int64(expiresAt), 0),
+			AuthProvider: authProvider,
+			TokenVersion: uint(tokenVersion),
+			Role:         role,
+			IsAdmin:      isAdmin,
+			IsActive:     isActive,
+			IsVerified:   isVerified,
+			OrgID:        orgID,
+			HasSnapshot:  hasSnapshot,
 		}, nil
 	}
 
 	return nil, ErrInvalidToken
 }
 
+// RevokeJWT adds a token to the denylist so it is rejected until it expires
+func (s *AuthService) RevokeJWT(tokenString string) error {
+	claims, err := s.ValidateJWT(tokenString)
+	if err != nil {
+		return err
+	}
+	if claims.ID == "" {
+		return ErrInvalidToken
+	}
+
+	revokedTokens.add(claims.ID, claims.ExpiresAt)
+	return s.sessionRepo.RevokeByTokenID(claims.ID, timeutil.Now())
+}
+
+// UserFromClaims builds a user from the account snapshot embedded in the token,
+// without a database round-trip. Only identity and access fields are populated.
+func (s *AuthService) UserFromClaims(claims *models.JWTClaims) *models.User {
+	user := &models.User{
+		Email:      claims.Email,
+		Role:       claims.Role,
+		IsAdmin:    claims.IsAdmin,
+		IsActive:   claims.IsActive,
+		IsVerified: claims.IsVerified,
+
+		OrganizationID: claims.OrgID,
+	}
+	user.ID = claims.UserID
+	return user
+}
+
+// RequireRecentAuth returns ErrReauthRequired if the credentials behind a session
+// were presented longer ago than the configured re-authentication window
+func (s *AuthService) RequireRecentAuth(claims *models.JWTClaims) error {
+	if claims == nil || time.Since(claims.AuthTime) > s.reauthWindow {
+		return ErrReauthRequired
+	}
+	return nil
+}
+
+// Reauthenticate verifies the user's password again and issues a token with a fresh auth time
+func (s *AuthService) Reauthenticate(userID uint, password string) (string, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return "", ErrUserNotFound
+	}
+
+	if err := s.checkCurrentPassword(user, password); err != nil {
+		return "", err
+	}
+
+	return s.GenerateJWT(user)
+}
+
+// checkCurrentPassword verifies a signed-in user's password for re-authentication.
+// Wrong guesses count against the user, so step-up endpoints cannot be used to
+// brute-force a password from a stolen session. OAuth-only accounts return
+// ErrNoLocalPassword.
+func (s *AuthService) checkCurrentPassword(user *models.User, password string) error {
+	if user.Password == "" {
+		return ErrNoLocalPassword
+	}
+
+	key := strconv.FormatUint(uint64(user.ID), 10)
+	now := time.Now()
+	if s.passwordThrottle.retryAfter(key, now) > 0 {
+		return ErrPasswordCheckThrottled
+	}
+
+	if err := s.checkPassword(user, password); err != nil {
+		s.passwordThrottle.fail(key, now)
+		return ErrInvalidCredentials
+	}
+	s.passwordThrottle.reset(key)
+	return nil
+}
+
+// PasswordCheckRetryAfter returns how long the user must wait before submitting
+// their password to re-authenticate again, or zero if they may try now
+func (s *AuthService) PasswordCheckRetryAfter(userID uint) time.Duration {
+	return s.passwordThrottle.retryAfter(strconv.FormatUint(uint64(userID), 10), time.Now())
+}
+
+// PasswordRecoveryAvailable reports whether the session may reset its password
+// through RecoverPassword
+func (s *AuthService) PasswordRecoveryAvailable(claims *models.JWTClaims) bool {
+	_, err := s.passwordRecoveryUser(claims)
+	return err == nil
+}
+
+// RecoverPassword sets a new password without asking for the old one, for a user who
+// has just signed in through a provider linked to their account. The provider sign-in
+// must be within the re-authentication window. It returns a fresh session token.
+func (s *AuthService) RecoverPassword(claims *models.JWTClaims, newPassword string) (string, *models.User, error) {
+	user, err := s.passwordRecoveryUser(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := s.ValidatePassword(newPassword); err != nil {
+		return "", nil, err
+	}
+
+	if err := s.setPassword(user, newPassword); err != nil {
+		return "", nil, err
+	}
+	user.TokenVersion++
+
+	user, err = s.userRepo.Update(user)
+	if err != nil {
+		return "", nil, err
+	}
+	s.invalidateTokens(user, claims.ID)
+
+	token, err := s.GenerateProviderJWT(user, claims.AuthProvider)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, user, nil
+}
+
+// invalidateTokens rejects every token issued to user before their token version was
+// bumped and marks their sessions and refresh tokens revoked, except the session
+// behind keepTokenID, which the caller moves to a fresh token
+func (s *AuthService) invalidateTokens(user *models.User, keepTokenID string) {
+	minTokenVersions.set(user.ID, user.TokenVersion)
+
+	now := timeutil.Now()
+	if err := s.refreshRepo.RevokeByUser(user.ID, now); err != nil {
+		log.Printf("Failed to revoke refresh tokens for user %d: %v", user.ID, err)
+	}
+
+	sessions, err := s.sessionRepo.ListActiveByUser(user.ID, now)
+	if err != nil {
+		log.Printf("Failed to list sessions for user %d: %v", user.ID, err)
+		return
+	}
+
+	var ids []uint
+	for _, session := range sessions {
+		if session.TokenID != keepTokenID {
+			ids = append(ids, session.ID)
+		}
+	}
+	if err := s.sessionRepo.Revoke(ids, now); err != nil {
+		log.Printf("Failed to revoke sessions for user %d: %v", user.ID, err)
+	}
+}
+
+// passwordRecoveryUser loads the account behind claims if it may recover its password:
+// the session comes from a recent provider sign-in, that provider is still linked to
+// this account, and the account has a local password to replace
+func (s *AuthService) passwordRecoveryUser(claims *models.JWTClaims) (*models.User, error) {
+	if s.RequireRecentAuth(claims) != nil {
+		return nil, ErrProviderReauthRequired
+	}
+	if claims.AuthProvider != AuthProviderGoogle && claims.AuthProvider != AuthProviderGitHub {
+		return nil, ErrProviderReauthRequired
+	}
+
+	user, err := s.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if !user.HasLinkedProvider(claims.AuthProvider) {
+		return nil, ErrProviderReauthRequired
+	}
+
+	if user.Password == "" {
+		return nil, ErrNoLocalPassword
+	}
+
+	return user, nil
+}
+
 // GetUserByID retrieves a user by ID
 func (s *AuthService) GetUserByID(id uint) (*models.User, error) {
 	return s.userRepo.GetByID(id)
 }
 
+// ExportUserData returns a copy of everything stored about the user
+func (s *AuthService) ExportUserData(userID uint) (*models.UserDataExport, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	return buildUserExport(user)
+}
+
 // UpdateProfile updates user profile information
 func (s *AuthService) UpdateProfile(userID uint, req models.UpdateProfileRequest) (*models.User, error) {
 	user, err := s.userRepo.GetByID(userID)
@@ -155,22 +882,117 @@ func (s *AuthService) UpdateProfile(userID uint, req models.UpdateProfileRequest
 		return nil, ErrUserNotFound
 	}
 
+	website, err := normalizeWebsite(req.Website)
+	if err != nil {
+		return nil, err
+	}
+
 	user.FirstName = req.FirstName
 	user.LastName = req.LastName
 	user.Bio = stringPtrAuth(req.Bio)
-	user.Website = stringPtrAuth(req.Website)
+	user.Website = stringPtrAuth(website)
 	user.Location = stringPtrAuth(req.Location)
+	if req.ProfilePublic != nil {
+		user.ProfilePublic = *req.ProfilePublic
+	}
 
 	return s.userRepo.Update(user)
 }
 
-// HashPassword hashes a plain text password
+// AddMissingEmail replaces the placeholder address of an account created through
+// OAuth without an email. The new address is unverified until its link is followed.
+func (s *AuthService) AddMissingEmail(userID uint, email string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if !user.EmailMissing {
+		return nil, ErrEmailAlreadySet
+	}
+
+	if err := s.disposableEmails.check(email); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.userRepo.ExistsByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrUserExists
+	}
+	if err := s.releaseDeletedEmail(email); err != nil {
+		return nil, err
+	}
+
+	user.Email = email
+	user.EmailMissing = false
+	user.IsVerified = false
+
+	user, err = s.userRepo.Update(user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.sendVerificationEmail(user)
+	return user, nil
+}
+
+// HashPassword hashes a plain text password, applying the pepper when one is configured
 func (s *AuthService) HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword(s.pepperPassword(password), bcrypt.DefaultCost)
 	return string(hashedPassword), err
 }
 
-// VerifyPassword checks if a password matches the hashed password
+// VerifyPassword checks if a password matches a hash created by HashPassword
 func (s *AuthService) VerifyPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), s.pepperPassword(password))
+}
+
+// pepperPassword returns the bytes handed to bcrypt: the password itself, or its
+// HMAC-SHA256 keyed with the pepper. The HMAC is base64-encoded so it stays well
+// under bcrypt's 72-byte input limit and contains no NUL bytes.
+func (s *AuthService) pepperPassword(password string) []byte {
+	if len(s.passwordPepper) == 0 {
+		return []byte(password)
+	}
+
+	mac := hmac.New(sha256.New, s.passwordPepper)
+	mac.Write([]byte(password))
+	return []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// setPassword hashes and stores a new local password on the user, recording
+// whether it was peppered and when it was set. The caller saves the user.
+func (s *AuthService) setPassword(user *models.User, password string) error {
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	now := timeutil.Now()
+	user.Password = hashedPassword
+	user.PasswordPeppered = len(s.passwordPepper) > 0
+	user.PasswordChangedAt = &now
+	return nil
+}
+
+// checkPassword verifies a password against the user's stored hash. Hashes from
+// before the pepper was introduced are checked against the raw password.
+func (s *AuthService) checkPassword(user *models.User, password string) error {
+	if user.Password == "" {
+		return ErrInvalidCredentials
+	}
+
+	if !user.PasswordPeppered {
+		return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	}
+
+	// A peppered hash cannot be verified once the pepper has been removed
+	if len(s.passwordPepper) == 0 {
+		return ErrInvalidCredentials
+	}
+
+	return s.VerifyPassword(user.Password, password)
 }
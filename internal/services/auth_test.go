@@ -0,0 +1,555 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+func TestRegisterEnumerationSafe(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		email   string
+		wantErr error
+	}{
+		{"taken email", nil, "taken@example.com", ErrUserExists},
+		{"deleted email", nil, "deleted@example.com", ErrUserExists},
+		{"new email", nil, "new@example.com", nil},
+		{"taken email, invite only", map[string]string{"INVITE_ONLY": "true"}, "taken@example.com", ErrInvitationRequired},
+		{"deleted email, invite only", map[string]string{"INVITE_ONLY": "true"}, "deleted@example.com", ErrInvitationRequired},
+		{"new email, invite only", map[string]string{"INVITE_ONLY": "true"}, "new@example.com", ErrInvitationRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"ENUMERATION_SAFE_SIGNUP": "true"}
+			for key, value := range tt.env {
+				env[key] = value
+			}
+			cfg := setupTestDB(t, env)
+			authService := newTestAuthService(t, cfg)
+			createTestUser(t, authService, "taken@example.com", "Passw0rd!x")
+			deleted := createTestUser(t, authService, "deleted@example.com", "Passw0rd!x")
+			if err := repository.NewUserRepository().Delete(deleted.ID); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+
+			_, err := authService.Register(models.RegisterRequest{
+				Email:     tt.email,
+				Password:  "An0ther-Passw0rd",
+				FirstName: "New",
+				LastName:  "User",
+			})
+			if err != tt.wantErr {
+				t.Errorf("Register error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReauthenticateRefreshesAuthTime(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	authService := newTestAuthService(t, cfg)
+	user := createTestUser(t, authService, "reauth@example.com", "Passw0rd!x")
+
+	token, err := authService.GenerateJWT(user)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	claims, err := authService.ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("ValidateJWT: %v", err)
+	}
+	claims.AuthTime = time.Now().Add(-cfg.ReauthWindow - time.Minute)
+	stale, err := authService.RefreshJWT(claims, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("RefreshJWT: %v", err)
+	}
+	if claims, _ = authService.ValidateJWT(stale); authService.RequireRecentAuth(claims) != ErrReauthRequired {
+		t.Fatal("stale session passed RequireRecentAuth")
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{"wrong password", "wrong-passw0rd", ErrInvalidCredentials},
+		{"right password", "Passw0rd!x", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fresh, err := authService.Reauthenticate(user.ID, tt.password)
+			if err != tt.wantErr {
+				t.Fatalf("Reauthenticate error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			claims, err := authService.ValidateJWT(fresh)
+			if err != nil {
+				t.Fatalf("ValidateJWT: %v", err)
+			}
+			if err := authService.RequireRecentAuth(claims); err != nil {
+				t.Errorf("RequireRecentAuth after re-authenticating = %v", err)
+			}
+		})
+	}
+}
+
+func TestLoginPasswordExpiry(t *testing.T) {
+	daysAgo := func(days int) *time.Time {
+		at := time.Now().AddDate(0, 0, -days)
+		return &at
+	}
+
+	tests := []struct {
+		name      string
+		maxAge    string
+		changedAt *time.Time
+		createdAt *time.Time
+		wantErr   error
+	}{
+		{"fresh password", "90", daysAgo(10), nil, nil},
+		{"expired password", "90", daysAgo(100), nil, ErrPasswordExpired},
+		{"old account, never changed", "90", nil, daysAgo(100), ErrPasswordExpired},
+		{"policy off", "0", daysAgo(1000), nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, map[string]string{"PASSWORD_MAX_AGE_DAYS": tt.maxAge})
+			authService := newTestAuthService(t, cfg)
+			user := createTestUser(t, authService, "aging@example.com", "Passw0rd!x")
+
+			updates := map[string]interface{}{"password_changed_at": tt.changedAt}
+			if tt.createdAt != nil {
+				updates["created_at"] = *tt.createdAt
+			}
+			if err := repository.GetDB().Model(&models.User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+				t.Fatalf("age password: %v", err)
+			}
+
+			login := models.LoginRequest{Email: user.Email, Password: "Passw0rd!x"}
+			token, _, err := authService.Login(login, "10.0.0.1", "test")
+			if err != tt.wantErr {
+				t.Fatalf("Login error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			if token != "" {
+				t.Error("Login issued a session for an expired password")
+			}
+
+			// Changing the password is the way through
+			if _, _, err := authService.ChangePassword(models.ChangePasswordRequest{
+				Email:           user.Email,
+				CurrentPassword: "Passw0rd!x",
+				NewPassword:     "An0ther-Passw0rd",
+			}, "10.0.0.1", "test"); err != nil {
+				t.Fatalf("ChangePassword: %v", err)
+			}
+			login.Password = "An0ther-Passw0rd"
+			if _, _, err := authService.Login(login, "10.0.0.1", "test"); err != nil {
+				t.Errorf("Login after changing the password: %v", err)
+			}
+		})
+	}
+
+	t.Run("oauth account", func(t *testing.T) {
+		cfg := setupTestDB(t, map[string]string{"PASSWORD_MAX_AGE_DAYS": "90"})
+		authService := newTestAuthService(t, cfg)
+		oauthUser := &models.User{CreatedAt: *daysAgo(1000)}
+		if authService.PasswordExpired(oauthUser) {
+			t.Error("account without a local password reported as expired")
+		}
+	})
+}
+
+func TestLoginActiveFrom(t *testing.T) {
+	tests := []struct {
+		name       string
+		activeFrom time.Duration
+		wantErr    error
+	}{
+		{"access started", -time.Hour, nil},
+		{"access starts tomorrow", 24 * time.Hour, ErrAccountNotYetActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, nil)
+			authService := newTestAuthService(t, cfg)
+			user := createTestUser(t, authService, "contractor@example.com", "Passw0rd!x")
+
+			activeFrom := time.Now().Add(tt.activeFrom)
+			user.ActiveFrom = &activeFrom
+			if _, err := repository.NewUserRepository().Update(user); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+
+			token, _, err := authService.Login(models.LoginRequest{Email: user.Email, Password: "Passw0rd!x"}, "10.0.0.1", "test")
+			if err != tt.wantErr {
+				t.Fatalf("Login error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && token == "" {
+				t.Error("Login returned no token")
+			}
+
+			// No other path may issue a session early either
+			if _, err := authService.GenerateJWT(user); err != tt.wantErr {
+				t.Errorf("GenerateJWT error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChangePasswordThrottled(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		wantErr  error
+	}{
+		{"no failures", 0, nil},
+		{"below the limit", 2, nil},
+		{"at the limit", 3, ErrLoginThrottled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, map[string]string{
+				"LOGIN_THROTTLE_ACCOUNT_ATTEMPTS": "3",
+				"LOGIN_THROTTLE_IP_ATTEMPTS":      "0",
+			})
+			authService := newTestAuthService(t, cfg)
+			createTestUser(t, authService, "change@example.com", "Passw0rd!x")
+
+			req := models.ChangePasswordRequest{
+				Email:           "change@example.com",
+				CurrentPassword: "wrong-passw0rd",
+				NewPassword:     "An0ther-Passw0rd",
+			}
+			// Each guess comes from a different address, so only the account limit applies
+			for i := 0; i < tt.failures; i++ {
+				if _, _, err := authService.ChangePassword(req, fmt.Sprintf("10.0.0.%d", i+1), "test"); err != ErrInvalidCredentials {
+					t.Fatalf("guess %d: error = %v, want ErrInvalidCredentials", i+1, err)
+				}
+			}
+
+			req.CurrentPassword = "Passw0rd!x"
+			if _, _, err := authService.ChangePassword(req, "10.0.1.1", "test"); err != tt.wantErr {
+				t.Errorf("ChangePassword error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && authService.LoginRetryAfter(req.Email, "10.0.1.1") <= 0 {
+				t.Error("LoginRetryAfter = 0 for a throttled account")
+			}
+		})
+	}
+}
+
+func TestChangePasswordReportsFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		password   string
+		wantReason string
+		wantUser   bool
+	}{
+		{"wrong current password", "change@example.com", "wrong-passw0rd", models.FailedLoginInvalidPassword, true},
+		{"unknown email", "nobody@example.com", "wrong-passw0rd", models.FailedLoginUnknownEmail, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posted := make(chan models.FailedLoginEvent, 1)
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var event models.FailedLoginEvent
+				json.NewDecoder(r.Body).Decode(&event)
+				posted <- event
+			}))
+			defer webhook.Close()
+
+			cfg := setupTestDB(t, map[string]string{"FAILED_LOGIN_WEBHOOK_URL": webhook.URL})
+			authService := newTestAuthService(t, cfg)
+			user := createTestUser(t, authService, "change@example.com", "Passw0rd!x")
+
+			_, _, err := authService.ChangePassword(models.ChangePasswordRequest{
+				Email:           tt.email,
+				CurrentPassword: tt.password,
+				NewPassword:     "An0ther-Passw0rd",
+			}, "10.0.0.1", "test-agent")
+			if err != ErrInvalidCredentials {
+				t.Fatalf("ChangePassword error = %v, want ErrInvalidCredentials", err)
+			}
+
+			var wantUserID uint
+			if tt.wantUser {
+				wantUserID = user.ID
+			}
+			entries, _, err := repository.NewAuditLogRepository().Query(models.AuditLogFilter{
+				Actions: []string{models.AuditActionLoginFailed},
+				Limit:   10,
+			})
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if len(entries) != 1 || entries[0].TargetID != wantUserID || entries[0].IPAddress != "10.0.0.1" {
+				t.Fatalf("audit entries = %+v, want one failed sign-in for user %d", entries, wantUserID)
+			}
+
+			select {
+			case event := <-posted:
+				if event.Reason != tt.wantReason || event.UserID != wantUserID || event.UserAgent != "test-agent" {
+					t.Errorf("webhook event = %+v, want reason %s for user %d", event, tt.wantReason, wantUserID)
+				}
+			case <-time.After(2 * time.Second):
+				t.Error("failed sign-in webhook was not posted")
+			}
+		})
+	}
+}
+
+func TestChangePasswordInvalidatesOldTokens(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	authService := newTestAuthService(t, cfg)
+	user := createTestUser(t, authService, "change@example.com", "Passw0rd!x")
+
+	oldToken, err := authService.GenerateJWT(user)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+
+	newToken, _, err := authService.ChangePassword(models.ChangePasswordRequest{
+		Email:           user.Email,
+		CurrentPassword: "Passw0rd!x",
+		NewPassword:     "An0ther-Passw0rd",
+	}, "10.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+
+	if _, err := authService.ValidateJWT(oldToken); err != ErrInvalidToken {
+		t.Errorf("old token: error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := authService.ValidateJWT(newToken); err != nil {
+		t.Errorf("new token: %v", err)
+	}
+
+	// The version floor is restored from the database after a restart
+	resetTokenState()
+	authService = newTestAuthService(t, cfg)
+	if _, err := authService.ValidateJWT(oldToken); err != ErrInvalidToken {
+		t.Errorf("old token after restart: error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := authService.ValidateJWT(newToken); err != nil {
+		t.Errorf("new token after restart: %v", err)
+	}
+}
+
+func TestRegisterWithInvitation(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		token   func(valid string) string
+		expired bool
+		wantErr error
+	}{
+		{"valid", "invited@example.com", nil, false, nil},
+		{"valid, email in other case", "Invited@Example.com", nil, false, nil},
+		{"expired", "invited@example.com", nil, true, ErrInvitationExpired},
+		{"other email", "someone@example.com", nil, false, ErrInvitationEmailMismatch},
+		{"unknown token", "invited@example.com", func(string) string { return "not-a-token" }, false, ErrInvalidInvitation},
+		{"no token", "invited@example.com", func(string) string { return "" }, false, ErrInvitationRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, map[string]string{"INVITE_ONLY": "true"})
+			authService := newTestAuthService(t, cfg)
+			adminService := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+			admin := createTestUser(t, authService, "admin@example.com", "Passw0rd!x")
+			admin.IsAdmin, admin.Role = true, "admin"
+
+			token, invitation, err := adminService.CreateInvitation(admin, "invited@example.com", "moderator")
+			if err != nil {
+				t.Fatalf("CreateInvitation: %v", err)
+			}
+			if tt.expired {
+				if err := repository.GetDB().Model(invitation).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+					t.Fatalf("expire invitation: %v", err)
+				}
+			}
+			if tt.token != nil {
+				token = tt.token(token)
+			}
+
+			register := func() (*models.User, error) {
+				return authService.Register(models.RegisterRequest{
+					Email:       tt.email,
+					Password:    "An0ther-Passw0rd",
+					FirstName:   "New",
+					LastName:    "User",
+					InviteToken: token,
+				})
+			}
+			user, err := register()
+			if err != tt.wantErr {
+				t.Fatalf("Register error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if exists, _ := repository.NewUserRepository().ExistsByEmailFold(tt.email); exists {
+					t.Error("refused registration created a user")
+				}
+				return
+			}
+
+			// The invite carries the role and stands in for email verification
+			if !user.IsVerified || user.Role != "moderator" {
+				t.Errorf("user verified %v with role %q, want verified with role moderator", user.IsVerified, user.Role)
+			}
+
+			// Invitations are single-use
+			if _, err := register(); err != ErrInvalidInvitation {
+				t.Errorf("second Register with the same invitation = %v, want %v", err, ErrInvalidInvitation)
+			}
+		})
+	}
+}
+
+func TestPasswordPepper(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	plain := newTestAuthService(t, cfg)
+	withPepper := func(pepper string) *AuthService {
+		peppered := *cfg
+		peppered.PasswordPepper = pepper
+		return newTestAuthService(t, &peppered)
+	}
+	peppered := withPepper("server-side-pepper")
+
+	tests := []struct {
+		name     string
+		hasher   *AuthService
+		verifier *AuthService
+		password string
+		wantOK   bool
+	}{
+		{"no pepper", plain, plain, "Passw0rd!x", true},
+		{"no pepper, wrong password", plain, plain, "wrong-passw0rd", false},
+		{"pepper", peppered, peppered, "Passw0rd!x", true},
+		{"pepper, wrong password", peppered, peppered, "wrong-passw0rd", false},
+		{"peppered hash without the pepper", peppered, plain, "Passw0rd!x", false},
+		{"peppered hash with another pepper", peppered, withPepper("other-pepper"), "Passw0rd!x", false},
+		{"plain hash with a pepper", plain, peppered, "Passw0rd!x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hasher.HashPassword("Passw0rd!x")
+			if err != nil {
+				t.Fatalf("HashPassword: %v", err)
+			}
+			if ok := tt.verifier.VerifyPassword(hash, tt.password) == nil; ok != tt.wantOK {
+				t.Errorf("VerifyPassword = %v, want %v", ok, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLoginRehashesWithPepper(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	user := createTestUser(t, newTestAuthService(t, cfg), "pepper@example.com", "Passw0rd!x")
+
+	// The pepper is introduced after the account was created
+	pepperedCfg := *cfg
+	pepperedCfg.PasswordPepper = "server-side-pepper"
+	peppered := newTestAuthService(t, &pepperedCfg)
+	login := models.LoginRequest{Email: user.Email, Password: "Passw0rd!x"}
+
+	if _, _, err := peppered.Login(login, "10.0.0.1", "test"); err != nil {
+		t.Fatalf("Login with a pre-pepper hash: %v", err)
+	}
+	stored, err := repository.NewUserRepository().GetByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if !stored.PasswordPeppered || stored.Password == user.Password {
+		t.Fatal("Login did not upgrade the hash to the pepper")
+	}
+	if err := peppered.VerifyPassword(stored.Password, "Passw0rd!x"); err != nil {
+		t.Errorf("upgraded hash does not verify with the pepper: %v", err)
+	}
+
+	if _, _, err := peppered.Login(login, "10.0.0.1", "test"); err != nil {
+		t.Errorf("Login with the upgraded hash: %v", err)
+	}
+	if _, _, err := newTestAuthService(t, cfg).Login(login, "10.0.0.1", "test"); err != ErrInvalidCredentials {
+		t.Errorf("Login without the pepper = %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestOrganizationClaim(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	authService := newTestAuthService(t, cfg)
+	admin := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+	adminUser := createTestAdmin(t, "admin@example.com")
+	user := createTestUser(t, authService, "member@example.com", "Passw0rd!x")
+
+	// orgClaim issues a token for the stored user and returns its org_id claim
+	orgClaim := func(t *testing.T) *uint {
+		t.Helper()
+		stored, err := authService.GetUserByID(user.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		token, err := authService.GenerateJWT(stored)
+		if err != nil {
+			t.Fatalf("GenerateJWT: %v", err)
+		}
+		claims, err := authService.ValidateJWT(token)
+		if err != nil {
+			t.Fatalf("ValidateJWT: %v", err)
+		}
+		fromClaims := authService.UserFromClaims(claims)
+		if (claims.OrgID == nil) != (fromClaims.OrganizationID == nil) ||
+			claims.OrgID != nil && *claims.OrgID != *fromClaims.OrganizationID {
+			t.Errorf("UserFromClaims organization = %v, want %v", fromClaims.OrganizationID, claims.OrgID)
+		}
+		return claims.OrgID
+	}
+
+	update := func(t *testing.T, orgID uint) {
+		t.Helper()
+		if _, err := admin.UpdateUser(adminUser, user.ID, models.AdminUpdateUserRequest{
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Email:     user.Email,
+			Role:      user.Role,
+			OrgID:     &orgID,
+		}); err != nil {
+			t.Fatalf("UpdateUser: %v", err)
+		}
+	}
+
+	if got := orgClaim(t); got != nil {
+		t.Errorf("org_id without an organization = %d, want none", *got)
+	}
+
+	update(t, 42)
+	if got := orgClaim(t); got == nil || *got != 42 {
+		t.Errorf("org_id = %v, want 42", got)
+	}
+
+	// 0 takes the user out of the organization again
+	update(t, 0)
+	if got := orgClaim(t); got != nil {
+		t.Errorf("org_id after clearing = %d, want none", *got)
+	}
+}
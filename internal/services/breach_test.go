@@ -0,0 +1,102 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sso-web-app/internal/models"
+)
+
+// pwnedRange serves a fake range API that knows breached as seen 42 times and
+// clean only as a padding entry, and records the paths asked for
+func pwnedRange(t *testing.T, breached, clean string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var requested []string
+	suffix := func(password string) string {
+		sum := sha1.Sum([]byte(password))
+		return strings.ToUpper(hex.EncodeToString(sum[:]))[5:]
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		fmt.Fprintf(w, "0018A45C4D1DEF81644B54AB7F969B88D65:3\r\n")
+		fmt.Fprintf(w, "%s:42\r\n", suffix(breached))
+		fmt.Fprintf(w, "%s:0\r\n", suffix(clean))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requested
+}
+
+func TestBreachChecker(t *testing.T) {
+	const breached, clean = "password123", "c0rrect-H0rse-battery"
+	server, requested := pwnedRange(t, breached, clean)
+
+	checker := newBreachChecker(true)
+	checker.rangeURL = server.URL + "/range/"
+
+	if err := checker.check(breached); err != ErrPasswordBreached {
+		t.Errorf("breached password: error = %v, want ErrPasswordBreached", err)
+	}
+	if err := checker.check(clean); err != nil {
+		t.Errorf("clean password: error = %v, want none", err)
+	}
+
+	// Only the five-character prefix of each hash leaves the process
+	for _, path := range *requested {
+		prefix := strings.TrimPrefix(path, "/range/")
+		if len(prefix) != 5 {
+			t.Errorf("requested %s, want a five-character hash prefix", path)
+		}
+	}
+
+	// A disabled checker makes no request at all
+	*requested = nil
+	checker.enabled = false
+	if err := checker.check(breached); err != nil || len(*requested) != 0 {
+		t.Errorf("disabled check = %v after %d requests, want no error and no request", err, len(*requested))
+	}
+}
+
+func TestBreachCheckerFailsOpen(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	for name, url := range map[string]string{"error status": failing.URL, "unreachable": unreachable.URL} {
+		t.Run(name, func(t *testing.T) {
+			checker := newBreachChecker(true)
+			checker.rangeURL = url + "/range/"
+			if err := checker.check("password123"); err != nil {
+				t.Errorf("check = %v, want the password allowed", err)
+			}
+		})
+	}
+}
+
+func TestRegisterRejectsBreachedPassword(t *testing.T) {
+	const breached, clean = "password123", "c0rrect-H0rse-battery"
+	server, _ := pwnedRange(t, breached, clean)
+
+	cfg := setupTestDB(t, map[string]string{"CHECK_BREACHED_PASSWORDS": "true"})
+	authService := newTestAuthService(t, cfg)
+	authService.breachChecker.rangeURL = server.URL + "/range/"
+
+	register := func(email, password string) error {
+		_, err := authService.Register(models.RegisterRequest{Email: email, Password: password, FirstName: "New", LastName: "User"})
+		return err
+	}
+	if err := register("breached@example.com", breached); err != ErrPasswordBreached {
+		t.Errorf("Register with a breached password: error = %v, want ErrPasswordBreached", err)
+	}
+	if err := register("clean@example.com", clean); err != nil {
+		t.Errorf("Register with a clean password: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/timeutil"
+)
+
+// CancelDeactivation clears a user's scheduled deactivation before it takes effect
+func (s *AdminService) CancelDeactivation(adminUser *models.User, userID uint) (*models.User, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	// Prevent modifying other admins unless super admin
+	if user.IsAdmin && adminUser.Role != "admin" {
+		return nil, ErrNotAuthorized
+	}
+
+	if user.DeactivateAt == nil {
+		return nil, ErrNoScheduledDeactivation
+	}
+
+	user.DeactivateAt = nil
+	return s.userRepo.Update(user)
+}
+
+// applyDueDeactivations deactivates every user whose scheduled deactivation has
+// come and returns how many were deactivated. Until it runs, the auth middleware
+// already refuses those users, so the interval only delays the stored flag.
+func (s *AdminService) applyDueDeactivations(now time.Time) int {
+	users, err := s.userRepo.ListDueDeactivations(now)
+	if err != nil {
+		log.Printf("Failed to load scheduled deactivations: %v", err)
+		return 0
+	}
+
+	applied := 0
+	for _, user := range users {
+		wasActiveAdmin := isActiveAdmin(user)
+		user.IsActive = false
+		user.DeactivateAt = nil
+		if _, err := s.saveUser(user, wasActiveAdmin); err != nil {
+			if err != ErrLastAdmin {
+				log.Printf("Failed to apply scheduled deactivation of user %d: %v", user.ID, err)
+				continue
+			}
+
+			// The last active admin stays active; drop the schedule so it is not retried
+			log.Printf("Scheduled deactivation of user %d skipped: they are the last active admin", user.ID)
+			if reloaded, err := s.userRepo.GetByID(user.ID); err == nil {
+				reloaded.DeactivateAt = nil
+				s.userRepo.Update(reloaded)
+			}
+			continue
+		}
+		applied++
+	}
+	return applied
+}
+
+// runDeactivations applies scheduled deactivations every interval for the life of
+// the process
+func (s *AdminService) runDeactivations(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if applied := s.applyDueDeactivations(timeutil.Now()); applied > 0 {
+			log.Printf("Applied %d scheduled deactivations", applied)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"sso-web-app/internal/repository"
+)
+
+func TestDeactivateUser(t *testing.T) {
+	setup := func(t *testing.T) (*AdminService, *AuthService) {
+		t.Helper()
+		cfg := setupTestDB(t, nil)
+		authService := newTestAuthService(t, cfg)
+		return NewAdminService(cfg, authService, NewOAuthService(cfg, authService)), authService
+	}
+
+	t.Run("immediate", func(t *testing.T) {
+		admin, authService := setup(t)
+		adminUser := createTestAdmin(t, "admin@example.com")
+		user := createTestUser(t, authService, "user@example.com", "Passw0rd!x")
+
+		// A time in the past takes effect now, like no time at all
+		for _, at := range []time.Time{{}, time.Now().Add(-time.Hour)} {
+			updated, err := admin.DeactivateUser(adminUser, user.ID, at)
+			if err != nil {
+				t.Fatalf("DeactivateUser(%v): %v", at, err)
+			}
+			if updated.IsActive || updated.DeactivateAt != nil {
+				t.Errorf("DeactivateUser(%v) = active %v, deactivate_at %v; want inactive now", at, updated.IsActive, updated.DeactivateAt)
+			}
+		}
+	})
+
+	t.Run("scheduled", func(t *testing.T) {
+		admin, authService := setup(t)
+		adminUser := createTestAdmin(t, "admin@example.com")
+		user := createTestUser(t, authService, "user@example.com", "Passw0rd!x")
+
+		at := time.Now().Add(time.Hour)
+		updated, err := admin.DeactivateUser(adminUser, user.ID, at)
+		if err != nil {
+			t.Fatalf("DeactivateUser: %v", err)
+		}
+		if !updated.IsActive || updated.DeactivateAt == nil || !updated.DeactivateAt.Equal(at) {
+			t.Fatalf("DeactivateUser = active %v, deactivate_at %v; want active until %v", updated.IsActive, updated.DeactivateAt, at)
+		}
+
+		if applied := admin.applyDueDeactivations(time.Now()); applied != 0 {
+			t.Errorf("applied %d deactivations before they were due, want 0", applied)
+		}
+		if applied := admin.applyDueDeactivations(at.Add(time.Minute)); applied != 1 {
+			t.Errorf("applied %d deactivations once due, want 1", applied)
+		}
+		stored, err := repository.NewUserRepository().GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if stored.IsActive || stored.DeactivateAt != nil {
+			t.Errorf("after the job: active %v, deactivate_at %v; want inactive with no schedule", stored.IsActive, stored.DeactivateAt)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		admin, authService := setup(t)
+		adminUser := createTestAdmin(t, "admin@example.com")
+		user := createTestUser(t, authService, "user@example.com", "Passw0rd!x")
+
+		at := time.Now().Add(time.Hour)
+		if _, err := admin.DeactivateUser(adminUser, user.ID, at); err != nil {
+			t.Fatalf("DeactivateUser: %v", err)
+		}
+		cancelled, err := admin.CancelDeactivation(adminUser, user.ID)
+		if err != nil {
+			t.Fatalf("CancelDeactivation: %v", err)
+		}
+		if !cancelled.IsActive || cancelled.DeactivateAt != nil {
+			t.Errorf("CancelDeactivation = active %v, deactivate_at %v; want active with no schedule", cancelled.IsActive, cancelled.DeactivateAt)
+		}
+
+		if applied := admin.applyDueDeactivations(at.Add(time.Minute)); applied != 0 {
+			t.Errorf("applied %d deactivations after cancelling, want 0", applied)
+		}
+		if _, err := admin.CancelDeactivation(adminUser, user.ID); err != ErrNoScheduledDeactivation {
+			t.Errorf("second CancelDeactivation: err = %v, want ErrNoScheduledDeactivation", err)
+		}
+	})
+}
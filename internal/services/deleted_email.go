@@ -0,0 +1,28 @@
+package services
+
+import (
+	"errors"
+)
+
+var ErrEmailDeleted = errors.New("email belonged to a deleted account")
+
+// releaseDeletedEmail makes email usable by a new or changed account when a
+// soft-deleted account still holds it, since deleted rows keep their place in
+// the unique email index. With ALLOW_DELETED_EMAIL_REUSE the deleted row is
+// scrubbed and gives the email up; otherwise the email stays blocked with
+// ErrEmailDeleted, or ErrUserExists in enumeration-safe mode so the answer
+// matches that for a taken email.
+func (s *AuthService) releaseDeletedEmail(email string) error {
+	held, err := s.userRepo.ExistsDeletedByEmail(email)
+	if err != nil || !held {
+		return err
+	}
+
+	if !s.reuseDeletedEmails {
+		if s.enumerationSafe {
+			return ErrUserExists
+		}
+		return ErrEmailDeleted
+	}
+	return s.userRepo.ScrubDeletedByEmail(email)
+}
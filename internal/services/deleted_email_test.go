@@ -0,0 +1,66 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+func TestRegisterWithDeletedEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr error
+	}{
+		{"reuse allowed", map[string]string{"ALLOW_DELETED_EMAIL_REUSE": "true"}, nil},
+		{"reuse blocked", map[string]string{"ALLOW_DELETED_EMAIL_REUSE": "false"}, ErrEmailDeleted},
+		{"reuse blocked, enumeration safe", map[string]string{"ALLOW_DELETED_EMAIL_REUSE": "false", "ENUMERATION_SAFE_SIGNUP": "true"}, ErrUserExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, tt.env)
+			authService := newTestAuthService(t, cfg)
+			admin := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+			adminUser := createTestAdmin(t, "admin@example.com")
+
+			old := createTestUser(t, authService, "returning@example.com", "Passw0rd!x")
+			if err := repository.GetDB().Model(old).Update("google_id", "google-returning").Error; err != nil {
+				t.Fatalf("link Google: %v", err)
+			}
+			if err := admin.DeleteUser(adminUser, old.ID); err != nil {
+				t.Fatalf("DeleteUser: %v", err)
+			}
+
+			user, err := authService.Register(models.RegisterRequest{Email: "returning@example.com", Password: "An0ther-Passw0rd", FirstName: "Back", LastName: "Again"})
+			if err != tt.wantErr {
+				t.Fatalf("Register error = %v, want %v", err, tt.wantErr)
+			}
+
+			var deleted models.User
+			if err := repository.GetDB().Unscoped().First(&deleted, old.ID).Error; err != nil {
+				t.Fatalf("load deleted row: %v", err)
+			}
+			if tt.wantErr != nil {
+				if deleted.Email != "returning@example.com" {
+					t.Errorf("blocked sign-up changed the deleted row's email to %q", deleted.Email)
+				}
+				return
+			}
+
+			if user.ID == old.ID {
+				t.Error("the new account reused the deleted row")
+			}
+			// The deleted row stays for the audit trail, without the personal data
+			if want := fmt.Sprintf("deleted-%d@deleted.invalid", old.ID); deleted.Email != want {
+				t.Errorf("deleted row email = %q, want %q", deleted.Email, want)
+			}
+			if deleted.Password != "" || deleted.FirstName != "" || deleted.GoogleID != nil {
+				t.Errorf("deleted row not scrubbed: password set %v, first name %q, google_id %v",
+					deleted.Password != "", deleted.FirstName, deleted.GoogleID)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenDenylist tracks revoked token IDs (jti) until their natural expiry.
+// It is shared by every AuthService so a revocation is seen by all middleware instances.
+type tokenDenylist struct {
+	mu      sync.RWMutex
+	entries map[string]time.Time
+}
+
+var revokedTokens = &tokenDenylist{entries: make(map[string]time.Time)}
+
+// add revokes a token ID until expiresAt, purging entries that have already expired
+func (d *tokenDenylist) add(jti string, expiresAt time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range d.entries {
+		if now.After(exp) {
+			delete(d.entries, id)
+		}
+	}
+	d.entries[jti] = expiresAt
+}
+
+// contains reports whether a token ID has been revoked
+func (d *tokenDenylist) contains(jti string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, revoked := d.entries[jti]
+	return revoked
+}
+
+// tokenVersionFloor tracks, per user, the lowest token version still accepted.
+// Bumping a user's version invalidates every token issued before the bump.
+type tokenVersionFloor struct {
+	mu       sync.RWMutex
+	versions map[uint]uint
+}
+
+var minTokenVersions = &tokenVersionFloor{versions: make(map[uint]uint)}
+
+// set raises the accepted token version for a user
+func (f *tokenVersionFloor) set(userID, version uint) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if version > f.versions[userID] {
+		f.versions[userID] = version
+	}
+}
+
+// stale reports whether a token carrying version was issued before the user's last bump
+func (f *tokenVersionFloor) stale(userID, version uint) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return version < f.versions[userID]
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
+)
+
+type DeviceService struct {
+	deviceRepo    repository.DeviceRepository
+	mailer        Mailer
+	alertsEnabled bool
+}
+
+func NewDeviceService(cfg *configs.Config, mailer Mailer) *DeviceService {
+	return &DeviceService{
+		deviceRepo:    repository.NewDeviceRepository(),
+		mailer:        mailer,
+		alertsEnabled: cfg.NewDeviceAlerts,
+	}
+}
+
+// DeviceFingerprint identifies a device by hashing its IP address and user agent
+func DeviceFingerprint(ipAddress, userAgent string) string {
+	hash := sha256.Sum256([]byte(ipAddress + "|" + userAgent))
+	return hex.EncodeToString(hash[:])
+}
+
+// RecordSignIn remembers the device a user signed in from. When the device is new
+// and the user has signed in from other devices before, a "new sign-in" email is
+// sent in the background so the login itself is never delayed. It reports whether
+// the device was new.
+func (s *DeviceService) RecordSignIn(user *models.User, ipAddress, userAgent string) bool {
+	now := timeutil.Now()
+	fingerprint := DeviceFingerprint(ipAddress, userAgent)
+
+	device, err := s.deviceRepo.GetByFingerprint(user.ID, fingerprint)
+	if err == nil {
+		device.LastSeenAt = now
+		if _, err := s.deviceRepo.Update(device); err != nil {
+			log.Printf("Failed to update device for user %d: %v", user.ID, err)
+		}
+		return false
+	}
+
+	// The very first device (usually registration) is not worth an alert
+	knownDevices, err := s.deviceRepo.CountByUser(user.ID)
+	if err != nil {
+		log.Printf("Failed to count devices for user %d: %v", user.ID, err)
+		return false
+	}
+
+	device = &models.UserDevice{
+		UserID:      user.ID,
+		Fingerprint: fingerprint,
+		IPAddress:   ipAddress,
+		UserAgent:   userAgent,
+		LastSeenAt:  now,
+	}
+	if _, err := s.deviceRepo.Create(device); err != nil {
+		log.Printf("Failed to record device for user %d: %v", user.ID, err)
+		return false
+	}
+
+	if s.alertsEnabled && knownDevices > 0 {
+		for _, email := range user.SecurityNoticeEmails() {
+			go s.sendNewDeviceAlert(email, user.FirstName, device)
+		}
+	}
+	return true
+}
+
+func (s *DeviceService) sendNewDeviceAlert(email, firstName string, device *models.UserDevice) {
+	body := fmt.Sprintf(`Hi %s,
+
+Your account was just accessed from a new device.
+
+Time:       %s
+IP address: %s
+Device:     %s
+
+If this was you, no action is needed. If not, please change your password immediately.
+`, firstName, timeutil.FormatLocal(device.LastSeenAt, "January 2, 2006 at 3:04 PM MST"), device.IPAddress, device.UserAgent)
+
+	if err := s.mailer.Send(email, "New sign-in to your account", body); err != nil {
+		log.Printf("Failed to send new device alert to %s: %v", email, err)
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// sentMail is a message handed to a mailRecorder
+type sentMail struct {
+	to, subject, body string
+}
+
+// mailRecorder is a Mailer that keeps what it is asked to send
+type mailRecorder struct {
+	sent chan sentMail
+}
+
+func newMailRecorder() *mailRecorder {
+	return &mailRecorder{sent: make(chan sentMail, 16)}
+}
+
+func (m *mailRecorder) Send(to, subject, body string) error {
+	m.sent <- sentMail{to, subject, body}
+	return nil
+}
+
+// next waits for a message sent in the background
+func (m *mailRecorder) next(t *testing.T) sentMail {
+	t.Helper()
+	select {
+	case mail := <-m.sent:
+		return mail
+	case <-time.After(2 * time.Second):
+		t.Fatal("no mail sent")
+		return sentMail{}
+	}
+}
+
+// none checks nothing has been sent
+func (m *mailRecorder) none(t *testing.T) {
+	t.Helper()
+	select {
+	case mail := <-m.sent:
+		t.Errorf("unexpected mail %q to %s", mail.subject, mail.to)
+	default:
+	}
+}
+
+func TestNewDeviceAlert(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		name := "alerts on"
+		if !enabled {
+			name = "alerts off"
+		}
+		t.Run(name, func(t *testing.T) {
+			cfg := setupTestDB(t, nil)
+			cfg.NewDeviceAlerts = enabled
+			mailer := newMailRecorder()
+			devices := NewDeviceService(cfg, mailer)
+			user := createTestUser(t, newTestAuthService(t, cfg), "device@example.com", "Passw0rd!x")
+
+			// The first device, usually registration, is not worth an alert
+			if !devices.RecordSignIn(user, "192.0.2.1", "laptop") {
+				t.Error("first device not reported as new")
+			}
+			mailer.none(t)
+
+			if devices.RecordSignIn(user, "192.0.2.1", "laptop") {
+				t.Error("known device reported as new")
+			}
+			mailer.none(t)
+
+			if !devices.RecordSignIn(user, "198.51.100.7", "phone") {
+				t.Error("new device not reported as new")
+			}
+			if !enabled {
+				mailer.none(t)
+				return
+			}
+			mail := mailer.next(t)
+			if mail.to != user.Email || mail.subject != "New sign-in to your account" {
+				t.Errorf("alert = %q to %s, want the new sign-in notice to %s", mail.subject, mail.to, user.Email)
+			}
+			if !strings.Contains(mail.body, "198.51.100.7") || !strings.Contains(mail.body, "phone") {
+				t.Errorf("alert does not name the new device:\n%s", mail.body)
+			}
+		})
+	}
+}
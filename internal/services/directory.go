@@ -0,0 +1,58 @@
+package services
+
+import (
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
+)
+
+// DirectoryService lets signed-in users look each other up. Entries carry only
+// the fields chosen by DIRECTORY_VISIBLE_FIELDS, and deactivated users are hidden.
+type DirectoryService struct {
+	userRepo repository.UserRepository
+	config   *configs.Config
+}
+
+func NewDirectoryService(cfg *configs.Config) *DirectoryService {
+	return &DirectoryService{
+		userRepo: repository.NewUserRepository(),
+		config:   cfg,
+	}
+}
+
+// Search returns one page of directory entries whose name matches query
+func (s *DirectoryService) Search(query string, page, pageSize int) (*models.PageResponse[models.PublicUserResponse], error) {
+	params := models.NewPageParams(page, pageSize, s.config.DefaultPageSize, s.config.MaxPageSize)
+
+	users, err := s.userRepo.DirectoryPage(query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.PublicUserResponse, 0, len(users.Items))
+	for _, user := range users.Items {
+		entries = append(entries, user.ToPublicResponse())
+	}
+	return models.NewPageResponse(entries, models.PageParams{Page: users.Page, PageSize: users.PageSize}, users.Total), nil
+}
+
+// GetUser returns the directory entry for an active user
+func (s *DirectoryService) GetUser(userID uint) (models.PublicUserResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil || !user.IsActive {
+		return models.PublicUserResponse{}, ErrUserNotFound
+	}
+	return user.ToPublicResponse(), nil
+}
+
+// GetPublicProfile returns the public profile of an active, verified user who has
+// opted in. Every other user, including missing ones, is reported as not found so
+// the response does not reveal whether an account exists.
+func (s *DirectoryService) GetPublicProfile(userID uint) (models.PublicProfile, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil || !user.IsActive || !user.IsVerified || !user.ProfilePublic || user.NotYetActive(timeutil.Now()) {
+		return models.PublicProfile{}, ErrUserNotFound
+	}
+	return user.ToPublicProfile(), nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+var ErrDisposableEmail = errors.New("disposable email addresses are not accepted; please use a permanent address")
+
+//go:embed disposable_domains.txt
+var builtinDisposableDomains string
+
+// disposableEmailChecker rejects addresses at throwaway email providers. A domain
+// is disposable when it, or any domain it is a subdomain of, is on the list.
+type disposableEmailChecker struct {
+	enabled bool
+	domains map[string]bool
+}
+
+// newDisposableEmailChecker loads the domain list from path, or the built-in list
+// when path is empty. The file is read once at startup; if it cannot be read the
+// built-in list is used instead, so the check is never silently off.
+func newDisposableEmailChecker(enabled bool, path string) *disposableEmailChecker {
+	checker := &disposableEmailChecker{enabled: enabled}
+	if !enabled {
+		return checker
+	}
+
+	if path != "" {
+		domains, err := readDomainFile(path)
+		if err == nil {
+			checker.domains = domains
+			return checker
+		}
+		log.Printf("Failed to load disposable email domains from %s, using the built-in list: %v", path, err)
+	}
+
+	checker.domains, _ = readDomainList(strings.NewReader(builtinDisposableDomains))
+	return checker
+}
+
+func readDomainFile(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readDomainList(file)
+}
+
+// readDomainList reads one domain per line, skipping blanks and # comments
+func readDomainList(r io.Reader) (map[string]bool, error) {
+	domains := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains[normalizeDomain(line)] = true
+	}
+	return domains, scanner.Err()
+}
+
+// normalizeDomain lowercases a domain and drops a leading @ and trailing dot
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@")), ".")
+}
+
+// check returns ErrDisposableEmail when email's domain, or a parent of it, is listed
+func (d *disposableEmailChecker) check(email string) error {
+	if !d.enabled {
+		return nil
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return nil
+	}
+
+	domain := normalizeDomain(email[at+1:])
+	for domain != "" {
+		if d.domains[domain] {
+			return ErrDisposableEmail
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sso-web-app/internal/models"
+)
+
+func TestDisposableEmailChecker(t *testing.T) {
+	builtin := newDisposableEmailChecker(true, "")
+
+	tests := []struct {
+		name  string
+		email string
+		want  error
+	}{
+		{"listed domain", "someone@mailinator.com", ErrDisposableEmail},
+		{"mixed case", "Someone@MailInator.COM", ErrDisposableEmail},
+		{"trailing dot", "someone@mailinator.com.", ErrDisposableEmail},
+		{"subdomain", "someone@inbox.eu.mailinator.com", ErrDisposableEmail},
+		{"permanent domain", "someone@example.com", nil},
+		{"listed name inside another domain", "someone@mailinator.com.example.org", nil},
+		{"listed name as a suffix", "someone@notmailinator.com", nil},
+	}
+	for _, tt := range tests {
+		if err := builtin.check(tt.email); err != tt.want {
+			t.Errorf("%s: check(%q) = %v, want %v", tt.name, tt.email, err, tt.want)
+		}
+	}
+
+	if err := newDisposableEmailChecker(false, "").check("someone@mailinator.com"); err != nil {
+		t.Errorf("disabled checker: check = %v, want nil", err)
+	}
+}
+
+func TestDisposableEmailDomainsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	if err := os.WriteFile(path, []byte("# local list\n\n@Throwaway.Example\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// The file replaces the built-in list
+	checker := newDisposableEmailChecker(true, path)
+	if err := checker.check("someone@sub.throwaway.example"); err != ErrDisposableEmail {
+		t.Errorf("domain from the file: check = %v, want ErrDisposableEmail", err)
+	}
+	if err := checker.check("someone@mailinator.com"); err != nil {
+		t.Errorf("built-in domain with a file: check = %v, want nil", err)
+	}
+
+	// A missing file falls back to the built-in list rather than allowing everything
+	missing := newDisposableEmailChecker(true, filepath.Join(t.TempDir(), "missing.txt"))
+	if err := missing.check("someone@mailinator.com"); err != ErrDisposableEmail {
+		t.Errorf("missing file: check = %v, want ErrDisposableEmail", err)
+	}
+}
+
+func TestRegisterRejectsDisposableEmail(t *testing.T) {
+	cfg := setupTestDB(t, map[string]string{"BLOCK_DISPOSABLE_EMAILS": "true"})
+	authService := newTestAuthService(t, cfg)
+
+	register := func(email string) error {
+		_, err := authService.Register(models.RegisterRequest{Email: email, Password: "An0ther-Passw0rd", FirstName: "New", LastName: "User"})
+		return err
+	}
+	if err := register("new@eu.mailinator.com"); err != ErrDisposableEmail {
+		t.Errorf("Register(disposable) = %v, want ErrDisposableEmail", err)
+	}
+	if err := register("new@example.com"); err != nil {
+		t.Errorf("Register(permanent) = %v, want nil", err)
+	}
+}
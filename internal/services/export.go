@@ -0,0 +1,89 @@
+package services
+
+import (
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
+)
+
+// buildUserExport gathers everything stored about a user for a data export.
+// Secrets stay out: the model types used here hide password hashes, token IDs and
+// device fingerprints from JSON.
+func buildUserExport(user *models.User) (*models.UserDataExport, error) {
+	export := &models.UserDataExport{
+		ExportedAt: models.NewTimestamp(timeutil.Now()),
+		Profile: models.ExportProfile{
+			UserResponse:      user.ToResponse(),
+			UpdatedAt:         models.NewTimestamp(user.UpdatedAt),
+			HasPassword:       user.Password != "",
+			PasswordChangedAt: models.NewTimestampPtr(user.PasswordChangedAt),
+			PasswordResetAt:   models.NewTimestampPtr(user.PasswordResetAt),
+		},
+		LinkedProviders: user.LinkedProviders(),
+		Roles:           []models.RoleResponse{},
+		Sessions:        []models.ExportSession{},
+		LoginHistory:    []models.LoginHistoryResponse{},
+	}
+
+	roles, err := repository.NewRoleRepository().ListByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		export.Roles = append(export.Roles, role.ToResponse())
+	}
+
+	sessions, err := repository.NewSessionRepository().ListByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, models.ExportSession{
+			SessionResponse: session.ToResponse(),
+			RevokedAt:       models.NewTimestampPtr(session.RevokedAt),
+		})
+	}
+
+	export.Devices, err = repository.NewDeviceRepository().ListByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	activity, err := repository.NewAuditLogRepository().ListByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	export.Activity = exportActivity(user.ID, activity)
+
+	history, err := repository.NewLoginHistoryRepository().ListByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range history {
+		export.LoginHistory = append(export.LoginHistory, entry.ToResponse())
+	}
+
+	return export, nil
+}
+
+// exportActivity returns copies of a user's audit entries with other users' data
+// removed. When someone else acted on the user, e.g. an admin, their ID and IP
+// address are dropped; when the user acted on someone else, that user's ID is.
+// The details of either kind may describe the other user, so they are dropped too.
+func exportActivity(userID uint, entries []*models.AuditLog) []*models.AuditLog {
+	activity := make([]*models.AuditLog, 0, len(entries))
+	for _, entry := range entries {
+		redacted := *entry
+		if redacted.ActorID != 0 && redacted.ActorID != userID {
+			redacted.ActorID = 0
+			redacted.IPAddress = ""
+			redacted.Details = ""
+		}
+		if redacted.TargetID != 0 && redacted.TargetID != userID {
+			redacted.TargetID = 0
+			redacted.Details = ""
+		}
+		activity = append(activity, &redacted)
+	}
+	return activity
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"testing"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+func TestExportActivityRedactsOtherUsers(t *testing.T) {
+	const userID, otherID = 7, 9
+
+	tests := []struct {
+		name  string
+		entry models.AuditLog
+		want  models.AuditLog
+	}{
+		{
+			"own action",
+			models.AuditLog{ActorID: userID, TargetID: userID, IPAddress: "10.0.0.1", Details: "password"},
+			models.AuditLog{ActorID: userID, TargetID: userID, IPAddress: "10.0.0.1", Details: "password"},
+		},
+		{
+			"admin acting on the user",
+			models.AuditLog{ActorID: otherID, TargetID: userID, IPAddress: "10.0.0.9", Details: "role: user -> moderator"},
+			models.AuditLog{TargetID: userID},
+		},
+		{
+			"user acting on someone else",
+			models.AuditLog{ActorID: userID, TargetID: otherID, IPAddress: "10.0.0.1", Details: "email: other@example.com"},
+			models.AuditLog{ActorID: userID, IPAddress: "10.0.0.1"},
+		},
+		{
+			"nobody signed in",
+			models.AuditLog{TargetID: userID, IPAddress: "10.0.0.5", Details: "invalid_password"},
+			models.AuditLog{TargetID: userID, IPAddress: "10.0.0.5", Details: "invalid_password"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := tt.entry
+			got := exportActivity(userID, []*models.AuditLog{&entry})
+			if len(got) != 1 || *got[0] != tt.want {
+				t.Errorf("exportActivity = %+v, want %+v", got[0], tt.want)
+			}
+			if entry != tt.entry {
+				t.Error("exportActivity modified the stored entry")
+			}
+		})
+	}
+}
+
+func TestBuildUserExportLoginHistory(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	authService := newTestAuthService(t, cfg)
+	user := createTestUser(t, authService, "export@example.com", "Passw0rd!x")
+	other := createTestUser(t, authService, "other@example.com", "Passw0rd!x")
+
+	history := repository.NewLoginHistoryRepository()
+	entries := []*models.LoginHistory{
+		{UserID: user.ID, Method: AuthProviderPassword, Outcome: models.LoginOutcomeFailure, Reason: models.FailedLoginInvalidPassword, IPAddress: "10.0.0.1"},
+		{UserID: user.ID, Method: AuthProviderGoogle, Outcome: models.LoginOutcomeSuccess, IPAddress: "10.0.0.2"},
+		{UserID: other.ID, Method: AuthProviderPassword, Outcome: models.LoginOutcomeSuccess, IPAddress: "10.0.0.9"},
+	}
+	for _, entry := range entries {
+		if err := history.Create(entry); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	export, err := buildUserExport(user)
+	if err != nil {
+		t.Fatalf("buildUserExport: %v", err)
+	}
+
+	// Newest first, and only the user's own attempts
+	want := []uint{entries[1].ID, entries[0].ID}
+	if len(export.LoginHistory) != len(want) {
+		t.Fatalf("export has %d login history entries, want %d", len(export.LoginHistory), len(want))
+	}
+	for i, id := range want {
+		if export.LoginHistory[i].ID != id {
+			t.Errorf("login_history[%d] = entry %d, want %d", i, export.LoginHistory[i].ID, id)
+		}
+	}
+	if export.LoginHistory[1].Reason != models.FailedLoginInvalidPassword {
+		t.Errorf("failure reason = %q, want %q", export.LoginHistory[1].Reason, models.FailedLoginInvalidPassword)
+	}
+}
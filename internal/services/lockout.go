@@ -0,0 +1,64 @@
+package services
+
+import (
+	"time"
+
+	"sso-web-app/internal/models"
+)
+
+// AccountLockout reports the sign-in throttle state of email: the failed
+// attempts counted in the current window, and until when password sign-in to
+// it is refused, or nil when it is not
+func (s *AuthService) AccountLockout(email string) (int, *time.Time) {
+	now := time.Now()
+	key := normalizeLoginEmail(email)
+
+	attempts := s.accountThrottle.count(key, now)
+	if wait := s.accountThrottle.retryAfter(key, now); wait > 0 {
+		until := now.Add(wait).UTC()
+		return attempts, &until
+	}
+	return attempts, nil
+}
+
+// GetLockout returns the sign-in lockout state of a user
+func (s *AdminService) GetLockout(adminUser *models.User, userID uint) (*models.LockoutResponse, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return s.lockoutResponse(user), nil
+}
+
+// ClearLockout forgets the failed sign-ins counted against a user's account, so
+// they can sign in again straight away. It returns the state before clearing;
+// clearing an account with no failed attempts changes nothing. Attempts counted
+// against the client IPs involved are left alone.
+func (s *AdminService) ClearLockout(adminUser *models.User, userID uint) (*models.LockoutResponse, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	lockout := s.lockoutResponse(user)
+	s.authService.accountThrottle.reset(normalizeLoginEmail(user.Email))
+	return lockout, nil
+}
+
+func (s *AdminService) lockoutResponse(user *models.User) *models.LockoutResponse {
+	attempts, lockedUntil := s.authService.AccountLockout(user.Email)
+	return &models.LockoutResponse{
+		UserID:         user.ID,
+		FailedAttempts: attempts,
+		Locked:         lockedUntil != nil,
+		LockedUntil:    models.NewTimestampPtr(lockedUntil),
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"strings"
+
+	"sso-web-app/configs"
+)
+
+// Mailer sends plain-text emails to users
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NewMailer returns an SMTP mailer when SMTP is configured, otherwise a mailer
+// that only logs messages, which is convenient in development
+func NewMailer(cfg *configs.Config) Mailer {
+	if cfg.SMTPHost == "" {
+		return &logMailer{}
+	}
+
+	return &smtpMailer{
+		addr:     fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort),
+		host:     cfg.SMTPHost,
+		username: cfg.SMTPUsername,
+		password: cfg.SMTPPassword,
+		from:     cfg.SMTPFrom,
+	}
+}
+
+type smtpMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	return smtp.SendMail(m.addr, auth, m.from, []string{to}, m.message(to, subject, body))
+}
+
+// message formats an email. The subject is MIME-encoded, which also keeps any
+// line breaks in it from starting new headers.
+func (m *smtpMailer) message(to, subject, body string) []byte {
+	return []byte(strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n"))
+}
+
+type logMailer struct{}
+
+func (m *logMailer) Send(to, subject, body string) error {
+	log.Printf("Email to %s: %s\n%s", to, subject, body)
+	return nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSMTPMailerMessageHeaders(t *testing.T) {
+	mailer := &smtpMailer{from: "noreply@example.com"}
+
+	tests := []struct {
+		name        string
+		subject     string
+		wantSubject string
+	}{
+		{"plain", "Scheduled maintenance", "Subject: Scheduled maintenance"},
+		{"non-ascii", "Maintenance prévue", "Subject: =?utf-8?q?Maintenance_pr=C3=A9vue?="},
+		{"header injection", "Hello\r\nBcc: victim@example.com", "Subject: =?utf-8?q?Hello=0D=0ABcc:_victim@example.com?="},
+		{"bare newline", "Hello\nBcc: victim@example.com", "Subject: =?utf-8?q?Hello=0ABcc:_victim@example.com?="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := string(mailer.message("user@example.com", tt.subject, "Body"))
+			headers, _, _ := strings.Cut(message, "\r\n\r\n")
+
+			lines := strings.Split(headers, "\r\n")
+			if len(lines) != 4 {
+				t.Fatalf("got %d header lines, want 4:\n%s", len(lines), headers)
+			}
+			if lines[2] != tt.wantSubject {
+				t.Errorf("subject header = %q, want %q", lines[2], tt.wantSubject)
+			}
+			if strings.Contains(headers, "\nBcc:") {
+				t.Error("subject injected a Bcc header")
+			}
+		})
+	}
+}
@@ -3,13 +3,20 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
-	"os"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
 	"golang.org/x/oauth2/google"
+	"sso-web-app/configs"
 	"sso-web-app/internal/models"
 	"sso-web-app/internal/repository"
 )
@@ -22,11 +29,93 @@ func stringPtr(s string) *string {
 	return &s
 }
 
+// truncateRunes shortens s to at most max characters, for provider profile values
+// that must fit a column but are not worth rejecting a sign-in over
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
+
+var ErrDomainNotAllowed = errors.New("email domain not allowed for this provider")
+var ErrProviderTimeout = errors.New("OAuth provider did not respond in time")
+var ErrProviderUnavailable = errors.New("OAuth provider is temporarily unavailable")
+var ErrRedirectHostNotAllowed = errors.New("OAuth redirect URL host is not in the allowed list")
+var ErrProviderEmailMissing = errors.New("OAuth provider did not share an email address")
+var ErrProviderNotConfigured = errors.New("OAuth provider is not configured")
+
+// parseDomainList splits a comma-separated list of email domains, ignoring blanks
+func parseDomainList(value string) []string {
+	var domains []string
+	for _, domain := range strings.Split(value, ",") {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// emailDomainAllowed reports whether email belongs to one of the allowed domains.
+// An empty allowlist allows every domain.
+func emailDomainAllowed(email string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+
+	domain := strings.ToLower(email[at+1:])
+	for _, allowedDomain := range allowed {
+		if domain == allowedDomain {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectURLAllowed reports whether redirectURL is an absolute http(s) URL whose host
+// is in the allowlist. Entries match either the bare hostname or host:port.
+// An empty allowlist allows every host.
+func redirectURLAllowed(redirectURL string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	u, err := url.Parse(redirectURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+
+	host := strings.ToLower(u.Host)
+	hostname := strings.ToLower(u.Hostname())
+	for _, allowedHost := range allowed {
+		if host == allowedHost || hostname == allowedHost {
+			return true
+		}
+	}
+	return false
+}
+
 type OAuthService struct {
-	userRepo     repository.UserRepository
-	authService  *AuthService
-	googleConfig *oauth2.Config
-	githubConfig *oauth2.Config
+	userRepo             repository.UserRepository
+	authService          *AuthService
+	googleConfig         *oauth2.Config
+	githubConfig         *oauth2.Config
+	googleAllowedDomains []string
+	githubAllowedDomains []string
+	allowedRedirectHosts []string
+	missingEmail         string
+	breaker              *providerBreaker // Pauses sign-ins with a provider that keeps failing
+	confirmLinks         bool             // REQUIRE_LINK_CONFIRMATION
+	httpClient           *http.Client     // Shared by every provider call; carries the timeout
+	states               *oauthStateStore
+	tokenRepo            repository.OAuthTokenRepository
+	tokenCipher          *tokenCipher // nil unless provider tokens are stored
 }
 
 type GoogleUser struct {
@@ -49,105 +138,257 @@ type GitHubUser struct {
 	Blog      string `json:"blog"`
 }
 
-func NewOAuthService() *OAuthService {
+func NewOAuthService(cfg *configs.Config, authService *AuthService) *OAuthService {
 	googleConfig := &oauth2.Config{
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-		RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
-		Scopes:       []string{"openid", "email", "profile"},
+		ClientID:     cfg.GoogleClientID,
+		ClientSecret: cfg.GoogleClientSecret,
+		RedirectURL:  cfg.GoogleRedirectURL,
+		Scopes:       append([]string{"openid", "email", "profile"}, parseScopeList(cfg.GoogleExtraScopes)...),
 		Endpoint:     google.Endpoint,
 	}
 
 	githubConfig := &oauth2.Config{
-		ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
-		ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
-		RedirectURL:  os.Getenv("GITHUB_REDIRECT_URL"),
-		Scopes:       []string{"user:email"},
+		ClientID:     cfg.GitHubClientID,
+		ClientSecret: cfg.GitHubClientSecret,
+		RedirectURL:  cfg.GitHubRedirectURL,
+		Scopes:       append([]string{"user:email"}, parseScopeList(cfg.GitHubExtraScopes)...),
 		Endpoint:     github.Endpoint,
 	}
 
-	return &OAuthService{
-		userRepo:     repository.NewUserRepository(),
-		authService:  NewAuthService(),
-		googleConfig: googleConfig,
-		githubConfig: githubConfig,
+	s := &OAuthService{
+		userRepo:             repository.NewUserRepository(),
+		authService:          authService,
+		googleConfig:         googleConfig,
+		githubConfig:         githubConfig,
+		googleAllowedDomains: parseDomainList(cfg.GoogleAllowedDomains),
+		githubAllowedDomains: parseDomainList(cfg.GitHubAllowedDomains),
+		allowedRedirectHosts: parseDomainList(cfg.OAuthAllowedRedirectHosts),
+		missingEmail:         cfg.OAuthMissingEmail,
+		confirmLinks:         cfg.RequireLinkConfirmation,
+		breaker:              newProviderBreaker(cfg.OAuthBreakerThreshold, cfg.OAuthBreakerCooldown),
+		httpClient:           &http.Client{Timeout: cfg.OAuthHTTPTimeout},
+		states:               newOAuthStateStore(),
+		tokenRepo:            repository.NewOAuthTokenRepository(),
+	}
+
+	if cfg.OAuthStoreTokens {
+		key, err := cfg.OAuthTokenKey()
+		if err == nil {
+			s.tokenCipher, err = newTokenCipher(key)
+		}
+		if err != nil {
+			log.Printf("OAuth token storage disabled: %v", err)
+		}
 	}
+
+	go s.states.runSweeper(cfg.OAuthStateSweepInterval)
+
+	return s
+}
+
+// ConfiguredProviders reports which OAuth providers have client credentials set,
+// keyed by provider name
+func (s *OAuthService) ConfiguredProviders() map[string]bool {
+	return map[string]bool{
+		AuthProviderGoogle: s.googleConfig.ClientID != "" && s.googleConfig.ClientSecret != "",
+		AuthProviderGitHub: s.githubConfig.ClientID != "" && s.githubConfig.ClientSecret != "",
+	}
+}
+
+// providerInfo lists every supported provider in display order
+var providerInfo = []models.OAuthProviderInfo{
+	{ID: AuthProviderGoogle, Name: "Google", AuthURL: "/auth/google", Icon: "fab fa-google", Color: "#db4437"},
+	{ID: AuthProviderGitHub, Name: "GitHub", AuthURL: "/auth/github", Icon: "fab fa-github", Color: "#333333"},
+}
+
+// AvailableProviders describes the configured providers, for clients that render
+// their own sign-in options
+func (s *OAuthService) AvailableProviders() []models.OAuthProviderInfo {
+	configured := s.ConfiguredProviders()
+
+	providers := []models.OAuthProviderInfo{}
+	for _, provider := range providerInfo {
+		if configured[provider.ID] {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
+
+// checkRedirectURL refuses to use a provider whose redirect URL points outside the
+// allowed hosts, so a misconfiguration cannot send authorization codes elsewhere
+func (s *OAuthService) checkRedirectURL(provider string, config *oauth2.Config) error {
+	if !redirectURLAllowed(config.RedirectURL, s.allowedRedirectHosts) {
+		log.Printf("ERROR: %s OAuth redirect URL %q is not in OAUTH_ALLOWED_REDIRECT_HOSTS; refusing to start sign-in", provider, config.RedirectURL)
+		return ErrRedirectHostNotAllowed
+	}
+	return nil
 }
 
 // GetGoogleAuthURL generates the Google OAuth authorization URL
-func (s *OAuthService) GetGoogleAuthURL(state string) string {
-	return s.googleConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
+func (s *OAuthService) GetGoogleAuthURL(state string) (string, error) {
+	if !s.ConfiguredProviders()[AuthProviderGoogle] {
+		return "", ErrProviderNotConfigured
+	}
+	if err := s.checkRedirectURL("Google", s.googleConfig); err != nil {
+		return "", err
+	}
+	if !s.breaker.allow(AuthProviderGoogle, time.Now()) {
+		return "", ErrProviderUnavailable
+	}
+	return s.googleConfig.AuthCodeURL(state, oauth2.AccessTypeOffline), nil
 }
 
 // GetGitHubAuthURL generates the GitHub OAuth authorization URL
-func (s *OAuthService) GetGitHubAuthURL(state string) string {
-	return s.githubConfig.AuthCodeURL(state)
+func (s *OAuthService) GetGitHubAuthURL(state string) (string, error) {
+	if !s.ConfiguredProviders()[AuthProviderGitHub] {
+		return "", ErrProviderNotConfigured
+	}
+	if err := s.checkRedirectURL("GitHub", s.githubConfig); err != nil {
+		return "", err
+	}
+	if !s.breaker.allow(AuthProviderGitHub, time.Now()) {
+		return "", ErrProviderUnavailable
+	}
+	return s.githubConfig.AuthCodeURL(state), nil
 }
 
-// HandleGoogleCallback handles the Google OAuth callback
-func (s *OAuthService) HandleGoogleCallback(code string) (string, *models.User, error) {
+// HandleGoogleCallback handles the Google OAuth callback. Provider calls stop
+// when ctx is cancelled, e.g. because the browser went away. signedIn is the
+// account the browser is already signed in to, if any.
+func (s *OAuthService) HandleGoogleCallback(ctx context.Context, code string, signedIn *models.User) (string, *models.User, error) {
+	if err := s.checkRedirectURL("Google", s.googleConfig); err != nil {
+		return "", nil, err
+	}
+
 	// Exchange code for token
-	token, err := s.googleConfig.Exchange(context.Background(), code)
+	token, err := s.googleConfig.Exchange(s.providerContext(ctx), code)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to exchange code for token: %v", err)
+		err = providerError(err)
+		s.breaker.record(AuthProviderGoogle, err, time.Now())
+		return "", nil, fmt.Errorf("failed to exchange code for token: %w", err)
 	}
 
 	// Get user info
-	googleUser, err := s.getGoogleUserInfo(token.AccessToken)
+	googleUser, err := s.getGoogleUserInfo(ctx, token.AccessToken)
+	err = providerError(err)
+	s.breaker.record(AuthProviderGoogle, err, time.Now())
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to get user info: %v", err)
+		return "", nil, fmt.Errorf("failed to get user info: %w", err)
 	}
 
 	// Find or create user
-	user, err := s.findOrCreateGoogleUser(googleUser)
+	user, err := s.findOrCreateGoogleUser(googleUser, signedIn)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to find or create user: %v", err)
+		return "", nil, fmt.Errorf("failed to find or create user: %w", err)
+	}
+
+	// Keep the provider's tokens for API access; failing to is not worth failing the sign-in over
+	if err := s.storeProviderToken(user.ID, AuthProviderGoogle, token); err != nil {
+		log.Printf("Failed to store Google token for user %d: %v", user.ID, err)
 	}
 
 	// Generate JWT token
-	jwtToken, err := s.authService.GenerateJWT(user)
+	jwtToken, err := s.authService.GenerateProviderJWT(user, AuthProviderGoogle)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to generate JWT: %v", err)
+		return "", nil, fmt.Errorf("failed to generate JWT: %w", err)
 	}
 
 	return jwtToken, user, nil
 }
 
-// HandleGitHubCallback handles the GitHub OAuth callback
-func (s *OAuthService) HandleGitHubCallback(code string) (string, *models.User, error) {
+// HandleGitHubCallback handles the GitHub OAuth callback. Provider calls stop
+// when ctx is cancelled, e.g. because the browser went away. signedIn is the
+// account the browser is already signed in to, if any.
+func (s *OAuthService) HandleGitHubCallback(ctx context.Context, code string, signedIn *models.User) (string, *models.User, error) {
+	if err := s.checkRedirectURL("GitHub", s.githubConfig); err != nil {
+		return "", nil, err
+	}
+
 	// Exchange code for token
-	token, err := s.githubConfig.Exchange(context.Background(), code)
+	token, err := s.githubConfig.Exchange(s.providerContext(ctx), code)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to exchange code for token: %v", err)
+		err = providerError(err)
+		s.breaker.record(AuthProviderGitHub, err, time.Now())
+		return "", nil, fmt.Errorf("failed to exchange code for token: %w", err)
 	}
 
 	// Get user info
-	githubUser, err := s.getGitHubUserInfo(token.AccessToken)
+	githubUser, err := s.getGitHubUserInfo(ctx, token.AccessToken)
+	err = providerError(err)
+	s.breaker.record(AuthProviderGitHub, err, time.Now())
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to get user info: %v", err)
+		return "", nil, fmt.Errorf("failed to get user info: %w", err)
 	}
 
 	// Find or create user
-	user, err := s.findOrCreateGitHubUser(githubUser)
+	user, err := s.findOrCreateGitHubUser(githubUser, signedIn)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to find or create user: %v", err)
+		return "", nil, fmt.Errorf("failed to find or create user: %w", err)
+	}
+
+	// Keep the provider's tokens for API access; failing to is not worth failing the sign-in over
+	if err := s.storeProviderToken(user.ID, AuthProviderGitHub, token); err != nil {
+		log.Printf("Failed to store GitHub token for user %d: %v", user.ID, err)
 	}
 
 	// Generate JWT token
-	jwtToken, err := s.authService.GenerateJWT(user)
+	jwtToken, err := s.authService.GenerateProviderJWT(user, AuthProviderGitHub)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to generate JWT: %v", err)
+		return "", nil, fmt.Errorf("failed to generate JWT: %w", err)
 	}
 
 	return jwtToken, user, nil
 }
 
-func (s *OAuthService) getGoogleUserInfo(accessToken string) (*GoogleUser, error) {
-	resp, err := http.Get("https://www.googleapis.com/oauth2/v2/userinfo?access_token=" + accessToken)
+// providerContext makes the oauth2 package use the shared, time-limited client
+func (s *OAuthService) providerContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, s.httpClient)
+}
+
+// providerError turns a provider call that ran out of time into ErrProviderTimeout
+// and a token exchange the provider failed with a server error into
+// ErrProviderUnavailable, and leaves other errors alone
+func providerError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %v", ErrProviderTimeout, err)
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.Response != nil && retrieveErr.Response.StatusCode >= 500 {
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	return err
+}
+
+// providerStatusError returns nil for a successful provider response and an error
+// for any other, wrapping ErrProviderUnavailable when the provider failed with a
+// server error
+func providerStatusError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err := fmt.Errorf("%s %s returned %s", resp.Request.Method, resp.Request.URL.Path, resp.Status)
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	return err
+}
+
+func (s *OAuthService) getGoogleUserInfo(ctx context.Context, accessToken string) (*GoogleUser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://www.googleapis.com/oauth2/v2/userinfo?access_token="+accessToken, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if err := providerStatusError(resp); err != nil {
+		return nil, err
+	}
 
 	var googleUser GoogleUser
 	if err := json.NewDecoder(resp.Body).Decode(&googleUser); err != nil {
@@ -157,19 +398,21 @@ func (s *OAuthService) getGoogleUserInfo(accessToken string) (*GoogleUser, error
 	return &googleUser, nil
 }
 
-func (s *OAuthService) getGitHubUserInfo(accessToken string) (*GitHubUser, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://api.github.com/user", nil)
+func (s *OAuthService) getGitHubUserInfo(ctx context.Context, accessToken string) (*GitHubUser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.github.com/user", nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Authorization", "token "+accessToken)
-	resp, err := client.Do(req)
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if err := providerStatusError(resp); err != nil {
+		return nil, err
+	}
 
 	var githubUser GitHubUser
 	if err := json.NewDecoder(resp.Body).Decode(&githubUser); err != nil {
@@ -178,7 +421,7 @@ func (s *OAuthService) getGitHubUserInfo(accessToken string) (*GitHubUser, error
 
 	// Get user's primary email if not public
 	if githubUser.Email == "" {
-		email, err := s.getGitHubUserEmail(accessToken)
+		email, err := s.getGitHubUserEmail(ctx, accessToken)
 		if err == nil {
 			githubUser.Email = email
 		}
@@ -187,19 +430,21 @@ func (s *OAuthService) getGitHubUserInfo(accessToken string) (*GitHubUser, error
 	return &githubUser, nil
 }
 
-func (s *OAuthService) getGitHubUserEmail(accessToken string) (string, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://api.github.com/user/emails", nil)
+func (s *OAuthService) getGitHubUserEmail(ctx context.Context, accessToken string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.github.com/user/emails", nil)
 	if err != nil {
 		return "", err
 	}
 
 	req.Header.Set("Authorization", "token "+accessToken)
-	resp, err := client.Do(req)
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
+	if err := providerStatusError(resp); err != nil {
+		return "", err
+	}
 
 	var emails []struct {
 		Email   string `json:"email"`
@@ -219,22 +464,24 @@ func (s *OAuthService) getGitHubUserEmail(accessToken string) (string, error) {
 	return "", fmt.Errorf("no primary email found")
 }
 
-func (s *OAuthService) findOrCreateGoogleUser(googleUser *GoogleUser) (*models.User, error) {
+func (s *OAuthService) findOrCreateGoogleUser(googleUser *GoogleUser, signedIn *models.User) (*models.User, error) {
+	if !emailDomainAllowed(googleUser.Email, s.googleAllowedDomains) {
+		return nil, ErrDomainNotAllowed
+	}
+
 	// Try to find user by Google ID
 	user, err := s.userRepo.GetByGoogleID(googleUser.ID)
 	if err == nil {
 		return user, nil
 	}
 
-	// Try to find user by email
-	user, err = s.userRepo.GetByEmail(googleUser.Email)
-	if err == nil {
-		// Update Google ID for existing user
-		user.GoogleID = stringPtr(googleUser.ID)
-		if user.AvatarURL == nil || *user.AvatarURL == "" {
-			user.AvatarURL = stringPtr(googleUser.Picture)
+	// Try to find user by email. Most misses here are brand-new users, so check
+	// existence before loading the row to link.
+	if s.emailExists(googleUser.Email) {
+		user, err = s.userRepo.GetByEmail(googleUser.Email)
+		if err == nil {
+			return s.linkExistingUser(user, signedIn, AuthProviderGoogle, googleUser.ID, googleUser.Picture)
 		}
-		return s.userRepo.Update(user)
 	}
 
 	// Create new user
@@ -248,10 +495,14 @@ func (s *OAuthService) findOrCreateGoogleUser(googleUser *GoogleUser) (*models.U
 		IsVerified: true, // OAuth users are considered verified
 	}
 
-	return s.userRepo.Create(user)
+	return s.createUser(user, "google_id", googleUser.ID)
 }
 
-func (s *OAuthService) findOrCreateGitHubUser(githubUser *GitHubUser) (*models.User, error) {
+func (s *OAuthService) findOrCreateGitHubUser(githubUser *GitHubUser, signedIn *models.User) (*models.User, error) {
+	if !emailDomainAllowed(githubUser.Email, s.githubAllowedDomains) {
+		return nil, ErrDomainNotAllowed
+	}
+
 	githubIDStr := fmt.Sprintf("%d", githubUser.ID)
 	
 	// Try to find user by GitHub ID
@@ -260,19 +511,25 @@ func (s *OAuthService) findOrCreateGitHubUser(githubUser *GitHubUser) (*models.U
 		return user, nil
 	}
 
-	// Try to find user by email if available
-	if githubUser.Email != "" {
+	// Try to find user by email if available, checking existence before loading the row
+	if githubUser.Email != "" && s.emailExists(githubUser.Email) {
 		user, err = s.userRepo.GetByEmail(githubUser.Email)
 		if err == nil {
-			// Update GitHub ID for existing user
-			user.GitHubID = stringPtr(githubIDStr)
-			if user.AvatarURL == nil || *user.AvatarURL == "" {
-				user.AvatarURL = stringPtr(githubUser.AvatarURL)
-			}
-			return s.userRepo.Update(user)
+			return s.linkExistingUser(user, signedIn, AuthProviderGitHub, githubIDStr, githubUser.AvatarURL)
 		}
 	}
 
+	// Without an email the account could never be found or signed into by
+	// password, so either refuse it or hold the address with GitHub's noreply
+	// form until the user adds a real one
+	email := githubUser.Email
+	if email == "" {
+		if s.missingEmail != "prompt" {
+			return nil, ErrProviderEmailMissing
+		}
+		email = fmt.Sprintf("%d+%s@users.noreply.github.com", githubUser.ID, githubUser.Login)
+	}
+
 	// Parse name
 	firstName := githubUser.Login
 	lastName := ""
@@ -280,19 +537,48 @@ func (s *OAuthService) findOrCreateGitHubUser(githubUser *GitHubUser) (*models.U
 		firstName = githubUser.Name
 	}
 
+	// Provider profiles are not validated, so an unusable blog URL is dropped
+	website, err := normalizeWebsite(githubUser.Blog)
+	if err != nil {
+		website = ""
+	}
+
 	// Create new user
 	user = &models.User{
-		Email:     githubUser.Email,
+		Email:     email,
 		FirstName: firstName,
 		LastName:  lastName,
 		GitHubID:  stringPtr(githubIDStr),
 		AvatarURL: stringPtr(githubUser.AvatarURL),
-		Bio:       stringPtr(githubUser.Bio),
-		Website:   stringPtr(githubUser.Blog),
-		Location:  stringPtr(githubUser.Location),
+		Bio:       stringPtr(truncateRunes(githubUser.Bio, models.MaxBioLength)),
+		Website:   stringPtr(website),
+		Location:  stringPtr(truncateRunes(githubUser.Location, models.MaxLocationLength)),
 		IsActive:  true,
 		IsVerified: githubUser.Email != "", // Only verified if we have an email
+		EmailMissing: githubUser.Email == "",
+	}
+
+	// GORM names the GitHubID column git_hub_id
+	return s.createUser(user, "git_hub_id", githubIDStr)
+}
+
+// createUser stores a user signing in through a provider for the first time and
+// welcomes them. When simultaneous first sign-ins race, every one of them gets the
+// account the winner created, and only that one is welcomed.
+func (s *OAuthService) createUser(user *models.User, column, providerID string) (*models.User, error) {
+	user, created, err := s.userRepo.CreateWithProvider(user, column, providerID)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		s.authService.welcome.send(user)
 	}
+	return user, nil
+}
 
-	return s.userRepo.Create(user)
+// emailExists reports whether an account already uses the email. Lookup
+// errors count as absent so sign-in falls through to account creation.
+func (s *OAuthService) emailExists(email string) bool {
+	exists, _ := s.userRepo.ExistsByEmail(email)
+	return exists
 }
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// providerBreaker stops new sign-ins with a provider that keeps failing. After
+// threshold outages in a row the provider is refused until cooldown has passed;
+// the next sign-in then gets through, and closes the breaker if it succeeds or
+// opens it again if it fails. A threshold of zero disables the breaker.
+type providerBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  map[string]int
+	openUntil map[string]time.Time
+}
+
+func newProviderBreaker(threshold int, cooldown time.Duration) *providerBreaker {
+	return &providerBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		failures:  make(map[string]int),
+		openUntil: make(map[string]time.Time),
+	}
+}
+
+// allow reports whether a sign-in with provider may start
+func (b *providerBreaker) allow(provider string, now time.Time) bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !now.Before(b.openUntil[provider])
+}
+
+// record notes how the calls to provider for a sign-in ended. Only outages count
+// against the provider; any other error means it answered, which closes the
+// breaker as a success does. Sign-ins the browser gave up on say nothing about
+// the provider and are not counted.
+func (b *providerBreaker) record(provider string, err error, now time.Time) {
+	if b.threshold <= 0 || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !providerOutage(err) {
+		b.failures[provider] = 0
+		delete(b.openUntil, provider)
+		return
+	}
+
+	b.failures[provider]++
+	if b.failures[provider] >= b.threshold {
+		b.openUntil[provider] = now.Add(b.cooldown)
+		log.Printf("%s sign-in paused for %s after %d failures in a row", ProviderDisplayName(provider), b.cooldown, b.failures[provider])
+	}
+}
+
+// providerOutage reports whether err means the provider was down rather than
+// refusing this one sign-in
+func providerOutage(err error) bool {
+	return errors.Is(err, ErrProviderTimeout) || errors.Is(err, ErrProviderUnavailable)
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"sso-web-app/internal/models"
+)
+
+// OAuthStateTTL is how long a sign-in may take at the provider; the state cookie
+// lasts as long
+const OAuthStateTTL = 10 * time.Minute
+
+// oauthStateStore remembers the state of every OAuth sign-in in progress, so a
+// callback is only accepted for a state this server issued, and only once. It also
+// counts, per provider, how sign-ins started and ended.
+type oauthStateStore struct {
+	mu     sync.Mutex
+	states map[string]pendingOAuthState
+	stats  map[string]*models.OAuthProviderStats
+}
+
+// pendingOAuthState is a sign-in waiting for the provider to redirect back
+type pendingOAuthState struct {
+	provider  string
+	expiresAt time.Time
+}
+
+func newOAuthStateStore() *oauthStateStore {
+	return &oauthStateStore{
+		states: make(map[string]pendingOAuthState),
+		stats:  make(map[string]*models.OAuthProviderStats),
+	}
+}
+
+// begin records a new state for provider and returns it
+func (st *oauthStateStore) begin(provider string, now time.Time) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	state := hex.EncodeToString(b)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.states[state] = pendingOAuthState{provider: provider, expiresAt: now.Add(OAuthStateTTL)}
+	st.statsFor(provider).Initiated++
+	return state, nil
+}
+
+// consume removes state and reports whether it was issued for provider and has not
+// expired. An expired state counts as abandoned, like one the sweeper removes.
+func (st *oauthStateStore) consume(provider, state string, now time.Time) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	pending, ok := st.states[state]
+	if !ok || pending.provider != provider {
+		return false
+	}
+	delete(st.states, state)
+
+	if now.After(pending.expiresAt) {
+		st.statsFor(provider).Abandoned++
+		return false
+	}
+	st.statsFor(provider).Completed++
+	return true
+}
+
+// sweep removes expired states, counting each as abandoned, and returns how many
+// were removed. It holds the same lock as consume, so it is safe alongside callbacks.
+func (st *oauthStateStore) sweep(now time.Time) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	removed := 0
+	for state, pending := range st.states {
+		if now.After(pending.expiresAt) {
+			delete(st.states, state)
+			st.statsFor(pending.provider).Abandoned++
+			removed++
+		}
+	}
+	return removed
+}
+
+// snapshot returns a copy of the per-provider counters with pending sign-ins and
+// the abandonment rate filled in
+func (st *oauthStateStore) snapshot() map[string]models.OAuthProviderStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	result := make(map[string]models.OAuthProviderStats, len(st.stats))
+	for provider, stats := range st.stats {
+		result[provider] = *stats
+	}
+	for _, pending := range st.states {
+		stats := result[pending.provider]
+		stats.Pending++
+		result[pending.provider] = stats
+	}
+	for provider, stats := range result {
+		if ended := stats.Completed + stats.Abandoned; ended > 0 {
+			stats.AbandonmentRate = float64(stats.Abandoned) / float64(ended)
+		}
+		result[provider] = stats
+	}
+	return result
+}
+
+// statsFor returns the counters for provider; the caller holds the lock
+func (st *oauthStateStore) statsFor(provider string) *models.OAuthProviderStats {
+	stats, ok := st.stats[provider]
+	if !ok {
+		stats = &models.OAuthProviderStats{}
+		st.stats[provider] = stats
+	}
+	return stats
+}
+
+// runSweeper removes expired states every interval for the life of the process
+func (st *oauthStateStore) runSweeper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if removed := st.sweep(now); removed > 0 {
+			log.Printf("Removed %d abandoned OAuth sign-ins", removed)
+		}
+	}
+}
+
+// BeginState starts an OAuth sign-in through provider and returns the state to
+// send to the provider and store in the browser's state cookie
+func (s *OAuthService) BeginState(provider string) (string, error) {
+	return s.states.begin(provider, time.Now())
+}
+
+// ConsumeState accepts a callback's state once: it must have been issued by
+// BeginState for provider within OAuthStateTTL
+func (s *OAuthService) ConsumeState(provider, state string) bool {
+	return s.states.consume(provider, state, time.Now())
+}
+
+// StateStats returns OAuth sign-in counts per provider since startup
+func (s *OAuthService) StateStats() map[string]models.OAuthProviderStats {
+	return s.states.snapshot()
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOAuthStateSweep(t *testing.T) {
+	store := newOAuthStateStore()
+	start := time.Now()
+
+	expired, err := store.begin(AuthProviderGoogle, start.Add(-OAuthStateTTL-time.Minute))
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	active, err := store.begin(AuthProviderGoogle, start)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	activeGitHub, err := store.begin(AuthProviderGitHub, start.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+
+	if removed := store.sweep(start); removed != 1 {
+		t.Errorf("sweep removed %d states, want 1", removed)
+	}
+
+	if store.consume(AuthProviderGoogle, expired, start) {
+		t.Error("the expired state was kept")
+	}
+	if !store.consume(AuthProviderGoogle, active, start) {
+		t.Error("the active Google state was removed")
+	}
+	if !store.consume(AuthProviderGitHub, activeGitHub, start) {
+		t.Error("the active GitHub state was removed")
+	}
+
+	stats := store.snapshot()
+	google := stats[AuthProviderGoogle]
+	if google.Initiated != 2 || google.Completed != 1 || google.Abandoned != 1 || google.Pending != 0 || google.AbandonmentRate != 0.5 {
+		t.Errorf("google stats = %+v, want 2 initiated, 1 completed, 1 abandoned", google)
+	}
+	if github := stats[AuthProviderGitHub]; github.Initiated != 1 || github.Completed != 1 || github.Abandoned != 0 {
+		t.Errorf("github stats = %+v, want 1 initiated and completed", github)
+	}
+}
+
+func TestOAuthStateSweepAlongsideCallbacks(t *testing.T) {
+	store := newOAuthStateStore()
+	now := time.Now()
+
+	const signIns = 200
+	states := make([]string, signIns)
+	for i := range states {
+		state, err := store.begin(AuthProviderGoogle, now)
+		if err != nil {
+			t.Fatalf("begin: %v", err)
+		}
+		states[i] = state
+	}
+
+	// Callbacks complete while the sweeper runs; none of these states has expired
+	var wg sync.WaitGroup
+	completed := make(chan bool, signIns)
+	for _, state := range states {
+		wg.Add(1)
+		go func(state string) {
+			defer wg.Done()
+			completed <- store.consume(AuthProviderGoogle, state, now)
+		}(state)
+	}
+	for i := 0; i < 10; i++ {
+		store.sweep(now)
+	}
+	wg.Wait()
+	close(completed)
+
+	for ok := range completed {
+		if !ok {
+			t.Fatal("a callback for an active state was refused")
+		}
+	}
+	if stats := store.snapshot()[AuthProviderGoogle]; stats.Completed != signIns || stats.Abandoned != 0 {
+		t.Errorf("stats = %+v, want %d completed and none abandoned", stats, signIns)
+	}
+}
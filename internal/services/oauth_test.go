@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+func TestOAuthAllowedDomains(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		email    string
+		wantErr  error
+	}{
+		{"google, allowed domain", AuthProviderGoogle, "ann@ourcompany.com", nil},
+		{"google, allowed domain in capitals", AuthProviderGoogle, "ann@OurCompany.COM", nil},
+		{"google, other domain", AuthProviderGoogle, "ann@gmail.com", ErrDomainNotAllowed},
+		{"google, lookalike subdomain", AuthProviderGoogle, "ann@evil.ourcompany.com", ErrDomainNotAllowed},
+		{"github, unrestricted", AuthProviderGitHub, "ann@gmail.com", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, map[string]string{"GOOGLE_ALLOWED_DOMAINS": "ourcompany.com, partner.org"})
+			oauthService := NewOAuthService(cfg, newTestAuthService(t, cfg))
+
+			var err error
+			if tt.provider == AuthProviderGoogle {
+				_, err = oauthService.findOrCreateGoogleUser(&GoogleUser{ID: "g-1", Email: tt.email, Given: "Ann", Family: "Example"}, nil)
+			} else {
+				_, err = oauthService.findOrCreateGitHubUser(&GitHubUser{ID: 1, Login: "ann", Email: tt.email}, nil)
+			}
+			if err != tt.wantErr {
+				t.Fatalf("findOrCreate = %v, want %v", err, tt.wantErr)
+			}
+
+			exists, err := repository.NewUserRepository().ExistsByEmailFold(tt.email)
+			if err != nil {
+				t.Fatalf("ExistsByEmailFold: %v", err)
+			}
+			if exists != (tt.wantErr == nil) {
+				t.Errorf("user exists = %v, want %v", exists, tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestOAuthConcurrentFirstSignIn(t *testing.T) {
+	for _, provider := range []string{AuthProviderGoogle, AuthProviderGitHub} {
+		t.Run(provider, func(t *testing.T) {
+			cfg := setupTestDB(t, nil)
+			oauthService := NewOAuthService(cfg, newTestAuthService(t, cfg))
+
+			const logins = 8
+			var wg sync.WaitGroup
+			ids := make(chan uint, logins)
+			errs := make(chan error, logins)
+			for i := 0; i < logins; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var user *models.User
+					var err error
+					if provider == AuthProviderGoogle {
+						user, err = oauthService.findOrCreateGoogleUser(&GoogleUser{ID: "g-race", Email: "race@example.com", Given: "Race", Family: "User"}, nil)
+					} else {
+						user, err = oauthService.findOrCreateGitHubUser(&GitHubUser{ID: 42, Login: "race", Email: "race@example.com"}, nil)
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+					ids <- user.ID
+				}()
+			}
+			wg.Wait()
+			close(ids)
+			close(errs)
+
+			for err := range errs {
+				t.Errorf("find-or-create: %v", err)
+			}
+			seen := make(map[uint]bool)
+			for id := range ids {
+				seen[id] = true
+			}
+			if len(seen) != 1 {
+				t.Errorf("sign-ins got %d different users, want 1", len(seen))
+			}
+
+			var count int64
+			if err := repository.GetDB().Model(&models.User{}).Where("email = ?", "race@example.com").Count(&count).Error; err != nil {
+				t.Fatalf("Count: %v", err)
+			}
+			if count != 1 {
+				t.Errorf("%d users stored, want 1", count)
+			}
+		})
+	}
+}
+
+func TestOAuthRedirectHostAllowlist(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     string
+		redirectURL string
+		wantErr     error
+	}{
+		{"allowed host", "sso.example.com", "https://sso.example.com/auth/google/callback", nil},
+		{"allowed host and port", "localhost:8080", "http://localhost:8080/auth/google/callback", nil},
+		{"host in capitals", "sso.example.com", "https://SSO.Example.com/auth/google/callback", nil},
+		{"no allowlist", "", "https://anywhere.example.net/callback", nil},
+		{"other host", "sso.example.com", "https://evil.example.net/auth/google/callback", ErrRedirectHostNotAllowed},
+		{"lookalike host", "sso.example.com", "https://sso.example.com.evil.net/callback", ErrRedirectHostNotAllowed},
+		{"other port", "localhost:8080", "http://localhost:9090/callback", ErrRedirectHostNotAllowed},
+		{"not http", "sso.example.com", "javascript://sso.example.com/callback", ErrRedirectHostNotAllowed},
+		{"relative", "sso.example.com", "/auth/google/callback", ErrRedirectHostNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, map[string]string{
+				"GOOGLE_CLIENT_ID":             "google-client",
+				"GOOGLE_CLIENT_SECRET":         "google-secret",
+				"GOOGLE_REDIRECT_URL":          tt.redirectURL,
+				"OAUTH_ALLOWED_REDIRECT_HOSTS": tt.allowed,
+			})
+			oauthService := NewOAuthService(cfg, newTestAuthService(t, cfg))
+
+			authURL, err := oauthService.GetGoogleAuthURL("state")
+			if err != tt.wantErr {
+				t.Fatalf("GetGoogleAuthURL error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if authURL != "" {
+					t.Errorf("GetGoogleAuthURL returned %q alongside the error", authURL)
+				}
+				// The callback refuses too, before any code is exchanged
+				if _, _, err := oauthService.HandleGoogleCallback(context.Background(), "code", nil); err != tt.wantErr {
+					t.Errorf("HandleGoogleCallback error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if !strings.Contains(authURL, "redirect_uri="+url.QueryEscape(tt.redirectURL)) {
+				t.Errorf("auth URL %q does not carry the redirect URL", authURL)
+			}
+		})
+	}
+}
+
+func TestOAuthGitHubWithoutEmail(t *testing.T) {
+	tests := []struct {
+		behavior string
+		wantErr  error
+	}{
+		{"reject", ErrProviderEmailMissing},
+		{"prompt", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.behavior, func(t *testing.T) {
+			cfg := setupTestDB(t, map[string]string{"OAUTH_MISSING_EMAIL": tt.behavior})
+			oauthService := NewOAuthService(cfg, newTestAuthService(t, cfg))
+
+			// GitHub hid the address and the emails endpoint gave nothing either
+			githubUser := &GitHubUser{ID: 42, Login: "octo"}
+			user, err := oauthService.findOrCreateGitHubUser(githubUser, nil)
+			if err != tt.wantErr {
+				t.Fatalf("findOrCreateGitHubUser = %v, want %v", err, tt.wantErr)
+			}
+
+			exists, err := repository.NewUserRepository().ExistsByGitHubID("42")
+			if err != nil {
+				t.Fatalf("ExistsByGitHubID: %v", err)
+			}
+			if tt.wantErr != nil {
+				if exists {
+					t.Error("an account was created without an email")
+				}
+				return
+			}
+
+			if !exists {
+				t.Fatal("no account was created")
+			}
+			if user.Email != "42+octo@users.noreply.github.com" || !user.EmailMissing || user.IsVerified {
+				t.Errorf("user = email %q, missing %v, verified %v; want the noreply placeholder, flagged and unverified",
+					user.Email, user.EmailMissing, user.IsVerified)
+			}
+
+			// Signing in again finds the same account rather than making another
+			again, err := oauthService.findOrCreateGitHubUser(githubUser, nil)
+			if err != nil || again.ID != user.ID {
+				t.Errorf("second sign-in = user %v, %v; want user %d", again, err, user.ID)
+			}
+		})
+	}
+}
+
+func TestOAuthLinkConfirmation(t *testing.T) {
+	tests := []struct {
+		name       string
+		confirm    bool
+		verified   bool
+		signedIn   string // "owner", "other" or none
+		wantLinked bool
+	}{
+		{"confirmation off", false, false, "", true},
+		{"verified email", true, true, "", true},
+		{"signed in as the owner", true, false, "owner", true},
+		{"unverified email", true, false, "", false},
+		{"signed in as someone else", true, false, "other", false},
+	}
+
+	for _, tt := range tests {
+		for _, provider := range []string{AuthProviderGoogle, AuthProviderGitHub} {
+			t.Run(tt.name+"/"+provider, func(t *testing.T) {
+				cfg := setupTestDB(t, map[string]string{"REQUIRE_LINK_CONFIRMATION": strconv.FormatBool(tt.confirm)})
+				mailer := newMailRecorder()
+				authService := newTestAuthServiceWithMailer(t, cfg, mailer)
+				oauthService := NewOAuthService(cfg, authService)
+
+				owner := createTestUser(t, authService, "owner@example.com", "Passw0rd!x")
+				if tt.verified {
+					if err := repository.GetDB().Model(owner).Update("is_verified", true).Error; err != nil {
+						t.Fatalf("Update: %v", err)
+					}
+				}
+				var signedIn *models.User
+				switch tt.signedIn {
+				case "owner":
+					signedIn = owner
+				case "other":
+					signedIn = createTestUser(t, authService, "other@example.com", "Passw0rd!x")
+				}
+
+				signIn := func() (*models.User, error) {
+					if provider == AuthProviderGoogle {
+						return oauthService.findOrCreateGoogleUser(&GoogleUser{ID: "g-1", Email: owner.Email, Given: "Eve"}, signedIn)
+					}
+					return oauthService.findOrCreateGitHubUser(&GitHubUser{ID: 1, Login: "eve", Email: owner.Email}, signedIn)
+				}
+				linked := func() bool {
+					t.Helper()
+					stored, err := repository.NewUserRepository().GetByID(owner.ID)
+					if err != nil {
+						t.Fatalf("GetByID: %v", err)
+					}
+					if provider == AuthProviderGoogle {
+						return stored.GoogleID != nil
+					}
+					return stored.GitHubID != nil
+				}
+
+				user, err := signIn()
+				if tt.wantLinked {
+					if err != nil || user.ID != owner.ID {
+						t.Fatalf("sign-in = %v, %v; want the existing account", user, err)
+					}
+					if !linked() {
+						t.Error("provider not linked")
+					}
+					time.Sleep(50 * time.Millisecond)
+					mailer.none(t)
+					return
+				}
+
+				if err != ErrLinkConfirmationRequired {
+					t.Fatalf("sign-in error = %v, want %v", err, ErrLinkConfirmationRequired)
+				}
+				if linked() {
+					t.Fatal("provider linked before the owner confirmed")
+				}
+
+				// The owner confirms from the mailed link, which also verifies the email
+				mail := mailer.next(t)
+				if mail.to != owner.Email {
+					t.Errorf("confirmation mailed to %q, want %q", mail.to, owner.Email)
+				}
+				token := linkTokenPattern.FindStringSubmatch(mail.body)
+				if token == nil {
+					t.Fatalf("no confirmation link in %q", mail.body)
+				}
+				confirmed, gotProvider, err := authService.ConfirmProviderLink(token[1])
+				if err != nil {
+					t.Fatalf("ConfirmProviderLink: %v", err)
+				}
+				if confirmed.ID != owner.ID || gotProvider != provider || !confirmed.IsVerified {
+					t.Errorf("confirmed user %d for %q, verified %v; want user %d for %q, verified", confirmed.ID, gotProvider, confirmed.IsVerified, owner.ID, provider)
+				}
+				if !linked() {
+					t.Error("provider not linked after confirmation")
+				}
+				if _, _, err := authService.ConfirmProviderLink(token[1]); err != ErrInvalidLinkToken {
+					t.Errorf("reusing the link: error = %v, want %v", err, ErrInvalidLinkToken)
+				}
+
+				// The provider now signs in to the account directly
+				if user, err := signIn(); err != nil || user.ID != owner.ID {
+					t.Errorf("sign-in after confirmation = %v, %v; want the existing account", user, err)
+				}
+			})
+		}
+	}
+}
+
+var linkTokenPattern = regexp.MustCompile(`/confirm-link\?token=([0-9a-f]+)`)
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+)
+
+var (
+	ErrTokenStorageDisabled = errors.New("OAuth token storage is disabled")
+	ErrProviderTokenMissing = errors.New("no provider token stored for this user")
+)
+
+// parseScopeList splits a comma-separated list of OAuth scopes, ignoring blanks
+func parseScopeList(value string) []string {
+	var scopes []string
+	for _, scope := range strings.Split(value, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// providerConfig returns the OAuth client configuration of a provider
+func (s *OAuthService) providerConfig(provider string) (*oauth2.Config, bool) {
+	switch provider {
+	case AuthProviderGoogle:
+		return s.googleConfig, true
+	case AuthProviderGitHub:
+		return s.githubConfig, true
+	}
+	return nil, false
+}
+
+// tokenAssociatedData binds a stored token's ciphertext to its user and provider
+func tokenAssociatedData(userID uint, provider string) string {
+	return strconv.FormatUint(uint64(userID), 10) + ":" + provider
+}
+
+// storeProviderToken keeps the tokens a sign-in returned, when token storage is
+// on. Providers often send a refresh token only on the first consent, so a
+// stored refresh token is kept when a later sign-in brings none.
+func (s *OAuthService) storeProviderToken(userID uint, provider string, token *oauth2.Token) error {
+	if s.tokenCipher == nil {
+		return nil
+	}
+
+	aad := tokenAssociatedData(userID, provider)
+	accessToken, err := s.tokenCipher.encrypt(token.AccessToken, aad)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := s.tokenCipher.encrypt(token.RefreshToken, aad)
+	if err != nil {
+		return err
+	}
+	if refreshToken == "" {
+		if stored, err := s.tokenRepo.Get(userID, provider); err == nil {
+			refreshToken = stored.RefreshToken
+		}
+	}
+
+	stored := &models.OAuthToken{
+		UserID:       userID,
+		Provider:     provider,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    token.TokenType,
+		Scopes:       grantedScopes(token, s.providerScopes(provider)),
+	}
+	if !token.Expiry.IsZero() {
+		expiry := token.Expiry.UTC()
+		stored.Expiry = &expiry
+	}
+	return s.tokenRepo.Upsert(stored)
+}
+
+// providerScopes returns the scopes requested from a provider
+func (s *OAuthService) providerScopes(provider string) []string {
+	if config, ok := s.providerConfig(provider); ok {
+		return config.Scopes
+	}
+	return nil
+}
+
+// grantedScopes returns the scopes the provider says it granted, space-separated,
+// falling back to the requested ones. GitHub separates them with commas.
+func grantedScopes(token *oauth2.Token, requested []string) string {
+	if granted, ok := token.Extra("scope").(string); ok && granted != "" {
+		return strings.Join(strings.FieldsFunc(granted, func(r rune) bool { return r == ',' || r == ' ' }), " ")
+	}
+	return strings.Join(requested, " ")
+}
+
+// GetValidProviderToken returns a usable access token for calling the provider's
+// API as the user. An expired token is refreshed with the stored refresh token
+// and the new one saved. If the provider refuses the refresh, the stored token is
+// deleted and ErrProviderTokenMissing returned: the user must sign in with the
+// provider again.
+func (s *OAuthService) GetValidProviderToken(userID uint, provider string) (*oauth2.Token, error) {
+	if s.tokenCipher == nil {
+		return nil, ErrTokenStorageDisabled
+	}
+	config, ok := s.providerConfig(provider)
+	if !ok {
+		return nil, ErrProviderNotConfigured
+	}
+
+	stored, err := s.tokenRepo.Get(userID, provider)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrProviderTokenMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.decryptProviderToken(stored)
+	if err != nil {
+		return nil, err
+	}
+	if token.Valid() {
+		return token, nil
+	}
+	if token.RefreshToken == "" {
+		return nil, ErrProviderTokenMissing
+	}
+
+	ctx, cancel := context.WithTimeout(s.providerContext(context.Background()), s.httpClient.Timeout)
+	defer cancel()
+	refreshed, err := config.TokenSource(ctx, token).Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+			if deleteErr := s.tokenRepo.Delete(userID, provider); deleteErr != nil {
+				log.Printf("Failed to delete revoked %s token for user %d: %v", provider, userID, deleteErr)
+			}
+			return nil, ErrProviderTokenMissing
+		}
+		return nil, fmt.Errorf("failed to refresh %s token: %w", provider, providerError(err))
+	}
+
+	if err := s.storeProviderToken(userID, provider, refreshed); err != nil {
+		log.Printf("Failed to store refreshed %s token for user %d: %v", provider, userID, err)
+	}
+	return refreshed, nil
+}
+
+// decryptProviderToken turns a stored token back into an oauth2.Token
+func (s *OAuthService) decryptProviderToken(stored *models.OAuthToken) (*oauth2.Token, error) {
+	aad := tokenAssociatedData(stored.UserID, stored.Provider)
+	accessToken, err := s.tokenCipher.decrypt(stored.AccessToken, aad)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := s.tokenCipher.decrypt(stored.RefreshToken, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    stored.TokenType,
+	}
+	if stored.Expiry != nil {
+		token.Expiry = *stored.Expiry
+	}
+	return token, nil
+}
+
+// forgetProviderTokens deletes a user's stored tokens for provider, or for every
+// provider when provider is empty, e.g. once the provider is unlinked
+func (s *OAuthService) forgetProviderTokens(userID uint, provider string) {
+	var err error
+	if provider == "" {
+		err = s.tokenRepo.DeleteByUser(userID)
+	} else {
+		err = s.tokenRepo.Delete(userID, provider)
+	}
+	if err != nil {
+		log.Printf("Failed to delete stored provider tokens for user %d: %v", userID, err)
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+// tokenEndpoint is a fake provider token endpoint that answers refresh grants
+// with a new access token, or with invalid_grant once revoked is set
+type tokenEndpoint struct {
+	mu        sync.Mutex
+	refreshes []string // refresh tokens presented
+	revoked   bool
+}
+
+func (e *tokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	r.ParseForm()
+	e.refreshes = append(e.refreshes, r.PostForm.Get("refresh_token"))
+	w.Header().Set("Content-Type", "application/json")
+	if e.revoked {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "refreshed-access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func newTokenStorageService(t *testing.T, store bool) (*OAuthService, *tokenEndpoint) {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+	cfg := setupTestDB(t, map[string]string{
+		"GOOGLE_CLIENT_ID":           "google-client",
+		"GOOGLE_CLIENT_SECRET":       "secret",
+		"OAUTH_STORE_TOKENS":         strconv.FormatBool(store),
+		"OAUTH_TOKEN_ENCRYPTION_KEY": base64.StdEncoding.EncodeToString(key),
+	})
+	oauthService := NewOAuthService(cfg, newTestAuthService(t, cfg))
+
+	endpoint := &tokenEndpoint{}
+	server := httptest.NewServer(endpoint)
+	t.Cleanup(server.Close)
+	oauthService.googleConfig.Endpoint.TokenURL = server.URL + "/token"
+	return oauthService, endpoint
+}
+
+func TestProviderTokenStorage(t *testing.T) {
+	oauthService, endpoint := newTokenStorageService(t, true)
+	authService := oauthService.authService
+	user := createTestUser(t, authService, "tokens@example.com", "Passw0rd!x")
+	other := createTestUser(t, authService, "other@example.com", "Passw0rd!x")
+
+	token := (&oauth2.Token{
+		AccessToken:  "first-access",
+		RefreshToken: "first-refresh",
+		TokenType:    "Bearer",
+		Expiry:       time.Now().Add(time.Hour),
+	}).WithExtra(map[string]interface{}{"scope": "openid email https://www.googleapis.com/auth/calendar.readonly"})
+	if err := oauthService.storeProviderToken(user.ID, AuthProviderGoogle, token); err != nil {
+		t.Fatalf("storeProviderToken: %v", err)
+	}
+
+	var stored models.OAuthToken
+	if err := repository.GetDB().Where("user_id = ? AND provider = ?", user.ID, AuthProviderGoogle).First(&stored).Error; err != nil {
+		t.Fatalf("load stored token: %v", err)
+	}
+	if strings.Contains(stored.AccessToken, "first-access") || strings.Contains(stored.RefreshToken, "first-refresh") {
+		t.Error("tokens stored in plain text")
+	}
+	if stored.Scopes != "openid email https://www.googleapis.com/auth/calendar.readonly" {
+		t.Errorf("scopes = %q, want the granted ones", stored.Scopes)
+	}
+
+	got, err := oauthService.GetValidProviderToken(user.ID, AuthProviderGoogle)
+	if err != nil {
+		t.Fatalf("GetValidProviderToken: %v", err)
+	}
+	if got.AccessToken != "first-access" || len(endpoint.refreshes) != 0 {
+		t.Errorf("access token = %q after %d refreshes, want the stored one unrefreshed", got.AccessToken, len(endpoint.refreshes))
+	}
+
+	// A later sign-in without a refresh token keeps the stored one
+	if err := oauthService.storeProviderToken(user.ID, AuthProviderGoogle, &oauth2.Token{AccessToken: "second-access", Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("storeProviderToken: %v", err)
+	}
+	got, err = oauthService.GetValidProviderToken(user.ID, AuthProviderGoogle)
+	if err != nil {
+		t.Fatalf("GetValidProviderToken: %v", err)
+	}
+	if got.AccessToken != "second-access" || got.RefreshToken != "first-refresh" {
+		t.Errorf("token = %q/%q, want second-access with first-refresh kept", got.AccessToken, got.RefreshToken)
+	}
+
+	// A ciphertext copied into another user's row does not decrypt
+	copied := stored
+	copied.ID = 0
+	copied.UserID = other.ID
+	if err := repository.GetDB().Create(&copied).Error; err != nil {
+		t.Fatalf("copy token: %v", err)
+	}
+	if _, err := oauthService.GetValidProviderToken(other.ID, AuthProviderGoogle); err == nil {
+		t.Error("token copied to another user decrypted")
+	}
+
+	if _, err := oauthService.GetValidProviderToken(user.ID, AuthProviderGitHub); err != ErrProviderTokenMissing {
+		t.Errorf("no GitHub token: err = %v, want ErrProviderTokenMissing", err)
+	}
+}
+
+func TestProviderTokenRefresh(t *testing.T) {
+	oauthService, endpoint := newTokenStorageService(t, true)
+	user := createTestUser(t, oauthService.authService, "tokens@example.com", "Passw0rd!x")
+
+	expired := &oauth2.Token{AccessToken: "old-access", RefreshToken: "the-refresh", TokenType: "Bearer", Expiry: time.Now().Add(-time.Minute)}
+	if err := oauthService.storeProviderToken(user.ID, AuthProviderGoogle, expired); err != nil {
+		t.Fatalf("storeProviderToken: %v", err)
+	}
+
+	got, err := oauthService.GetValidProviderToken(user.ID, AuthProviderGoogle)
+	if err != nil {
+		t.Fatalf("GetValidProviderToken: %v", err)
+	}
+	if got.AccessToken != "refreshed-access" || !got.Valid() {
+		t.Errorf("token = %q (valid %v), want the refreshed one", got.AccessToken, got.Valid())
+	}
+	if len(endpoint.refreshes) != 1 || endpoint.refreshes[0] != "the-refresh" {
+		t.Fatalf("refresh requests = %v, want one with the stored refresh token", endpoint.refreshes)
+	}
+
+	// The refreshed token was saved, so the next call needs no refresh
+	if got, err = oauthService.GetValidProviderToken(user.ID, AuthProviderGoogle); err != nil || got.AccessToken != "refreshed-access" {
+		t.Errorf("second call = %v, %v; want the saved refreshed token", got, err)
+	}
+	if got.RefreshToken != "the-refresh" || len(endpoint.refreshes) != 1 {
+		t.Errorf("refresh token %q after %d refreshes, want the-refresh kept and no new refresh", got.RefreshToken, len(endpoint.refreshes))
+	}
+
+	// A revoked grant deletes the stored token
+	endpoint.mu.Lock()
+	endpoint.revoked = true
+	endpoint.mu.Unlock()
+	expired.AccessToken = "old-again"
+	if err := oauthService.storeProviderToken(user.ID, AuthProviderGoogle, expired); err != nil {
+		t.Fatalf("storeProviderToken: %v", err)
+	}
+	if _, err := oauthService.GetValidProviderToken(user.ID, AuthProviderGoogle); err != ErrProviderTokenMissing {
+		t.Errorf("revoked grant: err = %v, want ErrProviderTokenMissing", err)
+	}
+	var stored models.OAuthToken
+	if err := repository.GetDB().Where("user_id = ?", user.ID).First(&stored).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("stored token after a revoked grant: err = %v, want it deleted", err)
+	}
+}
+
+func TestProviderTokenStorageDisabled(t *testing.T) {
+	oauthService, _ := newTokenStorageService(t, false)
+	user := createTestUser(t, oauthService.authService, "tokens@example.com", "Passw0rd!x")
+
+	if err := oauthService.storeProviderToken(user.ID, AuthProviderGoogle, &oauth2.Token{AccessToken: "access"}); err != nil {
+		t.Fatalf("storeProviderToken: %v", err)
+	}
+	var count int64
+	if err := repository.GetDB().Model(&models.OAuthToken{}).Count(&count).Error; err != nil {
+		t.Fatalf("count tokens: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("%d tokens stored with storage off, want 0", count)
+	}
+	if _, err := oauthService.GetValidProviderToken(user.ID, AuthProviderGoogle); err != ErrTokenStorageDisabled {
+		t.Errorf("GetValidProviderToken: err = %v, want ErrTokenStorageDisabled", err)
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"sso-web-app/internal/models"
+)
+
+const (
+	// MinPasswordLength is the fewest characters a new password may have; it
+	// matches the min=6 binding on the password request fields
+	MinPasswordLength = 6
+	// MaxPasswordBytes is bcrypt's input limit. It only applies without a pepper,
+	// since a peppered password reaches bcrypt as a fixed-size HMAC.
+	MaxPasswordBytes = 72
+)
+
+// Password policy requirement codes
+const (
+	PasswordRequirementMinLength   = "min_length"
+	PasswordRequirementMaxLength   = "max_length"
+	PasswordRequirementNotBreached = "not_breached"
+)
+
+// passwordStrengthWindow is the period PasswordStrengthRateLimit counts over
+const passwordStrengthWindow = time.Minute
+
+var (
+	ErrPasswordTooShort        = errors.New("password is too short")
+	ErrPasswordTooLong         = errors.New("password is too long")
+	ErrPasswordStrengthLimited = errors.New("too many password strength checks")
+)
+
+// ValidatePassword checks a new password against the password policy and returns
+// the first rule it breaks: ErrPasswordTooShort, ErrPasswordTooLong or
+// ErrPasswordBreached. Sign-up, password changes and the strength endpoint all
+// go through it, so they always agree.
+func (s *AuthService) ValidatePassword(password string) error {
+	if errs := s.passwordPolicyErrors(password); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// passwordPolicyErrors returns every policy rule the password breaks
+func (s *AuthService) passwordPolicyErrors(password string) []error {
+	var errs []error
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		errs = append(errs, ErrPasswordTooShort)
+	}
+	if len(s.passwordPepper) == 0 && len(password) > MaxPasswordBytes {
+		errs = append(errs, ErrPasswordTooLong)
+	}
+	if err := s.breachChecker.check(password); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// CheckPasswordStrength scores a candidate password for live feedback while the
+// user types. Every call counts toward clientIP's PasswordStrengthRateLimit, since
+// each one may query the breach API. The password is neither stored nor logged.
+func (s *AuthService) CheckPasswordStrength(password, clientIP string) (*models.PasswordStrengthResponse, error) {
+	now := time.Now()
+	if s.strengthThrottle.retryAfter(clientIP, now) > 0 {
+		return nil, ErrPasswordStrengthLimited
+	}
+	s.strengthThrottle.fail(clientIP, now)
+
+	response := &models.PasswordStrengthResponse{
+		Unmet:       []models.PasswordRequirement{},
+		Suggestions: []string{},
+	}
+	for _, err := range s.passwordPolicyErrors(password) {
+		response.Unmet = append(response.Unmet, passwordRequirement(err))
+	}
+	if len(response.Unmet) > 0 {
+		return response, nil
+	}
+
+	response.Valid = true
+	response.Score = 1
+	length := utf8.RuneCountInString(password)
+	if length >= 10 {
+		response.Score++
+	}
+	if length >= 14 {
+		response.Score++
+	} else {
+		response.Suggestions = append(response.Suggestions, "Use 14 or more characters")
+	}
+	if passwordCharacterClasses(password) >= 3 {
+		response.Score++
+	} else {
+		response.Suggestions = append(response.Suggestions, "Mix upper and lower case letters, digits and symbols")
+	}
+	return response, nil
+}
+
+// PasswordStrengthRetryAfter returns how long clientIP must wait before its next
+// strength check, or zero if it may check now
+func (s *AuthService) PasswordStrengthRetryAfter(clientIP string) time.Duration {
+	return s.strengthThrottle.retryAfter(clientIP, time.Now())
+}
+
+// passwordRequirement describes the rule behind a ValidatePassword error
+func passwordRequirement(err error) models.PasswordRequirement {
+	switch err {
+	case ErrPasswordTooShort:
+		return models.PasswordRequirement{
+			Code:    PasswordRequirementMinLength,
+			Message: fmt.Sprintf("Use at least %d characters", MinPasswordLength),
+		}
+	case ErrPasswordTooLong:
+		return models.PasswordRequirement{
+			Code:    PasswordRequirementMaxLength,
+			Message: fmt.Sprintf("Use at most %d bytes", MaxPasswordBytes),
+		}
+	default:
+		return models.PasswordRequirement{
+			Code:    PasswordRequirementNotBreached,
+			Message: "Choose a password that has not appeared in a data breach",
+		}
+	}
+}
+
+// passwordCharacterClasses counts which of lower case, upper case, digits and
+// other characters appear in password
+func passwordCharacterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			classes++
+		}
+	}
+	return classes
+}
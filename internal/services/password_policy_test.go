@@ -0,0 +1,77 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckPasswordStrength(t *testing.T) {
+	const breached, clean = "password123", "c0rrect-H0rse-battery"
+	server, _ := pwnedRange(t, breached, clean)
+
+	cfg := setupTestDB(t, map[string]string{"CHECK_BREACHED_PASSWORDS": "true"})
+	authService := newTestAuthService(t, cfg)
+	authService.breachChecker.rangeURL = server.URL + "/range/"
+
+	tests := []struct {
+		name      string
+		password  string
+		wantScore int
+		wantUnmet []string
+	}{
+		{"too short", "abc", 0, []string{PasswordRequirementMinLength}},
+		{"too long", strings.Repeat("a", MaxPasswordBytes+1), 0, []string{PasswordRequirementMaxLength}},
+		{"breached", breached, 0, []string{PasswordRequirementNotBreached}},
+		{"just long enough", "abcdef", 1, nil},
+		{"long, one character class", "abcdefghijklmn", 3, nil},
+		{"strong", clean, 4, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := authService.CheckPasswordStrength(tt.password, "10.0.0."+tt.name)
+			if err != nil {
+				t.Fatalf("CheckPasswordStrength: %v", err)
+			}
+
+			var unmet []string
+			for _, requirement := range got.Unmet {
+				if requirement.Message == "" {
+					t.Errorf("requirement %s has no message", requirement.Code)
+				}
+				unmet = append(unmet, requirement.Code)
+			}
+			if got.Score != tt.wantScore || strings.Join(unmet, ",") != strings.Join(tt.wantUnmet, ",") {
+				t.Errorf("score %d with unmet %v, want %d with %v", got.Score, unmet, tt.wantScore, tt.wantUnmet)
+			}
+			if tt.wantScore == 4 && len(got.Suggestions) != 0 {
+				t.Errorf("strong password got suggestions %v", got.Suggestions)
+			}
+
+			// The meter and the sign-up gate agree
+			if valid := authService.ValidatePassword(tt.password) == nil; got.Valid != valid {
+				t.Errorf("valid = %v, but ValidatePassword accepts it: %v", got.Valid, valid)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordStrengthRateLimit(t *testing.T) {
+	cfg := setupTestDB(t, map[string]string{"PASSWORD_STRENGTH_RATE_LIMIT": "2"})
+	authService := newTestAuthService(t, cfg)
+
+	for i := 0; i < 2; i++ {
+		if _, err := authService.CheckPasswordStrength("abcdef", "10.0.0.1"); err != nil {
+			t.Fatalf("check %d: %v", i+1, err)
+		}
+	}
+	if _, err := authService.CheckPasswordStrength("abcdef", "10.0.0.1"); err != ErrPasswordStrengthLimited {
+		t.Errorf("check over the limit: err = %v, want ErrPasswordStrengthLimited", err)
+	}
+	if authService.PasswordStrengthRetryAfter("10.0.0.1") <= 0 {
+		t.Error("no retry delay reported over the limit")
+	}
+	if _, err := authService.CheckPasswordStrength("abcdef", "10.0.0.2"); err != nil {
+		t.Errorf("another client: err = %v, want nil", err)
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"sso-web-app/internal/cryptoutil"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/timeutil"
+)
+
+const (
+	// phoneCodeTTL is how long an SMS verification code stays valid
+	phoneCodeTTL = 10 * time.Minute
+	// phoneCodeResendInterval throttles how often a code can be texted, to any number
+	phoneCodeResendInterval = time.Minute
+	// phoneCodeMaxAttempts is how many wrong codes are accepted before a new one is needed
+	phoneCodeMaxAttempts = 5
+)
+
+var (
+	ErrPhoneVerificationDisabled = errors.New("phone numbers are not enabled")
+	ErrInvalidPhoneNumber        = errors.New("phone number must be in international format, e.g. +14155550123")
+	ErrInvalidPhoneCode          = errors.New("verification code is incorrect")
+	ErrPhoneCodeExpired          = errors.New("verification code has expired; request a new one")
+)
+
+// e164Digits matches the digits of an E.164 number: a country code that does not
+// start with 0, and at most 15 digits in all
+var e164Digits = regexp.MustCompile(`^[1-9][0-9]{6,14}$`)
+
+// normalizePhoneNumber returns number in E.164 form (+ and digits only). Spaces,
+// dashes, dots and parentheses are dropped and a leading 00 is read as +. A number
+// without either takes countryCode, losing its trunk 0, and is refused without one.
+func normalizePhoneNumber(number, countryCode string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(number))
+
+	switch {
+	case strings.HasPrefix(digits, "+"):
+		digits = digits[1:]
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	case countryCode != "":
+		digits = countryCode + strings.TrimPrefix(digits, "0")
+	default:
+		return "", ErrInvalidPhoneNumber
+	}
+
+	if !e164Digits.MatchString(digits) {
+		return "", ErrInvalidPhoneNumber
+	}
+	return "+" + digits, nil
+}
+
+// PhoneVerificationEnabled reports whether users may add a phone number
+func (s *AuthService) PhoneVerificationEnabled() bool {
+	return s.phoneVerification
+}
+
+// SetPhoneNumber sets the user's phone number and texts a code to verify it. An
+// empty number removes it. Submitting the current, unverified number again sends
+// a new code.
+func (s *AuthService) SetPhoneNumber(userID uint, number string) (*models.User, error) {
+	if !s.phoneVerification {
+		return nil, ErrPhoneVerificationDisabled
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if strings.TrimSpace(number) == "" {
+		user.PhoneNumber = nil
+		user.PhoneVerified = false
+		user.PhoneCodeHash = ""
+		user.PhoneCodeAttempts = 0
+		return s.userRepo.Update(user)
+	}
+
+	normalized, err := normalizePhoneNumber(number, s.phoneCountryCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.PhoneNumber != nil && *user.PhoneNumber == normalized && user.PhoneVerified {
+		return user, nil
+	}
+	// The wait applies whichever number is asked for, so the account cannot be
+	// used to text many numbers quickly
+	if PhoneCodeRetryAfter(user) > 0 {
+		return nil, ErrVerificationCooldown
+	}
+
+	user.PhoneNumber = &normalized
+	user.PhoneVerified = false
+	return user, s.sendPhoneCode(user)
+}
+
+// PhoneCodeRetryAfter returns how long until another code can be texted for the
+// user, or zero when one can be sent now
+func PhoneCodeRetryAfter(user *models.User) time.Duration {
+	if user.PhoneCodeSentAt == nil {
+		return 0
+	}
+	if wait := phoneCodeResendInterval - timeutil.Now().Sub(*user.PhoneCodeSentAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// VerifyPhoneNumber marks the user's phone number as verified when code is the
+// one last texted to it. After phoneCodeMaxAttempts wrong codes, or once the code
+// is older than phoneCodeTTL, ErrPhoneCodeExpired is returned until a new one is sent.
+func (s *AuthService) VerifyPhoneNumber(userID uint, code string) (*models.User, error) {
+	if !s.phoneVerification {
+		return nil, ErrPhoneVerificationDisabled
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if user.PhoneNumber == nil || user.PhoneCodeHash == "" {
+		return nil, ErrInvalidPhoneCode
+	}
+	if user.PhoneCodeSentAt == nil || timeutil.Now().Sub(*user.PhoneCodeSentAt) > phoneCodeTTL ||
+		user.PhoneCodeAttempts >= phoneCodeMaxAttempts {
+		return nil, ErrPhoneCodeExpired
+	}
+
+	if !cryptoutil.Equal(phoneCodeHash(*user.PhoneNumber, code), user.PhoneCodeHash) {
+		// Concurrent guesses conflict on the user's version, so each one counts
+		user.PhoneCodeAttempts++
+		if _, err := s.userRepo.Update(user); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidPhoneCode
+	}
+
+	user.PhoneVerified = true
+	user.PhoneCodeHash = ""
+	user.PhoneCodeAttempts = 0
+	return s.userRepo.Update(user)
+}
+
+// phoneCodeHash is the stored form of a code, tied to the number it was sent to
+func phoneCodeHash(number, code string) string {
+	return HashInviteToken(number + ":" + code)
+}
+
+// sendPhoneCode stores a fresh code for the user's phone number and texts it in
+// the background, as sendVerificationEmail does for email links
+func (s *AuthService) sendPhoneCode(user *models.User) error {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		log.Printf("Failed to generate phone code for user %d: %v", user.ID, err)
+		return err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	now := timeutil.Now()
+	user.PhoneCodeHash = phoneCodeHash(*user.PhoneNumber, code)
+	user.PhoneCodeSentAt = &now
+	user.PhoneCodeAttempts = 0
+	if _, err := s.userRepo.Update(user); err != nil {
+		log.Printf("Failed to store phone code for user %d: %v", user.ID, err)
+		return err
+	}
+
+	message := fmt.Sprintf("Your SSO Web App verification code is %s. It expires in %d minutes.", code, int(phoneCodeTTL.Minutes()))
+	go func(userID uint, number string) {
+		if err := s.sms.Send(number, message); err != nil {
+			log.Printf("Failed to send phone verification code to user %d: %v", userID, err)
+		}
+	}(user.ID, *user.PhoneNumber)
+	return nil
+}
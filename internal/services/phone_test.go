@@ -0,0 +1,206 @@
+package services
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+type sentSMS struct {
+	to, message string
+}
+
+// smsRecorder is an SMSSender that keeps the messages it is given
+type smsRecorder struct {
+	sent chan sentSMS
+}
+
+func (s *smsRecorder) Send(to, message string) error {
+	s.sent <- sentSMS{to, message}
+	return nil
+}
+
+// nextCode waits for a text sent in the background and returns the number and code
+func (s *smsRecorder) nextCode(t *testing.T) (string, string) {
+	t.Helper()
+	select {
+	case sms := <-s.sent:
+		code := phoneCodePattern.FindString(sms.message)
+		if code == "" {
+			t.Fatalf("no code in %q", sms.message)
+		}
+		return sms.to, code
+	case <-time.After(2 * time.Second):
+		t.Fatal("no SMS sent")
+		return "", ""
+	}
+}
+
+var phoneCodePattern = regexp.MustCompile(`\b[0-9]{6}\b`)
+
+// newTestPhoneService returns an AuthService with phone numbers enabled that texts
+// through the returned recorder
+func newTestPhoneService(t *testing.T, cfg *configs.Config) (*AuthService, *smsRecorder) {
+	t.Helper()
+	cfg.PhoneVerification = true
+	cfg.PhoneCountryCode = "44"
+	signingKeys, err := NewSigningKeyService(cfg)
+	if err != nil {
+		t.Fatalf("NewSigningKeyService: %v", err)
+	}
+	mailer := NewMailer(cfg)
+	sms := &smsRecorder{sent: make(chan sentSMS, 16)}
+	return NewAuthService(cfg, mailer, sms, signingKeys, NewAuditService(cfg, NewAlertService(cfg, mailer))), sms
+}
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	tests := []struct {
+		number      string
+		countryCode string
+		want        string
+		wantErr     bool
+	}{
+		{"+1 (415) 555-0123", "", "+14155550123", false},
+		{"0044 20 7946 0958", "", "+442079460958", false},
+		{"020 7946 0958", "44", "+442079460958", false},
+		{"020 7946 0958", "", "", true},
+		{"+0 415 555 0123", "", "", true},
+		{"+1 415", "", "", true},
+		{"+1234567890123456", "", "", true},
+		{"+1 415 555 CALL", "", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizePhoneNumber(tt.number, tt.countryCode)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizePhoneNumber(%q, %q) = %q, %v; want %q, error %v", tt.number, tt.countryCode, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPhoneVerification(t *testing.T) {
+	// setPhone sets a new user's number and returns the code texted to it
+	setPhone := func(t *testing.T, authService *AuthService, sms *smsRecorder) (*models.User, string) {
+		t.Helper()
+		user := createTestUser(t, authService, "phone@example.com", "Passw0rd!x")
+		user, err := authService.SetPhoneNumber(user.ID, "07700 900123")
+		if err != nil {
+			t.Fatalf("SetPhoneNumber: %v", err)
+		}
+		if user.PhoneNumber == nil || *user.PhoneNumber != "+447700900123" || user.PhoneVerified {
+			t.Fatalf("phone = %v, verified %v; want unverified +447700900123", user.PhoneNumber, user.PhoneVerified)
+		}
+		to, code := sms.nextCode(t)
+		if to != "+447700900123" {
+			t.Errorf("code texted to %q, want +447700900123", to)
+		}
+		return user, code
+	}
+
+	t.Run("correct code", func(t *testing.T) {
+		authService, sms := newTestPhoneService(t, setupTestDB(t, nil))
+		user, code := setPhone(t, authService, sms)
+
+		verified, err := authService.VerifyPhoneNumber(user.ID, code)
+		if err != nil {
+			t.Fatalf("VerifyPhoneNumber: %v", err)
+		}
+		if !verified.PhoneVerified {
+			t.Error("phone not verified")
+		}
+		// The code is spent
+		if _, err := authService.VerifyPhoneNumber(user.ID, code); err != ErrInvalidPhoneCode {
+			t.Errorf("reusing the code: error = %v, want %v", err, ErrInvalidPhoneCode)
+		}
+	})
+
+	t.Run("incorrect code", func(t *testing.T) {
+		authService, sms := newTestPhoneService(t, setupTestDB(t, nil))
+		user, code := setPhone(t, authService, sms)
+
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+		if _, err := authService.VerifyPhoneNumber(user.ID, wrong); err != ErrInvalidPhoneCode {
+			t.Fatalf("error = %v, want %v", err, ErrInvalidPhoneCode)
+		}
+		if _, err := authService.VerifyPhoneNumber(user.ID, code); err != nil {
+			t.Errorf("correct code after one wrong guess: %v", err)
+		}
+	})
+
+	t.Run("too many incorrect codes", func(t *testing.T) {
+		authService, sms := newTestPhoneService(t, setupTestDB(t, nil))
+		user, code := setPhone(t, authService, sms)
+
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+		for i := 0; i < phoneCodeMaxAttempts; i++ {
+			if _, err := authService.VerifyPhoneNumber(user.ID, wrong); err != ErrInvalidPhoneCode {
+				t.Fatalf("guess %d: error = %v, want %v", i+1, err, ErrInvalidPhoneCode)
+			}
+		}
+		if _, err := authService.VerifyPhoneNumber(user.ID, code); err != ErrPhoneCodeExpired {
+			t.Errorf("correct code after %d wrong guesses: error = %v, want %v", phoneCodeMaxAttempts, err, ErrPhoneCodeExpired)
+		}
+	})
+
+	t.Run("expired code", func(t *testing.T) {
+		authService, sms := newTestPhoneService(t, setupTestDB(t, nil))
+		user, code := setPhone(t, authService, sms)
+
+		sentAt := time.Now().Add(-phoneCodeTTL - time.Minute)
+		if err := repository.GetDB().Model(&models.User{}).Where("id = ?", user.ID).Update("phone_code_sent_at", sentAt).Error; err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if _, err := authService.VerifyPhoneNumber(user.ID, code); err != ErrPhoneCodeExpired {
+			t.Fatalf("error = %v, want %v", err, ErrPhoneCodeExpired)
+		}
+
+		// A new code can be requested for the same number
+		if _, err := authService.SetPhoneNumber(user.ID, "+447700900123"); err != nil {
+			t.Fatalf("SetPhoneNumber: %v", err)
+		}
+		_, code = sms.nextCode(t)
+		if _, err := authService.VerifyPhoneNumber(user.ID, code); err != nil {
+			t.Errorf("new code: %v", err)
+		}
+	})
+
+	t.Run("resend too soon", func(t *testing.T) {
+		authService, sms := newTestPhoneService(t, setupTestDB(t, nil))
+		user, _ := setPhone(t, authService, sms)
+
+		if _, err := authService.SetPhoneNumber(user.ID, "+14155550123"); err != ErrVerificationCooldown {
+			t.Errorf("error = %v, want %v", err, ErrVerificationCooldown)
+		}
+	})
+
+	t.Run("invalid number", func(t *testing.T) {
+		authService, _ := newTestPhoneService(t, setupTestDB(t, nil))
+		user := createTestUser(t, authService, "phone@example.com", "Passw0rd!x")
+
+		if _, err := authService.SetPhoneNumber(user.ID, "+1 415"); err != ErrInvalidPhoneNumber {
+			t.Errorf("error = %v, want %v", err, ErrInvalidPhoneNumber)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		authService := newTestAuthService(t, setupTestDB(t, nil))
+		user := createTestUser(t, authService, "phone@example.com", "Passw0rd!x")
+
+		if _, err := authService.SetPhoneNumber(user.ID, "+14155550123"); err != ErrPhoneVerificationDisabled {
+			t.Errorf("SetPhoneNumber error = %v, want %v", err, ErrPhoneVerificationDisabled)
+		}
+		if _, err := authService.VerifyPhoneNumber(user.ID, "123456"); err != ErrPhoneVerificationDisabled {
+			t.Errorf("VerifyPhoneNumber error = %v, want %v", err, ErrPhoneVerificationDisabled)
+		}
+	})
+}
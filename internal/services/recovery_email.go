@@ -0,0 +1,144 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/timeutil"
+)
+
+var (
+	ErrRecoveryEmailIsPrimary = errors.New("recovery email must differ from your account email")
+	ErrRecoveryEmailInUse     = errors.New("recovery email is another account's email address")
+)
+
+// SetRecoveryEmail sets the user's recovery email and mails a link to verify it.
+// An empty email removes it. Submitting the current recovery email again only
+// updates notify, and re-sends the link if it is still unverified.
+//
+// A recovery email may not be any account's primary email, compared ignoring
+// case, so notices meant for one account never land in another account's inbox.
+// Several accounts may share a recovery email: it is never used to sign in or to
+// look an account up.
+func (s *AuthService) SetRecoveryEmail(userID uint, email string, notify bool) (*models.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	email = strings.TrimSpace(email)
+	if email == "" {
+		user.RecoveryEmail = nil
+		user.RecoveryEmailVerified = false
+		user.RecoveryNotifications = false
+		user.RecoveryTokenHash = ""
+		user.RecoverySentAt = nil
+		return s.userRepo.Update(user)
+	}
+
+	user.RecoveryNotifications = notify
+
+	if user.RecoveryEmail != nil && strings.EqualFold(*user.RecoveryEmail, email) {
+		if user.RecoveryEmailVerified {
+			return s.userRepo.Update(user)
+		}
+		if RecoveryEmailRetryAfter(user) > 0 {
+			return nil, ErrVerificationCooldown
+		}
+		return user, s.sendRecoveryVerificationEmail(user)
+	}
+
+	if strings.EqualFold(user.Email, email) {
+		return nil, ErrRecoveryEmailIsPrimary
+	}
+	if err := s.disposableEmails.check(email); err != nil {
+		return nil, err
+	}
+	inUse, err := s.userRepo.ExistsByEmailFold(email)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		return nil, ErrRecoveryEmailInUse
+	}
+
+	user.RecoveryEmail = &email
+	user.RecoveryEmailVerified = false
+	user.RecoverySentAt = nil
+	return user, s.sendRecoveryVerificationEmail(user)
+}
+
+// RecoveryEmailRetryAfter returns how long until another recovery email link can
+// be mailed to the user, or zero when one can be sent now
+func RecoveryEmailRetryAfter(user *models.User) time.Duration {
+	if user.RecoverySentAt == nil {
+		return 0
+	}
+	if wait := verificationResendInterval - timeutil.Now().Sub(*user.RecoverySentAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// VerifyRecoveryEmail marks the recovery email owning token as verified
+func (s *AuthService) VerifyRecoveryEmail(token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user, err := s.userRepo.GetByRecoveryTokenHash(HashInviteToken(token))
+	if err != nil || user.RecoveryEmail == nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	if user.RecoverySentAt == nil || timeutil.Now().Sub(*user.RecoverySentAt) > verificationTTL {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user.RecoveryEmailVerified = true
+	user.RecoveryTokenHash = ""
+	return s.userRepo.Update(user)
+}
+
+// sendRecoveryVerificationEmail stores a fresh token for the user's recovery email
+// and mails the link to that address in the background, as sendVerificationEmail
+// does for the primary one
+func (s *AuthService) sendRecoveryVerificationEmail(user *models.User) error {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		log.Printf("Failed to generate recovery email token for user %d: %v", user.ID, err)
+		return err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	now := timeutil.Now()
+	user.RecoveryTokenHash = HashInviteToken(token)
+	user.RecoverySentAt = &now
+	if _, err := s.userRepo.Update(user); err != nil {
+		log.Printf("Failed to store recovery email token for user %d: %v", user.ID, err)
+		return err
+	}
+
+	body := fmt.Sprintf(`Hi %s,
+
+This address was added as the recovery email of the account %s. Please confirm it by opening this link:
+
+%s/verify-recovery-email?token=%s
+
+The link expires in %d hours. If you did not expect this, you can ignore this email.
+`, user.FirstName, user.Email, s.baseURL, url.QueryEscape(token), int(verificationTTL.Hours()))
+
+	go func(email string) {
+		if err := s.mailer.Send(email, "Confirm your recovery email address", body); err != nil {
+			log.Printf("Failed to send recovery email verification to %s: %v", email, err)
+		}
+	}(*user.RecoveryEmail)
+	return nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var recoveryTokenPattern = regexp.MustCompile(`verify-recovery-email\?token=([0-9a-f]+)`)
+
+func TestRecoveryEmail(t *testing.T) {
+	setup := func(t *testing.T) (*AuthService, *mailRecorder) {
+		t.Helper()
+		cfg := setupTestDB(t, nil)
+		mailer := newMailRecorder()
+		return newTestAuthServiceWithMailer(t, cfg, mailer), mailer
+	}
+
+	t.Run("set and verify", func(t *testing.T) {
+		authService, mailer := setup(t)
+		user := createTestUser(t, authService, "user@example.com", "Passw0rd!x")
+
+		updated, err := authService.SetRecoveryEmail(user.ID, " backup@example.net ", true)
+		if err != nil {
+			t.Fatalf("SetRecoveryEmail: %v", err)
+		}
+		if updated.RecoveryEmail == nil || *updated.RecoveryEmail != "backup@example.net" || updated.RecoveryEmailVerified {
+			t.Fatalf("recovery email = %v (verified %v), want backup@example.net unverified", updated.RecoveryEmail, updated.RecoveryEmailVerified)
+		}
+
+		// The link goes to the recovery address, not the primary one
+		mail := mailer.next(t)
+		if mail.to != "backup@example.net" {
+			t.Errorf("verification mailed to %s, want backup@example.net", mail.to)
+		}
+		match := recoveryTokenPattern.FindStringSubmatch(mail.body)
+		if match == nil {
+			t.Fatalf("no verification link in:\n%s", mail.body)
+		}
+
+		if _, err := authService.VerifyRecoveryEmail("not-a-token"); err != ErrInvalidVerificationToken {
+			t.Errorf("VerifyRecoveryEmail(bad token) = %v, want ErrInvalidVerificationToken", err)
+		}
+		verified, err := authService.VerifyRecoveryEmail(match[1])
+		if err != nil {
+			t.Fatalf("VerifyRecoveryEmail: %v", err)
+		}
+		if !verified.RecoveryEmailVerified {
+			t.Error("recovery email not verified")
+		}
+		// The token is single use
+		if _, err := authService.VerifyRecoveryEmail(match[1]); err != ErrInvalidVerificationToken {
+			t.Errorf("second VerifyRecoveryEmail = %v, want ErrInvalidVerificationToken", err)
+		}
+	})
+
+	t.Run("rejected addresses", func(t *testing.T) {
+		authService, mailer := setup(t)
+		user := createTestUser(t, authService, "user@example.com", "Passw0rd!x")
+		createTestUser(t, authService, "other@example.com", "Passw0rd!x")
+
+		if _, err := authService.SetRecoveryEmail(user.ID, "USER@example.com", true); err != ErrRecoveryEmailIsPrimary {
+			t.Errorf("own primary email: err = %v, want ErrRecoveryEmailIsPrimary", err)
+		}
+		if _, err := authService.SetRecoveryEmail(user.ID, "Other@Example.com", true); err != ErrRecoveryEmailInUse {
+			t.Errorf("another account's email: err = %v, want ErrRecoveryEmailInUse", err)
+		}
+		mailer.none(t)
+	})
+
+	t.Run("security notices", func(t *testing.T) {
+		cfg := setupTestDB(t, nil)
+		cfg.NewDeviceAlerts = true
+		mailer := newMailRecorder()
+		authService := newTestAuthServiceWithMailer(t, cfg, mailer)
+		user := createTestUser(t, authService, "user@example.com", "Passw0rd!x")
+
+		if _, err := authService.SetRecoveryEmail(user.ID, "backup@example.net", true); err != nil {
+			t.Fatalf("SetRecoveryEmail: %v", err)
+		}
+		match := recoveryTokenPattern.FindStringSubmatch(mailer.next(t).body)
+		if match == nil {
+			t.Fatal("no verification link mailed")
+		}
+
+		// Unverified, notices go only to the primary email
+		user, err := authService.GetUserByID(user.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if got := user.SecurityNoticeEmails(); strings.Join(got, ",") != "user@example.com" {
+			t.Errorf("notices before verifying go to %v, want only user@example.com", got)
+		}
+
+		if _, err := authService.VerifyRecoveryEmail(match[1]); err != nil {
+			t.Fatalf("VerifyRecoveryEmail: %v", err)
+		}
+		user, err = authService.GetUserByID(user.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+
+		devices := NewDeviceService(cfg, mailer)
+		devices.RecordSignIn(user, "192.0.2.1", "laptop")
+		devices.RecordSignIn(user, "198.51.100.7", "phone")
+		got := []string{mailer.next(t).to, mailer.next(t).to}
+		sort.Strings(got)
+		if got[0] != "backup@example.net" || got[1] != "user@example.com" {
+			t.Errorf("new device alert went to %v, want both addresses", got)
+		}
+
+		// Turning notices off keeps the address for recovery but stops the copies
+		if _, err := authService.SetRecoveryEmail(user.ID, "backup@example.net", false); err != nil {
+			t.Fatalf("SetRecoveryEmail: %v", err)
+		}
+		user, err = authService.GetUserByID(user.ID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if got := user.SecurityNoticeEmails(); strings.Join(got, ",") != "user@example.com" {
+			t.Errorf("notices with recovery copies off go to %v, want only user@example.com", got)
+		}
+	})
+}
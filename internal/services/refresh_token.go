@@ -0,0 +1,215 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"sso-web-app/internal/cryptoutil"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/timeutil"
+)
+
+var (
+	ErrInvalidRefreshToken   = errors.New("refresh token is invalid or has expired")
+	ErrRefreshDeviceMismatch = errors.New("refresh token was presented from a different device")
+	ErrDeviceIDRequired      = errors.New("a device ID is required for a refresh token")
+)
+
+// Refresh token binding modes
+const (
+	RefreshBindingStrict  = "strict"
+	RefreshBindingLenient = "lenient"
+)
+
+// SessionRefresh is the result of exchanging a refresh token
+type SessionRefresh struct {
+	Token        string
+	RefreshToken string
+	User         *models.User
+}
+
+// RefreshFingerprint identifies the device a refresh token is bound to by hashing
+// the client's user agent and its self-generated device ID
+func RefreshFingerprint(userAgent, deviceID string) string {
+	hash := sha256.Sum256([]byte(userAgent + "|" + deviceID))
+	return hex.EncodeToString(hash[:])
+}
+
+func hashRefreshValue(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
+
+// IssueRefreshToken starts a refresh token family for the session behind token,
+// bound to the device that signed in. The family, like a sliding session, ends
+// SESSION_MAX_LIFETIME after sign-in.
+func (s *SessionService) IssueRefreshToken(user *models.User, token, deviceID, userAgent string) (string, error) {
+	if deviceID == "" {
+		return "", ErrDeviceIDRequired
+	}
+
+	claims, err := s.authService.ValidateJWT(token)
+	if err != nil {
+		return "", err
+	}
+	session, err := s.sessionRepo.GetByTokenID(claims.ID)
+	if err != nil {
+		return "", err
+	}
+
+	familyID := make([]byte, 16)
+	if _, err := rand.Read(familyID); err != nil {
+		return "", err
+	}
+
+	return s.createRefreshToken(&models.RefreshToken{
+		UserID:       user.ID,
+		SessionID:    session.ID,
+		FamilyID:     hex.EncodeToString(familyID),
+		Fingerprint:  RefreshFingerprint(userAgent, deviceID),
+		DeviceHash:   hashRefreshValue(deviceID),
+		TokenVersion: user.TokenVersion,
+		AuthTime:     claims.AuthTime,
+		AuthProvider: claims.AuthProvider,
+		ExpiresAt:    claims.AuthTime.Add(s.maxLifetime),
+	})
+}
+
+// Refresh exchanges a refresh token for a new session token and a new refresh
+// token in the same family. The session keeps its sign-in time, so a refresh never
+// counts as re-authentication. A token presented from another device, or presented
+// again after it was used, revokes the whole family and its session, so the
+// legitimate holder must sign in again; ErrRefreshDeviceMismatch reports the former.
+func (s *SessionService) Refresh(refreshToken, deviceID, userAgent string) (*SessionRefresh, error) {
+	stored, err := s.refreshRepo.GetByHash(hashRefreshValue(refreshToken))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	now := timeutil.Now()
+	if stored.RevokedAt != nil || !now.Before(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// A used token coming back means a copy exists somewhere else
+	if stored.UsedAt != nil {
+		log.Printf("Refresh token reuse for user %d; revoking session %d", stored.UserID, stored.SessionID)
+		s.revokeRefreshFamily(stored, now)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if !s.deviceMatches(stored, deviceID, userAgent) {
+		log.Printf("Refresh token for user %d presented from another device; revoking session %d", stored.UserID, stored.SessionID)
+		s.revokeRefreshFamily(stored, now)
+		return nil, ErrRefreshDeviceMismatch
+	}
+
+	used, err := s.refreshRepo.MarkUsed(stored.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		// Another request exchanged the same token first
+		s.revokeRefreshFamily(stored, now)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessionRepo.GetByID(stored.SessionID)
+	if err != nil || session.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.authService.GetUserByID(stored.UserID)
+	if err != nil || !user.IsActive || user.DeactivationDue(now) || user.TokenVersion != stored.TokenVersion {
+		s.revokeRefreshFamily(stored, now)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	expiresAt := now.Add(s.authService.tokenLifetime(user.Role, user.IsAdmin))
+	if expiresAt.After(stored.ExpiresAt) {
+		expiresAt = stored.ExpiresAt
+	}
+	token, err := s.authService.RefreshJWT(&models.JWTClaims{
+		UserID:       user.ID,
+		AuthTime:     stored.AuthTime,
+		AuthProvider: stored.AuthProvider,
+	}, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	newClaims, err := s.authService.ValidateJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// The session moves to the new token; the one it replaces stops working
+	revokedTokens.add(session.TokenID, session.ExpiresAt)
+	if _, err := s.sessionRepo.ReplaceToken(session, newClaims.ID, newClaims.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	next, err := s.createRefreshToken(&models.RefreshToken{
+		UserID:       stored.UserID,
+		SessionID:    stored.SessionID,
+		FamilyID:     stored.FamilyID,
+		Fingerprint:  stored.Fingerprint,
+		DeviceHash:   stored.DeviceHash,
+		TokenVersion: stored.TokenVersion,
+		AuthTime:     stored.AuthTime,
+		AuthProvider: stored.AuthProvider,
+		ExpiresAt:    stored.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionRefresh{Token: token, RefreshToken: next, User: user}, nil
+}
+
+// deviceMatches reports whether a refresh token is being presented from the device
+// it was issued to. Lenient binding ignores the user agent.
+func (s *SessionService) deviceMatches(stored *models.RefreshToken, deviceID, userAgent string) bool {
+	if deviceID == "" {
+		return false
+	}
+	if s.binding == RefreshBindingLenient {
+		return cryptoutil.Equal(stored.DeviceHash, hashRefreshValue(deviceID))
+	}
+	return cryptoutil.Equal(stored.Fingerprint, RefreshFingerprint(userAgent, deviceID))
+}
+
+// createRefreshToken stores template under a freshly generated token and returns the token
+func (s *SessionService) createRefreshToken(template *models.RefreshToken) (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	template.TokenHash = hashRefreshValue(token)
+	if _, err := s.refreshRepo.Create(template); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// revokeRefreshFamily revokes every refresh token descended from the same sign-in
+// and signs out the session they renew. Failures are logged.
+func (s *SessionService) revokeRefreshFamily(stored *models.RefreshToken, now time.Time) {
+	if err := s.refreshRepo.RevokeFamily(stored.FamilyID, now); err != nil {
+		log.Printf("Failed to revoke refresh tokens for user %d: %v", stored.UserID, err)
+	}
+
+	session, err := s.sessionRepo.GetByID(stored.SessionID)
+	if err != nil || session.RevokedAt != nil {
+		return
+	}
+	revokedTokens.add(session.TokenID, session.ExpiresAt)
+	if err := s.sessionRepo.Revoke([]uint{session.ID}, now); err != nil {
+		log.Printf("Failed to revoke session %d for user %d: %v", session.ID, stored.UserID, err)
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import "testing"
+
+func TestRefreshDeviceBinding(t *testing.T) {
+	tests := []struct {
+		name      string
+		binding   string
+		deviceID  string
+		userAgent string
+		wantErr   error
+	}{
+		{"strict, same device", RefreshBindingStrict, "device-1", "agent-1", nil},
+		{"strict, other user agent", RefreshBindingStrict, "device-1", "agent-2", ErrRefreshDeviceMismatch},
+		{"strict, other device", RefreshBindingStrict, "device-2", "agent-1", ErrRefreshDeviceMismatch},
+		{"strict, no device ID", RefreshBindingStrict, "", "agent-1", ErrRefreshDeviceMismatch},
+		{"lenient, other user agent", RefreshBindingLenient, "device-1", "agent-2", nil},
+		{"lenient, other device", RefreshBindingLenient, "device-2", "agent-1", ErrRefreshDeviceMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, map[string]string{"REFRESH_TOKEN_BINDING": tt.binding})
+			authService := newTestAuthService(t, cfg)
+			sessions := NewSessionService(cfg, authService)
+			user := createTestUser(t, authService, "refresh@example.com", "Passw0rd!x")
+
+			token, err := authService.GenerateJWT(user)
+			if err != nil {
+				t.Fatalf("GenerateJWT: %v", err)
+			}
+			if _, err := sessions.Start(user, token, "10.0.0.1", "agent-1"); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			refreshToken, err := sessions.IssueRefreshToken(user, token, "device-1", "agent-1")
+			if err != nil {
+				t.Fatalf("IssueRefreshToken: %v", err)
+			}
+
+			result, err := sessions.Refresh(refreshToken, tt.deviceID, tt.userAgent)
+			if err != tt.wantErr {
+				t.Fatalf("Refresh error = %v, want %v", err, tt.wantErr)
+			}
+
+			// The session token is replaced on success and signed out on a mismatch;
+			// either way the one issued at sign-in stops working
+			if _, err := authService.ValidateJWT(token); err == nil {
+				t.Error("original session token still valid")
+			}
+
+			if tt.wantErr != nil {
+				// The family is revoked, so even the right device cannot use it now
+				if _, err := sessions.Refresh(refreshToken, "device-1", "agent-1"); err != ErrInvalidRefreshToken {
+					t.Errorf("refresh after a mismatch = %v, want ErrInvalidRefreshToken", err)
+				}
+				return
+			}
+
+			if _, err := authService.ValidateJWT(result.Token); err != nil {
+				t.Errorf("refreshed session token rejected: %v", err)
+			}
+			if _, err := sessions.Refresh(result.RefreshToken, tt.deviceID, tt.userAgent); err != nil {
+				t.Errorf("rotated refresh token rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	authService := newTestAuthService(t, cfg)
+	sessions := NewSessionService(cfg, authService)
+	user := createTestUser(t, authService, "reuse@example.com", "Passw0rd!x")
+
+	token, err := authService.GenerateJWT(user)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	if _, err := sessions.Start(user, token, "10.0.0.1", "agent-1"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	first, err := sessions.IssueRefreshToken(user, token, "device-1", "agent-1")
+	if err != nil {
+		t.Fatalf("IssueRefreshToken: %v", err)
+	}
+	result, err := sessions.Refresh(first, "device-1", "agent-1")
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// Presenting the used token again looks like theft and ends the session
+	if _, err := sessions.Refresh(first, "device-1", "agent-1"); err != ErrInvalidRefreshToken {
+		t.Fatalf("reused refresh token error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := sessions.Refresh(result.RefreshToken, "device-1", "agent-1"); err != ErrInvalidRefreshToken {
+		t.Errorf("descendant refresh token error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := authService.ValidateJWT(result.Token); err == nil {
+		t.Error("session token from the family still valid")
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sso-web-app/configs"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
+)
+
+// retentionBatchSize caps how many rows are archived and deleted per query, so a
+// large backlog never holds the database for long
+const retentionBatchSize = 500
+
+// RetentionResult counts the rows removed by one cleanup
+type RetentionResult struct {
+	AuditLogs     int
+	Sessions      int
+	RefreshTokens int
+	LoginHistory  int
+}
+
+// RetentionService deletes audit entries, ended sessions and login history once
+// they are older than the configured retention periods, archiving them first when
+// an archive directory is set
+type RetentionService struct {
+	auditRepo        repository.AuditLogRepository
+	sessionRepo      repository.SessionRepository
+	refreshRepo      repository.RefreshTokenRepository
+	loginHistoryRepo repository.LoginHistoryRepository
+	config           *configs.Config
+}
+
+// NewRetentionService runs a cleanup straight away and then every
+// RetentionCheckInterval. Nothing runs when both retention periods are 0.
+func NewRetentionService(cfg *configs.Config) *RetentionService {
+	s := &RetentionService{
+		auditRepo:        repository.NewAuditLogRepository(),
+		sessionRepo:      repository.NewSessionRepository(),
+		refreshRepo:      repository.NewRefreshTokenRepository(),
+		loginHistoryRepo: repository.NewLoginHistoryRepository(),
+		config:           cfg,
+	}
+
+	if cfg.AuditRetentionDays > 0 || cfg.ActivityRetentionDays > 0 {
+		go s.run(cfg.RetentionCheckInterval)
+	}
+	return s
+}
+
+// Cleanup deletes every row past its retention period as of now. A table whose
+// archive cannot be written is left untouched.
+func (s *RetentionService) Cleanup(now time.Time) (RetentionResult, error) {
+	var result RetentionResult
+
+	if cutoff, ok := retentionCutoff(now, s.config.AuditRetentionDays); ok {
+		n, err := s.purgeAuditLogs(cutoff, now)
+		result.AuditLogs = n
+		if err != nil {
+			return result, fmt.Errorf("audit logs: %w", err)
+		}
+	}
+
+	if cutoff, ok := retentionCutoff(now, s.config.ActivityRetentionDays); ok {
+		n, err := s.purgeRefreshTokens(cutoff, now)
+		result.RefreshTokens = n
+		if err != nil {
+			return result, fmt.Errorf("refresh tokens: %w", err)
+		}
+
+		n, err = s.purgeSessions(cutoff, now)
+		result.Sessions = n
+		if err != nil {
+			return result, fmt.Errorf("sessions: %w", err)
+		}
+
+		n, err = s.purgeLoginHistory(cutoff, now)
+		result.LoginHistory = n
+		if err != nil {
+			return result, fmt.Errorf("login history: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// retentionCutoff returns the time before which rows are deleted, or false when
+// days is 0. Periods below MinRetentionDays are raised to it, so a bad value can
+// never wipe recent history.
+func retentionCutoff(now time.Time, days int) (time.Time, bool) {
+	if days <= 0 {
+		return time.Time{}, false
+	}
+	if days < configs.MinRetentionDays {
+		days = configs.MinRetentionDays
+	}
+	return now.AddDate(0, 0, -days), true
+}
+
+func (s *RetentionService) purgeAuditLogs(cutoff, now time.Time) (int, error) {
+	deleted := 0
+	for {
+		entries, err := s.auditRepo.ListBefore(cutoff, retentionBatchSize)
+		if err != nil || len(entries) == 0 {
+			return deleted, err
+		}
+
+		ids := make([]uint, len(entries))
+		for i, entry := range entries {
+			ids[i] = entry.ID
+		}
+		if err := archiveRows(s.config.RetentionArchiveDir, "audit_logs", now, entries); err != nil {
+			return deleted, err
+		}
+		if err := s.auditRepo.DeleteByIDs(ids); err != nil {
+			return deleted, err
+		}
+		deleted += len(ids)
+
+		if len(entries) < retentionBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+func (s *RetentionService) purgeSessions(cutoff, now time.Time) (int, error) {
+	deleted := 0
+	for {
+		sessions, err := s.sessionRepo.ListExpiredBefore(cutoff, now, retentionBatchSize)
+		if err != nil || len(sessions) == 0 {
+			return deleted, err
+		}
+
+		ids := make([]uint, len(sessions))
+		for i, session := range sessions {
+			ids[i] = session.ID
+		}
+		if err := archiveRows(s.config.RetentionArchiveDir, "user_sessions", now, sessions); err != nil {
+			return deleted, err
+		}
+		if err := s.sessionRepo.DeleteByIDs(ids); err != nil {
+			return deleted, err
+		}
+		deleted += len(ids)
+
+		if len(sessions) < retentionBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+func (s *RetentionService) purgeRefreshTokens(cutoff, now time.Time) (int, error) {
+	deleted := 0
+	for {
+		tokens, err := s.refreshRepo.ListExpiredBefore(cutoff, retentionBatchSize)
+		if err != nil || len(tokens) == 0 {
+			return deleted, err
+		}
+
+		ids := make([]uint, len(tokens))
+		for i, token := range tokens {
+			ids[i] = token.ID
+		}
+		if err := archiveRows(s.config.RetentionArchiveDir, "refresh_tokens", now, tokens); err != nil {
+			return deleted, err
+		}
+		if err := s.refreshRepo.DeleteByIDs(ids); err != nil {
+			return deleted, err
+		}
+		deleted += len(ids)
+
+		if len(tokens) < retentionBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+func (s *RetentionService) purgeLoginHistory(cutoff, now time.Time) (int, error) {
+	deleted := 0
+	for {
+		entries, err := s.loginHistoryRepo.ListBefore(cutoff, retentionBatchSize)
+		if err != nil || len(entries) == 0 {
+			return deleted, err
+		}
+
+		ids := make([]uint, len(entries))
+		for i, entry := range entries {
+			ids[i] = entry.ID
+		}
+		if err := archiveRows(s.config.RetentionArchiveDir, "login_history", now, entries); err != nil {
+			return deleted, err
+		}
+		if err := s.loginHistoryRepo.DeleteByIDs(ids); err != nil {
+			return deleted, err
+		}
+		deleted += len(ids)
+
+		if len(entries) < retentionBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// archiveRows appends rows as JSON lines to <table>-<run time>.jsonl in dir. It
+// does nothing when no directory is configured.
+func archiveRows[T any](dir, table string, now time.Time, rows []T) error {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.jsonl", table, now.UTC().Format("20060102T150405Z"))
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// run cleans up at startup and then every interval for the life of the process
+func (s *RetentionService) run(interval time.Duration) {
+	s.cleanupAndLog(timeutil.Now())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.cleanupAndLog(timeutil.Now())
+	}
+}
+
+func (s *RetentionService) cleanupAndLog(now time.Time) {
+	result, err := s.Cleanup(now)
+	if err != nil {
+		log.Printf("Data retention cleanup failed: %v", err)
+	}
+	if result.AuditLogs > 0 || result.Sessions > 0 || result.RefreshTokens > 0 || result.LoginHistory > 0 {
+		log.Printf("Data retention removed %d audit entries, %d sessions, %d refresh tokens and %d login history entries",
+			result.AuditLogs, result.Sessions, result.RefreshTokens, result.LoginHistory)
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+// seedRetentionRows stores an audit entry and a login history entry for each age,
+// given in days before now
+func seedRetentionRows(t *testing.T, now time.Time, ages ...int) {
+	t.Helper()
+	audit := repository.NewAuditLogRepository()
+	history := repository.NewLoginHistoryRepository()
+	for _, days := range ages {
+		at := now.AddDate(0, 0, -days)
+		if err := audit.Create(&models.AuditLog{CreatedAt: at, Action: models.AuditActionLogin}); err != nil {
+			t.Fatalf("Create audit entry: %v", err)
+		}
+		if err := history.Create(&models.LoginHistory{CreatedAt: at, UserID: 1, Method: AuthProviderPassword, Outcome: models.LoginOutcomeSuccess}); err != nil {
+			t.Fatalf("Create login history: %v", err)
+		}
+	}
+}
+
+// countRetentionRows returns how many audit and login history entries are stored
+func countRetentionRows(t *testing.T) (audit, history int64) {
+	t.Helper()
+	db := repository.GetDB()
+	if err := db.Model(&models.AuditLog{}).Count(&audit).Error; err != nil {
+		t.Fatalf("Count audit logs: %v", err)
+	}
+	if err := db.Model(&models.LoginHistory{}).Count(&history).Error; err != nil {
+		t.Fatalf("Count login history: %v", err)
+	}
+	return audit, history
+}
+
+func TestRetentionCleanup(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		audit       int
+		activity    int
+		wantAudit   int64
+		wantHistory int64
+	}{
+		{"both windows", 90, 60, 3, 2},
+		{"audit kept forever", 0, 60, 4, 2},
+		{"raised to the minimum", 5, 5, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, nil)
+			// Set after construction so the background job does not start
+			retention := NewRetentionService(cfg)
+			cfg.AuditRetentionDays = tt.audit
+			cfg.ActivityRetentionDays = tt.activity
+
+			seedRetentionRows(t, now, 1, 10, 75, 200)
+			result, err := retention.Cleanup(now)
+			if err != nil {
+				t.Fatalf("Cleanup: %v", err)
+			}
+
+			audit, history := countRetentionRows(t)
+			if audit != tt.wantAudit || history != tt.wantHistory {
+				t.Errorf("left %d audit entries and %d login history entries, want %d and %d",
+					audit, history, tt.wantAudit, tt.wantHistory)
+			}
+			if int64(result.AuditLogs) != 4-tt.wantAudit || int64(result.LoginHistory) != 4-tt.wantHistory {
+				t.Errorf("result = %+v, want %d audit entries and %d login history entries removed",
+					result, 4-tt.wantAudit, 4-tt.wantHistory)
+			}
+		})
+	}
+}
+
+func TestRetentionArchivesBeforeDeleting(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	cfg := setupTestDB(t, map[string]string{"RETENTION_ARCHIVE_DIR": dir})
+	retention := NewRetentionService(cfg)
+	cfg.AuditRetentionDays = 90
+
+	seedRetentionRows(t, now, 1, 200)
+	if _, err := retention.Cleanup(now); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "audit_logs-*.jsonl"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("archive files = %v (%v), want one", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], models.AuditActionLogin) {
+		t.Errorf("archive = %q, want the one purged entry", data)
+	}
+	if audit, _ := countRetentionRows(t); audit != 1 {
+		t.Errorf("left %d audit entries, want 1", audit)
+	}
+}
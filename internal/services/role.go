@@ -0,0 +1,219 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleExists        = errors.New("role already exists")
+	ErrInvalidPermission = errors.New("invalid permission specified")
+)
+
+type RoleService struct {
+	roleRepo repository.RoleRepository
+}
+
+func NewRoleService() *RoleService {
+	return &RoleService{
+		roleRepo: repository.NewRoleRepository(),
+	}
+}
+
+// canManageRoles checks if user may manage custom roles
+func (s *RoleService) canManageRoles(user *models.User) bool {
+	return user.IsAdmin || user.Role == "admin"
+}
+
+// canGrantAdminPermissions checks if user may create or assign admin-level roles
+func (s *RoleService) canGrantAdminPermissions(user *models.User) bool {
+	return user.Role == "admin"
+}
+
+// ListRoles returns all custom roles
+func (s *RoleService) ListRoles(adminUser *models.User) ([]*models.Role, error) {
+	if !s.canManageRoles(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	return s.roleRepo.List()
+}
+
+// GetRole returns a custom role by ID
+func (s *RoleService) GetRole(adminUser *models.User, roleID uint) (*models.Role, error) {
+	if !s.canManageRoles(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	role, err := s.roleRepo.GetByID(roleID)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// RolePermissions returns the permissions a role grants, sorted. name is a built-in
+// role (user, moderator, admin) or the ID of a custom role.
+func (s *RoleService) RolePermissions(adminUser *models.User, name string) (*models.RolePermissionsResponse, error) {
+	if !s.canManageRoles(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	if permissions, ok := models.BuiltInRolePermissions[name]; ok {
+		return &models.RolePermissionsResponse{Role: name, BuiltIn: true, Permissions: permissions}, nil
+	}
+
+	roleID, err := strconv.ParseUint(name, 10, 32)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+	role, err := s.roleRepo.GetByID(uint(roleID))
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+	return &models.RolePermissionsResponse{Role: role.Name, Permissions: role.PermissionList()}, nil
+}
+
+// CreateRole creates a custom role. Only super admins may create roles with admin-level permissions.
+func (s *RoleService) CreateRole(adminUser *models.User, req models.RoleRequest) (*models.Role, error) {
+	if !s.canManageRoles(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	if existing, _ := s.roleRepo.GetByName(req.Name); existing != nil {
+		return nil, ErrRoleExists
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	if err := s.applyPermissions(adminUser, role, req.Permissions); err != nil {
+		return nil, err
+	}
+
+	return s.roleRepo.Create(role)
+}
+
+// UpdateRole updates a custom role's name, description and permissions
+func (s *RoleService) UpdateRole(adminUser *models.User, roleID uint, req models.RoleRequest) (*models.Role, error) {
+	if !s.canManageRoles(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	role, err := s.roleRepo.GetByID(roleID)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+
+	// Admin-level roles can only be changed by super admins
+	if role.HasAdminPermissions() && !s.canGrantAdminPermissions(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	if existing, _ := s.roleRepo.GetByName(req.Name); existing != nil && existing.ID != role.ID {
+		return nil, ErrRoleExists
+	}
+
+	role.Name = req.Name
+	role.Description = req.Description
+	if err := s.applyPermissions(adminUser, role, req.Permissions); err != nil {
+		return nil, err
+	}
+
+	return s.roleRepo.Update(role)
+}
+
+// DeleteRole deletes a custom role and all of its assignments
+func (s *RoleService) DeleteRole(adminUser *models.User, roleID uint) error {
+	if !s.canManageRoles(adminUser) {
+		return ErrNotAuthorized
+	}
+
+	role, err := s.roleRepo.GetByID(roleID)
+	if err != nil {
+		return ErrRoleNotFound
+	}
+
+	if role.HasAdminPermissions() && !s.canGrantAdminPermissions(adminUser) {
+		return ErrNotAuthorized
+	}
+
+	return s.roleRepo.Delete(roleID)
+}
+
+// AssignRoleToUsers assigns a role to a batch of users in one transaction and
+// reports the outcome for each requested user
+func (s *RoleService) AssignRoleToUsers(adminUser *models.User, roleID uint, userIDs []uint) ([]models.RoleAssignmentResult, error) {
+	if !s.canManageRoles(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	role, err := s.roleRepo.GetByID(roleID)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+
+	if role.HasAdminPermissions() && !s.canGrantAdminPermissions(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	// Each user is reported once, in request order
+	seen := make(map[uint]bool, len(userIDs))
+	uniqueIDs := make([]uint, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if !seen[userID] {
+			seen[userID] = true
+			uniqueIDs = append(uniqueIDs, userID)
+		}
+	}
+
+	assigned, err := s.roleRepo.AssignToUsers(role.ID, uniqueIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[uint]bool, len(assigned))
+	for _, userID := range assigned {
+		found[userID] = true
+	}
+
+	results := make([]models.RoleAssignmentResult, 0, len(uniqueIDs))
+	for _, userID := range uniqueIDs {
+		result := models.RoleAssignmentResult{UserID: userID, Success: found[userID]}
+		if !result.Success {
+			result.Error = ErrUserNotFound.Error()
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// applyPermissions validates and sets permissions on a role
+func (s *RoleService) applyPermissions(adminUser *models.User, role *models.Role, permissions []string) error {
+	unique := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		if !models.ValidPermissions[permission] {
+			return ErrInvalidPermission
+		}
+		if models.AdminPermissions[permission] && !s.canGrantAdminPermissions(adminUser) {
+			return ErrNotAuthorized
+		}
+		unique[permission] = true
+	}
+
+	sorted := make([]string, 0, len(unique))
+	for permission := range unique {
+		sorted = append(sorted, permission)
+	}
+	sort.Strings(sorted)
+
+	role.SetPermissions(sorted)
+	return nil
+}
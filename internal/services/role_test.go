@@ -0,0 +1,84 @@
+package services
+
+import (
+	"testing"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+func TestAssignRoleToUsers(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	authService := newTestAuthService(t, cfg)
+	roles := NewRoleService()
+
+	superAdmin := &models.User{IsAdmin: true, Role: "admin"}
+	admin := &models.User{IsAdmin: true, Role: "moderator"}
+	first := createTestUser(t, authService, "first@example.com", "Passw0rd!x")
+	second := createTestUser(t, authService, "second@example.com", "Passw0rd!x")
+
+	support, err := roles.CreateRole(admin, models.RoleRequest{Name: "support", Permissions: []string{models.PermissionUsersRead}})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if _, err := roles.CreateRole(admin, models.RoleRequest{Name: "operators", Permissions: []string{models.PermissionAdminAccess}}); err != ErrNotAuthorized {
+		t.Errorf("admin creating an admin-level role = %v, want %v", err, ErrNotAuthorized)
+	}
+	operators, err := roles.CreateRole(superAdmin, models.RoleRequest{Name: "operators", Permissions: []string{models.PermissionAdminAccess}})
+	if err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+
+	// A missing user fails alone; duplicates are reported once
+	const missing = 9999
+	results, err := roles.AssignRoleToUsers(admin, support.ID, []uint{first.ID, missing, second.ID, first.ID})
+	if err != nil {
+		t.Fatalf("AssignRoleToUsers: %v", err)
+	}
+	want := []models.RoleAssignmentResult{
+		{UserID: first.ID, Success: true},
+		{UserID: missing, Error: ErrUserNotFound.Error()},
+		{UserID: second.ID, Success: true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+	for _, user := range []*models.User{first, second} {
+		assigned, err := repository.NewRoleRepository().ListByUser(user.ID)
+		if err != nil {
+			t.Fatalf("ListByUser: %v", err)
+		}
+		if len(assigned) != 1 || assigned[0].ID != support.ID {
+			t.Errorf("user %d has roles %+v, want only support", user.ID, assigned)
+		}
+	}
+
+	// Assigning again is harmless
+	if _, err := roles.AssignRoleToUsers(admin, support.ID, []uint{first.ID}); err != nil {
+		t.Errorf("repeated AssignRoleToUsers: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		user    *models.User
+		roleID  uint
+		wantErr error
+	}{
+		{"unknown role", admin, 4242, ErrRoleNotFound},
+		{"admin-level role by an admin", admin, operators.ID, ErrNotAuthorized},
+		{"admin-level role by a super admin", superAdmin, operators.ID, nil},
+		{"regular user", &models.User{Role: "user"}, support.ID, ErrNotAuthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := roles.AssignRoleToUsers(tt.user, tt.roleID, []uint{second.ID}); err != tt.wantErr {
+				t.Errorf("AssignRoleToUsers = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
+)
+
+var ErrSessionLimitReached = errors.New("maximum number of active sessions reached")
+
+// Session limit strategies
+const (
+	SessionLimitEvictOldest = "evict_oldest"
+	SessionLimitRejectNew   = "reject_new"
+)
+
+// SessionStart describes a newly recorded session and any sessions it displaced
+type SessionStart struct {
+	Session *models.UserSession
+	Evicted []*models.UserSession
+}
+
+type SessionService struct {
+	sessionRepo   repository.SessionRepository
+	refreshRepo   repository.RefreshTokenRepository
+	authService   *AuthService
+	maxSessions   int
+	strategy      string
+	cookieDomain  string
+	cookieSecure  bool
+	tokenInBody   bool
+	refreshWindow time.Duration
+	maxLifetime   time.Duration
+	binding       string
+
+	mu   sync.Mutex
+	slid map[string]slidToken // replacement token per refreshed token ID
+}
+
+// slidToken is the token a refreshed session moved to. It is kept until the old
+// token expires so concurrent requests carrying the old token share one replacement.
+type slidToken struct {
+	token      string
+	claims     *models.JWTClaims
+	oldExpires time.Time
+}
+
+// NewSessionService creates the session service and restores the token denylist
+// from revoked sessions, so revocations survive a restart
+func NewSessionService(cfg *configs.Config, authService *AuthService) *SessionService {
+	s := &SessionService{
+		sessionRepo:  repository.NewSessionRepository(),
+		refreshRepo:  repository.NewRefreshTokenRepository(),
+		authService:  authService,
+		maxSessions:  cfg.MaxSessionsPerUser,
+		strategy:     cfg.SessionLimitStrategy,
+		cookieDomain: cfg.CookieDomain,
+		cookieSecure: cfg.CookieSecure,
+		tokenInBody:  cfg.ReturnTokenInBody,
+
+		refreshWindow: cfg.SessionRefreshWindow,
+		maxLifetime:   cfg.SessionMaxLifetime,
+		binding:       cfg.RefreshTokenBinding,
+		slid:          make(map[string]slidToken),
+	}
+
+	revoked, err := s.sessionRepo.ListRevokedUnexpired(timeutil.Now())
+	if err != nil {
+		log.Printf("Failed to restore revoked sessions: %v", err)
+	}
+	for _, session := range revoked {
+		revokedTokens.add(session.TokenID, session.ExpiresAt)
+	}
+
+	// Tokens replaced by a newer one for the same session are kept apart
+	now := timeutil.Now()
+	if err := s.sessionRepo.DeleteExpiredRevokedTokens(now); err != nil {
+		log.Printf("Failed to delete expired revoked tokens: %v", err)
+	}
+	replaced, err := s.sessionRepo.ListRevokedTokens(now)
+	if err != nil {
+		log.Printf("Failed to restore replaced tokens: %v", err)
+	}
+	for _, token := range replaced {
+		revokedTokens.add(token.TokenID, token.ExpiresAt)
+	}
+
+	return s
+}
+
+// CookieDomain returns the domain the session cookie is set on; empty means host-only
+func (s *SessionService) CookieDomain() string {
+	return s.cookieDomain
+}
+
+// CookieSecure reports whether cookies are marked Secure, i.e. sent over HTTPS only
+func (s *SessionService) CookieSecure() bool {
+	return s.cookieSecure
+}
+
+// TokenInBody reports whether responses that set the session cookie also carry
+// the token in their JSON body
+func (s *SessionService) TokenInBody() bool {
+	return s.tokenInBody
+}
+
+// Start records a session for a freshly issued token and enforces the per-user
+// session cap. With evict_oldest the oldest sessions are revoked to make room; with
+// reject_new the new token is revoked and ErrSessionLimitReached is returned.
+func (s *SessionService) Start(user *models.User, token, ipAddress, userAgent string) (*SessionStart, error) {
+	claims, err := s.authService.ValidateJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := timeutil.Now()
+	result := &SessionStart{}
+
+	if s.maxSessions > 0 {
+		active, err := s.sessionRepo.ListActiveByUser(user.ID, now)
+		if err != nil {
+			return nil, err
+		}
+
+		if excess := len(active) - s.maxSessions + 1; excess > 0 {
+			if s.strategy == SessionLimitRejectNew {
+				revokedTokens.add(claims.ID, claims.ExpiresAt)
+				return nil, ErrSessionLimitReached
+			}
+
+			result.Evicted = active[:excess]
+			ids := make([]uint, 0, excess)
+			for _, session := range result.Evicted {
+				ids = append(ids, session.ID)
+				revokedTokens.add(session.TokenID, session.ExpiresAt)
+			}
+			if err := s.sessionRepo.Revoke(ids, now); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	session, err := s.sessionRepo.Create(&models.UserSession{
+		UserID:    user.ID,
+		TokenID:   claims.ID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		ExpiresAt: claims.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Session = session
+	return result, nil
+}
+
+// Rotate moves a session onto a newly issued token (e.g. after re-authentication)
+// and revokes the token it replaces, so the session count is unchanged
+func (s *SessionService) Rotate(oldClaims *models.JWTClaims, newToken string) error {
+	newClaims, err := s.authService.ValidateJWT(newToken)
+	if err != nil {
+		return err
+	}
+
+	revokedTokens.add(oldClaims.ID, oldClaims.ExpiresAt)
+
+	session, err := s.sessionRepo.GetByTokenID(oldClaims.ID)
+	if err != nil {
+		// Tokens issued before sessions were tracked have no row to move
+		return nil
+	}
+
+	_, err = s.sessionRepo.ReplaceToken(session, newClaims.ID, newClaims.ExpiresAt)
+	return err
+}
+
+// Slide refreshes a session whose token is within the refresh window of expiry,
+// returning the replacement token and its claims. It returns an empty token when
+// sliding is disabled, the token is not yet near expiry, or the session has
+// reached its max lifetime after sign-in. The old token is not revoked: it
+// expires within the window anyway, and requests already using it keep working.
+func (s *SessionService) Slide(claims *models.JWTClaims) (string, *models.JWTClaims, error) {
+	if s.refreshWindow <= 0 || claims.ID == "" {
+		return "", nil, nil
+	}
+
+	now := timeutil.Now()
+	if claims.ExpiresAt.Sub(now) > s.refreshWindow {
+		return "", nil, nil
+	}
+
+	expiresAt := now.Add(s.authService.tokenLifetime(claims.Role, claims.IsAdmin))
+	if limit := claims.AuthTime.Add(s.maxLifetime); expiresAt.After(limit) {
+		expiresAt = limit
+	}
+	// Tokens are signed to the second, so only a longer lifetime is worth a new token
+	if expiresAt.Unix() <= claims.ExpiresAt.Unix() {
+		return "", nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, slid := range s.slid {
+		if now.After(slid.oldExpires) {
+			delete(s.slid, id)
+		}
+	}
+	if slid, ok := s.slid[claims.ID]; ok {
+		return slid.token, slid.claims, nil
+	}
+
+	token, err := s.authService.RefreshJWT(claims, expiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+	newClaims, err := s.authService.ValidateJWT(token)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Tokens issued before sessions were tracked have no row to move
+	if session, err := s.sessionRepo.GetByTokenID(claims.ID); err == nil {
+		session.TokenID = newClaims.ID
+		session.ExpiresAt = newClaims.ExpiresAt
+		if _, err := s.sessionRepo.Update(session); err != nil {
+			return "", nil, err
+		}
+	}
+
+	s.slid[claims.ID] = slidToken{token: token, claims: newClaims, oldExpires: claims.ExpiresAt}
+	return token, newClaims, nil
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+// setupTestDB opens a fresh, migrated in-memory database for one test and returns
+// the configuration, with env applied on top of the defaults. The process-wide token
+// denylist and version floors are emptied, as after a restart.
+func setupTestDB(t *testing.T, env map[string]string) *configs.Config {
+	t.Helper()
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("DB_LOG_LEVEL", "silent")
+	// Escaped so characters such as # in subtest names cannot end the path and
+	// drop mode=memory, which would leave a database file behind
+	t.Setenv("DATABASE_URL", "file:"+url.PathEscape(t.Name())+"?mode=memory&cache=shared")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	cfg, err := configs.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if err := repository.InitDB(cfg); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	if err := repository.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	resetTokenState()
+	return cfg
+}
+
+// resetTokenState forgets the in-memory revocations, as a restart does
+func resetTokenState() {
+	revokedTokens = &tokenDenylist{entries: make(map[string]time.Time)}
+	minTokenVersions = &tokenVersionFloor{versions: make(map[uint]uint)}
+}
+
+// newTestAuthService returns an auth service on the test database that records
+// failed sign-ins through a real audit service
+func newTestAuthService(t *testing.T, cfg *configs.Config) *AuthService {
+	t.Helper()
+	return newTestAuthServiceWithMailer(t, cfg, NewMailer(cfg))
+}
+
+// newTestAuthServiceWithMailer is newTestAuthService sending mail through mailer
+func newTestAuthServiceWithMailer(t *testing.T, cfg *configs.Config, mailer Mailer) *AuthService {
+	t.Helper()
+	signingKeys, err := NewSigningKeyService(cfg)
+	if err != nil {
+		t.Fatalf("NewSigningKeyService: %v", err)
+	}
+	return NewAuthService(cfg, mailer, NewSMSSender(cfg), signingKeys, NewAuditService(cfg, NewAlertService(cfg, mailer)))
+}
+
+// createTestUser stores an active user with a local password
+func createTestUser(t *testing.T, authService *AuthService, email, password string) *models.User {
+	t.Helper()
+	hashed, err := authService.HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	user, err := repository.NewUserRepository().Create(&models.User{
+		Email:     email,
+		Password:  hashed,
+		FirstName: "Test",
+		LastName:  "User",
+		Role:      "user",
+		IsActive:  true,
+	})
+	if err != nil {
+		t.Fatalf("Create %s: %v", email, err)
+	}
+	return user
+}
+
+func TestSessionLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		strategy    string
+		existing    int
+		wantErr     error
+		wantEvicted int
+		wantActive  int
+	}{
+		{"evict below the cap", SessionLimitEvictOldest, 1, nil, 0, 2},
+		{"evict at the cap", SessionLimitEvictOldest, 2, nil, 1, 2},
+		{"reject below the cap", SessionLimitRejectNew, 1, nil, 0, 2},
+		{"reject at the cap", SessionLimitRejectNew, 2, ErrSessionLimitReached, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, map[string]string{
+				"MAX_SESSIONS_PER_USER":  "2",
+				"SESSION_LIMIT_STRATEGY": tt.strategy,
+			})
+			authService := newTestAuthService(t, cfg)
+			sessions := NewSessionService(cfg, authService)
+			user := createTestUser(t, authService, "limit@example.com", "Passw0rd!x")
+
+			var first, firstRefresh string
+			for i := 0; i < tt.existing; i++ {
+				token, err := authService.GenerateJWT(user)
+				if err != nil {
+					t.Fatalf("GenerateJWT: %v", err)
+				}
+				if _, err := sessions.Start(user, token, "10.0.0.1", "test"); err != nil {
+					t.Fatalf("Start existing session: %v", err)
+				}
+				if i == 0 {
+					first = token
+					if firstRefresh, err = sessions.IssueRefreshToken(user, token, "device-1", "test"); err != nil {
+						t.Fatalf("IssueRefreshToken: %v", err)
+					}
+				}
+			}
+
+			token, err := authService.GenerateJWT(user)
+			if err != nil {
+				t.Fatalf("GenerateJWT: %v", err)
+			}
+			result, err := sessions.Start(user, token, "10.0.0.2", "test")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Start error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && len(result.Evicted) != tt.wantEvicted {
+				t.Errorf("evicted %d sessions, want %d", len(result.Evicted), tt.wantEvicted)
+			}
+
+			active, _ := repository.NewSessionRepository().ListActiveByUser(user.ID, time.Now())
+			if len(active) != tt.wantActive {
+				t.Errorf("%d active sessions, want %d", len(active), tt.wantActive)
+			}
+
+			_, newErr := authService.ValidateJWT(token)
+			if rejected := newErr != nil; rejected != (tt.wantErr != nil) {
+				t.Errorf("new token rejected = %v, want %v", rejected, tt.wantErr != nil)
+			}
+			_, firstErr := authService.ValidateJWT(first)
+			if evicted := firstErr != nil; evicted != (tt.wantEvicted > 0) {
+				t.Errorf("oldest token rejected = %v, want %v", evicted, tt.wantEvicted > 0)
+			}
+
+			// An evicted session cannot be renewed with its refresh token either
+			_, refreshErr := sessions.Refresh(firstRefresh, "device-1", "test")
+			if evicted := refreshErr == ErrInvalidRefreshToken; evicted != (tt.wantEvicted > 0) {
+				t.Errorf("oldest refresh token rejected = %v (%v), want %v", evicted, refreshErr, tt.wantEvicted > 0)
+			}
+		})
+	}
+}
+
+func TestRotateRevokesOldTokenAcrossRestart(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	authService := newTestAuthService(t, cfg)
+	sessions := NewSessionService(cfg, authService)
+	user := createTestUser(t, authService, "rotate@example.com", "Passw0rd!x")
+
+	oldToken, err := authService.GenerateJWT(user)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	if _, err := sessions.Start(user, oldToken, "10.0.0.1", "test"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	oldClaims, err := authService.ValidateJWT(oldToken)
+	if err != nil {
+		t.Fatalf("ValidateJWT: %v", err)
+	}
+
+	newToken, err := authService.GenerateJWT(user)
+	if err != nil {
+		t.Fatalf("GenerateJWT: %v", err)
+	}
+	if err := sessions.Rotate(oldClaims, newToken); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	// A restart loses the in-memory denylist; the stored revocation brings it back
+	resetTokenState()
+	authService = newTestAuthService(t, cfg)
+	NewSessionService(cfg, authService)
+
+	if _, err := authService.ValidateJWT(oldToken); err == nil {
+		t.Error("rotated token is valid again after a restart")
+	}
+	if _, err := authService.ValidateJWT(newToken); err != nil {
+		t.Errorf("new token rejected after a restart: %v", err)
+	}
+}
+
+func TestSessionTTLPerRole(t *testing.T) {
+	cfg := setupTestDB(t, map[string]string{"SESSION_TTL_ADMIN": "2h", "SESSION_TTL_USER": "168h"})
+	authService := newTestAuthService(t, cfg)
+	sessions := NewSessionService(cfg, authService)
+	user := createTestUser(t, authService, "user@example.com", "Passw0rd!x")
+	admin := createTestAdmin(t, "admin@example.com")
+	moderator := createTestUser(t, authService, "mod@example.com", "Passw0rd!x")
+	moderator.Role = "moderator"
+
+	// expiry checks a token expires about ttl from now
+	expiry := func(t *testing.T, token string, ttl time.Duration) {
+		t.Helper()
+		claims, err := authService.ValidateJWT(token)
+		if err != nil {
+			t.Fatalf("ValidateJWT: %v", err)
+		}
+		if got := time.Until(claims.ExpiresAt); got > ttl || got < ttl-time.Minute {
+			t.Errorf("token expires in %v, want %v", got.Round(time.Second), ttl)
+		}
+	}
+
+	tests := []struct {
+		name string
+		user *models.User
+		want time.Duration
+	}{
+		{"admin", admin, 2 * time.Hour},
+		{"user", user, 168 * time.Hour},
+		{"role without a TTL", moderator, TokenLifetime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := authService.GenerateJWT(tt.user)
+			if err != nil {
+				t.Fatalf("GenerateJWT: %v", err)
+			}
+			expiry(t, token, tt.want)
+		})
+	}
+
+	t.Run("refreshed admin token", func(t *testing.T) {
+		token, err := authService.GenerateJWT(admin)
+		if err != nil {
+			t.Fatalf("GenerateJWT: %v", err)
+		}
+		if _, err := sessions.Start(admin, token, "10.0.0.1", "agent-1"); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+		refreshToken, err := sessions.IssueRefreshToken(admin, token, "device-1", "agent-1")
+		if err != nil {
+			t.Fatalf("IssueRefreshToken: %v", err)
+		}
+		result, err := sessions.Refresh(refreshToken, "device-1", "agent-1")
+		if err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+		expiry(t, result.Token, 2*time.Hour)
+	})
+}
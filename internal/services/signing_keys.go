@@ -0,0 +1,319 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
+)
+
+const (
+	signingKeyBits = 2048
+	// signingKeyReloadInterval is how often keys rotated elsewhere, by another
+	// instance or the rotate-signing-key command, are picked up
+	signingKeyReloadInterval = time.Minute
+	// signingKeyMissReloadInterval limits the reloads a token with an unknown kid can trigger
+	signingKeyMissReloadInterval = 10 * time.Second
+
+	// JWKSMaxAge is how long clients may cache the JWKS. It is far shorter than any
+	// grace period, so a new key is fetched well before the old one is dropped.
+	JWKSMaxAge = 15 * time.Minute
+)
+
+var (
+	ErrSigningKeysDisabled = errors.New("signing keys are only used when JWT_SIGNING_METHOD is RS256")
+	ErrNoSigningKey        = errors.New("no active signing key")
+)
+
+// SigningKeyService keeps the RSA keys session tokens are signed with under
+// RS256. The newest key signs; keys it replaced keep verifying tokens, and stay
+// in the JWKS, until their grace period ends.
+type SigningKeyService struct {
+	repo        repository.SigningKeyRepository
+	enabled     bool
+	gracePeriod time.Duration
+
+	mu       sync.RWMutex
+	active   *signingKey
+	keys     map[string]*signingKey // Published keys by kid
+	loadedAt time.Time
+}
+
+type signingKey struct {
+	record  *models.SigningKey
+	private *rsa.PrivateKey
+}
+
+// NewSigningKeyService loads the published signing keys, creating the first one
+// on a new database, and keeps them in step with the database in the background.
+// Under HS256 it does nothing.
+func NewSigningKeyService(cfg *configs.Config) (*SigningKeyService, error) {
+	s := &SigningKeyService{
+		repo:        repository.NewSigningKeyRepository(),
+		enabled:     cfg.JWTSigningMethod == "RS256",
+		gracePeriod: cfg.JWTKeyGracePeriod,
+		keys:        make(map[string]*signingKey),
+	}
+	if !s.enabled {
+		return s, nil
+	}
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	if s.activeKey() == nil {
+		if _, err := s.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	go s.run(signingKeyReloadInterval)
+	return s, nil
+}
+
+// Enabled reports whether tokens are signed with these keys rather than JWT_SECRET
+func (s *SigningKeyService) Enabled() bool {
+	return s.enabled
+}
+
+// GracePeriod is how long a replaced key stays published
+func (s *SigningKeyService) GracePeriod() time.Duration {
+	return s.gracePeriod
+}
+
+// Sign signs claims with the active key, naming it in the kid header
+func (s *SigningKeyService) Sign(claims jwt.Claims) (string, error) {
+	key := s.activeKey()
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.record.KID
+	return token.SignedString(key.private)
+}
+
+// VerificationKey is a jwt.Keyfunc returning the public key named by the token's
+// kid. Tokens signed any other way, or by a key past its grace period, are refused.
+func (s *SigningKeyService) VerificationKey(token *jwt.Token) (interface{}, error) {
+	if token.Method != jwt.SigningMethodRS256 {
+		return nil, ErrInvalidToken
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrInvalidToken
+	}
+
+	key := s.lookup(kid)
+	if key == nil && s.stale(signingKeyMissReloadInterval) {
+		// The key may have been created by another instance since the last reload
+		if err := s.reload(); err != nil {
+			log.Printf("Failed to reload signing keys: %v", err)
+		}
+		key = s.lookup(kid)
+	}
+	if key == nil || !s.published(key.record, timeutil.Now()) {
+		return nil, ErrInvalidToken
+	}
+	return &key.private.PublicKey, nil
+}
+
+// JWKS returns the public halves of the published keys, newest first
+func (s *SigningKeyService) JWKS() models.JSONWebKeySet {
+	set := models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+	for _, key := range s.publishedKeys() {
+		set.Keys = append(set.Keys, jsonWebKey(key.record.KID, &key.private.PublicKey))
+	}
+	return set
+}
+
+// List returns the published keys, newest first
+func (s *SigningKeyService) List() ([]*models.SigningKey, error) {
+	if !s.enabled {
+		return nil, ErrSigningKeysDisabled
+	}
+
+	keys := s.publishedKeys()
+	records := make([]*models.SigningKey, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, key.record)
+	}
+	return records, nil
+}
+
+// Rotate creates a new signing key and retires the active one, which keeps
+// verifying the tokens it signed for the grace period. Other instances start
+// signing with the new key within signingKeyReloadInterval.
+func (s *SigningKeyService) Rotate() (*models.SigningKey, error) {
+	if !s.enabled {
+		return nil, ErrSigningKeysDisabled
+	}
+
+	private, err := rsa.GenerateKey(rand.Reader, signingKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.SigningKey{
+		KID:        jwkThumbprint(&private.PublicKey),
+		Algorithm:  jwt.SigningMethodRS256.Alg(),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}
+	if err := s.repo.Rotate(record, timeutil.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	log.Printf("Rotated JWT signing key; new kid %s", record.KID)
+	return record, nil
+}
+
+// run reloads the keys and deletes those past their grace period every interval
+func (s *SigningKeyService) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.reload(); err != nil {
+			log.Printf("Failed to reload signing keys: %v", err)
+		}
+		if _, err := s.repo.DeleteRetiredBefore(timeutil.Now().Add(-s.gracePeriod)); err != nil {
+			log.Printf("Failed to delete expired signing keys: %v", err)
+		}
+	}
+}
+
+// reload replaces the cached keys with the published ones in the database
+func (s *SigningKeyService) reload() error {
+	now := timeutil.Now()
+	records, err := s.repo.ListPublished(now.Add(-s.gracePeriod))
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %v", err)
+	}
+
+	var active *signingKey
+	keys := make(map[string]*signingKey, len(records))
+	for _, record := range records {
+		private, err := parseSigningKey(record.PrivateKey)
+		if err != nil {
+			log.Printf("Skipping unreadable signing key %s: %v", record.KID, err)
+			continue
+		}
+		key := &signingKey{record: record, private: private}
+		keys[record.KID] = key
+		if active == nil && record.RetiredAt == nil {
+			active = key
+		}
+	}
+
+	s.mu.Lock()
+	s.active = active
+	s.keys = keys
+	s.loadedAt = now
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *SigningKeyService) activeKey() *signingKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+func (s *SigningKeyService) lookup(kid string) *signingKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys[kid]
+}
+
+// stale reports whether the keys were loaded more than age ago
+func (s *SigningKeyService) stale(age time.Duration) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return timeutil.Now().Sub(s.loadedAt) > age
+}
+
+// published reports whether key is active or still within its grace period at now
+func (s *SigningKeyService) published(key *models.SigningKey, now time.Time) bool {
+	return key.RetiredAt == nil || now.Before(key.RetiredAt.Add(s.gracePeriod))
+}
+
+// publishedKeys returns the cached keys still published, newest first
+func (s *SigningKeyService) publishedKeys() []*signingKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := timeutil.Now()
+	keys := make([]*signingKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if s.published(key.record, now) {
+			keys = append(keys, key)
+		}
+	}
+	// Newest first, so clients that try keys in order find the active one first
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].record.CreatedAt.After(keys[j].record.CreatedAt)
+	})
+	return keys
+}
+
+// parseSigningKey decodes a PEM-encoded PKCS#8 RSA private key
+func parseSigningKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("not PEM-encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return private, nil
+}
+
+// jsonWebKey describes an RSA public key as a JWK
+func jsonWebKey(kid string, public *rsa.PublicKey) models.JSONWebKey {
+	return models.JSONWebKey{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: jwt.SigningMethodRS256.Alg(),
+		KID:       kid,
+		N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+	}
+}
+
+// jwkThumbprint is the RFC 7638 thumbprint of an RSA public key, used as its kid
+func jwkThumbprint(public *rsa.PublicKey) string {
+	jwk := jsonWebKey("", public)
+	// The members must be in lexicographic order with no whitespace, which
+	// encoding/json gives for a map
+	canonical, _ := json.Marshal(map[string]string{"e": jwk.E, "kty": jwk.KeyType, "n": jwk.N})
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+	"sso-web-app/internal/timeutil"
+)
+
+func TestSigningKeyRotation(t *testing.T) {
+	cfg := setupTestDB(t, map[string]string{"JWT_SIGNING_METHOD": "RS256"})
+	resetTokenState()
+	mailer := NewMailer(cfg)
+	signingKeys, err := NewSigningKeyService(cfg)
+	if err != nil {
+		t.Fatalf("NewSigningKeyService: %v", err)
+	}
+	authService := NewAuthService(cfg, mailer, NewSMSSender(cfg), signingKeys, NewAuditService(cfg, NewAlertService(cfg, mailer)))
+	user := createTestUser(t, authService, "rotate@example.com", "Passw0rd!x")
+
+	// One token per key: the first signed before either rotation
+	var tokens []string
+	var kids []string
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			if _, err := signingKeys.Rotate(); err != nil {
+				t.Fatalf("Rotate: %v", err)
+			}
+		}
+		token, err := authService.GenerateJWT(user)
+		if err != nil {
+			t.Fatalf("GenerateJWT: %v", err)
+		}
+		tokens = append(tokens, token)
+		kids = append(kids, signingKeys.activeKey().record.KID)
+	}
+
+	// Every key is published, newest first, and each verifies its own tokens
+	jwks := signingKeys.JWKS()
+	if len(jwks.Keys) != len(kids) {
+		t.Fatalf("JWKS has %d keys, want %d", len(jwks.Keys), len(kids))
+	}
+	for i, key := range jwks.Keys {
+		if want := kids[len(kids)-1-i]; key.KID != want {
+			t.Errorf("JWKS key %d = %s, want %s", i, key.KID, want)
+		}
+	}
+	for i, token := range tokens {
+		if _, err := authService.ValidateJWT(token); err != nil {
+			t.Errorf("token signed by key %d within the grace period: %v", i, err)
+		}
+	}
+
+	// Move the first key's retirement back past the grace period
+	expired := timeutil.Now().Add(-cfg.JWTKeyGracePeriod - time.Minute)
+	if err := repository.GetDB().Model(&models.SigningKey{}).Where(&models.SigningKey{KID: kids[0]}).
+		Update("retired_at", expired).Error; err != nil {
+		t.Fatalf("backdate retirement: %v", err)
+	}
+	if err := signingKeys.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	if _, err := authService.ValidateJWT(tokens[0]); err != ErrInvalidToken {
+		t.Errorf("token signed by a key past its grace period: err = %v, want %v", err, ErrInvalidToken)
+	}
+	for i, token := range tokens[1:] {
+		if _, err := authService.ValidateJWT(token); err != nil {
+			t.Errorf("token signed by key %d: %v", i+1, err)
+		}
+	}
+	for _, key := range signingKeys.JWKS().Keys {
+		if key.KID == kids[0] {
+			t.Error("JWKS still publishes the key past its grace period")
+		}
+	}
+}
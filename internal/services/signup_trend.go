@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/timeutil"
+)
+
+// Signup trend granularities
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// maxSignupBuckets bounds how many periods one trend may span
+const maxSignupBuckets = 731
+
+var (
+	ErrInvalidGranularity = errors.New("granularity must be day, week or month")
+	ErrTooManyBuckets     = errors.New("date range spans too many periods")
+)
+
+// GetSignupTrend counts signups per day, week (starting Monday) or month between
+// from and to. Periods follow the application timezone; the first starts at the
+// beginning of the period containing from, and periods without signups are
+// reported with a count of 0 so the series has no gaps.
+func (s *AdminService) GetSignupTrend(adminUser *models.User, from, to time.Time, granularity string) (*models.SignupTrendResponse, error) {
+	if !s.IsAdmin(adminUser) {
+		return nil, ErrNotAuthorized
+	}
+
+	switch granularity {
+	case GranularityDay, GranularityWeek, GranularityMonth:
+	default:
+		return nil, ErrInvalidGranularity
+	}
+	if from.After(to) {
+		return nil, ErrInvalidDateRange
+	}
+
+	bounds, err := signupBucketBounds(from, to, granularity, timeutil.Location())
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.userRepo.CountSignupsByBucket(bounds)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]models.SignupBucket, len(counts))
+	for i, count := range counts {
+		buckets[i] = models.SignupBucket{Start: models.NewTimestamp(bounds[i]), Count: count}
+	}
+	return &models.SignupTrendResponse{
+		Granularity: granularity,
+		From:        models.NewTimestamp(bounds[0]),
+		To:          models.NewTimestamp(to),
+		Buckets:     buckets,
+	}, nil
+}
+
+// signupBucketBounds returns the start of every period from the one containing
+// from through the one containing to, followed by the end of the last period.
+// Starts are midnights in loc, so a day is 23 or 25 hours long across a DST change.
+func signupBucketBounds(from, to time.Time, granularity string, loc *time.Location) ([]time.Time, error) {
+	start := from.In(loc)
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	switch granularity {
+	case GranularityWeek:
+		// Go counts weekdays from Sunday; weeks here start on Monday
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	case GranularityMonth:
+		start = start.AddDate(0, 0, 1-start.Day())
+	}
+
+	var bounds []time.Time
+	for bound := start; ; {
+		bounds = append(bounds, bound.UTC())
+		if bound.After(to) {
+			return bounds, nil
+		}
+		if len(bounds) > maxSignupBuckets {
+			return nil, ErrTooManyBuckets
+		}
+
+		switch granularity {
+		case GranularityDay:
+			bound = bound.AddDate(0, 0, 1)
+		case GranularityWeek:
+			bound = bound.AddDate(0, 0, 7)
+		case GranularityMonth:
+			bound = bound.AddDate(0, 1, 0)
+		}
+	}
+}
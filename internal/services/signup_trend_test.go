@@ -0,0 +1,92 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"sso-web-app/internal/repository"
+)
+
+func TestGetSignupTrend(t *testing.T) {
+	cfg := setupTestDB(t, nil)
+	authService := newTestAuthService(t, cfg)
+	admin := NewAdminService(cfg, authService, NewOAuthService(cfg, authService))
+	adminUser := createTestAdmin(t, "admin@example.com")
+
+	// March 1st 2026 is a Sunday, so its week started on Monday February 23rd
+	date := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	signups := []time.Time{
+		date(time.March, 2, 0),
+		date(time.March, 2, 23),
+		date(time.March, 4, 12),
+		date(time.March, 9, 8),
+		date(time.April, 3, 12),
+	}
+	for i, at := range signups {
+		user := createTestUser(t, authService, fmt.Sprintf("signup%d@example.com", i), "Passw0rd!x")
+		if err := repository.GetDB().Model(user).UpdateColumn("created_at", at).Error; err != nil {
+			t.Fatalf("set created_at: %v", err)
+		}
+	}
+
+	type bucket struct {
+		start time.Time
+		count int64
+	}
+	tests := []struct {
+		granularity string
+		from, to    time.Time
+		want        []bucket
+	}{
+		{GranularityDay, date(time.March, 1, 9), date(time.March, 5, 12), []bucket{
+			{date(time.March, 1, 0), 0},
+			{date(time.March, 2, 0), 2},
+			{date(time.March, 3, 0), 0},
+			{date(time.March, 4, 0), 1},
+			{date(time.March, 5, 0), 0},
+		}},
+		{GranularityWeek, date(time.March, 1, 0), date(time.March, 10, 12), []bucket{
+			{date(time.February, 23, 0), 0},
+			{date(time.March, 2, 0), 3},
+			{date(time.March, 9, 0), 1},
+		}},
+		{GranularityMonth, date(time.March, 15, 0), date(time.April, 15, 0), []bucket{
+			{date(time.March, 1, 0), 4},
+			{date(time.April, 1, 0), 1},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.granularity, func(t *testing.T) {
+			trend, err := admin.GetSignupTrend(adminUser, tt.from, tt.to, tt.granularity)
+			if err != nil {
+				t.Fatalf("GetSignupTrend: %v", err)
+			}
+			if len(trend.Buckets) != len(tt.want) {
+				t.Fatalf("got %d buckets, want %d: %+v", len(trend.Buckets), len(tt.want), trend.Buckets)
+			}
+			for i, want := range tt.want {
+				got := trend.Buckets[i]
+				if !got.Start.Equal(want.start) || got.Count != want.count {
+					t.Errorf("bucket %d = %d from %v, want %d from %v", i, got.Count, got.Start.Time, want.count, want.start)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid requests", func(t *testing.T) {
+		from, to := date(time.March, 1, 0), date(time.March, 5, 0)
+		if _, err := admin.GetSignupTrend(adminUser, from, to, "hour"); err != ErrInvalidGranularity {
+			t.Errorf("granularity hour: err = %v, want ErrInvalidGranularity", err)
+		}
+		if _, err := admin.GetSignupTrend(adminUser, to, from, GranularityDay); err != ErrInvalidDateRange {
+			t.Errorf("inverted range: err = %v, want ErrInvalidDateRange", err)
+		}
+		if _, err := admin.GetSignupTrend(adminUser, from.AddDate(-5, 0, 0), to, GranularityDay); err != ErrTooManyBuckets {
+			t.Errorf("five years by day: err = %v, want ErrTooManyBuckets", err)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"sso-web-app/configs"
+)
+
+// SMSSender sends text messages to phone numbers in E.164 form
+type SMSSender interface {
+	Send(to, message string) error
+}
+
+// NewSMSSender returns a sender that posts messages to SMS_WEBHOOK_URL when it is
+// set, otherwise one that only logs them, which is convenient in development. The
+// webhook lets any SMS provider be plugged in through a small relay.
+func NewSMSSender(cfg *configs.Config) SMSSender {
+	if cfg.SMSWebhookURL == "" {
+		return &logSMSSender{}
+	}
+
+	return &webhookSMSSender{
+		url:        cfg.SMSWebhookURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// webhookSMSSender posts {"to": ..., "message": ...} to a URL
+type webhookSMSSender struct {
+	url        string
+	httpClient *http.Client
+}
+
+func (s *webhookSMSSender) Send(to, message string) error {
+	payload, err := json.Marshal(map[string]string{"to": to, "message": message})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.httpClient.Post(s.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("SMS webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+type logSMSSender struct{}
+
+func (s *logSMSSender) Send(to, message string) error {
+	log.Printf("SMS to %s: %s", to, message)
+	return nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/timeutil"
+)
+
+var (
+	ErrTermsNotAccepted     = errors.New("you must accept the terms of service to sign up")
+	ErrTermsNotRequired     = errors.New("terms acceptance is not enabled")
+	ErrTermsVersionMismatch = errors.New("the terms of service have changed; please review the current version")
+)
+
+// TermsRequired reports whether users must accept the terms of service
+func (s *AuthService) TermsRequired() bool {
+	return s.requireTerms
+}
+
+// TermsVersion is the version of the terms users must have accepted
+func (s *AuthService) TermsVersion() string {
+	return s.termsVersion
+}
+
+// TermsURL is where the current terms of service are published
+func (s *AuthService) TermsURL() string {
+	return s.termsURL
+}
+
+// TermsOutdated reports whether the user has yet to accept the current terms,
+// either because they never did (accounts created through OAuth or before terms
+// were required) or because the version has changed since
+func (s *AuthService) TermsOutdated(user *models.User) bool {
+	return s.requireTerms && user.AcceptedTermsVersion != s.termsVersion
+}
+
+// AcceptTerms records that the user accepted the current terms, which must be
+// the version they were shown
+func (s *AuthService) AcceptTerms(userID uint, version string) (*models.User, error) {
+	if !s.requireTerms {
+		return nil, ErrTermsNotRequired
+	}
+	if version != s.termsVersion {
+		return nil, ErrTermsVersionMismatch
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.AcceptedTermsVersion == s.termsVersion {
+		return user, nil
+	}
+
+	s.recordTermsAcceptance(user)
+	return s.userRepo.Update(user)
+}
+
+// recordTermsAcceptance marks the user as having accepted the current terms now
+func (s *AuthService) recordTermsAcceptance(user *models.User) {
+	now := timeutil.Now()
+	user.AcceptedTermsAt = &now
+	user.AcceptedTermsVersion = s.termsVersion
+}
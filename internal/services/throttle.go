@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrLoginThrottled         = errors.New("too many sign-in attempts")
+	ErrPasswordCheckThrottled = errors.New("too many password attempts")
+)
+
+// attemptThrottle counts failed attempts per key within a sliding window. A key
+// that reaches the limit is refused until its oldest counted attempt ages out.
+type attemptThrottle struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	attempts map[string][]time.Time
+}
+
+func newAttemptThrottle(limit int, window time.Duration) *attemptThrottle {
+	return &attemptThrottle{
+		limit:    limit,
+		window:   window,
+		attempts: make(map[string][]time.Time),
+	}
+}
+
+// retryAfter returns how long key must wait before its next attempt, or zero if
+// it may try now. A limit of zero disables the throttle.
+func (t *attemptThrottle) retryAfter(key string, now time.Time) time.Duration {
+	if t.limit <= 0 {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recent := t.prune(key, now)
+	if len(recent) < t.limit {
+		return 0
+	}
+	return recent[len(recent)-t.limit].Add(t.window).Sub(now)
+}
+
+// count returns how many failed attempts key has within the window
+func (t *attemptThrottle) count(key string, now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.prune(key, now))
+}
+
+// fail records a failed attempt for key
+func (t *attemptThrottle) fail(key string, now time.Time) {
+	if t.limit <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.attempts[key] = append(t.prune(key, now), now)
+}
+
+// reset forgets key's failed attempts, e.g. after a successful sign-in
+func (t *attemptThrottle) reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.attempts, key)
+}
+
+// prune drops attempts older than the window and returns the rest. Callers hold mu.
+func (t *attemptThrottle) prune(key string, now time.Time) []time.Time {
+	recent := t.attempts[key][:0]
+	for _, at := range t.attempts[key] {
+		if now.Sub(at) < t.window {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) == 0 {
+		delete(t.attempts, key)
+		return nil
+	}
+	t.attempts[key] = recent
+	return recent
+}
+
+// normalizeLoginEmail maps the spellings of one address to a single throttle key
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// LoginRetryAfter returns how long sign-in from clientIP or against email is
+// throttled, or zero if an attempt may be made now. Unknown emails are counted
+// like real ones, so the throttle does not reveal which accounts exist.
+func (s *AuthService) LoginRetryAfter(email, clientIP string) time.Duration {
+	now := time.Now()
+	wait := s.accountThrottle.retryAfter(normalizeLoginEmail(email), now)
+	if ipWait := s.ipThrottle.retryAfter(clientIP, now); ipWait > wait {
+		wait = ipWait
+	}
+	return wait
+}
+
+// recordFailedLogin counts a failed sign-in against the account and the client IP
+func (s *AuthService) recordFailedLogin(email, clientIP string) {
+	now := time.Now()
+	s.accountThrottle.fail(normalizeLoginEmail(email), now)
+	s.ipThrottle.fail(clientIP, now)
+}
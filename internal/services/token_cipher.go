@@ -0,0 +1,63 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+)
+
+var errTokenCiphertext = errors.New("stored token cannot be decrypted")
+
+// tokenCipher encrypts provider tokens at rest with AES-256-GCM. Each value gets a
+// fresh random nonce, stored in front of the ciphertext, and the whole is
+// base64-encoded. The associated data ties a ciphertext to its row, so a token
+// copied into another user's row fails to decrypt.
+type tokenCipher struct {
+	aead cipher.AEAD
+}
+
+func newTokenCipher(key []byte) (*tokenCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenCipher{aead: aead}, nil
+}
+
+// encrypt seals plaintext; an empty plaintext stays empty
+func (c *tokenCipher) encrypt(plaintext, associatedData string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(associatedData))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value made by encrypt with the same associated data
+func (c *tokenCipher) decrypt(value, associatedData string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", errTokenCiphertext
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(associatedData))
+	if err != nil {
+		return "", errTokenCiphertext
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/timeutil"
+)
+
+const (
+	// verificationTTL is how long an email verification link stays valid
+	verificationTTL = 48 * time.Hour
+	// verificationResendInterval throttles how often a new link can be mailed
+	verificationResendInterval = time.Minute
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("verification link is invalid or has expired")
+	ErrAlreadyVerified          = errors.New("email address is already verified")
+	ErrVerificationCooldown     = errors.New("a verification email was sent too recently")
+)
+
+// RequireVerifiedLogin reports whether password sign-in needs a verified email
+func (s *AuthService) RequireVerifiedLogin() bool {
+	return s.requireVerified
+}
+
+// VerificationPending reports whether the user must verify their email before they
+// can sign in with a password. Admins are never blocked, and accounts created before
+// REQUIRE_VERIFIED_LOGIN was enabled only when REQUIRE_VERIFIED_LOGIN_EXISTING is set.
+func (s *AuthService) VerificationPending(user *models.User) bool {
+	if !s.requireVerified || user.IsVerified || user.IsAdmin || user.Role == "admin" {
+		return false
+	}
+	return user.VerificationRequired || s.verifyExisting
+}
+
+// ResendVerification mails a new verification link. Unknown or already verified
+// addresses are ignored so the response cannot reveal which emails have accounts.
+func (s *AuthService) ResendVerification(email string) {
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		return
+	}
+
+	s.resendVerificationEmail(user)
+}
+
+// VerificationRetryAfter returns how long until another verification link can be
+// mailed to the user, or zero when one can be sent now
+func VerificationRetryAfter(user *models.User) time.Duration {
+	if user.VerificationSentAt == nil {
+		return 0
+	}
+	if wait := verificationResendInterval - timeutil.Now().Sub(*user.VerificationSentAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// resendVerificationEmail mails a new link unless the user is already verified or
+// one was sent within verificationResendInterval
+func (s *AuthService) resendVerificationEmail(user *models.User) error {
+	if user.IsVerified {
+		return ErrAlreadyVerified
+	}
+	if VerificationRetryAfter(user) > 0 {
+		return ErrVerificationCooldown
+	}
+	return s.sendVerificationEmail(user)
+}
+
+// VerifyEmail marks the account owning token as verified
+func (s *AuthService) VerifyEmail(token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user, err := s.userRepo.GetByVerificationTokenHash(HashInviteToken(token))
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	if user.VerificationSentAt == nil || timeutil.Now().Sub(*user.VerificationSentAt) > verificationTTL {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user.IsVerified = true
+	user.VerificationTokenHash = ""
+	return s.userRepo.Update(user)
+}
+
+// sendVerificationEmail stores a fresh verification token for the user and mails
+// them the link in the background. Only the token's hash is kept, as with invitations.
+// Failures are logged; the error is returned for callers that report them.
+func (s *AuthService) sendVerificationEmail(user *models.User) error {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		log.Printf("Failed to generate verification token for %s: %v", user.Email, err)
+		return err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	now := timeutil.Now()
+	user.VerificationTokenHash = HashInviteToken(token)
+	user.VerificationSentAt = &now
+	if _, err := s.userRepo.Update(user); err != nil {
+		log.Printf("Failed to store verification token for %s: %v", user.Email, err)
+		return err
+	}
+
+	body := fmt.Sprintf(`Hi %s,
+
+Please confirm your email address by opening this link:
+
+%s/verify-email?token=%s
+
+The link expires in %d hours. If you did not create an account, you can ignore this email.
+`, user.FirstName, s.baseURL, url.QueryEscape(token), int(verificationTTL.Hours()))
+
+	go func(email string) {
+		if err := s.mailer.Send(email, "Verify your email address", body); err != nil {
+			log.Printf("Failed to send verification email to %s: %v", email, err)
+		}
+	}(user.Email)
+	return nil
+}
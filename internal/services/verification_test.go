@@ -0,0 +1,104 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"sso-web-app/internal/models"
+	"sso-web-app/internal/repository"
+)
+
+func TestLoginRequiresVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		require  string
+		existing string
+		verified bool
+		// required marks accounts registered while REQUIRE_VERIFIED_LOGIN was on
+		required bool
+		role     string
+		wantErr  error
+	}{
+		{"off, unverified", "false", "false", false, true, "user", nil},
+		{"off, verified", "false", "false", true, true, "user", nil},
+		{"on, unverified", "true", "false", false, true, "user", ErrEmailNotVerified},
+		{"on, verified", "true", "false", true, true, "user", nil},
+		{"on, unverified admin", "true", "false", false, true, "admin", nil},
+		{"on, unverified account from before", "true", "false", false, false, "user", nil},
+		{"on for existing, unverified account from before", "true", "true", false, false, "user", ErrEmailNotVerified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, map[string]string{
+				"REQUIRE_VERIFIED_LOGIN":          tt.require,
+				"REQUIRE_VERIFIED_LOGIN_EXISTING": tt.existing,
+			})
+			authService := newTestAuthService(t, cfg)
+			user := createTestUser(t, authService, "verify@example.com", "Passw0rd!x")
+			if err := repository.GetDB().Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+				"is_verified":           tt.verified,
+				"verification_required": tt.required,
+				"role":                  tt.role,
+			}).Error; err != nil {
+				t.Fatalf("update user: %v", err)
+			}
+
+			token, _, err := authService.Login(models.LoginRequest{Email: user.Email, Password: "Passw0rd!x"}, "10.0.0.1", "test")
+			if err != tt.wantErr {
+				t.Fatalf("Login error = %v, want %v", err, tt.wantErr)
+			}
+			if (token != "") != (tt.wantErr == nil) {
+				t.Errorf("session issued = %v, want %v", token != "", tt.wantErr == nil)
+			}
+		})
+	}
+}
+
+func TestRegisterAutoVerifyDomains(t *testing.T) {
+	tests := []struct {
+		name         string
+		domains      string
+		email        string
+		wantVerified bool
+	}{
+		{"trusted domain", " @OurCompany.com, partner.example ", "alice@ourcompany.com", true},
+		{"trusted domain, mixed case address", "ourcompany.com", "Alice@OurCompany.COM", true},
+		{"untrusted domain", "ourcompany.com", "bob@example.com", false},
+		{"subdomain of a trusted domain", "ourcompany.com", "carol@mail.ourcompany.com", false},
+		{"nothing trusted by default", "", "alice@ourcompany.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := setupTestDB(t, map[string]string{
+				"AUTO_VERIFY_DOMAINS":    tt.domains,
+				"REQUIRE_VERIFIED_LOGIN": "true",
+				"SEND_WELCOME_EMAIL":     "false",
+			})
+			mailer := newMailRecorder()
+			authService := newTestAuthServiceWithMailer(t, cfg, mailer)
+
+			user, err := authService.Register(models.RegisterRequest{Email: tt.email, Password: "An0ther-Passw0rd", FirstName: "New", LastName: "User"})
+			if err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+			if user.IsVerified != tt.wantVerified {
+				t.Errorf("verified = %v, want %v", user.IsVerified, tt.wantVerified)
+			}
+			if pending := authService.VerificationPending(user); pending == tt.wantVerified {
+				t.Errorf("verification pending = %v, want %v", pending, !tt.wantVerified)
+			}
+
+			// Only accounts that still need verifying get the email round trip
+			if tt.wantVerified {
+				time.Sleep(50 * time.Millisecond)
+				mailer.none(t)
+				return
+			}
+			if mail := mailer.next(t); mail.subject != "Verify your email address" {
+				t.Errorf("mail = %q, want the verification email", mail.subject)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"sso-web-app/internal/models"
+)
+
+var (
+	ErrInvalidWebsite = errors.New("website must be a valid http or https URL")
+	ErrWebsiteTooLong = fmt.Errorf("website must be at most %d characters", models.MaxWebsiteLength)
+)
+
+// normalizeWebsite turns a website value into an absolute http or https URL so it
+// renders as a safe link. Values without a scheme, as GitHub blogs often are, get
+// https:// prefixed. An empty value stays empty. The result must fit the database
+// column, including any added scheme.
+func normalizeWebsite(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", ErrInvalidWebsite
+	}
+
+	// A host needs at least one dot; localhost is the only exception worth keeping
+	host := parsed.Hostname()
+	if host == "" || (!strings.Contains(host, ".") && host != "localhost") || strings.ContainsAny(host, " <>\"'") {
+		return "", ErrInvalidWebsite
+	}
+
+	normalized := parsed.String()
+	if utf8.RuneCountInString(normalized) > models.MaxWebsiteLength {
+		return "", ErrWebsiteTooLong
+	}
+
+	return normalized, nil
+}
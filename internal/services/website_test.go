@@ -0,0 +1,67 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"sso-web-app/internal/models"
+)
+
+func TestNormalizeWebsite(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr error
+	}{
+		{"no scheme", "example.com", "https://example.com", nil},
+		{"https", "https://x.com", "https://x.com", nil},
+		{"http kept", "http://x.com/blog", "http://x.com/blog", nil},
+		{"empty", "", "", nil},
+		{"blank", "   ", "", nil},
+		{"javascript", "javascript:alert(1)", "", ErrInvalidWebsite},
+		{"other scheme", "ftp://x.com", "", ErrInvalidWebsite},
+		{"no dot", "my blog", "", ErrInvalidWebsite},
+		{"too long", "example.com/" + strings.Repeat("a", models.MaxWebsiteLength), "", ErrWebsiteTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeWebsite(tt.raw)
+			if err != tt.wantErr || got != tt.want {
+				t.Errorf("normalizeWebsite(%q) = %q, %v; want %q, %v", tt.raw, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGitHubBlogNormalized(t *testing.T) {
+	tests := []struct {
+		blog string
+		want string
+	}{
+		{"example.com", "https://example.com"},
+		{"https://x.com", "https://x.com"},
+		{"", ""},
+		{"not a site", ""},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.blog, func(t *testing.T) {
+			cfg := setupTestDB(t, nil)
+			oauthService := NewOAuthService(cfg, newTestAuthService(t, cfg))
+
+			user, err := oauthService.findOrCreateGitHubUser(&GitHubUser{ID: i + 1, Login: "octo", Email: "octo@example.com", Blog: tt.blog}, nil)
+			if err != nil {
+				t.Fatalf("findOrCreateGitHubUser: %v", err)
+			}
+			var got string
+			if user.Website != nil {
+				got = *user.Website
+			}
+			if got != tt.want {
+				t.Errorf("website = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
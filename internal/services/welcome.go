@@ -0,0 +1,86 @@
+package services
+
+import (
+	"bytes"
+	"log"
+	"text/template"
+
+	"sso-web-app/configs"
+	"sso-web-app/internal/models"
+)
+
+// defaultWelcomeTemplate is used when WELCOME_EMAIL_TEMPLATE is not set
+const defaultWelcomeTemplate = `Hi {{.FirstName}},
+
+Welcome! Your account for {{.Email}} is ready.
+
+You can sign in at any time at {{.LoginURL}}
+
+If you did not create this account, please contact support.
+`
+
+// welcomeData is what a welcome email template can use
+type welcomeData struct {
+	FirstName string
+	LastName  string
+	Email     string
+	LoginURL  string
+}
+
+// welcomeEmail sends new accounts a welcome message when SEND_WELCOME_EMAIL is set
+type welcomeEmail struct {
+	enabled  bool
+	subject  string
+	template *template.Template
+	loginURL string
+	mailer   Mailer
+}
+
+// newWelcomeEmail loads the configured template, falling back to the built-in
+// one if it cannot be parsed. The config has already checked that it parses.
+func newWelcomeEmail(cfg *configs.Config, baseURL string, mailer Mailer) *welcomeEmail {
+	w := &welcomeEmail{
+		enabled:  cfg.SendWelcomeEmail,
+		subject:  cfg.WelcomeEmailSubject,
+		loginURL: baseURL + "/login",
+		mailer:   mailer,
+		template: template.Must(template.New("welcome").Parse(defaultWelcomeTemplate)),
+	}
+
+	if cfg.WelcomeEmailTemplate != "" {
+		tmpl, err := template.ParseFiles(cfg.WelcomeEmailTemplate)
+		if err != nil {
+			log.Printf("Failed to load welcome email template, using the default: %v", err)
+		} else {
+			w.template = tmpl
+		}
+	}
+
+	return w
+}
+
+// send mails the welcome message in the background so account creation is never
+// delayed or failed by the mailer. Accounts without a real email address are skipped.
+func (w *welcomeEmail) send(user *models.User) {
+	if !w.enabled || user.Email == "" || user.EmailMissing {
+		return
+	}
+
+	var body bytes.Buffer
+	err := w.template.Execute(&body, welcomeData{
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Email:     user.Email,
+		LoginURL:  w.loginURL,
+	})
+	if err != nil {
+		log.Printf("Failed to render welcome email for %s: %v", user.Email, err)
+		return
+	}
+
+	go func(email string) {
+		if err := w.mailer.Send(email, w.subject, body.String()); err != nil {
+			log.Printf("Failed to send welcome email to %s: %v", email, err)
+		}
+	}(user.Email)
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sso-web-app/internal/models"
+)
+
+// welcomeSubject is the default subject of the welcome email
+const welcomeSubject = "Welcome to SSO Web App"
+
+// welcomeSent waits briefly for mail and reports whether a welcome email went to to.
+// Other mail, such as the verification email, is ignored.
+func welcomeSent(mailer *mailRecorder, to string) bool {
+	deadline := time.After(300 * time.Millisecond)
+	for {
+		select {
+		case mail := <-mailer.sent:
+			if mail.subject == welcomeSubject && mail.to == to {
+				return true
+			}
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// failingMailer is a Mailer whose every send fails
+type failingMailer struct{}
+
+func (failingMailer) Send(to, subject, body string) error {
+	return errors.New("mail server unavailable")
+}
+
+func TestWelcomeEmail(t *testing.T) {
+	register := func(authService *AuthService, email string) (*models.User, error) {
+		return authService.Register(models.RegisterRequest{Email: email, Password: "An0ther-Passw0rd", FirstName: "New", LastName: "User"})
+	}
+
+	t.Run("registration", func(t *testing.T) {
+		cfg := setupTestDB(t, map[string]string{"SEND_WELCOME_EMAIL": "true"})
+		mailer := newMailRecorder()
+		authService := newTestAuthServiceWithMailer(t, cfg, mailer)
+
+		if _, err := register(authService, "new@example.com"); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		if !welcomeSent(mailer, "new@example.com") {
+			t.Error("no welcome email was sent")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg := setupTestDB(t, map[string]string{"SEND_WELCOME_EMAIL": "false"})
+		mailer := newMailRecorder()
+		authService := newTestAuthServiceWithMailer(t, cfg, mailer)
+
+		if _, err := register(authService, "new@example.com"); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		if welcomeSent(mailer, "new@example.com") {
+			t.Error("a welcome email was sent while disabled")
+		}
+	})
+
+	t.Run("OAuth sign-up", func(t *testing.T) {
+		cfg := setupTestDB(t, map[string]string{"SEND_WELCOME_EMAIL": "true", "OAUTH_MISSING_EMAIL": "prompt"})
+		mailer := newMailRecorder()
+		oauthService := NewOAuthService(cfg, newTestAuthServiceWithMailer(t, cfg, mailer))
+
+		if _, err := oauthService.findOrCreateGitHubUser(&GitHubUser{ID: 1, Login: "octo", Email: "octo@example.com"}, nil); err != nil {
+			t.Fatalf("findOrCreateGitHubUser: %v", err)
+		}
+		if !welcomeSent(mailer, "octo@example.com") {
+			t.Error("no welcome email was sent on the first OAuth sign-in")
+		}
+
+		// Later sign-ins are not welcomed again
+		if _, err := oauthService.findOrCreateGitHubUser(&GitHubUser{ID: 1, Login: "octo", Email: "octo@example.com"}, nil); err != nil {
+			t.Fatalf("findOrCreateGitHubUser again: %v", err)
+		}
+		if welcomeSent(mailer, "octo@example.com") {
+			t.Error("a returning user was welcomed again")
+		}
+	})
+
+	t.Run("OAuth sign-up without an email", func(t *testing.T) {
+		cfg := setupTestDB(t, map[string]string{"SEND_WELCOME_EMAIL": "true", "OAUTH_MISSING_EMAIL": "prompt"})
+		mailer := newMailRecorder()
+		oauthService := NewOAuthService(cfg, newTestAuthServiceWithMailer(t, cfg, mailer))
+
+		user, err := oauthService.findOrCreateGitHubUser(&GitHubUser{ID: 2, Login: "hidden"}, nil)
+		if err != nil {
+			t.Fatalf("findOrCreateGitHubUser: %v", err)
+		}
+		// Not even to the noreply placeholder
+		if welcomeSent(mailer, user.Email) {
+			t.Error("a welcome email was sent to an account without an email")
+		}
+	})
+
+	t.Run("mailer failure", func(t *testing.T) {
+		cfg := setupTestDB(t, map[string]string{"SEND_WELCOME_EMAIL": "true"})
+		authService := newTestAuthServiceWithMailer(t, cfg, failingMailer{})
+
+		user, err := register(authService, "new@example.com")
+		if err != nil || user == nil || !strings.EqualFold(user.Email, "new@example.com") {
+			t.Errorf("Register = %v, %v; want the account created despite the mailer", user, err)
+		}
+	})
+}
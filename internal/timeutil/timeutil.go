@@ -0,0 +1,48 @@
+package timeutil
+
+import (
+	"time"
+)
+
+// location is the application timezone used for day boundaries and rendering
+var location = time.UTC
+
+// SetLocation configures the application timezone from an IANA name.
+// An empty name selects UTC.
+func SetLocation(name string) error {
+	if name == "" {
+		location = time.UTC
+		return nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+	location = loc
+	return nil
+}
+
+// Location returns the application timezone, UTC unless configured otherwise
+func Location() *time.Location {
+	return location
+}
+
+// Now returns the current time in UTC. All timestamps are stored in UTC.
+func Now() time.Time {
+	return time.Now().UTC()
+}
+
+// StartOfDay returns midnight of the day containing t in the given location, expressed in UTC
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc).UTC()
+}
+
+// FormatLocal renders a timestamp in the application timezone
+func FormatLocal(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(Location()).Format(layout)
+}
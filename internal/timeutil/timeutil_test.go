@@ -0,0 +1,43 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartOfDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		at       string
+		want     string
+	}{
+		{"utc", "UTC", "2026-03-10T15:04:05Z", "2026-03-10T00:00:00Z"},
+		{"utc at midnight", "UTC", "2026-03-10T00:00:00Z", "2026-03-10T00:00:00Z"},
+		{"behind utc, same day", "America/New_York", "2026-03-10T15:00:00Z", "2026-03-10T04:00:00Z"},
+		{"behind utc, still yesterday locally", "America/New_York", "2026-03-10T02:00:00Z", "2026-03-09T04:00:00Z"},
+		{"ahead of utc, already tomorrow locally", "Asia/Kolkata", "2026-03-10T20:00:00Z", "2026-03-10T18:30:00Z"},
+		{"just before local midnight", "Asia/Kolkata", "2026-03-10T18:29:59Z", "2026-03-09T18:30:00Z"},
+		{"day after a dst change", "America/New_York", "2026-03-09T12:00:00Z", "2026-03-09T04:00:00Z"},
+		{"day of a dst change", "America/New_York", "2026-03-08T12:00:00Z", "2026-03-08T05:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.location)
+			if err != nil {
+				t.Skipf("timezone data unavailable: %v", err)
+			}
+			at, _ := time.Parse(time.RFC3339, tt.at)
+			want, _ := time.Parse(time.RFC3339, tt.want)
+
+			got := StartOfDay(at, loc)
+			if !got.Equal(want) {
+				t.Errorf("StartOfDay(%s) = %s, want %s", tt.at, got, want)
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("StartOfDay returned %s, want UTC", got.Location())
+			}
+		})
+	}
+}